@@ -15,6 +15,9 @@ func main() {
 
 	config := server.Config{
 		//Innit:  []server.InnitFuncs{initializers.InitDBonDev},
+		Innit:       []server.InnitFuncs{server.MigrateJSONGroups},
+		AutoMigrate: true,
+		Dev:         true,
 	}
 	server := server.NewServer(&config)
 	err := server.ListenAndServe()
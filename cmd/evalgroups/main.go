@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/initializers"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/user"
+)
+
+// evalgroups replays the /select history of every user with a grouping config
+// and reports how many groups were created and how pure they are.
+func main() {
+	configPath := flag.String("config", "", "JSON file with the grouping config, missing fields use the defaults")
+	label := flag.String("label", "cuisine", "what a pure group has in common: cuisine or diet")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+
+	labelFunc, ok := labels[*label]
+	if !ok {
+		log.Fatalf("unknown label %s", *label)
+	}
+
+	cfg := user.DefaultGroupingConfig()
+	if *configPath != "" {
+		data, err := os.ReadFile(*configPath)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		err = json.Unmarshal(data, &cfg)
+		if err != nil {
+			log.Fatalln("failed to read grouping config:", err)
+		}
+	}
+	err := cfg.Validate()
+	if err != nil {
+		log.Fatalln("invalid grouping config:", err)
+	}
+
+	initializers.LoadEnvVariables()
+	db := database.ConnectToDB(&sqlx.Conn{}, database.ConnectionStringFromEnv())
+
+	history, err := loadHistory(db)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	report := user.EvaluateGrouping(cfg, history, labelFunc)
+	if *asJSON {
+		json.NewEncoder(os.Stdout).Encode(report)
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tSELECTS\tGROUPS\tPURITY")
+	for _, u := range report.Users {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.3f\n", u.UserID, u.Selects, u.Groups, u.Purity)
+	}
+	fmt.Fprintf(w, "total\t%d\t%d\t%.3f\n", report.Selects, report.Groups, report.Purity)
+	w.Flush()
+	fmt.Printf("%d users, %.2f groups per user\n", len(report.Users), report.AvgGroups)
+}
+
+var labels = map[string]func(*recipe.RecipeSchema) string{
+	"cuisine": func(r *recipe.RecipeSchema) string {
+		return r.Cuisine
+	},
+	"diet": func(r *recipe.RecipeSchema) string {
+		if len(r.Diet) == 0 {
+			return ""
+		}
+		return r.Diet[0].Name
+	},
+}
+
+func loadHistory(db *sqlx.DB) (map[string][]*recipe.RecipeSchema, error) {
+	var selects []struct {
+		UserID   string `db:"user_id"`
+		RecipeID string `db:"recipe_id"`
+	}
+	err := db.Select(&selects, `SELECT user_id, recipe_id FROM user_select_log ORDER BY user_id, created_at`)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := recipe.NewRecipeRepo(db)
+	recipes := make(map[string]*recipe.RecipeSchema)
+	history := make(map[string][]*recipe.RecipeSchema)
+	for _, s := range selects {
+		r, ok := recipes[s.RecipeID]
+		if !ok {
+			var apiErr *error_handler.APIError
+			r, apiErr = repo.GetRecipeByID(s.RecipeID)
+			if apiErr != nil {
+				return nil, apiErr.Errors[0]
+			}
+			recipes[s.RecipeID] = r
+		}
+		history[s.UserID] = append(history[s.UserID], r)
+	}
+	return history, nil
+}
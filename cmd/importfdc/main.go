@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/fdc"
+	"github.com/madswillem/recipeApp/internal/initializers"
+	"github.com/madswillem/recipeApp/internal/recipe"
+)
+
+// importfdc fills the nutritional values of ingredients with an fdic_id from a locally downloaded
+// USDA FoodData Central file. It doesn't need network access, only the file and the database.
+func main() {
+	path := flag.String("file", "", "FoodData Central JSON file or directory of the CSV download")
+	dryRun := flag.Bool("dry-run", false, "only report what would be imported")
+	overwrite := flag.Bool("overwrite", false, "replace values that were entered by hand or come from another source")
+	asJSON := flag.Bool("json", false, "print the report as JSON")
+	flag.Parse()
+	if *path == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	initializers.LoadEnvVariables()
+	db := database.ConnectToDB(&sqlx.Conn{}, database.ConnectionStringFromEnv())
+
+	targets, apiErr := fdc.LoadTargets(db)
+	if apiErr != nil {
+		log.Fatalln(apiErr.Message)
+	}
+	foods, err := fdc.Load(*path, fdc.Wanted(targets))
+	if err != nil {
+		log.Fatalln("failed to read", *path+":", err)
+	}
+	report := fdc.Plan(foods, targets, fdc.Options{Overwrite: *overwrite, Now: time.Now().UTC()})
+
+	var refreshed []string
+	if !*dryRun {
+		recipeIDs, apiErr := fdc.Apply(report, db)
+		if apiErr != nil {
+			log.Fatalln(apiErr.Message)
+		}
+		repo := recipe.NewRecipeRepo(db)
+		for _, id := range recipeIDs {
+			apiErr = repo.RefreshNutrition(id)
+			if apiErr != nil {
+				log.Println("failed to recalculate the nutrition of recipe", id+":", apiErr.Message)
+				continue
+			}
+			refreshed = append(refreshed, id)
+		}
+	}
+
+	if *asJSON {
+		json.NewEncoder(os.Stdout).Encode(struct {
+			*fdc.Report
+			RefreshedRecipes []string `json:"refreshed_recipes"`
+		}{report, refreshed})
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "INGREDIENT\tFDC ID\tSTATUS\tDETAILS")
+	for _, e := range report.Imported {
+		status := "imported"
+		if e.Replaced {
+			status = "replaced"
+		}
+		details := e.Description
+		if e.Nutrition.Incomplete {
+			details = fmt.Sprintf("%s, missing %v", details, e.Nutrition.Missing)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", e.Ingredient, e.FdcID, status, details)
+	}
+	for _, p := range report.Conflicts {
+		fmt.Fprintf(w, "%s\t%d\tconflict\t%s\n", p.Ingredient, p.FdcID, p.Reason)
+	}
+	for _, p := range report.Unmatched {
+		fmt.Fprintf(w, "%s\t%d\tunmatched\t%s\n", p.Ingredient, p.FdcID, p.Reason)
+	}
+	w.Flush()
+
+	verb := "imported"
+	if *dryRun {
+		verb = "would import"
+	}
+	fmt.Printf("%s %d, %d conflicts, %d unmatched, %d foods in the file unused, %d recipes recalculated\n",
+		verb, len(report.Imported), len(report.Conflicts), len(report.Unmatched), report.UnusedFoods, len(refreshed))
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/madswillem/recipeApp/internal/initializers"
 	"github.com/madswillem/recipeApp/internal/server"
@@ -12,7 +13,14 @@ func init() {
 }
 
 func main() {
-	server := server.NewServer(&server.Config{})
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate(os.Args[2:])
+		return
+	}
+
+	server := server.NewServer(&server.Config{
+		Innit: []server.InnitFuncs{server.MigrateJSONGroups},
+	})
 	err := server.ListenAndServe()
 	if err != nil {
 		panic(fmt.Sprintf("cannot start server: %s", err))
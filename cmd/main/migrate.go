@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/database"
+)
+
+const migrateUsage = `usage: main migrate <command>
+
+commands:
+  up [n]         apply the next n pending migrations, all if n is omitted
+  down [n]       roll back the last n migrations, 1 if n is omitted
+  status         list the migrations and when they were applied
+  create <name>  add an empty migration to ` + database.MigrationsDir
+
+func migrate(args []string) {
+	if len(args) < 1 {
+		log.Fatalln(migrateUsage)
+	}
+
+	if args[0] == "create" {
+		if len(args) != 2 {
+			log.Fatalln(migrateUsage)
+		}
+		up, down, err := database.CreateMigration(database.MigrationsDir, args[1])
+		if err != nil {
+			log.Fatalln(err)
+		}
+		fmt.Println("created", up)
+		fmt.Println("created", down)
+		return
+	}
+
+	db := database.ConnectToDB(&sqlx.Conn{}, database.ConnectionStringFromEnv())
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		done, err := database.MigrateUp(db, steps(args, 0))
+		printMigrations("applied", done)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	case "down":
+		done, err := database.MigrateDown(db, steps(args, 1))
+		printMigrations("rolled back", done)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	case "status":
+		statuses, err := database.MigrationStatuses(db)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		w.Flush()
+	default:
+		log.Fatalln(migrateUsage)
+	}
+}
+
+func steps(args []string, def int) int {
+	if len(args) < 2 {
+		return def
+	}
+	n, err := strconv.Atoi(args[1])
+	if err != nil || n < 1 {
+		log.Fatalln("the number of migrations has to be a positive number")
+	}
+	return n
+}
+
+func printMigrations(action string, migrations []database.Migration) {
+	if len(migrations) == 0 {
+		fmt.Println("nothing to do")
+	}
+	for _, m := range migrations {
+		fmt.Printf("%s %04d_%s\n", action, m.Version, m.Name)
+	}
+}
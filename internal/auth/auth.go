@@ -3,28 +3,65 @@ package auth
 import (
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/jmoiron/sqlx"
 	"github.com/madswillem/recipeApp/internal/error_handler"
-	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/mail"
 	"github.com/madswillem/recipeApp/internal/user"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	DefaultAccessTTL  = 15 * time.Minute
+	DefaultRefreshTTL = 30 * 24 * time.Hour
+)
+
 type Auth struct {
-	jwtKey []byte
+	keys *KeySet
+	// AccessTTL is how long access tokens are valid, RefreshTTL how long a session lasts without a refresh
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// Mailer sends the verification and password reset mails, without one they fail
+	Mailer mail.Mailer
+	// RequireVerifiedEmail refuses logins until the email is verified
+	RequireVerifiedEmail bool
+	// BaseURL is where the server is reachable, the verification link points to it
+	BaseURL string
+	// ResetPasswordURL is a page setting a new password, the reset mail links it with ?token= if set
+	ResetPasswordURL string
+	VerifyEmailTTL   time.Duration
+	PasswordResetTTL time.Duration
 }
 
+// NewAuth signs tokens with the HS256 secret key
 func NewAuth(key []byte) *Auth {
+	keys, _ := NewKeySet(NewHMACKey("", key))
+	return NewAuthWithKeys(keys)
+}
+
+func NewAuthWithKeys(keys *KeySet) *Auth {
 	return &Auth{
-		jwtKey: key,
+		keys:             keys,
+		AccessTTL:        DefaultAccessTTL,
+		RefreshTTL:       DefaultRefreshTTL,
+		Mailer:           mail.NoMailer{},
+		BaseURL:          "http://localhost:8080",
+		VerifyEmailTTL:   DefaultVerifyEmailTTL,
+		PasswordResetTTL: DefaultPasswordResetTTL,
 	}
 }
 
+// JWKS returns the public keys tokens can be verified with
+func (a *Auth) JWKS() JWKS {
+	return a.keys.JWKS()
+}
+
 func (a *Auth) Signup(c *gin.Context, db *sqlx.DB) {
 	var input struct {
 		Email    string `json:"email" binding:"required,email"`
@@ -62,12 +99,17 @@ func (a *Auth) Signup(c *gin.Context, db *sqlx.DB) {
 	}
 
 	// Insert into database
-	_, err = db.Exec(`INSERT INTO public.user (email, password) VALUES ($1, $2)`, user.Email, user.Password)
+	err = db.Get(&user.ID, `INSERT INTO public.user (email, password) VALUES ($1, $2) RETURNING id`, user.Email, user.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating user"})
 		return
 	}
 
+	// The account exists either way, the link can be sent again
+	apiErr := a.sendVerification(db, user.ID, user.Email)
+	if apiErr != nil {
+		log.Default().Println("Error sending verification mail:", apiErr.Errors)
+	}
 	c.Status(http.StatusCreated)
 }
 
@@ -82,8 +124,11 @@ func (a *Auth) Login(c *gin.Context, db *sqlx.DB) {
 		return
 	}
 
-	var user user.UserModel
-	err := db.Get(&user, "SELECT id, password, email FROM public.user WHERE email = $1", input.Email)
+	var user struct {
+		user.UserModel
+		EmailVerified bool `db:"email_verified"`
+	}
+	err := db.Get(&user, "SELECT id, COALESCE(password, '') AS password, email, email_verified_at IS NOT NULL AS email_verified FROM public.user WHERE email = $1", input.Email)
 	if err != nil {
 		fmt.Println(err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
@@ -98,62 +143,137 @@ func (a *Auth) Login(c *gin.Context, db *sqlx.DB) {
 		return
 	}
 
-	// Create JWT token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"email":   user.Email,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
-	})
+	if a.RequireVerifiedEmail && !user.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Verify your email before logging in"})
+		return
+	}
+
+	session, refreshToken, apiErr := createSession(db, user.ID, c.Request.UserAgent(), c.ClientIP(), a.RefreshTTL)
+	if apiErr != nil {
+		error_handler.HandleError(c, apiErr.Code, apiErr.Message, apiErr.Errors)
+		return
+	}
+	a.respondWithTokens(c, user.ID, user.Email, session, refreshToken)
+}
 
-	tokenString, err := token.SignedString(a.jwtKey)
+// Refresh swaps the refresh token from the body or the cookie for a new access and refresh token
+func (a *Auth) Refresh(c *gin.Context, db *sqlx.DB) {
+	var input struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	// The body is optional, browsers send the cookie
+	c.ShouldBindJSON(&input)
+	if input.RefreshToken == "" {
+		input.RefreshToken, _ = c.Cookie("refresh_token")
+	}
+
+	session, refreshToken, apiErr := rotateRefreshToken(db, input.RefreshToken, c.ClientIP(), a.RefreshTTL)
+	if apiErr != nil {
+		if apiErr.Code == http.StatusUnauthorized {
+			ClearCookies(c)
+		}
+		error_handler.HandleError(c, apiErr.Code, apiErr.Message, apiErr.Errors)
+		return
+	}
+	var email string
+	err := db.Get(&email, `SELECT COALESCE(email, '') FROM public.user WHERE id = $1`, session.UserID)
+	if err != nil {
+		error_handler.HandleError(c, http.StatusInternalServerError, "Error getting user", []error{err})
+		return
+	}
+	a.respondWithTokens(c, session.UserID, email, session, refreshToken)
+}
+
+// respondWithTokens sends a new access token for the session together with its refresh token
+func (a *Auth) respondWithTokens(c *gin.Context, userID string, email string, session *Session, refreshToken string) {
+	body, err := a.setTokens(c, userID, email, session, refreshToken)
 	if err != nil {
 		fmt.Println(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating token"})
 		return
 	}
-
-	c.SetCookie("token", tokenString, 60*60*24, "/", "", false, true)
-	c.JSON(http.StatusOK, gin.H{"token": tokenString})
+	c.JSON(http.StatusOK, body)
 }
 
-func (a *Auth) Verify(db *sqlx.DB, tokenString string) (*error_handler.APIError, user.UserModel) {
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return a.jwtKey, nil
+// setTokens signs an access token for the session and sets both tokens as cookies
+func (a *Auth) setTokens(c *gin.Context, userID string, email string, session *Session, refreshToken string) (gin.H, error) {
+	now := time.Now()
+	tokenString, err := a.keys.Sign(jwt.MapClaims{
+		"user_id": userID,
+		"email":   email,
+		"sid":     session.ID,
+		"iat":     now.Unix(),
+		"exp":     now.Add(a.AccessTTL).Unix(),
 	})
+	if err != nil {
+		return nil, err
+	}
 
+	c.SetCookie("token", tokenString, int(seconds(a.AccessTTL)), "/", "", false, true)
+	c.SetCookie("refresh_token", refreshToken, int(seconds(a.RefreshTTL)), "/", "", false, true)
+	return gin.H{
+		"token":         tokenString,
+		"expires_in":    seconds(a.AccessTTL),
+		"refresh_token": refreshToken,
+		"session_id":    session.ID,
+	}, nil
+}
+
+// Verify accepts access tokens whose session is still active
+func (a *Auth) Verify(db *sqlx.DB, tokenString string) (*error_handler.APIError, user.UserModel) {
+	claims, err := a.keys.Parse(strings.TrimPrefix(tokenString, "Bearer "))
 	if err != nil {
 		return error_handler.New("Invalid token", http.StatusUnauthorized, err), user.UserModel{}
 	}
-
-	// Extract claims
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		// Create user from claims
-		user := user.UserModel{
-			ID:    claims["user_id"].(string),
-			Email: claims["email"].(string),
-		}
-		return nil, user
+	userID, _ := claims["user_id"].(string)
+	email, _ := claims["email"].(string)
+	sessionID, _ := claims["sid"].(string)
+	if userID == "" || sessionID == "" {
+		return error_handler.New("Invalid token", http.StatusUnauthorized, errors.New("token without user or session")), user.UserModel{}
 	}
 
-	return error_handler.New("Invalid token", http.StatusUnauthorized, errors.New("invalid token")), user.UserModel{}
-}
-
-func (a *Auth) Logout(c *gin.Context) {
-	c.SetCookie("token", "", -1, "/", "", false, true)
-	c.JSON(http.StatusOK, gin.H{"message": "Successfully logged out"})
+	apiErr := checkSession(db, sessionID, userID)
+	if apiErr != nil {
+		return apiErr, user.UserModel{}
+	}
+	return nil, user.UserModel{ID: userID, Email: email, SessionID: sessionID}
 }
 
-func (a *Auth) AccessControl(sub string, obj string, act string, repo recipe.RecipeRepository) (bool, *error_handler.APIError) {
-	owner, ownererr := repo.GetRecipeAuthorbyID(obj)
-	if ownererr != nil {
-		return false, ownererr
+// Logout revokes the session of the access or refresh token, an expired access token still names its session
+func (a *Auth) Logout(c *gin.Context, db *sqlx.DB) {
+	sessions := []string{}
+	tokenString := c.GetHeader("Authorization")
+	if tokenString == "" {
+		tokenString, _ = c.Cookie("token")
+	}
+	if tokenString != "" {
+		claims, err := a.keys.Parse(strings.TrimPrefix(tokenString, "Bearer "), jwt.WithoutClaimsValidation())
+		if err == nil {
+			if sid, ok := claims["sid"].(string); ok {
+				sessions = append(sessions, sid)
+			}
+		}
+	}
+	if refreshToken, _ := c.Cookie("refresh_token"); refreshToken != "" {
+		sid, err := sessionOfRefreshToken(db, refreshToken)
+		if err == nil {
+			sessions = append(sessions, sid)
+		}
 	}
-	if owner != sub {
-		return false, error_handler.New("Unauthorized", http.StatusUnauthorized, errors.New("Unauthorized"))
+
+	if len(sessions) > 0 {
+		err := revokeSessions(db, sessions)
+		if err != nil {
+			error_handler.HandleError(c, http.StatusInternalServerError, "Error revoking session", []error{err})
+			return
+		}
 	}
+	ClearCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully logged out"})
+}
 
-	return true, nil
+// ClearCookies removes the access and refresh token cookies
+func ClearCookies(c *gin.Context) {
+	c.SetCookie("token", "", -1, "/", "", false, true)
+	c.SetCookie("refresh_token", "", -1, "/", "", false, true)
 }
\ No newline at end of file
@@ -0,0 +1,378 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// minRSABits is the smallest RSA key accepted
+const minRSABits = 2048
+
+// Key signs or verifies tokens with the kid ID. Secret is set for HS256, the key pair otherwise.
+type Key struct {
+	ID        string
+	Algorithm string
+	secret    []byte
+	private   crypto.Signer
+	public    crypto.PublicKey
+}
+
+// KeySet signs tokens with one key and verifies them with all of its keys, so tokens signed with
+// a previous key stay valid after a rotation until they expire.
+type KeySet struct {
+	signing *Key
+	keys    map[string]*Key
+}
+
+// KeyConfig configures the keys, the signing key is either Secret or the PEM in PrivateKeyFile
+type KeyConfig struct {
+	// Secret is a shared HS256 secret
+	Secret string
+	// PrivateKeyFile is a PEM file with an RSA or Ed25519 private key, it signs with RS256 or EdDSA
+	PrivateKeyFile string
+	// KeyID is the kid of the signing key, it defaults to a hash of the public key
+	KeyID string
+	// VerifyKeyFiles are PEM files with keys that still verify tokens, like the one used before a rotation
+	VerifyKeyFiles []string
+}
+
+// KeyConfigFromEnv reads JWT_SECRET, JWT_KEY_FILE, JWT_KEY_ID and the comma separated JWT_VERIFY_KEY_FILES
+func KeyConfigFromEnv() KeyConfig {
+	cfg := KeyConfig{
+		Secret:         os.Getenv("JWT_SECRET"),
+		PrivateKeyFile: os.Getenv("JWT_KEY_FILE"),
+		KeyID:          os.Getenv("JWT_KEY_ID"),
+	}
+	for _, f := range strings.Split(os.Getenv("JWT_VERIFY_KEY_FILES"), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			cfg.VerifyKeyFiles = append(cfg.VerifyKeyFiles, f)
+		}
+	}
+	return cfg
+}
+
+// Empty is true if no signing key is configured
+func (cfg KeyConfig) Empty() bool {
+	return cfg.Secret == "" && cfg.PrivateKeyFile == ""
+}
+
+// LoadKeys reads the keys of the config
+func LoadKeys(cfg KeyConfig) (*KeySet, error) {
+	if cfg.Empty() {
+		return nil, errors.New("no JWT signing key configured, set JWT_SECRET or JWT_KEY_FILE")
+	}
+	if cfg.Secret != "" && cfg.PrivateKeyFile != "" {
+		return nil, errors.New("configure either JWT_SECRET or JWT_KEY_FILE, not both")
+	}
+
+	var signing *Key
+	if cfg.Secret != "" {
+		signing = NewHMACKey(cfg.KeyID, []byte(cfg.Secret))
+	} else {
+		keys, err := readKeyFile(cfg.PrivateKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) != 1 || keys[0].private == nil {
+			return nil, fmt.Errorf("%s has to contain exactly one private key", cfg.PrivateKeyFile)
+		}
+		signing = keys[0]
+		if cfg.KeyID != "" {
+			signing.ID = cfg.KeyID
+		}
+	}
+
+	ks, err := NewKeySet(signing)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range cfg.VerifyKeyFiles {
+		keys, err := readKeyFile(f)
+		if err != nil {
+			return nil, err
+		}
+		for _, k := range keys {
+			err = ks.AddVerificationKey(k)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f, err)
+			}
+		}
+	}
+	return ks, nil
+}
+
+// NewKeySet returns a key set signing with the key
+func NewKeySet(signing *Key) (*KeySet, error) {
+	if signing.secret == nil && signing.private == nil {
+		return nil, errors.New("the signing key has no private key")
+	}
+	ks := &KeySet{signing: signing, keys: map[string]*Key{}}
+	err := ks.AddVerificationKey(signing)
+	if err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// AddVerificationKey accepts tokens signed with the key, its kid has to be unique
+func (ks *KeySet) AddVerificationKey(k *Key) error {
+	if _, ok := ks.keys[k.ID]; ok {
+		return fmt.Errorf("duplicate key id %q", k.ID)
+	}
+	ks.keys[k.ID] = k
+	return nil
+}
+
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, Algorithm: AlgHS256, secret: secret}
+}
+
+// NewKey returns the key for an RSA or Ed25519 private or public key, the id defaults to a hash of the public key
+func NewKey(id string, key any) (*Key, error) {
+	k := &Key{ID: id}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		k.Algorithm, k.private, k.public = AlgRS256, key, &key.PublicKey
+	case *rsa.PublicKey:
+		k.Algorithm, k.public = AlgRS256, key
+	case ed25519.PrivateKey:
+		k.Algorithm, k.private, k.public = AlgEdDSA, key, key.Public()
+	case ed25519.PublicKey:
+		k.Algorithm, k.public = AlgEdDSA, key
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, use RSA or Ed25519", key)
+	}
+	if pub, ok := k.public.(*rsa.PublicKey); ok && pub.N.BitLen() < minRSABits {
+		return nil, fmt.Errorf("RSA keys need at least %d bits", minRSABits)
+	}
+	if k.ID == "" {
+		der, err := x509.MarshalPKIXPublicKey(k.public)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(der)
+		k.ID = base64.RawURLEncoding.EncodeToString(sum[:12])
+	}
+	return k, nil
+}
+
+// readKeyFile reads all PEM encoded keys of the file
+func readKeyFile(path string) ([]*Key, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ParseKeys(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return keys, nil
+}
+
+// ParseKeys reads PKCS #8, PKCS #1 and PKIX PEM blocks
+func ParseKeys(data []byte) ([]*Key, error) {
+	keys := []*Key{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		var key any
+		var err error
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		k, err := NewKey("", key)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, k)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no PEM encoded key found")
+	}
+	return keys, nil
+}
+
+func (k *Key) method() jwt.SigningMethod {
+	switch k.Algorithm {
+	case AlgRS256:
+		return jwt.SigningMethodRS256
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA
+	}
+	return jwt.SigningMethodHS256
+}
+
+// Sign signs the claims with the signing key and names it in the kid header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method(), claims)
+	if ks.signing.ID != "" {
+		token.Header["kid"] = ks.signing.ID
+	}
+	if ks.signing.secret != nil {
+		return token.SignedString(ks.signing.secret)
+	}
+	return token.SignedString(ks.signing.private)
+}
+
+// Parse verifies the token with the key its kid names, the algorithm has to be the one of the key
+func (ks *KeySet) Parse(tokenString string, opts ...jwt.ParserOption) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		k, ok := ks.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		if token.Method.Alg() != k.Algorithm {
+			return nil, errors.New("unexpected signing method")
+		}
+		if k.secret != nil {
+			return k.secret, nil
+		}
+		return k.public, nil
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// JWK is a public key in the JSON Web Key format
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// N and E are the modulus and exponent of RSA keys
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Curve and X are the curve and public key of Ed25519 keys, EC keys add Y
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+	Y     string `json:"y,omitempty"`
+}
+
+// PublicKey decodes RSA, P-256 and Ed25519 keys
+func (j JWK) PublicKey() (crypto.PublicKey, error) {
+	b64 := base64.RawURLEncoding.DecodeString
+	switch {
+	case j.KeyType == "RSA":
+		n, err := b64(j.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64(j.E)
+		if err != nil {
+			return nil, err
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if pub.N.BitLen() < minRSABits || pub.E < 3 {
+			return nil, fmt.Errorf("RSA keys need at least %d bits", minRSABits)
+		}
+		return pub, nil
+	case j.KeyType == "EC" && j.Curve == "P-256":
+		x, err := b64(j.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := b64(j.Y)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 key")
+		}
+		// ecdh checks the point is on the curve
+		if _, err := ecdh.P256().NewPublicKey(append(append([]byte{4}, x...), y...)); err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case j.KeyType == "OKP" && j.Curve == "Ed25519":
+		x, err := b64(j.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s %s", j.KeyType, j.Curve)
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public keys that verify tokens, shared secrets are left out
+func (ks *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range ks.keys {
+		jwk := JWK{KeyID: k.ID, Use: "sig", Algorithm: k.Algorithm}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	// The signing key first, the others by id so the document is stable
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return jwkLess(set.Keys[i], set.Keys[j], ks.signing.ID)
+	})
+	return set
+}
+
+func jwkLess(a JWK, b JWK, first string) bool {
+	if a.KeyID == first || b.KeyID == first {
+		return a.KeyID == first
+	}
+	return a.KeyID < b.KeyID
+}
@@ -0,0 +1,319 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCProviderConfig configures an OpenID Connect provider users can sign in with
+type OIDCProviderConfig struct {
+	// Name identifies the provider in ?provider= and in the linked identities
+	Name   string
+	Issuer string
+	// ClientSecret is optional for public clients, PKCE is used either way
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the callback registered with the provider, it defaults to BaseURL/login/callback
+	RedirectURL string
+	// Scopes are requested in addition to openid, they default to email and profile
+	Scopes []string
+}
+
+// OIDCProvidersFromEnv reads the comma separated OIDC_PROVIDERS, for a provider named google
+// OIDC_GOOGLE_ISSUER, OIDC_GOOGLE_CLIENT_ID, OIDC_GOOGLE_CLIENT_SECRET and the space separated
+// OIDC_GOOGLE_SCOPES
+func OIDCProvidersFromEnv() []OIDCProviderConfig {
+	configs := []OIDCProviderConfig{}
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+		configs = append(configs, OIDCProviderConfig{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			Scopes:       strings.Fields(os.Getenv(prefix + "SCOPES")),
+		})
+	}
+	return configs
+}
+
+type oidcDiscovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+// OIDCProvider talks to one provider. Its discovery document and keys are fetched on first use,
+// so the server starts while the provider is unreachable.
+type OIDCProvider struct {
+	OIDCProviderConfig
+	client *http.Client
+
+	mu          sync.Mutex
+	discovery   *oidcDiscovery
+	keys        map[string]any
+	keysFetched time.Time
+}
+
+// IDClaims are the claims of a validated ID token the login uses
+type IDClaims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// idTokenAlgorithms are the algorithms ID tokens may be signed with, never none or HMAC
+var idTokenAlgorithms = []string{"RS256", "ES256", "EdDSA"}
+
+// keyRefetchInterval limits how often tokens with unknown key ids make the keys be fetched again
+const keyRefetchInterval = time.Minute
+
+// NewOIDCProvider checks the config, client defaults to an http.Client with a timeout
+func NewOIDCProvider(cfg OIDCProviderConfig, client *http.Client) (*OIDCProvider, error) {
+	if cfg.Name == "" || cfg.Issuer == "" || cfg.ClientID == "" {
+		return nil, fmt.Errorf("OIDC provider %q needs a name, an issuer and a client id", cfg.Name)
+	}
+	if cfg.Scopes == nil {
+		cfg.Scopes = []string{"email", "profile"}
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &OIDCProvider{OIDCProviderConfig: cfg, client: client}, nil
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", u, res.Status)
+	}
+	return json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(v)
+}
+
+// discover returns the discovery document, its issuer has to be the configured one
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	d := &oidcDiscovery{}
+	err := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", d)
+	if err != nil {
+		return nil, err
+	}
+	if d.Issuer != p.Issuer {
+		return nil, fmt.Errorf("discovery document of %s is for issuer %s", p.Issuer, d.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("discovery document without authorization, token or jwks endpoint")
+	}
+	if len(d.CodeChallengeMethods) > 0 && !contains(d.CodeChallengeMethods, "S256") {
+		return nil, errors.New("provider doesn't support PKCE with S256")
+	}
+	p.discovery = d
+	return d, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// pkceChallenge is the S256 code challenge of the verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// AuthCodeURL is where the user is sent to sign in
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state string, nonce string, verifier string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(d.AuthorizationEndpoint)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(append([]string{"openid"}, p.Scopes...), " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", pkceChallenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange swaps the authorization code for the ID token
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, verifier string) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if p.ClientSecret == "" {
+		form.Set("client_id", p.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+	res, err := p.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	err = json.NewDecoder(io.LimitReader(res.Body, 1<<20)).Decode(&body)
+	if err != nil {
+		return "", fmt.Errorf("token response: %w", err)
+	}
+	if res.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token request failed: %s %s %s", res.Status, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", errors.New("token response without id_token")
+	}
+	return body.IDToken, nil
+}
+
+// key returns the public key with the kid, the keys are fetched again if it is unknown.
+// Without a kid the provider has to publish exactly one key.
+func (p *OIDCProvider) key(ctx context.Context, kid string) (any, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	find := func() (any, bool) {
+		if kid == "" && len(p.keys) == 1 {
+			for _, k := range p.keys {
+				return k, true
+			}
+		}
+		k, ok := p.keys[kid]
+		return k, ok && kid != ""
+	}
+	if k, ok := find(); ok {
+		return k, nil
+	}
+	if time.Since(p.keysFetched) < keyRefetchInterval {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	var set JWKS
+	err = p.getJSON(ctx, d.JWKSURI, &set)
+	if err != nil {
+		return nil, err
+	}
+	p.keysFetched = time.Now()
+	p.keys = map[string]any{}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		pub, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		p.keys[jwk.KeyID] = pub
+	}
+	if k, ok := find(); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown key id %q", kid)
+}
+
+// VerifyIDToken checks the signature, issuer, audience, expiry and nonce of the ID token
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, raw string, nonce string) (*IDClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.key(ctx, kid)
+	},
+		jwt.WithValidMethods(idTokenAlgorithms),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithAudience(p.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if n, _ := claims["nonce"].(string); nonce == "" || n != nonce {
+		return nil, errors.New("nonce doesn't match")
+	}
+	aud, _ := claims.GetAudience()
+	if azp, _ := claims["azp"].(string); (len(aud) > 1 || azp != "") && azp != p.ClientID {
+		return nil, errors.New("token was issued to another client")
+	}
+	sub, _ := claims.GetSubject()
+	if sub == "" {
+		return nil, errors.New("token without subject")
+	}
+
+	id := &IDClaims{Subject: sub}
+	id.Email, _ = claims["email"].(string)
+	// Some providers send the flag as a string
+	switch v := claims["email_verified"].(type) {
+	case bool:
+		id.EmailVerified = v
+	case string:
+		id.EmailVerified = v == "true"
+	}
+	return id, nil
+}
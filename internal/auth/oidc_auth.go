@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+)
+
+// DefaultOIDCLoginTTL is how long a user may take to sign in with the provider
+const DefaultOIDCLoginTTL = 10 * time.Minute
+
+// OIDCAuth signs users in with OpenID Connect providers using the authorization code flow with PKCE.
+// Once signed in the user gets a session and tokens like a password login, the embedded Auth
+// verifies and refreshes them.
+type OIDCAuth struct {
+	*Auth
+	providers map[string]*OIDCProvider
+	// PasswordLogin keeps logins and signups with email and password working next to the providers
+	PasswordLogin bool
+	// AfterLoginURL is where browsers are sent after signing in, the tokens are answered as JSON if empty
+	AfterLoginURL string
+	LoginTTL      time.Duration
+}
+
+// NewOIDCAuth signs in with the providers, their RedirectURL defaults to BaseURL/login/callback
+func NewOIDCAuth(a *Auth, providers ...*OIDCProvider) (*OIDCAuth, error) {
+	o := &OIDCAuth{Auth: a, providers: map[string]*OIDCProvider{}, PasswordLogin: true, LoginTTL: DefaultOIDCLoginTTL}
+	for _, p := range providers {
+		if _, ok := o.providers[p.Name]; ok {
+			return nil, fmt.Errorf("duplicate OIDC provider %q", p.Name)
+		}
+		if p.RedirectURL == "" {
+			p.RedirectURL = strings.TrimSuffix(a.BaseURL, "/") + "/login/callback"
+		}
+		o.providers[p.Name] = p
+	}
+	return o, nil
+}
+
+// Providers returns the names of the providers
+func (o *OIDCAuth) Providers() []string {
+	names := []string{}
+	for name := range o.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Login sends the user to the provider of ?provider=, without one it is a password login if those are allowed
+func (o *OIDCAuth) Login(c *gin.Context, db *sqlx.DB) {
+	if c.Query("provider") == "" && o.PasswordLogin && c.Request.Method == http.MethodPost {
+		o.Auth.Login(c, db)
+		return
+	}
+	o.redirect(c, db)
+}
+
+// Signup is the same as Login for providers, the account is created on the first sign in
+func (o *OIDCAuth) Signup(c *gin.Context, db *sqlx.DB) {
+	if c.Query("provider") == "" && o.PasswordLogin {
+		o.Auth.Signup(c, db)
+		return
+	}
+	o.redirect(c, db)
+}
+
+func (o *OIDCAuth) redirect(c *gin.Context, db *sqlx.DB) {
+	p, ok := o.providers[c.Query("provider")]
+	if !ok {
+		error_handler.HandleError(c, http.StatusBadRequest, "Choose one of the providers with ?provider=", []error{fmt.Errorf("unknown provider %q, known are %s", c.Query("provider"), strings.Join(o.Providers(), ", "))})
+		return
+	}
+
+	state, _, err := newRefreshToken()
+	if err != nil {
+		error_handler.HandleError(c, http.StatusInternalServerError, "Error starting login", []error{err})
+		return
+	}
+	nonce, _, err := newRefreshToken()
+	if err != nil {
+		error_handler.HandleError(c, http.StatusInternalServerError, "Error starting login", []error{err})
+		return
+	}
+	verifier, _, err := newRefreshToken()
+	if err != nil {
+		error_handler.HandleError(c, http.StatusInternalServerError, "Error starting login", []error{err})
+		return
+	}
+
+	u, err := p.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+	if err != nil {
+		error_handler.HandleError(c, http.StatusBadGateway, "The provider isn't reachable", []error{err})
+		return
+	}
+	_, err = db.Exec(`DELETE FROM oidc_login WHERE expires_at < now()`)
+	if err == nil {
+		_, err = db.Exec(`INSERT INTO oidc_login (state_hash, provider, nonce, code_verifier, expires_at)
+			VALUES ($1, $2, $3, $4, now() + $5 * interval '1 second')`, hashToken(state), p.Name, nonce, verifier, seconds(o.LoginTTL))
+	}
+	if err != nil {
+		error_handler.HandleError(c, http.StatusInternalServerError, "Error starting login", []error{err})
+		return
+	}
+	// The cookie ties the callback to the browser that started the login
+	c.SetCookie("oidc_state", state, int(seconds(o.LoginTTL)), "/", "", false, true)
+	c.Redirect(http.StatusFound, u)
+}
+
+// Callback finishes the login the provider sent the user back from
+func (o *OIDCAuth) Callback(c *gin.Context, db *sqlx.DB) {
+	if e := c.Query("error"); e != "" {
+		error_handler.HandleError(c, http.StatusUnauthorized, "Sign in was cancelled or failed", []error{fmt.Errorf("%s: %s", e, c.Query("error_description"))})
+		return
+	}
+	state := c.Query("state")
+	cookie, _ := c.Cookie("oidc_state")
+	if state == "" || cookie != state {
+		error_handler.HandleError(c, http.StatusBadRequest, "The login wasn't started in this browser, start it again", []error{errors.New("state mismatch")})
+		return
+	}
+	c.SetCookie("oidc_state", "", -1, "/", "", false, true)
+
+	var login struct {
+		Provider     string `db:"provider"`
+		Nonce        string `db:"nonce"`
+		CodeVerifier string `db:"code_verifier"`
+		Active       bool   `db:"active"`
+	}
+	err := db.Get(&login, `DELETE FROM oidc_login WHERE state_hash = $1
+		RETURNING provider, nonce, code_verifier, expires_at > now() AS active`, hashToken(state))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !login.Active) {
+		error_handler.HandleError(c, http.StatusBadRequest, "The login expired or was already finished, start it again", []error{errors.New("unknown state")})
+		return
+	}
+	if err != nil {
+		error_handler.HandleError(c, http.StatusInternalServerError, "Error finishing login", []error{err})
+		return
+	}
+	p, ok := o.providers[login.Provider]
+	if !ok {
+		error_handler.HandleError(c, http.StatusBadRequest, "The provider isn't configured anymore", []error{fmt.Errorf("unknown provider %q", login.Provider)})
+		return
+	}
+
+	idToken, err := p.Exchange(c.Request.Context(), c.Query("code"), login.CodeVerifier)
+	if err != nil {
+		error_handler.HandleError(c, http.StatusUnauthorized, "Sign in failed", []error{err})
+		return
+	}
+	claims, err := p.VerifyIDToken(c.Request.Context(), idToken, login.Nonce)
+	if err != nil {
+		error_handler.HandleError(c, http.StatusUnauthorized, "Sign in failed", []error{err})
+		return
+	}
+	userID, email, apiErr := linkIdentity(db, p.Name, claims)
+	if apiErr != nil {
+		error_handler.HandleError(c, apiErr.Code, apiErr.Message, apiErr.Errors)
+		return
+	}
+
+	session, refreshToken, apiErr := createSession(db, userID, c.Request.UserAgent(), c.ClientIP(), o.RefreshTTL)
+	if apiErr != nil {
+		error_handler.HandleError(c, apiErr.Code, apiErr.Message, apiErr.Errors)
+		return
+	}
+	if o.AfterLoginURL == "" {
+		o.respondWithTokens(c, userID, email, session, refreshToken)
+		return
+	}
+	_, err = o.setTokens(c, userID, email, session, refreshToken)
+	if err != nil {
+		error_handler.HandleError(c, http.StatusInternalServerError, "Error creating token", []error{err})
+		return
+	}
+	c.Redirect(http.StatusFound, o.AfterLoginURL)
+}
+
+// linkIdentity returns the user of the provider account. Unknown accounts are linked to the user
+// with the same email if the provider verified it, otherwise a new user is created.
+func linkIdentity(db *sqlx.DB, provider string, claims *IDClaims) (string, string, *error_handler.APIError) {
+	tx, err := db.Beginx()
+	if err != nil {
+		return "", "", error_handler.New("Error signing in: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	defer tx.Rollback()
+
+	var u struct {
+		ID       string `db:"id"`
+		Email    string `db:"email"`
+		Verified bool   `db:"verified"`
+	}
+	err = tx.Get(&u, `SELECT u.id, COALESCE(u.email, '') AS email, u.email_verified_at IS NOT NULL AS verified
+		FROM user_identity i JOIN public.user u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.subject = $2`, provider, claims.Subject)
+	if err == nil {
+		_, err = tx.Exec(`UPDATE user_identity SET email = $3 WHERE provider = $1 AND subject = $2`, provider, claims.Subject, claims.Email)
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			return "", "", error_handler.New("Error signing in: "+err.Error(), http.StatusInternalServerError, err)
+		}
+		return u.ID, u.Email, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", "", error_handler.New("Error signing in: "+err.Error(), http.StatusInternalServerError, err)
+	}
+
+	if claims.Email != "" {
+		err = tx.Get(&u, `SELECT id, email, email_verified_at IS NOT NULL AS verified FROM public.user WHERE LOWER(email) = LOWER($1)`, claims.Email)
+	} else {
+		err = sql.ErrNoRows
+	}
+	switch {
+	case err == nil && !claims.EmailVerified:
+		return "", "", error_handler.New("An account with this email exists but the provider didn't verify the email", http.StatusConflict, errors.New("unverified email of an existing account"))
+	case err == nil && !u.Verified:
+		// Whoever signed up with the email never proved it was theirs, their password and sessions go
+		_, err = tx.Exec(`UPDATE public.user SET password = NULL, email_verified_at = now() WHERE id = $1`, u.ID)
+		if err == nil {
+			_, err = tx.Exec(`UPDATE session SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`, u.ID)
+		}
+	case errors.Is(err, sql.ErrNoRows):
+		var email *string
+		if claims.Email != "" {
+			email = &claims.Email
+		}
+		u.Email = claims.Email
+		err = tx.Get(&u.ID, `INSERT INTO public.user (email, email_verified_at)
+			VALUES ($1, CASE WHEN $2::boolean THEN now() END) RETURNING id`, email, claims.EmailVerified)
+	}
+	if err == nil {
+		_, err = tx.Exec(`INSERT INTO user_identity (provider, subject, user_id, email) VALUES ($1, $2, $3, $4)`,
+			provider, claims.Subject, u.ID, claims.Email)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		return "", "", error_handler.New("Error signing in: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return u.ID, u.Email, nil
+}
@@ -0,0 +1,249 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/mail"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	purposeVerifyEmail   = "verify_email"
+	purposeResetPassword = "reset_password"
+)
+
+const (
+	DefaultVerifyEmailTTL   = 48 * time.Hour
+	DefaultPasswordResetTTL = time.Hour
+)
+
+var errInvalidToken = errors.New("invalid, expired or used token")
+
+// issueToken creates a single use token for the purpose, earlier unused ones of the user stop working
+func issueToken(db *sqlx.DB, userID string, purpose string, ttl time.Duration) (string, *error_handler.APIError) {
+	token, hash, err := newRefreshToken()
+	if err != nil {
+		return "", error_handler.New("Error creating token", http.StatusInternalServerError, err)
+	}
+	tx, err := db.Beginx()
+	if err != nil {
+		return "", error_handler.New("Error creating token: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	_, err = tx.Exec(`DELETE FROM auth_token WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`, userID, purpose)
+	if err == nil {
+		_, err = tx.Exec(`INSERT INTO auth_token (hash, user_id, purpose, expires_at)
+			VALUES ($1, $2, $3, now() + $4 * interval '1 second')`, hash, userID, purpose, seconds(ttl))
+	}
+	if err != nil {
+		tx.Rollback()
+		return "", error_handler.New("Error creating token: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return "", error_handler.New("Error creating token: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return token, nil
+}
+
+// useToken uses up the token and returns the user it was issued to
+func useToken(tx *sqlx.Tx, token string, purpose string) (string, *error_handler.APIError) {
+	var userID string
+	err := tx.Get(&userID, `UPDATE auth_token SET used_at = now()
+		WHERE hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id`, hashToken(token), purpose)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", error_handler.New("The link is invalid, expired or was already used", http.StatusBadRequest, errInvalidToken)
+	}
+	if err != nil {
+		return "", error_handler.New("Error checking token: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return userID, nil
+}
+
+// humanDuration writes whole hours and minutes out for mails
+func humanDuration(d time.Duration) string {
+	switch {
+	case d == time.Hour:
+		return "1 hour"
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%d hours", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%d minutes", d/time.Minute)
+	}
+	return d.String()
+}
+
+func (a *Auth) link(path string, token string) string {
+	return strings.TrimSuffix(a.BaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// sendVerification mails the user a link that verifies their email
+func (a *Auth) sendVerification(db *sqlx.DB, userID string, email string) *error_handler.APIError {
+	token, apiErr := issueToken(db, userID, purposeVerifyEmail, a.VerifyEmailTTL)
+	if apiErr != nil {
+		return apiErr
+	}
+	err := a.Mailer.Send(mail.Message{
+		To:      email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Open this link to verify your email:\n\n%s\n\nThe link is valid for %s. If you didn't sign up, ignore this mail.\n",
+			a.link("/email/verify", token), humanDuration(a.VerifyEmailTTL)),
+	})
+	if err != nil {
+		return error_handler.New("Error sending mail", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// VerifyEmail marks the email of the token from ?token= or the body as verified
+func (a *Auth) VerifyEmail(c *gin.Context, db *sqlx.DB) {
+	var input struct {
+		Token string `json:"token" form:"token"`
+	}
+	if c.Request.Method == http.MethodGet {
+		c.ShouldBindQuery(&input)
+	} else {
+		c.ShouldBindJSON(&input)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		error_handler.HandleError(c, http.StatusInternalServerError, "Database error", []error{err})
+		return
+	}
+	userID, apiErr := useToken(tx, input.Token, purposeVerifyEmail)
+	if apiErr != nil {
+		tx.Rollback()
+		error_handler.HandleError(c, apiErr.Code, apiErr.Message, apiErr.Errors)
+		return
+	}
+	_, err = tx.Exec(`UPDATE public.user SET email_verified_at = COALESCE(email_verified_at, now()) WHERE id = $1`, userID)
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		error_handler.HandleError(c, http.StatusInternalServerError, "Error verifying email", []error{err})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// ResendVerification mails a new verification link. The answer is the same whether the email
+// belongs to an unverified account or not, so it can't be used to find accounts.
+func (a *Auth) ResendVerification(c *gin.Context, db *sqlx.DB) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var userID string
+	err := db.Get(&userID, `SELECT id FROM public.user WHERE email = $1 AND email_verified_at IS NULL`, input.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		error_handler.HandleError(c, http.StatusInternalServerError, "Database error", []error{err})
+		return
+	}
+	if userID != "" {
+		apiErr := a.sendVerification(db, userID, input.Email)
+		if apiErr != nil {
+			log.Default().Println("Error sending verification mail:", apiErr.Errors)
+		}
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists and isn't verified a new link was sent"})
+}
+
+// RequestPasswordReset mails a link to set a new password, it answers the same for unknown emails
+func (a *Auth) RequestPasswordReset(c *gin.Context, db *sqlx.DB) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var userID string
+	err := db.Get(&userID, `SELECT id FROM public.user WHERE email = $1`, input.Email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		error_handler.HandleError(c, http.StatusInternalServerError, "Database error", []error{err})
+		return
+	}
+	if userID != "" {
+		token, apiErr := issueToken(db, userID, purposeResetPassword, a.PasswordResetTTL)
+		if apiErr != nil {
+			error_handler.HandleError(c, apiErr.Code, apiErr.Message, apiErr.Errors)
+			return
+		}
+		body := fmt.Sprintf("Someone asked to reset the password of your account. Use this code to set a new one within %s:\n\n%s\n",
+			humanDuration(a.PasswordResetTTL), token)
+		if a.ResetPasswordURL != "" {
+			body += fmt.Sprintf("\nOr open %s?token=%s\n", a.ResetPasswordURL, url.QueryEscape(token))
+		}
+		body += "\nIf it wasn't you, ignore this mail, your password stays the same.\n"
+		err = a.Mailer.Send(mail.Message{To: input.Email, Subject: "Reset your password", Body: body})
+		if err != nil {
+			log.Default().Println("Error sending password reset mail:", err)
+		}
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists a link to reset the password was sent"})
+}
+
+// ResetPassword sets the password of the token. Every session is logged out and, since the user
+// got the mail, the email counts as verified.
+func (a *Auth) ResetPassword(c *gin.Context, db *sqlx.DB) {
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=6"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error hashing password"})
+		return
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		error_handler.HandleError(c, http.StatusInternalServerError, "Database error", []error{err})
+		return
+	}
+	userID, apiErr := useToken(tx, input.Token, purposeResetPassword)
+	if apiErr != nil {
+		tx.Rollback()
+		error_handler.HandleError(c, apiErr.Code, apiErr.Message, apiErr.Errors)
+		return
+	}
+	_, err = tx.Exec(`UPDATE public.user SET password = $2, email_verified_at = COALESCE(email_verified_at, now()) WHERE id = $1`,
+		userID, string(hashedPassword))
+	if err == nil {
+		_, err = tx.Exec(`UPDATE session SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		error_handler.HandleError(c, http.StatusInternalServerError, "Error resetting password", []error{err})
+		return
+	}
+	ClearCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed, log in with the new one"})
+}
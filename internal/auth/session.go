@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+)
+
+// Session is a device the user logged in with
+type Session struct {
+	ID        string     `db:"id" json:"id"`
+	UserID    string     `db:"user_id" json:"-"`
+	CreatedAt time.Time  `db:"created_at" json:"created_at"`
+	LastSeen  time.Time  `db:"last_seen" json:"last_seen"`
+	ExpiresAt time.Time  `db:"expires_at" json:"expires_at"`
+	RevokedAt *time.Time `db:"revoked_at" json:"-"`
+	UserAgent string     `db:"user_agent" json:"user_agent"`
+	IP        string     `db:"ip" json:"ip"`
+	// Current is set for the session the request was made with
+	Current bool `db:"-" json:"current"`
+}
+
+const sessionColumns = `id, user_id, created_at, last_seen, expires_at, revoked_at, user_agent, ip`
+
+var errSessionInvalid = errors.New("session expired or revoked")
+
+// newRefreshToken returns a random token and the hash that is stored
+func newRefreshToken() (string, string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func seconds(d time.Duration) int64 {
+	return int64(d / time.Second)
+}
+
+// createSession starts a session for the device and returns its first refresh token.
+// Sessions of the user that ended a while ago are cleaned up.
+func createSession(db *sqlx.DB, userID string, userAgent string, ip string, ttl time.Duration) (*Session, string, *error_handler.APIError) {
+	token, hash, err := newRefreshToken()
+	if err != nil {
+		return nil, "", error_handler.New("Error creating refresh token", http.StatusInternalServerError, err)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, "", error_handler.New("Error creating session: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	_, err = tx.Exec(`DELETE FROM session WHERE user_id = $1 AND LEAST(expires_at, COALESCE(revoked_at, expires_at)) < now() - interval '30 days'`, userID)
+	if err != nil {
+		tx.Rollback()
+		return nil, "", error_handler.New("Error removing old sessions: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	session := &Session{}
+	err = tx.Get(session, `INSERT INTO session (user_id, expires_at, user_agent, ip)
+		VALUES ($1, now() + $2 * interval '1 second', $3, $4) RETURNING `+sessionColumns,
+		userID, seconds(ttl), userAgent, ip)
+	if err != nil {
+		tx.Rollback()
+		return nil, "", error_handler.New("Error creating session: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	_, err = tx.Exec(`INSERT INTO refresh_token (hash, session_id) VALUES ($1, $2)`, hash, session.ID)
+	if err != nil {
+		tx.Rollback()
+		return nil, "", error_handler.New("Error creating refresh token: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, "", error_handler.New("Error creating session: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return session, token, nil
+}
+
+// rotateRefreshToken uses up the refresh token and returns its session with a new one. Using a token
+// a second time revokes the session, the token was stolen or the client is broken.
+func rotateRefreshToken(db *sqlx.DB, token string, ip string, ttl time.Duration) (*Session, string, *error_handler.APIError) {
+	invalid := error_handler.New("Invalid refresh token", http.StatusUnauthorized, errors.New("invalid refresh token"))
+	if token == "" {
+		return nil, "", invalid
+	}
+	newToken, newHash, err := newRefreshToken()
+	if err != nil {
+		return nil, "", error_handler.New("Error creating refresh token", http.StatusInternalServerError, err)
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return nil, "", error_handler.New("Error refreshing session: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	var row struct {
+		SessionID string `db:"session_id"`
+		Used      bool   `db:"used"`
+		Active    bool   `db:"active"`
+	}
+	err = tx.Get(&row, `SELECT rt.session_id, rt.used_at IS NOT NULL AS used,
+			s.revoked_at IS NULL AND s.expires_at > now() AS active
+		FROM refresh_token rt
+		JOIN session s ON s.id = rt.session_id
+		WHERE rt.hash = $1
+		FOR UPDATE OF rt, s`, hashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		tx.Rollback()
+		return nil, "", invalid
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, "", error_handler.New("Error refreshing session: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	if !row.Active {
+		tx.Rollback()
+		return nil, "", error_handler.New("Session expired or revoked", http.StatusUnauthorized, errSessionInvalid)
+	}
+	if row.Used {
+		_, err = tx.Exec(`UPDATE session SET revoked_at = now() WHERE id = $1`, row.SessionID)
+		if err != nil {
+			tx.Rollback()
+			return nil, "", error_handler.New("Error revoking session: "+err.Error(), http.StatusInternalServerError, err)
+		}
+		err = tx.Commit()
+		if err != nil {
+			return nil, "", error_handler.New("Error revoking session: "+err.Error(), http.StatusInternalServerError, err)
+		}
+		return nil, "", error_handler.New("Refresh token was already used, the session is revoked", http.StatusUnauthorized, errors.New("refresh token reused"))
+	}
+
+	_, err = tx.Exec(`UPDATE refresh_token SET used_at = now() WHERE hash = $1`, hashToken(token))
+	if err == nil {
+		_, err = tx.Exec(`INSERT INTO refresh_token (hash, session_id) VALUES ($1, $2)`, newHash, row.SessionID)
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, "", error_handler.New("Error rotating refresh token: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	session := &Session{}
+	err = tx.Get(session, `UPDATE session SET last_seen = now(), expires_at = now() + $2 * interval '1 second', ip = $3
+		WHERE id = $1 RETURNING `+sessionColumns, row.SessionID, seconds(ttl), ip)
+	if err != nil {
+		tx.Rollback()
+		return nil, "", error_handler.New("Error refreshing session: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, "", error_handler.New("Error refreshing session: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return session, newToken, nil
+}
+
+// sessionOfRefreshToken returns the id of the session the refresh token belongs to, used or not
+func sessionOfRefreshToken(db *sqlx.DB, token string) (string, error) {
+	var id string
+	err := db.Get(&id, `SELECT session_id FROM refresh_token WHERE hash = $1`, hashToken(token))
+	return id, err
+}
+
+// checkSession fails unless the session of the user is active. When it was last seen is updated
+// at most once a minute.
+func checkSession(db *sqlx.DB, id string, userID string) *error_handler.APIError {
+	var stale bool
+	err := db.Get(&stale, `SELECT last_seen < now() - interval '1 minute' FROM session
+		WHERE id::text = $1 AND user_id::text = $2 AND revoked_at IS NULL AND expires_at > now()`, id, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return error_handler.New("Session expired or revoked", http.StatusUnauthorized, errSessionInvalid)
+	}
+	if err != nil {
+		return error_handler.New("Error checking session: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	if stale {
+		_, err = db.Exec(`UPDATE session SET last_seen = now() WHERE id = $1`, id)
+		if err != nil {
+			return error_handler.New("Error updating session: "+err.Error(), http.StatusInternalServerError, err)
+		}
+	}
+	return nil
+}
+
+// ListSessions returns the active sessions of the user, the last seen first. currentID marks the one of the request.
+func ListSessions(db *sqlx.DB, userID string, currentID string) ([]Session, *error_handler.APIError) {
+	sessions := []Session{}
+	err := db.Select(&sessions, `SELECT `+sessionColumns+` FROM session
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+		ORDER BY last_seen DESC, id`, userID)
+	if err != nil {
+		return nil, error_handler.New("Error getting sessions: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+	return sessions, nil
+}
+
+// RevokeSession logs the device of one of the users sessions out
+func RevokeSession(db *sqlx.DB, userID string, id string) *error_handler.APIError {
+	result, err := db.Exec(`UPDATE session SET revoked_at = now()
+		WHERE id::text = $1 AND user_id = $2 AND revoked_at IS NULL AND expires_at > now()`, id, userID)
+	if err != nil {
+		return error_handler.New("Error revoking session: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return error_handler.New("Session doesn't exist", http.StatusNotFound, errors.New("session not found"))
+	}
+	return nil
+}
+
+// RevokeAllSessions logs out every device of the user and returns how many sessions were active
+func RevokeAllSessions(db *sqlx.DB, userID string) (int64, *error_handler.APIError) {
+	result, err := db.Exec(`UPDATE session SET revoked_at = now()
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()`, userID)
+	if err != nil {
+		return 0, error_handler.New("Error revoking sessions: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	n, _ := result.RowsAffected()
+	return n, nil
+}
+
+// revokeSessions revokes the sessions with the ids, whoever they belong to
+func revokeSessions(db *sqlx.DB, ids []string) error {
+	_, err := db.Exec(`UPDATE session SET revoked_at = now() WHERE id::text = ANY($1) AND revoked_at IS NULL`, pq.Array(ids))
+	return err
+}
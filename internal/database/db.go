@@ -1,7 +1,9 @@
 package database
 
 import (
+	"fmt"
 	"log"
+	"os"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
@@ -14,3 +16,12 @@ func ConnectToDB(conf *sqlx.Conn, connection_string string) *sqlx.DB {
 	}
 	return db
 }
+
+func ConnectionStringFromEnv() string {
+	return fmt.Sprintf(
+		"user=%s password=%s database=%s sslmode=disable",
+		os.Getenv("POSTGRES_USER"),
+		os.Getenv("POSTGRES_PASSWORD"),
+		os.Getenv("POSTGRES_DB"),
+	)
+}
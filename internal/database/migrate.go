@@ -0,0 +1,326 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// MigrationsDir is where migrate create puts new migrations, relative to the repository root
+const MigrationsDir = "internal/database/migrations"
+
+// migrationLock is the key of the advisory lock held while a migration runs
+const migrationLock = 72451
+
+var migrationName = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrations returns the embedded migrations ordered by version
+func Migrations() ([]Migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		match := migrationName.FindStringSubmatch(e.Name())
+		if match == nil {
+			return nil, fmt.Errorf("invalid migration file name %s", e.Name())
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		sql, err := fs.ReadFile(migrationFiles, "migrations/"+e.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up = string(sql)
+		} else {
+			m.Down = string(sql)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// LatestVersion returns the version the embedded migrations migrate to
+func LatestVersion() (int64, error) {
+	migrations, err := Migrations()
+	if err != nil || len(migrations) == 0 {
+		return 0, err
+	}
+	return migrations[len(migrations)-1].Version, nil
+}
+
+// ensureMigrationTable creates the schema_migrations table. Databases created from the
+// old innit-db.sql dump already have the initial schema, so it is recorded as applied.
+func ensureMigrationTable(db *sqlx.DB) error {
+	var exists bool
+	err := db.Get(&exists, `SELECT to_regclass('public.schema_migrations') IS NOT NULL`)
+	if err != nil || exists {
+		return err
+	}
+
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLock)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`CREATE TABLE IF NOT EXISTS public.schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at timestamp without time zone DEFAULT (now())::timestamp without time zone
+	)`)
+	if err != nil {
+		return err
+	}
+
+	var dumped bool
+	err = tx.Get(&dumped, `SELECT to_regclass('public.recipes') IS NOT NULL`)
+	if err != nil {
+		return err
+	}
+	if dumped {
+		_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES (1, 'initial_schema') ON CONFLICT DO NOTHING`)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// SchemaVersion returns the version of the last applied migration, 0 if none was applied
+func SchemaVersion(db *sqlx.DB) (int64, error) {
+	var exists bool
+	err := db.Get(&exists, `SELECT to_regclass('public.schema_migrations') IS NOT NULL`)
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	var version int64
+	err = db.Get(&version, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`)
+	return version, err
+}
+
+// CheckSchemaVersion fails if the database isn't migrated to exactly the version of the embedded migrations
+func CheckSchemaVersion(db *sqlx.DB) error {
+	latest, err := LatestVersion()
+	if err != nil {
+		return err
+	}
+	version, err := SchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if version < latest {
+		return fmt.Errorf("database schema is at version %d but %d is required, run `migrate up`", version, latest)
+	}
+	if version > latest {
+		return fmt.Errorf("database schema is at version %d which is newer than %d known to this binary", version, latest)
+	}
+	return nil
+}
+
+// MigrationStatuses lists every embedded migration and when it was applied
+func MigrationStatuses(db *sqlx.DB) ([]MigrationStatus, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	err = ensureMigrationTable(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []struct {
+		Version   int64     `db:"version"`
+		AppliedAt time.Time `db:"applied_at"`
+	}
+	err = db.Select(&applied, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	appliedAt := make(map[int64]time.Time, len(applied))
+	for _, a := range applied {
+		appliedAt[a.Version] = a.AppliedAt
+	}
+
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		statuses[i].Migration = m
+		if t, ok := appliedAt[m.Version]; ok {
+			statuses[i].AppliedAt = &t
+		}
+	}
+	return statuses, nil
+}
+
+// MigrateUp applies up to steps pending migrations, all of them if steps is 0
+func MigrateUp(db *sqlx.DB, steps int) ([]Migration, error) {
+	statuses, err := MigrationStatuses(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, s := range statuses {
+		if s.AppliedAt != nil {
+			continue
+		}
+		if steps > 0 && len(done) >= steps {
+			break
+		}
+		err = runMigration(db, s.Migration, true)
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s failed: %w", s.Version, s.Name, err)
+		}
+		done = append(done, s.Migration)
+	}
+	return done, nil
+}
+
+// MigrateDown rolls back the last steps applied migrations
+func MigrateDown(db *sqlx.DB, steps int) ([]Migration, error) {
+	if steps < 1 {
+		return nil, errors.New("steps has to be at least 1")
+	}
+	statuses, err := MigrationStatuses(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(statuses) - 1; i >= 0 && len(done) < steps; i-- {
+		if statuses[i].AppliedAt == nil {
+			continue
+		}
+		err = runMigration(db, statuses[i].Migration, false)
+		if err != nil {
+			return done, fmt.Errorf("rollback of %d_%s failed: %w", statuses[i].Version, statuses[i].Name, err)
+		}
+		done = append(done, statuses[i].Migration)
+	}
+	return done, nil
+}
+
+// runMigration applies or rolls back one migration in a transaction. Concurrent runs wait
+// for the lock and skip the migration if it was applied in the meantime.
+func runMigration(db *sqlx.DB, m Migration, up bool) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLock)
+	if err != nil {
+		return err
+	}
+	var applied bool
+	err = tx.Get(&applied, `SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, m.Version)
+	if err != nil {
+		return err
+	}
+	if applied == up {
+		return nil
+	}
+
+	if up {
+		_, err = tx.Exec(m.Up)
+		if err == nil {
+			_, err = tx.Exec(`INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`, m.Version, m.Name)
+		}
+	} else {
+		_, err = tx.Exec(m.Down)
+		if err == nil {
+			_, err = tx.Exec(`DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CreateMigration writes an empty up and down file for the next version into dir and returns their paths
+func CreateMigration(dir string, name string) (string, string, error) {
+	if !regexp.MustCompile(`^\w+$`).MatchString(name) {
+		return "", "", errors.New("migration names may only contain letters, digits and underscores")
+	}
+	latest, err := LatestVersion()
+	if err != nil {
+		return "", "", err
+	}
+
+	// migrations that weren't embedded yet because the binary wasn't rebuilt
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", err
+	}
+	for _, e := range entries {
+		match := migrationName.FindStringSubmatch(e.Name())
+		if match == nil {
+			continue
+		}
+		version, _ := strconv.ParseInt(match[1], 10, 64)
+		latest = max(latest, version)
+	}
+
+	base := filepath.Join(dir, fmt.Sprintf("%04d_%s", latest+1, name))
+	up, down := base+".up.sql", base+".down.sql"
+	err = os.WriteFile(up, []byte("-- "+name+"\n"), 0o644)
+	if err != nil {
+		return "", "", err
+	}
+	err = os.WriteFile(down, []byte("-- revert "+name+"\n"), 0o644)
+	if err != nil {
+		return "", "", err
+	}
+	return up, down, nil
+}
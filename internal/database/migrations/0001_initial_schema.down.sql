@@ -0,0 +1,12 @@
+DROP TABLE IF EXISTS public.step;
+DROP TABLE IF EXISTS public.technique;
+DROP TABLE IF EXISTS public.rel_diet_user;
+DROP TABLE IF EXISTS public.rel_diet_recipe;
+DROP TABLE IF EXISTS public.recipe_selects_views_log;
+DROP TABLE IF EXISTS public.recipe_ingredient;
+DROP TABLE IF EXISTS public.rating;
+DROP TABLE IF EXISTS public.nutritional_value;
+DROP TABLE IF EXISTS public.recipes;
+DROP TABLE IF EXISTS public.ingredient;
+DROP TABLE IF EXISTS public.diet;
+DROP TABLE IF EXISTS public."user";
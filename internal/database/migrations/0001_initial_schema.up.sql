@@ -0,0 +1,414 @@
+-- Schema of the database dump the app was developed against before migrations existed
+
+--
+-- Name: diet; Type: TABLE; Schema: public
+--
+
+CREATE TABLE public.diet (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone,
+    name text NOT NULL,
+    description text NOT NULL
+);
+
+--
+-- Name: ingredient; Type: TABLE; Schema: public
+--
+
+CREATE TABLE public.ingredient (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone,
+    name text NOT NULL,
+    standard_unit text,
+    ndb_number bigint,
+    category text,
+    fdic_id bigint
+);
+
+--
+-- Name: nutritional_value; Type: TABLE; Schema: public
+--
+
+CREATE TABLE public.nutritional_value (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone,
+    ingredient_id uuid,
+    recipe_id uuid,
+    kcal numeric,
+    kj numeric,
+    fat numeric,
+    saturated_fat numeric,
+    carbohydrate numeric,
+    sugar numeric,
+    protein numeric,
+    salt numeric,
+    nutriscore character(1),
+    CONSTRAINT nutritional_value_check CHECK (((((ingredient_id IS NOT NULL))::integer + ((recipe_id IS NOT NULL))::integer) = 1))
+);
+
+--
+-- Name: rating; Type: TABLE; Schema: public
+--
+
+CREATE TABLE public.rating (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone,
+    ingredient_id uuid,
+    recipe_id uuid,
+    overall numeric,
+    mon numeric,
+    tue numeric,
+    wed numeric,
+    thu numeric,
+    fri numeric,
+    sat numeric,
+    sun numeric,
+    win numeric,
+    spr numeric,
+    sum numeric,
+    aut numeric,
+    thirtydegree numeric,
+    twentiedegree numeric,
+    tendegree numeric,
+    zerodegree numeric,
+    subzerodegree numeric,
+    CONSTRAINT rating_check CHECK (((((ingredient_id IS NOT NULL))::integer + ((recipe_id IS NOT NULL))::integer) = 1))
+);
+
+--
+-- Name: recipe_ingredient; Type: TABLE; Schema: public
+--
+
+CREATE TABLE public.recipe_ingredient (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone,
+    recipe_id uuid,
+    ingredient_id uuid,
+    amount bigint,
+    unit text
+);
+
+--
+-- Name: recipe_selects_views_log; Type: TABLE; Schema: public
+--
+
+CREATE TABLE public.recipe_selects_views_log (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    day timestamp without time zone DEFAULT (now())::timestamp without time zone,
+    selects bigint,
+    views bigint,
+    recipe_id uuid NOT NULL,
+    view_change bigint,
+    selects_change bigint
+);
+
+--
+-- Name: recipes; Type: TABLE; Schema: public
+--
+
+CREATE TABLE public.recipes (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone,
+    author uuid NOT NULL,
+    name text NOT NULL,
+    cuisine text,
+    yield smallint,
+    yield_unit text,
+    prep_time interval,
+    cooking_time interval,
+    version bigint DEFAULT 0,
+    selects bigint DEFAULT 0,
+    views bigint DEFAULT 0
+);
+
+--
+-- Name: rel_diet_recipe; Type: TABLE; Schema: public
+--
+
+CREATE TABLE public.rel_diet_recipe (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    recipe_id uuid,
+    diet_id uuid
+);
+
+--
+-- Name: rel_diet_user; Type: TABLE; Schema: public
+--
+
+CREATE TABLE public.rel_diet_user (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    user_id uuid NOT NULL,
+    diet_id uuid NOT NULL
+);
+
+--
+-- Name: step; Type: TABLE; Schema: public
+--
+
+CREATE TABLE public.step (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone,
+    step text,
+    recipe_id uuid NOT NULL,
+    technique_id uuid,
+    ingredient_id uuid
+);
+
+--
+-- Name: technique; Type: TABLE; Schema: public
+--
+
+CREATE TABLE public.technique (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone,
+    description text NOT NULL
+);
+
+--
+-- Name: user; Type: TABLE; Schema: public
+--
+
+CREATE TABLE public."user" (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone,
+    cookie text,
+    ip text,
+    groups jsonb
+);
+
+--
+-- Name: ingredient con_unique_name; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.ingredient
+    ADD CONSTRAINT con_unique_name UNIQUE (name);
+
+--
+-- Name: diet diet_pkey; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.diet
+    ADD CONSTRAINT diet_pkey PRIMARY KEY (id);
+
+--
+-- Name: diet diet_unique; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.diet
+    ADD CONSTRAINT diet_unique UNIQUE (name);
+
+--
+-- Name: ingredient ingredient_pkey; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.ingredient
+    ADD CONSTRAINT ingredient_pkey PRIMARY KEY (id);
+
+--
+-- Name: nutritional_value nutritional_value_pkey; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.nutritional_value
+    ADD CONSTRAINT nutritional_value_pkey PRIMARY KEY (id);
+
+--
+-- Name: nutritional_value nutritional_value_unique_fk_ingredient; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.nutritional_value
+    ADD CONSTRAINT nutritional_value_unique_fk_ingredient UNIQUE (ingredient_id);
+
+--
+-- Name: nutritional_value nutritional_value_unique_fk_recipe; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.nutritional_value
+    ADD CONSTRAINT nutritional_value_unique_fk_recipe UNIQUE (recipe_id);
+
+--
+-- Name: rating rating_pkey; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.rating
+    ADD CONSTRAINT rating_pkey PRIMARY KEY (id);
+
+--
+-- Name: rating rating_unique_fk_ingredient; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.rating
+    ADD CONSTRAINT rating_unique_fk_ingredient UNIQUE (ingredient_id);
+
+--
+-- Name: rating rating_unique_fk_recipe; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.rating
+    ADD CONSTRAINT rating_unique_fk_recipe UNIQUE (recipe_id);
+
+--
+-- Name: recipe_ingredient recipe_ingredient_pkey; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.recipe_ingredient
+    ADD CONSTRAINT recipe_ingredient_pkey PRIMARY KEY (id);
+
+--
+-- Name: recipe_selects_views_log recipe_selected_view_log_pkey; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.recipe_selects_views_log
+    ADD CONSTRAINT recipe_selected_view_log_pkey PRIMARY KEY (id);
+
+--
+-- Name: recipes recipes_pkey; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.recipes
+    ADD CONSTRAINT recipes_pkey PRIMARY KEY (id);
+
+--
+-- Name: rel_diet_recipe rel_diet_recipe_pkey; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.rel_diet_recipe
+    ADD CONSTRAINT rel_diet_recipe_pkey PRIMARY KEY (id);
+
+--
+-- Name: rel_diet_user rel_diet_user_pkey; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.rel_diet_user
+    ADD CONSTRAINT rel_diet_user_pkey PRIMARY KEY (id);
+
+--
+-- Name: step step_pkey; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.step
+    ADD CONSTRAINT step_pkey PRIMARY KEY (id);
+
+--
+-- Name: technique technique_pkey; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.technique
+    ADD CONSTRAINT technique_pkey PRIMARY KEY (id);
+
+--
+-- Name: user user_pkey; Type: CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public."user"
+    ADD CONSTRAINT user_pkey PRIMARY KEY (id);
+
+--
+-- Name: fki_fk_recipe_user; Type: INDEX; Schema: public
+--
+
+CREATE INDEX fki_fk_recipe_user ON public.recipes USING btree (author);
+
+--
+-- Name: rel_diet_recipe fk_diet_rel_recipe; Type: FK CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.rel_diet_recipe
+    ADD CONSTRAINT fk_diet_rel_recipe FOREIGN KEY (diet_id) REFERENCES public.diet(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+--
+-- Name: rel_diet_user fk_diet_rel_user; Type: FK CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.rel_diet_user
+    ADD CONSTRAINT fk_diet_rel_user FOREIGN KEY (diet_id) REFERENCES public.diet(id);
+
+--
+-- Name: recipe_selects_views_log fk_log_recipe; Type: FK CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.recipe_selects_views_log
+    ADD CONSTRAINT fk_log_recipe FOREIGN KEY (recipe_id) REFERENCES public.recipes(id);
+
+--
+-- Name: nutritional_value fk_nutritional_value_ingredient; Type: FK CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.nutritional_value
+    ADD CONSTRAINT fk_nutritional_value_ingredient FOREIGN KEY (ingredient_id) REFERENCES public.ingredient(id) ON UPDATE CASCADE ON DELETE CASCADE NOT VALID;
+
+--
+-- Name: nutritional_value fk_nutritional_value_recipe; Type: FK CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.nutritional_value
+    ADD CONSTRAINT fk_nutritional_value_recipe FOREIGN KEY (recipe_id) REFERENCES public.recipes(id) ON UPDATE CASCADE ON DELETE CASCADE NOT VALID;
+
+--
+-- Name: rating fk_rating_ingredient; Type: FK CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.rating
+    ADD CONSTRAINT fk_rating_ingredient FOREIGN KEY (ingredient_id) REFERENCES public.ingredient(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+--
+-- Name: rating fk_rating_recipe; Type: FK CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.rating
+    ADD CONSTRAINT fk_rating_recipe FOREIGN KEY (recipe_id) REFERENCES public.recipes(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+--
+-- Name: recipe_ingredient fk_recipe_ingredient_ingredient; Type: FK CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.recipe_ingredient
+    ADD CONSTRAINT fk_recipe_ingredient_ingredient FOREIGN KEY (ingredient_id) REFERENCES public.ingredient(id) MATCH FULL ON UPDATE CASCADE;
+
+--
+-- Name: recipe_ingredient fk_recipe_ingredient_recipe; Type: FK CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.recipe_ingredient
+    ADD CONSTRAINT fk_recipe_ingredient_recipe FOREIGN KEY (recipe_id) REFERENCES public.recipes(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+--
+-- Name: rel_diet_recipe fk_recipe_rel_diet; Type: FK CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.rel_diet_recipe
+    ADD CONSTRAINT fk_recipe_rel_diet FOREIGN KEY (recipe_id) REFERENCES public.recipes(id) ON UPDATE CASCADE ON DELETE CASCADE;
+
+--
+-- Name: recipes fk_recipe_user; Type: FK CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.recipes
+    ADD CONSTRAINT fk_recipe_user FOREIGN KEY (author) REFERENCES public."user"(id) ON UPDATE CASCADE ON DELETE CASCADE NOT VALID;
+
+--
+-- Name: step fk_step_recipe; Type: FK CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.step
+    ADD CONSTRAINT fk_step_recipe FOREIGN KEY (recipe_id) REFERENCES public.recipes(id) ON UPDATE CASCADE ON DELETE CASCADE NOT VALID;
+
+--
+-- Name: step fk_step_recipe_ingredient; Type: FK CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.step
+    ADD CONSTRAINT fk_step_recipe_ingredient FOREIGN KEY (ingredient_id) REFERENCES public.recipe_ingredient(id) ON DELETE SET NULL NOT VALID;
+
+--
+-- Name: step fk_step_technique; Type: FK CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.step
+    ADD CONSTRAINT fk_step_technique FOREIGN KEY (technique_id) REFERENCES public.technique(id) ON DELETE SET NULL NOT VALID;
+
+--
+-- Name: rel_diet_user fk_user_rel_diet; Type: FK CONSTRAINT; Schema: public
+--
+
+ALTER TABLE ONLY public.rel_diet_user
+    ADD CONSTRAINT fk_user_rel_diet FOREIGN KEY (user_id) REFERENCES public."user"(id) NOT VALID;
@@ -0,0 +1,10 @@
+-- IF NOT EXISTS because the table was added to the old dump before migrations existed
+CREATE TABLE IF NOT EXISTS public.user_select_log (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone,
+    user_id uuid NOT NULL,
+    recipe_id uuid NOT NULL,
+    CONSTRAINT user_select_log_pkey PRIMARY KEY (id),
+    CONSTRAINT fk_user_select_log_recipe FOREIGN KEY (recipe_id) REFERENCES public.recipes(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    CONSTRAINT fk_user_select_log_user FOREIGN KEY (user_id) REFERENCES public."user"(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
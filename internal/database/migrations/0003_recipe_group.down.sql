@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS public.recipe_group_recipe;
+DROP TABLE IF EXISTS public.recipe_group;
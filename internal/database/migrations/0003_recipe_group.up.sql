@@ -0,0 +1,32 @@
+-- IF NOT EXISTS because the tables were added to the old dump before migrations existed
+CREATE TABLE IF NOT EXISTS public.recipe_group (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone,
+    user_id uuid NOT NULL,
+    name text DEFAULT ''::text NOT NULL,
+    version bigint DEFAULT 0 NOT NULL,
+    ingredient_dict jsonb,
+    ingredient_vec double precision[],
+    preperation_dict jsonb,
+    preperation_vec double precision[],
+    cuisine_dict jsonb,
+    cuisine_vec double precision[],
+    diet_dict jsonb,
+    diet_vec double precision[],
+    techniques_dict jsonb,
+    techniques_vec double precision[],
+    prep_time bigint DEFAULT 0 NOT NULL,
+    cooking_time bigint DEFAULT 0 NOT NULL,
+    CONSTRAINT recipe_group_pkey PRIMARY KEY (id),
+    CONSTRAINT fk_recipe_group_user FOREIGN KEY (user_id) REFERENCES public."user"(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS public.recipe_group_recipe (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    group_id uuid NOT NULL,
+    recipe_id uuid NOT NULL,
+    "position" integer DEFAULT 0 NOT NULL,
+    CONSTRAINT recipe_group_recipe_pkey PRIMARY KEY (id),
+    CONSTRAINT fk_recipe_group_recipe_group FOREIGN KEY (group_id) REFERENCES public.recipe_group(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    CONSTRAINT fk_recipe_group_recipe_recipe FOREIGN KEY (recipe_id) REFERENCES public.recipes(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
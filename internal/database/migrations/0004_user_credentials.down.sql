@@ -0,0 +1,3 @@
+ALTER TABLE public."user"
+    DROP COLUMN IF EXISTS email,
+    DROP COLUMN IF EXISTS password;
@@ -0,0 +1,7 @@
+-- auth.Signup and auth.Login read and write these. IF NOT EXISTS because they were
+-- added by hand to databases that signups worked with.
+ALTER TABLE public."user"
+    ADD COLUMN IF NOT EXISTS email text,
+    ADD COLUMN IF NOT EXISTS password text;
+
+CREATE UNIQUE INDEX IF NOT EXISTS user_email_unique ON public."user" USING btree (email);
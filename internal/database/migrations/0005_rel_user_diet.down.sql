@@ -0,0 +1,9 @@
+ALTER TABLE public.rel_user_diet
+    DROP CONSTRAINT rel_user_diet_unique,
+    DROP CONSTRAINT fk_user_rel_diet,
+    DROP CONSTRAINT fk_diet_rel_user,
+    ADD CONSTRAINT fk_user_rel_diet FOREIGN KEY (user_id) REFERENCES public."user"(id) NOT VALID,
+    ADD CONSTRAINT fk_diet_rel_user FOREIGN KEY (diet_id) REFERENCES public.diet(id);
+
+ALTER TABLE public.rel_user_diet RENAME CONSTRAINT rel_user_diet_pkey TO rel_diet_user_pkey;
+ALTER TABLE public.rel_user_diet RENAME TO rel_diet_user;
@@ -0,0 +1,10 @@
+-- Server.Filter reads the diets of a user from rel_user_diet
+ALTER TABLE public.rel_diet_user RENAME TO rel_user_diet;
+ALTER TABLE public.rel_user_diet RENAME CONSTRAINT rel_diet_user_pkey TO rel_user_diet_pkey;
+
+ALTER TABLE public.rel_user_diet
+    DROP CONSTRAINT fk_user_rel_diet,
+    DROP CONSTRAINT fk_diet_rel_user,
+    ADD CONSTRAINT fk_user_rel_diet FOREIGN KEY (user_id) REFERENCES public."user"(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    ADD CONSTRAINT fk_diet_rel_user FOREIGN KEY (diet_id) REFERENCES public.diet(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    ADD CONSTRAINT rel_user_diet_unique UNIQUE (user_id, diet_id);
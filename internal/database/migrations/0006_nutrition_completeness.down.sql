@@ -0,0 +1,3 @@
+ALTER TABLE public.nutritional_value
+    DROP COLUMN incomplete,
+    DROP COLUMN missing;
@@ -0,0 +1,4 @@
+-- Recipes get a calculated nutritional_value row, these mark the ones missing ingredient data
+ALTER TABLE public.nutritional_value
+    ADD COLUMN incomplete boolean DEFAULT false NOT NULL,
+    ADD COLUMN missing text[];
@@ -0,0 +1,3 @@
+ALTER TABLE public.nutritional_value
+    DROP COLUMN fiber,
+    DROP COLUMN nutriscore_points;
@@ -0,0 +1,3 @@
+ALTER TABLE public.nutritional_value
+    ADD COLUMN fiber numeric,
+    ADD COLUMN nutriscore_points integer;
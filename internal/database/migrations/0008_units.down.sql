@@ -0,0 +1,9 @@
+-- The old schema spelled piece as pice
+UPDATE public.recipe_ingredient SET unit = 'pice' WHERE unit = 'piece';
+UPDATE public.ingredient SET standard_unit = 'pice' WHERE standard_unit = 'piece';
+
+DROP TABLE public.ingredient_piece_weight;
+
+ALTER TABLE public.ingredient DROP COLUMN density;
+
+ALTER TABLE public.recipe_ingredient ALTER COLUMN amount TYPE bigint USING round(amount)::bigint;
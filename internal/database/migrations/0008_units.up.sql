@@ -0,0 +1,16 @@
+ALTER TABLE public.recipe_ingredient ALTER COLUMN amount TYPE numeric USING amount::numeric;
+
+ALTER TABLE public.ingredient ADD COLUMN density numeric CHECK (density > 0);
+
+CREATE TABLE public.ingredient_piece_weight (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    ingredient_id uuid NOT NULL,
+    unit text NOT NULL,
+    grams numeric NOT NULL CHECK (grams > 0),
+    CONSTRAINT ingredient_piece_weight_pkey PRIMARY KEY (id),
+    CONSTRAINT ingredient_piece_weight_unique UNIQUE (ingredient_id, unit),
+    CONSTRAINT fk_ingredient_piece_weight_ingredient FOREIGN KEY (ingredient_id) REFERENCES public.ingredient(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+UPDATE public.ingredient SET standard_unit = 'piece' WHERE standard_unit = 'pice';
+UPDATE public.recipe_ingredient SET unit = 'piece' WHERE unit = 'pice';
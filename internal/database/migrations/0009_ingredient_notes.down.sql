@@ -0,0 +1,4 @@
+ALTER TABLE public.recipe_ingredient
+    DROP CONSTRAINT recipe_ingredient_amount_range,
+    DROP COLUMN amount_max,
+    DROP COLUMN note;
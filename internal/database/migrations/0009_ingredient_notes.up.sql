@@ -0,0 +1,4 @@
+ALTER TABLE public.recipe_ingredient
+    ADD COLUMN amount_max numeric,
+    ADD COLUMN note text DEFAULT ''::text NOT NULL,
+    ADD CONSTRAINT recipe_ingredient_amount_range CHECK (amount_max IS NULL OR amount_max >= amount);
@@ -0,0 +1,5 @@
+DROP INDEX public.ingredient_name_trgm;
+
+DROP TABLE public.ingredient_alias;
+
+DROP EXTENSION IF EXISTS pg_trgm;
@@ -0,0 +1,15 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE TABLE public.ingredient_alias (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    created_at timestamp without time zone DEFAULT now() NOT NULL,
+    ingredient_id uuid NOT NULL,
+    alias text NOT NULL CHECK (alias <> ''),
+    CONSTRAINT ingredient_alias_pkey PRIMARY KEY (id),
+    CONSTRAINT fk_ingredient_alias_ingredient FOREIGN KEY (ingredient_id) REFERENCES public.ingredient(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX ingredient_alias_unique_alias ON public.ingredient_alias (LOWER(alias));
+
+CREATE INDEX ingredient_name_trgm ON public.ingredient USING gin (LOWER(name) gin_trgm_ops);
+CREATE INDEX ingredient_alias_trgm ON public.ingredient_alias USING gin (LOWER(alias) gin_trgm_ops);
@@ -0,0 +1,2 @@
+ALTER TABLE public."user"
+    ADD COLUMN role text DEFAULT 'user' NOT NULL CHECK (role IN ('user', 'admin'));
@@ -0,0 +1,4 @@
+ALTER TABLE public.nutritional_value
+    DROP COLUMN source,
+    DROP COLUMN source_id,
+    DROP COLUMN imported_at;
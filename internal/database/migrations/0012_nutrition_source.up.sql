@@ -0,0 +1,5 @@
+-- Where the nutritional values of an ingredient come from, NULL for ones entered by hand and calculated ones
+ALTER TABLE public.nutritional_value
+    ADD COLUMN source text,
+    ADD COLUMN source_id bigint,
+    ADD COLUMN imported_at timestamp without time zone;
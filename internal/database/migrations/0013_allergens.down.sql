@@ -0,0 +1,6 @@
+DROP VIEW IF EXISTS public.recipe_allergen;
+DROP VIEW IF EXISTS public.ingredient_allergens;
+DROP TABLE IF EXISTS public.user_allergy;
+DROP TABLE IF EXISTS public.category_allergen;
+DROP TABLE IF EXISTS public.ingredient_allergen;
+DROP TABLE IF EXISTS public.allergen;
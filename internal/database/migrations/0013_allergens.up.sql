@@ -0,0 +1,74 @@
+-- The 14 allergens that have to be declared in the EU (Regulation (EU) No 1169/2011, Annex II)
+CREATE TABLE public.allergen (
+    id text NOT NULL,
+    name text NOT NULL,
+    description text DEFAULT '' NOT NULL,
+    CONSTRAINT allergen_pkey PRIMARY KEY (id)
+);
+
+INSERT INTO public.allergen (id, name, description) VALUES
+    ('gluten', 'Gluten', 'Cereals containing gluten like wheat, rye, barley and oats'),
+    ('crustaceans', 'Crustaceans', 'Prawns, crabs, lobster and crayfish'),
+    ('eggs', 'Eggs', ''),
+    ('fish', 'Fish', ''),
+    ('peanuts', 'Peanuts', ''),
+    ('soybeans', 'Soybeans', ''),
+    ('milk', 'Milk', 'Milk including lactose'),
+    ('nuts', 'Nuts', 'Tree nuts like almonds, hazelnuts, walnuts, cashews, pecans, pistachios and macadamia nuts'),
+    ('celery', 'Celery', 'Including celeriac'),
+    ('mustard', 'Mustard', ''),
+    ('sesame', 'Sesame', ''),
+    ('sulphites', 'Sulphites', 'Sulphur dioxide and sulphites above 10 mg per kg'),
+    ('lupin', 'Lupin', ''),
+    ('molluscs', 'Molluscs', 'Mussels, oysters, squid and snails');
+
+CREATE TABLE public.ingredient_allergen (
+    ingredient_id uuid NOT NULL,
+    allergen_id text NOT NULL,
+    CONSTRAINT ingredient_allergen_pkey PRIMARY KEY (ingredient_id, allergen_id),
+    CONSTRAINT fk_ingredient_allergen_ingredient FOREIGN KEY (ingredient_id) REFERENCES public.ingredient(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    CONSTRAINT fk_ingredient_allergen_allergen FOREIGN KEY (allergen_id) REFERENCES public.allergen(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+-- Every ingredient of a category contains the allergen, categories are matched case insensitively.
+-- Categories mixing several allergens are linked to all of them, over-flagging is the safe side for allergies.
+CREATE TABLE public.category_allergen (
+    category text NOT NULL CHECK (category <> ''),
+    allergen_id text NOT NULL,
+    CONSTRAINT fk_category_allergen_allergen FOREIGN KEY (allergen_id) REFERENCES public.allergen(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX category_allergen_unique ON public.category_allergen (LOWER(category), allergen_id);
+
+INSERT INTO public.category_allergen (category, allergen_id) VALUES
+    ('Cereal Grains and Pasta', 'gluten'),
+    ('Pasta by Shape & Type', 'gluten'),
+    ('Baked Products', 'gluten'),
+    ('Dairy and Egg Products', 'milk'),
+    ('Dairy and Egg Products', 'eggs'),
+    ('Finfish and Shellfish Products', 'fish'),
+    ('Finfish and Shellfish Products', 'crustaceans'),
+    ('Finfish and Shellfish Products', 'molluscs'),
+    ('Nut and Seed Products', 'nuts'),
+    ('Nut and Seed Products', 'sesame');
+
+CREATE TABLE public.user_allergy (
+    user_id uuid NOT NULL,
+    allergen_id text NOT NULL,
+    CONSTRAINT user_allergy_pkey PRIMARY KEY (user_id, allergen_id),
+    CONSTRAINT fk_user_allergy_user FOREIGN KEY (user_id) REFERENCES public."user"(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    CONSTRAINT fk_user_allergy_allergen FOREIGN KEY (allergen_id) REFERENCES public.allergen(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+-- The allergens of a recipe are derived from its ingredients so they can't get out of date
+CREATE VIEW public.ingredient_allergens AS
+    SELECT ingredient_id, allergen_id FROM public.ingredient_allergen
+    UNION
+    SELECT ingredient.id, ca.allergen_id
+    FROM public.ingredient
+    JOIN public.category_allergen ca ON LOWER(ca.category) = LOWER(ingredient.category);
+
+CREATE VIEW public.recipe_allergen AS
+    SELECT DISTINCT ri.recipe_id, ia.allergen_id
+    FROM public.recipe_ingredient ri
+    JOIN public.ingredient_allergens ia ON ia.ingredient_id = ri.ingredient_id;
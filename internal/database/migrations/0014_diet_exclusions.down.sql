@@ -0,0 +1,10 @@
+DROP VIEW IF EXISTS public.recipe_diet;
+DROP VIEW IF EXISTS public.inferred_diet_recipe;
+DROP VIEW IF EXISTS public.diet_conflict;
+
+ALTER TABLE public.rel_diet_recipe
+    DROP CONSTRAINT IF EXISTS rel_diet_recipe_unique,
+    ALTER COLUMN recipe_id DROP NOT NULL,
+    ALTER COLUMN diet_id DROP NOT NULL;
+
+DROP TABLE IF EXISTS public.diet_excluded_category;
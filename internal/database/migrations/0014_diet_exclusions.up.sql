@@ -0,0 +1,53 @@
+-- Ingredients of an excluded category contradict the diet, categories are matched case insensitively
+CREATE TABLE public.diet_excluded_category (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    diet_id uuid NOT NULL,
+    name text NOT NULL CHECK (name <> ''),
+    CONSTRAINT diet_excluded_category_pkey PRIMARY KEY (id),
+    CONSTRAINT fk_diet_excluded_category_diet FOREIGN KEY (diet_id) REFERENCES public.diet(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX diet_excluded_category_unique ON public.diet_excluded_category (diet_id, LOWER(name));
+
+DELETE FROM public.rel_diet_recipe a USING public.rel_diet_recipe b
+    WHERE a.recipe_id = b.recipe_id AND a.diet_id = b.diet_id AND a.id > b.id;
+DELETE FROM public.rel_diet_recipe WHERE recipe_id IS NULL OR diet_id IS NULL;
+
+ALTER TABLE public.rel_diet_recipe
+    ALTER COLUMN recipe_id SET NOT NULL,
+    ALTER COLUMN diet_id SET NOT NULL,
+    ADD CONSTRAINT rel_diet_recipe_unique UNIQUE (recipe_id, diet_id);
+
+-- Explicit diet tags of recipes that contain an excluded ingredient
+CREATE VIEW public.diet_conflict AS
+    SELECT rel.recipe_id, rel.diet_id, ingredient.id AS ingredient_id, ingredient.name AS ingredient
+    FROM public.rel_diet_recipe rel
+    JOIN public.recipe_ingredient ri ON ri.recipe_id = rel.recipe_id
+    JOIN public.ingredient ON ingredient.id = ri.ingredient_id
+    JOIN public.diet_excluded_category ex ON ex.diet_id = rel.diet_id AND LOWER(ex.name) = LOWER(ingredient.category);
+
+-- A recipe follows a diet with exclusions if all its ingredients have a category and none of them is excluded
+CREATE VIEW public.inferred_diet_recipe AS
+    SELECT recipes.id AS recipe_id, diet.id AS diet_id
+    FROM public.recipes
+    CROSS JOIN public.diet
+    WHERE EXISTS (SELECT 1 FROM public.diet_excluded_category ex WHERE ex.diet_id = diet.id)
+      AND EXISTS (SELECT 1 FROM public.recipe_ingredient ri WHERE ri.recipe_id = recipes.id)
+      AND NOT EXISTS (
+        SELECT 1
+        FROM public.recipe_ingredient ri
+        JOIN public.ingredient ON ingredient.id = ri.ingredient_id
+        WHERE ri.recipe_id = recipes.id
+          AND (COALESCE(ingredient.category, '') = ''
+            OR EXISTS (SELECT 1 FROM public.diet_excluded_category ex
+                WHERE ex.diet_id = diet.id AND LOWER(ex.name) = LOWER(ingredient.category))));
+
+-- The explicit and inferred diets of recipes, explicit tags win
+CREATE VIEW public.recipe_diet AS
+    SELECT DISTINCT ON (recipe_id, diet_id) recipe_id, diet_id, inferred
+    FROM (
+        SELECT recipe_id, diet_id, false AS inferred FROM public.rel_diet_recipe
+        UNION ALL
+        SELECT recipe_id, diet_id, true AS inferred FROM public.inferred_diet_recipe
+    ) diets
+    ORDER BY recipe_id, diet_id, inferred;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS public.user_disliked_ingredient;
+
+ALTER TABLE public."user" DROP COLUMN IF EXISTS max_prep_time;
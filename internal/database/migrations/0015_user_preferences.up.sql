@@ -0,0 +1,10 @@
+ALTER TABLE public."user"
+    ADD COLUMN max_prep_time interval CHECK (max_prep_time > '0'::interval);
+
+CREATE TABLE public.user_disliked_ingredient (
+    user_id uuid NOT NULL,
+    ingredient_id uuid NOT NULL,
+    CONSTRAINT user_disliked_ingredient_pkey PRIMARY KEY (user_id, ingredient_id),
+    CONSTRAINT fk_user_disliked_ingredient_user FOREIGN KEY (user_id) REFERENCES public."user"(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    CONSTRAINT fk_user_disliked_ingredient_ingredient FOREIGN KEY (ingredient_id) REFERENCES public.ingredient(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
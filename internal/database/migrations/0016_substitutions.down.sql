@@ -0,0 +1,3 @@
+ALTER TABLE public.recipes DROP COLUMN IF EXISTS variant_of;
+
+DROP TABLE IF EXISTS public.ingredient_substitute;
@@ -0,0 +1,21 @@
+-- An ingredient can be replaced by amount * ratio of the substitute, the context like baking
+-- limits where the substitute works, an empty one means it works everywhere
+CREATE TABLE public.ingredient_substitute (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone,
+    ingredient_id uuid NOT NULL,
+    substitute_id uuid NOT NULL,
+    ratio numeric NOT NULL DEFAULT 1 CHECK (ratio > 0),
+    context text NOT NULL DEFAULT '',
+    note text NOT NULL DEFAULT '',
+    CONSTRAINT ingredient_substitute_pkey PRIMARY KEY (id),
+    CONSTRAINT ingredient_substitute_self CHECK (ingredient_id <> substitute_id),
+    CONSTRAINT fk_ingredient_substitute_ingredient FOREIGN KEY (ingredient_id) REFERENCES public.ingredient(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    CONSTRAINT fk_ingredient_substitute_substitute FOREIGN KEY (substitute_id) REFERENCES public.ingredient(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX ingredient_substitute_unique ON public.ingredient_substitute (ingredient_id, substitute_id, LOWER(context));
+
+-- Variants are copies of a recipe with some ingredients substituted
+ALTER TABLE public.recipes
+    ADD COLUMN variant_of uuid REFERENCES public.recipes(id) ON UPDATE CASCADE ON DELETE SET NULL;
@@ -0,0 +1,29 @@
+-- A session is created on login and lives as long as its refresh tokens are used in time.
+-- Access tokens name their session, revoking it logs the device out at once.
+CREATE TABLE public.session (
+    id uuid DEFAULT gen_random_uuid() NOT NULL,
+    user_id uuid NOT NULL,
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone NOT NULL,
+    last_seen timestamp without time zone DEFAULT (now())::timestamp without time zone NOT NULL,
+    expires_at timestamp without time zone NOT NULL,
+    revoked_at timestamp without time zone,
+    user_agent text NOT NULL DEFAULT '',
+    ip text NOT NULL DEFAULT '',
+    CONSTRAINT session_pkey PRIMARY KEY (id),
+    CONSTRAINT fk_session_user FOREIGN KEY (user_id) REFERENCES public."user"(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE INDEX session_user ON public.session (user_id);
+
+-- Only the SHA-256 of refresh tokens is stored. Every refresh uses up the token and issues a new one,
+-- presenting a used one again revokes the session because the token was stolen.
+CREATE TABLE public.refresh_token (
+    hash text NOT NULL,
+    session_id uuid NOT NULL,
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone NOT NULL,
+    used_at timestamp without time zone,
+    CONSTRAINT refresh_token_pkey PRIMARY KEY (hash),
+    CONSTRAINT fk_refresh_token_session FOREIGN KEY (session_id) REFERENCES public.session(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE INDEX refresh_token_session ON public.refresh_token (session_id);
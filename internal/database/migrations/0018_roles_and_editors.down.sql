@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS public.recipe_editor;
+
+UPDATE public."user" SET role = 'user' WHERE role = 'moderator';
+ALTER TABLE public."user" DROP CONSTRAINT IF EXISTS user_role_check;
+ALTER TABLE public."user"
+    ADD CONSTRAINT user_role_check CHECK (role IN ('user', 'admin'));
@@ -0,0 +1,17 @@
+-- Moderators curate recipes and ingredients of others, admins additionally manage diets, allergens and roles
+ALTER TABLE public."user" DROP CONSTRAINT IF EXISTS user_role_check;
+ALTER TABLE public."user"
+    ADD CONSTRAINT user_role_check CHECK (role IN ('user', 'moderator', 'admin'));
+
+-- Editors may change a recipe, co-authors may additionally manage its editors. The author isn't listed.
+CREATE TABLE public.recipe_editor (
+    recipe_id uuid NOT NULL,
+    user_id uuid NOT NULL,
+    role text DEFAULT 'editor' NOT NULL CHECK (role IN ('editor', 'coauthor')),
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone NOT NULL,
+    CONSTRAINT recipe_editor_pkey PRIMARY KEY (recipe_id, user_id),
+    CONSTRAINT fk_recipe_editor_recipe FOREIGN KEY (recipe_id) REFERENCES public.recipes(id) ON UPDATE CASCADE ON DELETE CASCADE,
+    CONSTRAINT fk_recipe_editor_user FOREIGN KEY (user_id) REFERENCES public."user"(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE INDEX recipe_editor_user ON public.recipe_editor (user_id);
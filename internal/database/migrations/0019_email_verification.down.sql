@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS public.auth_token;
+
+ALTER TABLE public."user"
+    DROP COLUMN IF EXISTS email_verified_at;
@@ -0,0 +1,19 @@
+-- Accounts that existed before verification was introduced count as verified, they couldn't have proven it
+ALTER TABLE public."user"
+    ADD COLUMN email_verified_at timestamp without time zone;
+
+UPDATE public."user" SET email_verified_at = COALESCE(created_at, now()) WHERE email IS NOT NULL;
+
+-- Single use tokens mailed to the user. Like refresh tokens only their SHA-256 is stored.
+CREATE TABLE public.auth_token (
+    hash text NOT NULL,
+    user_id uuid NOT NULL,
+    purpose text NOT NULL CHECK (purpose IN ('verify_email', 'reset_password')),
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone NOT NULL,
+    expires_at timestamp without time zone NOT NULL,
+    used_at timestamp without time zone,
+    CONSTRAINT auth_token_pkey PRIMARY KEY (hash),
+    CONSTRAINT fk_auth_token_user FOREIGN KEY (user_id) REFERENCES public."user"(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE INDEX auth_token_user ON public.auth_token (user_id, purpose);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS public.oidc_login;
+DROP TABLE IF EXISTS public.user_identity;
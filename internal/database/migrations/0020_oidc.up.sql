@@ -0,0 +1,24 @@
+-- Accounts of OpenID Connect providers linked to users, users signing in only with a provider have no password
+CREATE TABLE public.user_identity (
+    provider text NOT NULL,
+    subject text NOT NULL,
+    user_id uuid NOT NULL,
+    email text,
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone NOT NULL,
+    CONSTRAINT user_identity_pkey PRIMARY KEY (provider, subject),
+    CONSTRAINT fk_user_identity_user FOREIGN KEY (user_id) REFERENCES public."user"(id) ON UPDATE CASCADE ON DELETE CASCADE
+);
+
+CREATE INDEX user_identity_user ON public.user_identity (user_id);
+
+-- Logins that were sent to a provider and didn't come back yet. The state is stored hashed,
+-- the nonce and PKCE verifier only live until the callback.
+CREATE TABLE public.oidc_login (
+    state_hash text NOT NULL,
+    provider text NOT NULL,
+    nonce text NOT NULL,
+    code_verifier text NOT NULL,
+    created_at timestamp without time zone DEFAULT (now())::timestamp without time zone NOT NULL,
+    expires_at timestamp without time zone NOT NULL,
+    CONSTRAINT oidc_login_pkey PRIMARY KEY (state_hash)
+);
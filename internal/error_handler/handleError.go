@@ -1,6 +1,10 @@
 package error_handler
 
-import "github.com/gin-gonic/gin"
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
 
 type APIError struct {
 	Message string
@@ -11,6 +15,13 @@ type Errors struct {
 	Errors []error
 }
 
+// DetailedError is an error carrying extra information for the client, like suggestions how to fix it.
+// HandleError adds the details to the response.
+type DetailedError interface {
+	error
+	Details() any
+}
+
 func New(message string, code int, errors error) *APIError {
 	return &APIError{
 		Message: message,
@@ -20,8 +31,13 @@ func New(message string, code int, errors error) *APIError {
 }
 
 func HandleError(c *gin.Context, statusCode int, errorMessage string, err []error) {
-	c.AbortWithStatusJSON(statusCode, gin.H{
+	body := gin.H{
 		"errors":     err[0].Error(),
 		"errMessage": errorMessage,
-	})
+	}
+	var detailed DetailedError
+	if errors.As(err[0], &detailed) {
+		body["details"] = detailed.Details()
+	}
+	c.AbortWithStatusJSON(statusCode, body)
 }
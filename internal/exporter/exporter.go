@@ -0,0 +1,256 @@
+package exporter
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/units"
+)
+
+const (
+	FormatJSONLD   = "jsonld"
+	FormatMarkdown = "markdown"
+	FormatText     = "text"
+)
+
+type format struct {
+	contentType string
+	extension   string
+	write       func(w io.Writer, r *recipe.RecipeSchema) error
+}
+
+var formats = map[string]format{
+	FormatJSONLD:   {"application/ld+json", "jsonld", writeJSONLD},
+	FormatMarkdown: {"text/markdown; charset=utf-8", "md", writeMarkdown},
+	FormatText:     {"text/plain; charset=utf-8", "txt", writeText},
+}
+
+// ValidFormat reports if the format is known, an empty format means JSON-LD
+func ValidFormat(f string) bool {
+	_, ok := formats[orDefault(f)]
+	return ok
+}
+
+func orDefault(f string) string {
+	if f == "" {
+		return FormatJSONLD
+	}
+	return f
+}
+
+func ContentType(f string) string {
+	return formats[orDefault(f)].contentType
+}
+
+// FileName is the name a recipe is exported as, made from its name and id
+func FileName(r *recipe.RecipeSchema, f string) string {
+	return fmt.Sprintf("%s-%s.%s", slug(r.Name), r.ID, formats[orDefault(f)].extension)
+}
+
+// Write writes the recipe in the format
+func Write(w io.Writer, r *recipe.RecipeSchema, f string) error {
+	ft, ok := formats[orDefault(f)]
+	if !ok {
+		return fmt.Errorf("unknown format %s", f)
+	}
+	return ft.write(w, r)
+}
+
+// Zip writes all recipes into one zip archive, each in its own file
+func Zip(w io.Writer, recipes []*recipe.RecipeSchema, f string) error {
+	archive := zip.NewWriter(w)
+	for _, r := range recipes {
+		file, err := archive.Create(FileName(r, f))
+		if err != nil {
+			return err
+		}
+		err = Write(file, r, f)
+		if err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+func slug(name string) string {
+	s := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if s == "" {
+		return "recipe"
+	}
+	return s
+}
+
+// IngredientLine formats an ingredient like "2-3 clove garlic, minced"
+func IngredientLine(ing recipe.IngredientsSchema) string {
+	line := strings.TrimSpace(fmt.Sprintf("%s %s %s", ing.Quantity(), ing.Unit, ing.Name))
+	if ing.Note != "" {
+		line += ", " + ing.Note
+	}
+	return line
+}
+
+// ISODuration converts a postgres interval like "01:30:00" or "1 day 02:00:00" into an ISO-8601 duration
+func ISODuration(interval string) string {
+	d, ok := recipe.ParseInterval(interval)
+	if !ok || d <= 0 {
+		return ""
+	}
+	hours := int(d / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	seconds := int(d % time.Minute / time.Second)
+
+	var b strings.Builder
+	b.WriteString("PT")
+	if hours > 0 {
+		fmt.Fprintf(&b, "%dH", hours)
+	}
+	if minutes > 0 {
+		fmt.Fprintf(&b, "%dM", minutes)
+	}
+	if seconds > 0 {
+		fmt.Fprintf(&b, "%dS", seconds)
+	}
+	return b.String()
+}
+
+type howToStep struct {
+	Type string `json:"@type"`
+	Text string `json:"text"`
+}
+
+type nutritionInformation struct {
+	Type                string `json:"@type"`
+	Calories            string `json:"calories"`
+	FatContent          string `json:"fatContent"`
+	SaturatedFatContent string `json:"saturatedFatContent"`
+	CarbohydrateContent string `json:"carbohydrateContent"`
+	SugarContent        string `json:"sugarContent"`
+	ProteinContent      string `json:"proteinContent"`
+	FiberContent        string `json:"fiberContent"`
+	SodiumContent       string `json:"sodiumContent"`
+}
+
+type jsonLDRecipe struct {
+	Context            string                `json:"@context"`
+	Type               string                `json:"@type"`
+	Identifier         string                `json:"identifier,omitempty"`
+	Name               string                `json:"name"`
+	DateCreated        string                `json:"dateCreated,omitempty"`
+	RecipeCuisine      string                `json:"recipeCuisine,omitempty"`
+	RecipeYield        string                `json:"recipeYield,omitempty"`
+	PrepTime           string                `json:"prepTime,omitempty"`
+	CookTime           string                `json:"cookTime,omitempty"`
+	RecipeIngredient   []string              `json:"recipeIngredient"`
+	RecipeInstructions []howToStep           `json:"recipeInstructions"`
+	Nutrition          *nutritionInformation `json:"nutrition,omitempty"`
+}
+
+func grams(v float64) string {
+	return units.FormatAmount(v) + " g"
+}
+
+func writeJSONLD(w io.Writer, r *recipe.RecipeSchema) error {
+	doc := jsonLDRecipe{
+		Context:            "https://schema.org",
+		Type:               "Recipe",
+		Identifier:         r.ID,
+		Name:               r.Name,
+		RecipeCuisine:      r.Cuisine,
+		PrepTime:           ISODuration(r.PrepTime),
+		CookTime:           ISODuration(r.CookingTime),
+		RecipeIngredient:   []string{},
+		RecipeInstructions: []howToStep{},
+	}
+	if !r.CreatedAt.IsZero() {
+		doc.DateCreated = r.CreatedAt.Format("2006-01-02")
+	}
+	if r.Yield > 0 {
+		doc.RecipeYield = strings.TrimSpace(fmt.Sprintf("%d %s", r.Yield, r.YieldUnit))
+	}
+	for _, ing := range r.Ingredients {
+		doc.RecipeIngredient = append(doc.RecipeIngredient, IngredientLine(ing))
+	}
+	for _, s := range r.Steps {
+		doc.RecipeInstructions = append(doc.RecipeInstructions, howToStep{Type: "HowToStep", Text: s.Step})
+	}
+	if nv := r.NutritionalValue; nv.ID != "" {
+		doc.Nutrition = &nutritionInformation{
+			Type:                "NutritionInformation",
+			Calories:            units.FormatAmount(nv.Kcal) + " kcal",
+			FatContent:          grams(nv.Fat),
+			SaturatedFatContent: grams(nv.SaturatedFat),
+			CarbohydrateContent: grams(nv.Carbohydrate),
+			SugarContent:        grams(nv.Sugar),
+			ProteinContent:      grams(nv.Protein),
+			FiberContent:        grams(nv.Fiber),
+			// Sodium is 40% of salt
+			SodiumContent: units.FormatAmount(nv.Salt*400) + " mg",
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// meta lists cuisine, times and yield of the recipe, leaving out empty ones
+func meta(r *recipe.RecipeSchema) []string {
+	var m []string
+	if r.Cuisine != "" {
+		m = append(m, "Cuisine: "+r.Cuisine)
+	}
+	if r.PrepTime != "" {
+		m = append(m, "Prep: "+r.PrepTime)
+	}
+	if r.CookingTime != "" {
+		m = append(m, "Cook: "+r.CookingTime)
+	}
+	if r.Yield > 0 {
+		m = append(m, strings.TrimSpace(fmt.Sprintf("Yield: %d %s", r.Yield, r.YieldUnit)))
+	}
+	return m
+}
+
+func writeMarkdown(w io.Writer, r *recipe.RecipeSchema) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", r.Name)
+	if m := meta(r); len(m) > 0 {
+		fmt.Fprintf(&b, "*%s*\n\n", strings.Join(m, " · "))
+	}
+	b.WriteString("## Ingredients\n\n")
+	for _, ing := range r.Ingredients {
+		fmt.Fprintf(&b, "- %s\n", IngredientLine(ing))
+	}
+	b.WriteString("\n## Instructions\n\n")
+	for i, s := range r.Steps {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, s.Step)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeText(w io.Writer, r *recipe.RecipeSchema) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%s\n\n", r.Name, strings.Repeat("=", len([]rune(r.Name))))
+	for _, m := range meta(r) {
+		fmt.Fprintf(&b, "%s\n", m)
+	}
+	b.WriteString("\nIngredients\n\n")
+	for _, ing := range r.Ingredients {
+		fmt.Fprintf(&b, "  %s\n", IngredientLine(ing))
+	}
+	b.WriteString("\nInstructions\n\n")
+	for i, s := range r.Steps {
+		fmt.Fprintf(&b, "  %d. %s\n", i+1, s.Step)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
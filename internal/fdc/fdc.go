@@ -0,0 +1,258 @@
+package fdc
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Source is stored as the source of imported nutritional values
+const Source = "USDA FoodData Central"
+
+// Ids of the FoodData Central nutrients that are imported, amounts are per 100 g
+const (
+	NutrientProtein               = 1003
+	NutrientFat                   = 1004
+	NutrientCarbohydrate          = 1005
+	NutrientEnergyKcal            = 1008
+	NutrientEnergyKj              = 1062
+	NutrientSugarNLEA             = 1063
+	NutrientFiber                 = 1079
+	NutrientSodium                = 1093 // mg
+	NutrientSaturatedFat          = 1258
+	NutrientSugar                 = 2000
+	NutrientEnergyAtwaterGeneral  = 2047
+	NutrientEnergyAtwaterSpecific = 2048
+)
+
+var nutrients = map[int]bool{
+	NutrientProtein: true, NutrientFat: true, NutrientCarbohydrate: true, NutrientEnergyKcal: true,
+	NutrientEnergyKj: true, NutrientSugarNLEA: true, NutrientFiber: true, NutrientSodium: true,
+	NutrientSaturatedFat: true, NutrientSugar: true, NutrientEnergyAtwaterGeneral: true, NutrientEnergyAtwaterSpecific: true,
+}
+
+type Food struct {
+	FdcID       int64  `json:"fdc_id"`
+	Description string `json:"description"`
+	NdbNumber   int64  `json:"ndb_number,omitempty"`
+	// Nutrients maps nutrient ids to their amount per 100 g
+	Nutrients map[int]float64 `json:"nutrients"`
+	// Conflicts lists nutrients the file gives different amounts for
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+func (f *Food) add(nutrient int, amount float64) {
+	if !nutrients[nutrient] {
+		return
+	}
+	if f.Nutrients == nil {
+		f.Nutrients = make(map[int]float64)
+	}
+	if old, ok := f.Nutrients[nutrient]; ok && old != amount {
+		f.Conflicts = append(f.Conflicts, fmt.Sprintf("nutrient %d is given as %g and %g", nutrient, old, amount))
+		return
+	}
+	f.Nutrients[nutrient] = amount
+}
+
+// Load reads a FoodData Central download, either one of the JSON files or the directory of the CSV download.
+// Only foods whose id is in wanted are kept, all of them if wanted is nil.
+func Load(path string, wanted map[int64]bool) ([]Food, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return ReadCSV(path, wanted)
+	}
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		return ReadCSV(filepath.Dir(path), wanted)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadJSON(file, wanted)
+}
+
+type jsonFood struct {
+	FdcID         int64           `json:"fdcId"`
+	Description   string          `json:"description"`
+	NdbNumber     json.RawMessage `json:"ndbNumber"`
+	FoodNutrients []struct {
+		Nutrient struct {
+			ID int `json:"id"`
+		} `json:"nutrient"`
+		Amount *float64 `json:"amount"`
+	} `json:"foodNutrients"`
+}
+
+// ReadJSON reads the foods of a FoodData Central JSON download like {"SRLegacyFoods": [...]} or a plain array
+// of foods. The foods are streamed so even the large downloads don't have to fit into memory.
+func ReadJSON(r io.Reader, wanted map[int64]bool) ([]Food, error) {
+	dec := json.NewDecoder(r)
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if tok == json.Delim('{') {
+		// The foods are the array of the first key
+		if _, err = dec.Token(); err != nil {
+			return nil, err
+		}
+		if tok, err = dec.Token(); err != nil {
+			return nil, err
+		}
+	}
+	if tok != json.Delim('[') {
+		return nil, errors.New("expected a list of foods")
+	}
+
+	foods := []Food{}
+	for dec.More() {
+		var jf jsonFood
+		err = dec.Decode(&jf)
+		if err != nil {
+			return nil, err
+		}
+		if wanted != nil && !wanted[jf.FdcID] {
+			continue
+		}
+		food := Food{FdcID: jf.FdcID, Description: jf.Description}
+		// ndbNumber is a number in some downloads and a string in others
+		food.NdbNumber, _ = strconv.ParseInt(strings.Trim(string(jf.NdbNumber), `"`), 10, 64)
+		for _, n := range jf.FoodNutrients {
+			if n.Amount != nil {
+				food.add(n.Nutrient.ID, *n.Amount)
+			}
+		}
+		foods = append(foods, food)
+	}
+	return foods, nil
+}
+
+// csvFile calls row for every row of the CSV file with its values keyed by the header.
+// Missing optional files are skipped.
+func csvFile(path string, optional bool, row func(map[string]string) error) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) && optional {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.ReuseRecord = true
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	header = append([]string(nil), header...)
+	values := make(map[string]string, len(header))
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+		for i, h := range header {
+			if i < len(record) {
+				values[h] = record[i]
+			}
+		}
+		err = row(values)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(path), err)
+		}
+	}
+}
+
+// ReadCSV reads the food_nutrient.csv of a FoodData Central CSV download in dir. Descriptions are taken
+// from food.csv and NDB numbers from sr_legacy_food.csv if they are there.
+func ReadCSV(dir string, wanted map[int64]bool) ([]Food, error) {
+	foods := map[int64]*Food{}
+	var order []int64
+	food := func(id int64) *Food {
+		f, ok := foods[id]
+		if !ok {
+			f = &Food{FdcID: id}
+			foods[id] = f
+			order = append(order, id)
+		}
+		return f
+	}
+	fdcID := func(values map[string]string) (int64, bool, error) {
+		id, err := strconv.ParseInt(values["fdc_id"], 10, 64)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid fdc_id %q", values["fdc_id"])
+		}
+		return id, wanted == nil || wanted[id], nil
+	}
+
+	err := csvFile(filepath.Join(dir, "food_nutrient.csv"), false, func(values map[string]string) error {
+		id, ok, err := fdcID(values)
+		if err != nil || !ok {
+			return err
+		}
+		nutrient, err := strconv.Atoi(values["nutrient_id"])
+		if err != nil {
+			return fmt.Errorf("invalid nutrient_id %q", values["nutrient_id"])
+		}
+		if values["amount"] == "" {
+			return nil
+		}
+		amount, err := strconv.ParseFloat(values["amount"], 64)
+		if err != nil {
+			return fmt.Errorf("invalid amount %q", values["amount"])
+		}
+		food(id).add(nutrient, amount)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = csvFile(filepath.Join(dir, "food.csv"), true, func(values map[string]string) error {
+		id, ok, err := fdcID(values)
+		if err != nil || !ok {
+			return err
+		}
+		if f, ok := foods[id]; ok {
+			f.Description = values["description"]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = csvFile(filepath.Join(dir, "sr_legacy_food.csv"), true, func(values map[string]string) error {
+		id, ok, err := fdcID(values)
+		if err != nil || !ok {
+			return err
+		}
+		if f, ok := foods[id]; ok {
+			f.NdbNumber, _ = strconv.ParseInt(values["NDB_number"], 10, 64)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Food, len(order))
+	for i, id := range order {
+		result[i] = *foods[id]
+	}
+	return result, nil
+}
@@ -0,0 +1,301 @@
+package fdc
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/units"
+)
+
+// Target is an ingredient with an fdic_id that nutritional values can be imported for
+type Target struct {
+	ID           string `db:"id"`
+	Name         string `db:"name"`
+	FdcID        int64  `db:"fdic_id"`
+	NdbNumber    int64  `db:"ndb_number"`
+	StandardUnit string `db:"standard_unit"`
+	Category     string `db:"category"`
+	// PieceGrams is the weight of one standard unit of counted ingredients, 0 if it is unknown
+	PieceGrams float64 `db:"piece_grams"`
+	// Source of the nutritional values the ingredient already has, nil if it has none
+	Source *string `db:"source"`
+}
+
+type Entry struct {
+	IngredientID string                  `json:"ingredient_id"`
+	Ingredient   string                  `json:"ingredient"`
+	FdcID        int64                   `json:"fdc_id"`
+	Description  string                  `json:"description"`
+	Nutrition    recipe.NutritionalValue `json:"nutrition"`
+	// Replaced is set if the ingredient had nutritional values before
+	Replaced bool `json:"replaced"`
+}
+
+// Problem is an ingredient whose values weren't imported
+type Problem struct {
+	IngredientID string `json:"ingredient_id"`
+	Ingredient   string `json:"ingredient"`
+	FdcID        int64  `json:"fdc_id"`
+	Reason       string `json:"reason"`
+}
+
+type Report struct {
+	Imported  []Entry   `json:"imported"`
+	Conflicts []Problem `json:"conflicts"`
+	// Unmatched are ingredients whose fdic_id isn't in the file
+	Unmatched []Problem `json:"unmatched"`
+	// UnusedFoods counts the foods of the file no ingredient refers to
+	UnusedFoods int `json:"unused_foods"`
+}
+
+type Options struct {
+	// Overwrite replaces nutritional values that were entered by hand or come from another source
+	Overwrite bool
+	Now       time.Time
+}
+
+// Wanted returns the FDC ids of the targets, to only load those foods from large files
+func Wanted(targets []Target) map[int64]bool {
+	wanted := make(map[int64]bool, len(targets))
+	for _, t := range targets {
+		wanted[t.FdcID] = true
+	}
+	return wanted
+}
+
+// Plan matches the foods to the targets by their FDC id and decides which values are imported.
+// Nothing is written, see Apply.
+func Plan(foods []Food, targets []Target, opts Options) *Report {
+	report := &Report{Imported: []Entry{}, Conflicts: []Problem{}, Unmatched: []Problem{}}
+
+	byID := map[int64][]Food{}
+	for _, f := range foods {
+		byID[f.FdcID] = append(byID[f.FdcID], f)
+	}
+	used := map[int64]bool{}
+
+	for _, t := range targets {
+		problem := Problem{IngredientID: t.ID, Ingredient: t.Name, FdcID: t.FdcID}
+		matches, ok := byID[t.FdcID]
+		if !ok {
+			problem.Reason = "not in the file"
+			report.Unmatched = append(report.Unmatched, problem)
+			continue
+		}
+		used[t.FdcID] = true
+
+		reason := conflict(t, matches, opts)
+		if reason != "" {
+			problem.Reason = reason
+			report.Conflicts = append(report.Conflicts, problem)
+			continue
+		}
+
+		food := matches[0]
+		nv, ok := food.nutrition(t)
+		if !ok {
+			problem.Reason = fmt.Sprintf("%s is counted without a weight for one %s", t.Name, t.StandardUnit)
+			report.Conflicts = append(report.Conflicts, problem)
+			continue
+		}
+		now := opts.Now
+		nv.ImportedAt = &now
+		report.Imported = append(report.Imported, Entry{
+			IngredientID: t.ID, Ingredient: t.Name, FdcID: t.FdcID, Description: food.Description,
+			Nutrition: nv, Replaced: t.Source != nil,
+		})
+	}
+
+	for id := range byID {
+		if !used[id] {
+			report.UnusedFoods++
+		}
+	}
+	return report
+}
+
+// conflict returns why the values of the foods can't be imported for the target, "" if they can
+func conflict(t Target, foods []Food, opts Options) string {
+	food := foods[0]
+	for _, other := range foods[1:] {
+		if !sameNutrients(food.Nutrients, other.Nutrients) {
+			return fmt.Sprintf("the file contains %d different foods with this id", len(foods))
+		}
+	}
+	if len(food.Conflicts) > 0 {
+		return food.Conflicts[0]
+	}
+	if t.NdbNumber != 0 && food.NdbNumber != 0 && t.NdbNumber != food.NdbNumber {
+		return fmt.Sprintf("ndb number %d of the ingredient doesn't match %d of %s", t.NdbNumber, food.NdbNumber, food.Description)
+	}
+	if len(food.Nutrients) == 0 {
+		return "the food has none of the imported nutrients"
+	}
+	if t.Source != nil && *t.Source != Source && !opts.Overwrite {
+		source := *t.Source
+		if source == "" {
+			source = "values entered by hand"
+		}
+		return fmt.Sprintf("already has %s, use -overwrite to replace them", source)
+	}
+	return ""
+}
+
+func sameNutrients(a, b map[int]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if w, ok := b[k]; !ok || w != v {
+			return false
+		}
+	}
+	return true
+}
+
+// first returns the amount of the first of the nutrients the food has
+func (f *Food) first(ids ...int) (float64, bool) {
+	for _, id := range ids {
+		if v, ok := f.Nutrients[id]; ok {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// nutrition converts the values per 100 g into the nutritional values of the target. Counted ingredients
+// store them per piece, ok is false if the weight of a piece isn't known.
+func (f *Food) nutrition(t Target) (recipe.NutritionalValue, bool) {
+	fdcID := f.FdcID
+	nv := recipe.NutritionalValue{IngredientID: &t.ID, Source: Source, SourceID: &fdcID}
+	var missing []string
+	value := func(name string, ids ...int) float64 {
+		v, ok := f.first(ids...)
+		if !ok {
+			missing = append(missing, name)
+		}
+		return v
+	}
+
+	kcal, hasKcal := f.first(NutrientEnergyKcal, NutrientEnergyAtwaterGeneral, NutrientEnergyAtwaterSpecific)
+	kj, hasKj := f.first(NutrientEnergyKj)
+	switch {
+	case !hasKcal && !hasKj:
+		missing = append(missing, "energy")
+	case !hasKj:
+		kj = kcal * 4.184
+	case !hasKcal:
+		kcal = kj / 4.184
+	}
+	nv.Kcal, nv.Kj = kcal, kj
+	nv.Fat = value("fat", NutrientFat)
+	nv.SaturatedFat = value("saturated fat", NutrientSaturatedFat)
+	nv.Carbohydrate = value("carbohydrate", NutrientCarbohydrate)
+	nv.Sugar = value("sugar", NutrientSugar, NutrientSugarNLEA)
+	nv.Protein = value("protein", NutrientProtein)
+	nv.Fiber = value("fiber", NutrientFiber)
+	// Salt is 2.5 times the sodium, which is given in mg
+	nv.Salt = value("salt", NutrientSodium) * 2.5 / 1000
+	if len(missing) > 0 {
+		nv.Incomplete = true
+		nv.Missing = missing
+	}
+
+	fvn := 0.0
+	if recipe.IsFruitVegNut(t.Category) {
+		fvn = 100
+	}
+	points, letter := recipe.NutriScore(recipe.NutriScoreInput{
+		EnergyKj: nv.Kj, Sugar: nv.Sugar, SaturatedFat: nv.SaturatedFat, Salt: nv.Salt,
+		Fiber: nv.Fiber, Protein: nv.Protein, FruitVegNut: fvn,
+	})
+	nv.NutriscorePoints = &points
+	nv.Nutriscore = letter
+
+	if u, ok := units.Lookup(t.StandardUnit); ok && u.Kind == units.Count {
+		if t.PieceGrams <= 0 {
+			return nv, false
+		}
+		factor := t.PieceGrams / 100
+		for _, v := range []*float64{&nv.Kcal, &nv.Kj, &nv.Fat, &nv.SaturatedFat, &nv.Carbohydrate, &nv.Sugar, &nv.Protein, &nv.Salt, &nv.Fiber} {
+			*v *= factor
+		}
+	}
+	for _, v := range []*float64{&nv.Kcal, &nv.Kj, &nv.Fat, &nv.SaturatedFat, &nv.Carbohydrate, &nv.Sugar, &nv.Protein, &nv.Salt, &nv.Fiber} {
+		*v = math.Round(*v*1000) / 1000
+	}
+	return nv, true
+}
+
+// LoadTargets returns all ingredients with an fdic_id
+func LoadTargets(db *sqlx.DB) ([]Target, *error_handler.APIError) {
+	targets := []Target{}
+	err := db.Select(&targets, `SELECT ingredient.id, ingredient.name, ingredient.fdic_id,
+			COALESCE(ingredient.ndb_number, 0) AS ndb_number, COALESCE(ingredient.standard_unit, '') AS standard_unit,
+			COALESCE(ingredient.category, '') AS category,
+			COALESCE(
+				(SELECT grams FROM ingredient_piece_weight w WHERE w.ingredient_id = ingredient.id AND w.unit = ingredient.standard_unit),
+				(SELECT grams FROM ingredient_piece_weight w WHERE w.ingredient_id = ingredient.id AND w.unit = 'piece'),
+				0) AS piece_grams,
+			CASE WHEN nv.id IS NULL THEN NULL ELSE COALESCE(nv.source, '') END AS source
+		FROM ingredient
+		LEFT JOIN nutritional_value nv ON nv.ingredient_id = ingredient.id
+		WHERE ingredient.fdic_id > 0
+		ORDER BY ingredient.name`)
+	if err != nil {
+		return nil, error_handler.New("Error loading ingredients: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return targets, nil
+}
+
+// Apply stores the imported values and returns the ids of the recipes using the ingredients,
+// their nutrition has to be recalculated.
+func Apply(report *Report, db *sqlx.DB) ([]string, *error_handler.APIError) {
+	tx := db.MustBegin()
+	ids := make([]string, 0, len(report.Imported))
+	for _, e := range report.Imported {
+		_, err := tx.NamedExec(`INSERT INTO nutritional_value
+				(ingredient_id, kcal, kj, fat, saturated_fat, carbohydrate, sugar, protein, salt, fiber,
+				nutriscore, nutriscore_points, incomplete, missing, source, source_id, imported_at)
+			VALUES
+				(:ingredient_id, :kcal, :kj, :fat, :saturated_fat, :carbohydrate, :sugar, :protein, :salt, :fiber,
+				:nutriscore, :nutriscore_points, :incomplete, :missing, :source, :source_id, :imported_at)
+			ON CONFLICT (ingredient_id) DO UPDATE SET
+				kcal = EXCLUDED.kcal, kj = EXCLUDED.kj, fat = EXCLUDED.fat, saturated_fat = EXCLUDED.saturated_fat,
+				carbohydrate = EXCLUDED.carbohydrate, sugar = EXCLUDED.sugar, protein = EXCLUDED.protein,
+				salt = EXCLUDED.salt, fiber = EXCLUDED.fiber, nutriscore = EXCLUDED.nutriscore,
+				nutriscore_points = EXCLUDED.nutriscore_points, incomplete = EXCLUDED.incomplete,
+				missing = EXCLUDED.missing, source = EXCLUDED.source, source_id = EXCLUDED.source_id,
+				imported_at = EXCLUDED.imported_at`, e.Nutrition)
+		if err != nil {
+			tx.Rollback()
+			return nil, error_handler.New("Error saving nutrition of "+e.Ingredient+": "+err.Error(), http.StatusInternalServerError, err)
+		}
+		ids = append(ids, e.IngredientID)
+	}
+
+	recipeIDs := []string{}
+	if len(ids) > 0 {
+		query, args, err := sqlx.In(`SELECT DISTINCT recipe_id FROM recipe_ingredient WHERE ingredient_id::text IN (?)`, ids)
+		if err == nil {
+			err = tx.Select(&recipeIDs, tx.Rebind(query), args...)
+		}
+		if err != nil {
+			tx.Rollback()
+			return nil, error_handler.New("Error finding recipes: "+err.Error(), http.StatusInternalServerError, err)
+		}
+	}
+
+	err := tx.Commit()
+	if err != nil {
+		return nil, error_handler.New("Error saving nutrition: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	sort.Strings(recipeIDs)
+	return recipeIDs, nil
+}
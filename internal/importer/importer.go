@@ -0,0 +1,119 @@
+package importer
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/recipe"
+)
+
+type Unmatched struct {
+	Line   string `json:"line"`
+	Name   string `json:"name,omitempty"`
+	Reason string `json:"reason"`
+	// Suggestions are known ingredients with a similar name
+	Suggestions []recipe.IngredientMatch `json:"suggestions,omitempty"`
+}
+
+type Result struct {
+	Recipe    *recipe.RecipeSchema `json:"recipe"`
+	Unmatched []Unmatched          `json:"unmatched"`
+	Created   bool                 `json:"created"`
+}
+
+type Importer struct {
+	Recipes recipe.RecipeRepository
+	// LookupIngredient returns the id of the ingredient with the given name
+	LookupIngredient func(name string) (string, *error_handler.APIError)
+	// SuggestIngredients returns similar ingredients for unknown ones, it may be nil
+	SuggestIngredients func(name string) ([]recipe.IngredientMatch, *error_handler.APIError)
+}
+
+func New(recipes recipe.RecipeRepository, db database.SQLDB) *Importer {
+	return &Importer{
+		Recipes: recipes,
+		LookupIngredient: func(name string) (string, *error_handler.APIError) {
+			return recipe.GetIngIDByName(name, db)
+		},
+		SuggestIngredients: func(name string) ([]recipe.IngredientMatch, *error_handler.APIError) {
+			return recipe.SuggestIngredients(name, recipe.MaxSuggestions, db)
+		},
+	}
+}
+
+// Import parses a schema.org Recipe and creates it for the author. Ingredients that can't be parsed or
+// aren't known are left out and reported. With dryRun the recipe is only parsed and matched.
+func (i *Importer) Import(data []byte, authorID string, dryRun bool) (*Result, *error_handler.APIError) {
+	parsed, err := Parse(data)
+	if err != nil {
+		return nil, error_handler.New("Couldn't read recipe: "+err.Error(), http.StatusBadRequest, err)
+	}
+
+	result := &Result{Recipe: &parsed.Recipe, Unmatched: []Unmatched{}}
+	result.Recipe.Ingredients = []recipe.IngredientsSchema{}
+	for _, line := range parsed.IngredientLines {
+		ing, err := recipe.ParseIngredientLine(line)
+		if err != nil {
+			result.Unmatched = append(result.Unmatched, Unmatched{Line: line, Name: ing.Name, Reason: err.Error()})
+			continue
+		}
+		id, apiErr := i.match(&ing)
+		if apiErr != nil {
+			if apiErr.Code != http.StatusNotFound {
+				return nil, apiErr
+			}
+			unmatched := Unmatched{Line: line, Name: ing.Name, Reason: "unknown ingredient"}
+			if i.SuggestIngredients != nil {
+				unmatched.Suggestions, apiErr = i.SuggestIngredients(ing.Name)
+				if apiErr != nil {
+					return nil, apiErr
+				}
+			}
+			result.Unmatched = append(result.Unmatched, unmatched)
+			continue
+		}
+		ing.IngredientID = id
+		result.Recipe.Ingredients = append(result.Recipe.Ingredients, ing)
+	}
+
+	if len(result.Recipe.Ingredients) == 0 {
+		return result, error_handler.New("None of the ingredients could be matched", http.StatusUnprocessableEntity, errors.New("no ingredients matched"))
+	}
+	apiErr := result.Recipe.Build(authorID)
+	if apiErr != nil {
+		return result, apiErr
+	}
+	result.Recipe.Author = authorID
+	if dryRun {
+		return result, nil
+	}
+
+	apiErr = i.Recipes.Create(result.Recipe)
+	if apiErr != nil {
+		return result, apiErr
+	}
+	result.Created = true
+	return result, nil
+}
+
+// match looks the ingredient up by its name and its singular and plural forms, the name is set to the one that matched
+func (i *Importer) match(ing *recipe.IngredientsSchema) (string, *error_handler.APIError) {
+	apiErr := error_handler.New("ingredient name is empty", http.StatusNotFound, errors.New("empty ingredient name"))
+	for n, name := range recipe.NameVariants(ing.Name) {
+		var id string
+		id, apiErr = i.LookupIngredient(name)
+		if apiErr == nil {
+			// Keep the spelling of the recipe unless another form matched
+			if n > 0 {
+				ing.Name = name
+			}
+			return id, nil
+		}
+		if apiErr.Code != http.StatusNotFound {
+			return "", apiErr
+		}
+	}
+	return "", apiErr
+}
@@ -0,0 +1,215 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/madswillem/recipeApp/internal/recipe"
+)
+
+var ErrNoRecipe = errors.New("no schema.org Recipe found")
+
+var jsonLDScript = regexp.MustCompile(`(?is)<script[^>]*type\s*=\s*["']?application/ld\+json["']?[^>]*>(.*?)</script>`)
+
+// ParsedRecipe is a schema.org Recipe with its ingredient lines still unparsed
+type ParsedRecipe struct {
+	Recipe          recipe.RecipeSchema
+	IngredientLines []string
+}
+
+// Parse reads a schema.org Recipe from JSON-LD or from the JSON-LD scripts of an HTML page
+func Parse(data []byte) (*ParsedRecipe, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '<' {
+		for _, match := range jsonLDScript.FindAllSubmatch(trimmed, -1) {
+			parsed, err := parseJSONLD(match[1])
+			if err == nil {
+				return parsed, nil
+			}
+		}
+		return nil, ErrNoRecipe
+	}
+	return parseJSONLD(trimmed)
+}
+
+func parseJSONLD(data []byte) (*ParsedRecipe, error) {
+	var doc any
+	err := json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON-LD: %w", err)
+	}
+	node := findRecipe(doc)
+	if node == nil {
+		return nil, ErrNoRecipe
+	}
+
+	parsed := &ParsedRecipe{}
+	r := &parsed.Recipe
+	r.Name = text(node["name"])
+	r.Cuisine = strings.ToLower(text(node["recipeCuisine"]))
+	r.Yield, r.YieldUnit = parseYield(node["recipeYield"])
+
+	r.PrepTime, err = interval(node["prepTime"])
+	if err != nil {
+		return nil, err
+	}
+	r.CookingTime, err = interval(node["cookTime"])
+	if err != nil {
+		return nil, err
+	}
+
+	ingredients := node["recipeIngredient"]
+	if ingredients == nil {
+		ingredients = node["ingredients"]
+	}
+	for _, line := range list(ingredients) {
+		if s := text(line); s != "" {
+			parsed.IngredientLines = append(parsed.IngredientLines, s)
+		}
+	}
+
+	r.Steps = []recipe.StepsStruct{}
+	for _, step := range instructions(node["recipeInstructions"]) {
+		r.Steps = append(r.Steps, recipe.StepsStruct{Step: step})
+	}
+	return parsed, nil
+}
+
+// findRecipe searches the document for the first node typed Recipe, it may be nested in @graph or mainEntity
+func findRecipe(v any) map[string]any {
+	switch v := v.(type) {
+	case map[string]any:
+		for _, t := range list(v["@type"]) {
+			if s, ok := t.(string); ok && (s == "Recipe" || strings.HasSuffix(s, "/Recipe")) {
+				return v
+			}
+		}
+		for _, child := range v {
+			if r := findRecipe(child); r != nil {
+				return r
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if r := findRecipe(child); r != nil {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+// list returns v as a slice, single values become a slice of one
+func list(v any) []any {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		return v
+	}
+	return []any{v}
+}
+
+// text returns the text of a JSON-LD value with HTML entities decoded, of lists the first element is used
+func text(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(html.UnescapeString(v))
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []any:
+		if len(v) > 0 {
+			return text(v[0])
+		}
+	case map[string]any:
+		if t := text(v["text"]); t != "" {
+			return t
+		}
+		return text(v["name"])
+	}
+	return ""
+}
+
+var leadingNumber = regexp.MustCompile(`^\s*(\d+(?:[.,]\d+)?)\s*(.*)$`)
+
+// parseYield reads yields like 4, "4", "4 servings" or ["4", "4 servings"]
+func parseYield(v any) (int, string) {
+	var best string
+	for _, y := range list(v) {
+		s := text(y)
+		if best == "" || len(s) > len(best) {
+			best = s
+		}
+	}
+	match := leadingNumber.FindStringSubmatch(best)
+	if match == nil {
+		return 0, ""
+	}
+	n, _ := strconv.ParseFloat(strings.Replace(match[1], ",", ".", 1), 64)
+	return int(math.Round(n)), strings.TrimSpace(match[2])
+}
+
+var isoDuration = regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// ParseDuration parses an ISO-8601 duration like PT1H30M
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	match := isoDuration.FindStringSubmatch(s)
+	if match == nil || s == "P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("invalid ISO-8601 duration %q", s)
+	}
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+	var d time.Duration
+	for i, u := range units {
+		if match[i+1] == "" {
+			continue
+		}
+		n, _ := strconv.ParseFloat(match[i+1], 64)
+		d += time.Duration(n * float64(u))
+	}
+	return d, nil
+}
+
+// interval converts an ISO-8601 duration into the HH:MM:SS format of a postgres interval
+func interval(v any) (string, error) {
+	s := text(v)
+	if s == "" {
+		return "00:00:00", nil
+	}
+	d, err := ParseDuration(s)
+	if err != nil {
+		return "", err
+	}
+	d = d.Round(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60), nil
+}
+
+// instructions flattens text, HowToStep and HowToSection instructions into steps
+func instructions(v any) []string {
+	var steps []string
+	for _, item := range list(v) {
+		switch item := item.(type) {
+		case string:
+			for _, line := range strings.Split(item, "\n") {
+				if line = strings.TrimSpace(html.UnescapeString(line)); line != "" {
+					steps = append(steps, line)
+				}
+			}
+		case map[string]any:
+			if item["itemListElement"] != nil {
+				steps = append(steps, instructions(item["itemListElement"])...)
+			} else if t := text(item); t != "" {
+				steps = append(steps, t)
+			}
+		}
+	}
+	return steps
+}
@@ -0,0 +1,174 @@
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends plain text emails
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// FromEnv returns an SMTPMailer if SMTP_HOST is set, a FileMailer writing to MAIL_DIR if that is set
+// and nil otherwise
+func FromEnv() Mailer {
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return &SMTPMailer{
+			Addr:     net.JoinHostPort(host, port),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+	}
+	if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		return &FileMailer{Dir: dir}
+	}
+	return nil
+}
+
+// format encodes the message with its headers, header values may not span lines
+func format(from string, msg Message) ([]byte, error) {
+	if strings.ContainsAny(msg.To+msg.Subject+from, "\r\n") {
+		return nil, errors.New("line break in a mail header")
+	}
+	if _, err := mail.ParseAddress(msg.To); err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", msg.To, err)
+	}
+	var b bytes.Buffer
+	if from != "" {
+		fmt.Fprintf(&b, "From: %s\r\n", from)
+	}
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	return b.Bytes(), nil
+}
+
+// SMTPMailer sends mails through an SMTP server, with STARTTLS if the server offers it
+type SMTPMailer struct {
+	// Addr is the host:port of the server
+	Addr string
+	// Username and Password authenticate with PLAIN auth if Username is set
+	Username string
+	Password string
+	From     string
+}
+
+func (m *SMTPMailer) Send(msg Message) error {
+	if m.From == "" {
+		return errors.New("no sender configured, set MAIL_FROM")
+	}
+	data, err := format(m.From, msg)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if m.Username != "" {
+		host, _, _ := net.SplitHostPort(m.Addr)
+		auth = smtp.PlainAuth("", m.Username, m.Password, host)
+	}
+	return smtp.SendMail(m.Addr, auth, m.From, []string{msg.To}, data)
+}
+
+// FileMailer writes every mail to its own .eml file in Dir, meant for development and tests
+type FileMailer struct {
+	Dir string
+	mu  sync.Mutex
+	n   int
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	data, err := format("", msg)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	err = os.MkdirAll(m.Dir, 0o755)
+	if err != nil {
+		return err
+	}
+	m.n++
+	name := fmt.Sprintf("%d-%04d.eml", time.Now().UnixNano(), m.n)
+	return os.WriteFile(filepath.Join(m.Dir, name), data, 0o600)
+}
+
+// Messages reads back the mails in Dir, the oldest first
+func (m *FileMailer) Messages() ([]Message, error) {
+	files, err := filepath.Glob(filepath.Join(m.Dir, "*.eml"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	msgs := []Message{}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := mail.ReadMessage(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		var body bytes.Buffer
+		body.ReadFrom(parsed.Body)
+		subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		msgs = append(msgs, Message{
+			To:      parsed.Header.Get("To"),
+			Subject: subject,
+			Body:    strings.ReplaceAll(body.String(), "\r\n", "\n"),
+		})
+	}
+	return msgs, nil
+}
+
+// NoMailer fails every mail, the tokens in them end up nowhere
+type NoMailer struct{}
+
+func (NoMailer) Send(msg Message) error {
+	return errors.New("no mailer configured, set SMTP_HOST or MAIL_DIR")
+}
+
+// LogMailer logs the mails instead of sending them, only meant for development as the logs then
+// contain live verification and password reset tokens. Mails go to log.Default() if Logger is nil
+type LogMailer struct {
+	Logger *log.Logger
+}
+
+func (m *LogMailer) Send(msg Message) error {
+	l := m.Logger
+	if l == nil {
+		l = log.Default()
+	}
+	l.Printf("Mail to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
@@ -0,0 +1,52 @@
+package policy
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+)
+
+var tables = map[Resource]string{
+	Recipe:     "recipes",
+	Ingredient: "ingredient",
+	Diet:       "diet",
+	Technique:  "technique",
+	Allergen:   "allergen",
+	User:       `"user"`,
+}
+
+// Lookup returns the relation of the user to the object. Every resource can be read by anyone, so a
+// missing object is a 404 for everybody before any permission is checked.
+func Lookup(db *sqlx.DB, res Resource, id string, userID string) (Relation, *error_handler.APIError) {
+	table, ok := tables[res]
+	if !ok {
+		return None, error_handler.New("Unknown resource", http.StatusInternalServerError, fmt.Errorf("unknown resource %s", res))
+	}
+
+	var rel Relation
+	var err error
+	if res == Recipe {
+		err = db.Get(&rel, `SELECT CASE
+				WHEN r.author::text = $2 THEN $3
+				WHEN e.role = 'coauthor' THEN $4
+				WHEN e.role = 'editor' THEN $5
+				ELSE $6 END
+			FROM recipes r
+			LEFT JOIN recipe_editor e ON e.recipe_id = r.id AND e.user_id::text = $2
+			WHERE r.id::text = $1`, id, userID, Owner, Coauthor, Editor, None)
+	} else {
+		err = db.Get(&rel, `SELECT $2::int FROM `+table+` WHERE id::text = $1`, id, None)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return None, error_handler.New(strings.ToUpper(string(res[:1]))+string(res[1:])+" doesn't exist", http.StatusNotFound, err)
+	}
+	if err != nil {
+		return None, error_handler.New("Error checking permissions: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return rel, nil
+}
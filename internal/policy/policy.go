@@ -0,0 +1,108 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/madswillem/recipeApp/internal/user"
+)
+
+type Resource string
+
+const (
+	Recipe     Resource = "recipe"
+	Ingredient Resource = "ingredient"
+	Diet       Resource = "diet"
+	Technique  Resource = "technique"
+	Allergen   Resource = "allergen"
+	User       Resource = "user"
+)
+
+type Action string
+
+const (
+	Create        Action = "create"
+	Update        Action = "update"
+	Delete        Action = "delete"
+	Merge         Action = "merge"
+	ManageEditors Action = "manage_editors"
+	UpdateRole    Action = "update_role"
+)
+
+// Relation is what the subject is to the object, each relation includes the ones before it
+type Relation int
+
+const (
+	None Relation = iota
+	Editor
+	Coauthor
+	Owner
+)
+
+// Rule allows an action to subjects with at least Role, or with at least Relation to the object.
+// An empty Role or a None Relation grants nothing.
+type Rule struct {
+	Role     string
+	Relation Relation
+}
+
+// Policy holds the rule of every action, actions without a rule are denied
+type Policy map[Resource]map[Action]Rule
+
+// Default lets users manage their recipes, moderators curate the recipes and ingredients of
+// others and admins additionally manage diets, allergens and roles
+var Default = Policy{
+	Recipe: {
+		Create:        {Role: user.RoleUser},
+		Update:        {Role: user.RoleModerator, Relation: Editor},
+		Delete:        {Role: user.RoleModerator, Relation: Owner},
+		ManageEditors: {Role: user.RoleAdmin, Relation: Coauthor},
+	},
+	Ingredient: {
+		Create: {Role: user.RoleModerator},
+		Update: {Role: user.RoleModerator},
+		Merge:  {Role: user.RoleAdmin},
+		Delete: {Role: user.RoleAdmin},
+	},
+	Diet: {
+		Create: {Role: user.RoleAdmin},
+		Update: {Role: user.RoleAdmin},
+		Delete: {Role: user.RoleAdmin},
+	},
+	Technique: {
+		Create: {Role: user.RoleModerator},
+		Update: {Role: user.RoleModerator},
+		Delete: {Role: user.RoleAdmin},
+	},
+	Allergen: {
+		Update: {Role: user.RoleAdmin},
+	},
+	User: {
+		UpdateRole: {Role: user.RoleAdmin},
+	},
+}
+
+var roleRank = map[string]int{
+	user.RoleUser:      1,
+	user.RoleModerator: 2,
+	user.RoleAdmin:     3,
+}
+
+// HasRole is true if the role is at least min, unknown roles have none
+func HasRole(role string, min string) bool {
+	r, ok := roleRank[role]
+	m, mok := roleRank[min]
+	return ok && mok && r >= m
+}
+
+// Allowed is true if the rule of the action lets the role with the relation to the object through.
+// It fails for actions without a rule so a route can't silently be open or closed by a typo.
+func (p Policy) Allowed(role string, rel Relation, res Resource, act Action) (bool, error) {
+	rule, ok := p[res][act]
+	if !ok {
+		return false, fmt.Errorf("no rule for %s on %s", act, res)
+	}
+	if rule.Role != "" && HasRole(role, rule.Role) {
+		return true, nil
+	}
+	return rule.Relation != None && rel >= rule.Relation, nil
+}
@@ -0,0 +1,160 @@
+package recipe
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+)
+
+type Allergen struct {
+	ID          string `db:"id" json:"id"`
+	Name        string `db:"name" json:"name"`
+	Description string `db:"description" json:"description,omitempty"`
+	// Categories are the ingredient categories whose ingredients all contain the allergen
+	Categories []string `db:"-" json:"categories,omitempty"`
+}
+
+// GetAllergens returns all allergens with their categories
+func GetAllergens(db *sqlx.DB) ([]Allergen, *error_handler.APIError) {
+	allergens := []Allergen{}
+	err := db.Select(&allergens, `SELECT id, name, description FROM allergen ORDER BY name`)
+	if err != nil {
+		return nil, error_handler.New("Error getting allergens: "+err.Error(), http.StatusInternalServerError, err)
+	}
+
+	var categories []struct {
+		AllergenID string `db:"allergen_id"`
+		Category   string `db:"category"`
+	}
+	err = db.Select(&categories, `SELECT allergen_id, category FROM category_allergen ORDER BY LOWER(category)`)
+	if err != nil {
+		return nil, error_handler.New("Error getting allergen categories: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	byID := make(map[string]*Allergen, len(allergens))
+	for i := range allergens {
+		byID[allergens[i].ID] = &allergens[i]
+	}
+	for _, c := range categories {
+		if a, ok := byID[c.AllergenID]; ok {
+			a.Categories = append(a.Categories, c.Category)
+		}
+	}
+	return allergens, nil
+}
+
+// CheckAllergens returns a 400 naming the ids that aren't allergens
+func CheckAllergens(ids []string, db sqlx.Queryer) *error_handler.APIError {
+	if len(ids) == 0 {
+		return nil
+	}
+	unknown := []string{}
+	err := sqlx.Select(db, &unknown, `SELECT a FROM unnest($1::text[]) a WHERE NOT EXISTS (SELECT 1 FROM allergen WHERE id = a) ORDER BY a`, pq.Array(ids))
+	if err != nil {
+		return error_handler.New("Error checking allergens: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	if len(unknown) > 0 {
+		msg := "Unknown allergens " + strings.Join(unknown, ", ")
+		return error_handler.New(msg, http.StatusBadRequest, errors.New(strings.ToLower(msg)))
+	}
+	return nil
+}
+
+// SetAllergenCategories replaces the categories whose ingredients contain the allergen
+func SetAllergenCategories(id string, categories []string, db *sqlx.DB) *error_handler.APIError {
+	apiErr := CheckAllergens([]string{id}, db)
+	if apiErr != nil {
+		apiErr.Code = http.StatusNotFound
+		return apiErr
+	}
+
+	tx := db.MustBegin()
+	_, err := tx.Exec(`DELETE FROM category_allergen WHERE allergen_id = $1`, id)
+	if err != nil {
+		tx.Rollback()
+		return error_handler.New("Error removing allergen categories: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	for _, category := range categories {
+		_, err = tx.Exec(`INSERT INTO category_allergen (category, allergen_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+			strings.TrimSpace(category), id)
+		if err != nil {
+			tx.Rollback()
+			return ingredientError("Error adding category "+category, err)
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return error_handler.New("Error saving allergen categories: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// setIngredientAllergens replaces the allergens linked to the ingredient directly
+func setIngredientAllergens(tx *sqlx.Tx, ingredientID string, allergens []string) *error_handler.APIError {
+	apiErr := CheckAllergens(allergens, tx)
+	if apiErr != nil {
+		return apiErr
+	}
+	_, err := tx.Exec(`DELETE FROM ingredient_allergen WHERE ingredient_id = $1`, ingredientID)
+	if err != nil {
+		return ingredientError("Error removing allergens", err)
+	}
+	for _, a := range allergens {
+		_, err = tx.Exec(`INSERT INTO ingredient_allergen (ingredient_id, allergen_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, ingredientID, a)
+		if err != nil {
+			return ingredientError("Error adding allergen "+a, err)
+		}
+	}
+	return nil
+}
+
+// loadAllergens sets the allergens of the recipes, derived from their ingredients and their categories
+func loadAllergens(recipes map[string]*RecipeSchema, db *sqlx.DB) *error_handler.APIError {
+	if len(recipes) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(recipes))
+	for id := range recipes {
+		ids = append(ids, id)
+	}
+	var rows []struct {
+		RecipeID string `db:"recipe_id"`
+		Allergen
+	}
+	err := db.Select(&rows, `SELECT ra.recipe_id, a.id, a.name, a.description
+		FROM recipe_allergen ra
+		JOIN allergen a ON a.id = ra.allergen_id
+		WHERE ra.recipe_id::text = ANY($1)
+		ORDER BY a.name`, pq.Array(ids))
+	if err != nil {
+		return error_handler.New("Error getting allergens: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	for _, r := range recipes {
+		r.Allergens = []Allergen{}
+	}
+	for _, row := range rows {
+		if r, ok := recipes[row.RecipeID]; ok {
+			r.Allergens = append(r.Allergens, row.Allergen)
+		}
+	}
+	return nil
+}
+
+// ContainsAny returns the ids of the given allergens the recipe contains, sorted
+func (recipe *RecipeSchema) ContainsAny(allergens []string) []string {
+	found := []string{}
+	for _, a := range recipe.Allergens {
+		for _, id := range allergens {
+			if a.ID == id {
+				found = append(found, id)
+				break
+			}
+		}
+	}
+	sort.Strings(found)
+	return found
+}
@@ -1,10 +1,15 @@
 package recipe
 
 import (
+	"database/sql"
 	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/madswillem/recipeApp/internal/database"
 	"github.com/madswillem/recipeApp/internal/error_handler"
 )
 
@@ -15,18 +20,328 @@ func NewDietRepo() *DietRepository {
 	return &DietRepository{}
 }
 
-func (dr *DietRepository) Create(diet *DietSchema, recipeid string, db *sqlx.Tx) *error_handler.APIError {
+func (dr *DietRepository) Create(diet *DietSchema, recipeid string, db database.SQLDB) *error_handler.APIError {
 	var exists bool
-	err := db.Get(&exists, "SELECT EXISTS (SELECT 1 FROM diet WHERE id = $1);", diet.ID)
+	err := db.Get(&exists, "SELECT EXISTS (SELECT 1 FROM diet WHERE id::text = $1);", diet.ID)
 	if err != nil {
 		return error_handler.New("error while checking if diet exists", http.StatusInternalServerError, err)
 	}
 	if !exists {
 		return error_handler.New("couldn't find diet "+diet.ID, http.StatusNotFound, errors.New("couldn't find diet "+diet.ID))
 	}
-	_, err = db.Exec("INSERT INTO rel_diet_recipe (recipe_id, diet_id) VALUES ($1, $2)", recipeid, diet.ID)
+	_, err = db.Exec("INSERT INTO rel_diet_recipe (recipe_id, diet_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", recipeid, diet.ID)
 	if err != nil {
 		return error_handler.New("error while inserting the relationship between diet and recipe", http.StatusInternalServerError, err)
 	}
 	return nil
-}
\ No newline at end of file
+}
+
+// Replace replaces the diets the recipe is tagged with
+func (dr *DietRepository) Replace(diets []DietSchema, recipeid string, db database.SQLDB) *error_handler.APIError {
+	_, err := db.Exec("DELETE FROM rel_diet_recipe WHERE recipe_id = $1", recipeid)
+	if err != nil {
+		return error_handler.New("error while removing the diets of the recipe", http.StatusInternalServerError, err)
+	}
+	for i := range diets {
+		apiErr := dr.Create(&diets[i], recipeid, db)
+		if apiErr != nil {
+			return apiErr
+		}
+	}
+	return nil
+}
+
+// CheckConflicts returns a 422 if the recipe is tagged with a diet that excludes one of its ingredients
+func (dr *DietRepository) CheckConflicts(recipeid string, db database.SQLDB) *error_handler.APIError {
+	conflicts, apiErr := dietConflicts([]string{recipeid}, db)
+	if apiErr != nil {
+		return apiErr
+	}
+	if len(conflicts[recipeid]) == 0 {
+		return nil
+	}
+	conflictErr := &DietConflictError{Conflicts: conflicts[recipeid]}
+	return error_handler.New("Recipe contradicts its diets: "+conflictErr.Error(), http.StatusUnprocessableEntity, conflictErr)
+}
+
+// dietConflicts returns the conflicting diets of the recipes by recipe id
+func dietConflicts(recipeIDs []string, db database.SQLDB) (map[string][]DietConflict, *error_handler.APIError) {
+	var rows []struct {
+		RecipeID   string `db:"recipe_id"`
+		DietID     string `db:"diet_id"`
+		Diet       string `db:"diet"`
+		Ingredient string `db:"ingredient"`
+	}
+	err := db.Select(&rows, `SELECT DISTINCT c.recipe_id, c.diet_id, diet.name AS diet, c.ingredient
+		FROM diet_conflict c
+		JOIN diet ON diet.id = c.diet_id
+		WHERE c.recipe_id::text = ANY($1)
+		ORDER BY c.recipe_id, diet.name, c.diet_id, c.ingredient`, pq.Array(recipeIDs))
+	if err != nil {
+		return nil, error_handler.New("Error checking diets: "+err.Error(), http.StatusInternalServerError, err)
+	}
+
+	conflicts := map[string][]DietConflict{}
+	for _, row := range rows {
+		list := conflicts[row.RecipeID]
+		if len(list) == 0 || list[len(list)-1].DietID != row.DietID {
+			list = append(list, DietConflict{DietID: row.DietID, Diet: row.Diet})
+		}
+		last := &list[len(list)-1]
+		last.Ingredients = append(last.Ingredients, row.Ingredient)
+		conflicts[row.RecipeID] = list
+	}
+	return conflicts, nil
+}
+
+// loadDiets sets the explicit and inferred diets of the recipes, explicit ones that exclude
+// some of the ingredients list them as conflicts
+func loadDiets(recipes map[string]*RecipeSchema, db *sqlx.DB) *error_handler.APIError {
+	if len(recipes) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(recipes))
+	for id := range recipes {
+		ids = append(ids, id)
+	}
+	var rows []struct {
+		RecipeID string `db:"recipe_id"`
+		DietSchema
+	}
+	err := db.Select(&rows, `SELECT rd.recipe_id, rd.inferred, diet.*
+		FROM recipe_diet rd
+		JOIN diet ON diet.id = rd.diet_id
+		WHERE rd.recipe_id::text = ANY($1)
+		ORDER BY diet.name, diet.id`, pq.Array(ids))
+	if err != nil {
+		return error_handler.New("Error while getting diets: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	conflicts, apiErr := dietConflicts(ids, db)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	for _, r := range recipes {
+		r.Diet = []DietSchema{}
+	}
+	for _, row := range rows {
+		r, ok := recipes[row.RecipeID]
+		if !ok {
+			continue
+		}
+		for _, c := range conflicts[row.RecipeID] {
+			if c.DietID == row.ID {
+				row.Conflicts = c.Ingredients
+			}
+		}
+		r.Diet = append(r.Diet, row.DietSchema)
+	}
+	return nil
+}
+
+type DietDBRepository interface {
+	List() ([]DietSchema, *error_handler.APIError)
+	GetByID(id string) (*DietSchema, *error_handler.APIError)
+	Create(diet *DietSchema) *error_handler.APIError
+	Update(id string, diet *DietSchema) *error_handler.APIError
+	Delete(id string) *error_handler.APIError
+}
+
+// DietDBRepo manages the diets themselves, DietRepository tags recipes with them
+type DietDBRepo struct {
+	DB *sqlx.DB
+}
+
+func NewDietDBRepo(db *sqlx.DB) *DietDBRepo {
+	return &DietDBRepo{DB: db}
+}
+
+// dietError maps database errors to the matching status
+func dietError(msg string, err error) *error_handler.APIError {
+	if errors.Is(err, sql.ErrNoRows) {
+		return error_handler.New("Diet doesn't exist", http.StatusNotFound, err)
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "22P02": // invalid_text_representation, the id isn't a uuid
+			return error_handler.New("Diet doesn't exist", http.StatusNotFound, err)
+		case "23505": // unique_violation
+			return error_handler.New("The diet excludes a category twice", http.StatusConflict, err)
+		case "23514": // check_violation
+			return error_handler.New(msg+": "+pqErr.Message, http.StatusBadRequest, err)
+		}
+	}
+	return error_handler.New(msg+": "+err.Error(), http.StatusInternalServerError, err)
+}
+
+// loadCategories sets the excluded categories of the diets
+func loadCategories(diets []DietSchema, db *sqlx.DB) *error_handler.APIError {
+	if len(diets) == 0 {
+		return nil
+	}
+	ids := make([]string, len(diets))
+	byID := make(map[string]*DietSchema, len(diets))
+	for i := range diets {
+		ids[i] = diets[i].ID
+		byID[diets[i].ID] = &diets[i]
+		diets[i].ExIngCategory = []Category{}
+	}
+	var rows []struct {
+		DietID string `db:"diet_id"`
+		Category
+	}
+	err := db.Select(&rows, `SELECT diet_id, id, name FROM diet_excluded_category
+		WHERE diet_id::text = ANY($1) ORDER BY LOWER(name)`, pq.Array(ids))
+	if err != nil {
+		return dietError("Error getting excluded categories", err)
+	}
+	for _, row := range rows {
+		if d, ok := byID[row.DietID]; ok {
+			d.ExIngCategory = append(d.ExIngCategory, row.Category)
+		}
+	}
+	return nil
+}
+
+// checkDuplicate returns a 409 if another diet has the name
+func (dr *DietDBRepo) checkDuplicate(name string, exceptID string) *error_handler.APIError {
+	var exists bool
+	err := dr.DB.Get(&exists, `SELECT EXISTS (SELECT 1 FROM diet WHERE LOWER(name) = LOWER($1) AND id::text <> $2)`, name, exceptID)
+	if err != nil {
+		return dietError("Error checking diet", err)
+	}
+	if exists {
+		return error_handler.New("A diet named "+name+" already exists", http.StatusConflict, errors.New("duplicate diet"))
+	}
+	return nil
+}
+
+func setCategories(tx *sqlx.Tx, dietID string, categories []Category) *error_handler.APIError {
+	_, err := tx.Exec(`DELETE FROM diet_excluded_category WHERE diet_id = $1`, dietID)
+	if err != nil {
+		return dietError("Error removing excluded categories", err)
+	}
+	for _, c := range categories {
+		_, err = tx.Exec(`INSERT INTO diet_excluded_category (diet_id, name) VALUES ($1, $2)`, dietID, strings.TrimSpace(c.Name))
+		if err != nil {
+			return dietError("Error adding excluded category "+c.Name, err)
+		}
+	}
+	return nil
+}
+
+func (dr *DietDBRepo) List() ([]DietSchema, *error_handler.APIError) {
+	diets := []DietSchema{}
+	err := dr.DB.Select(&diets, `SELECT * FROM diet ORDER BY LOWER(name), id`)
+	if err != nil {
+		return nil, dietError("Error getting diets", err)
+	}
+	apiErr := loadCategories(diets, dr.DB)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return diets, nil
+}
+
+func (dr *DietDBRepo) GetByID(id string) (*DietSchema, *error_handler.APIError) {
+	diets := make([]DietSchema, 1)
+	err := dr.DB.Get(&diets[0], `SELECT * FROM diet WHERE id = $1`, id)
+	if err != nil {
+		return nil, dietError("Error getting diet", err)
+	}
+	apiErr := loadCategories(diets, dr.DB)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return &diets[0], nil
+}
+
+func (dr *DietDBRepo) Create(diet *DietSchema) *error_handler.APIError {
+	diet.Name = strings.TrimSpace(diet.Name)
+	if diet.Name == "" {
+		return error_handler.New("missing diet name", http.StatusBadRequest, errors.New("missing name"))
+	}
+	apiErr := dr.checkDuplicate(diet.Name, "")
+	if apiErr != nil {
+		return apiErr
+	}
+
+	tx := dr.DB.MustBegin()
+	err := tx.Get(diet, `INSERT INTO diet (name, description) VALUES ($1, $2) RETURNING id, created_at, name, description`,
+		diet.Name, diet.Description)
+	if err != nil {
+		tx.Rollback()
+		return dietError("Error creating diet", err)
+	}
+	apiErr = setCategories(tx, diet.ID, diet.ExIngCategory)
+	if apiErr != nil {
+		tx.Rollback()
+		return apiErr
+	}
+	err = tx.Commit()
+	if err != nil {
+		return dietError("Error creating diet", err)
+	}
+	return nil
+}
+
+// Update changes the given fields, the excluded categories replace the existing ones if they are set.
+// Recipes tagged with the diet may contradict it afterwards, they list the ingredients as conflicts.
+func (dr *DietDBRepo) Update(id string, diet *DietSchema) *error_handler.APIError {
+	var setParts []string
+	var args []interface{}
+	if strings.TrimSpace(diet.Name) != "" {
+		diet.Name = strings.TrimSpace(diet.Name)
+		apiErr := dr.checkDuplicate(diet.Name, id)
+		if apiErr != nil {
+			return apiErr
+		}
+		args = append(args, diet.Name)
+		setParts = append(setParts, "name = $"+strconv.Itoa(len(args)))
+	}
+	if diet.Description != "" {
+		args = append(args, diet.Description)
+		setParts = append(setParts, "description = $"+strconv.Itoa(len(args)))
+	}
+	if len(setParts) == 0 && diet.ExIngCategory == nil {
+		return error_handler.New("Nothing to update", http.StatusExpectationFailed, errors.New("nothing to update"))
+	}
+
+	tx := dr.DB.MustBegin()
+	args = append(args, id)
+	query := `SELECT id FROM diet WHERE id = $` + strconv.Itoa(len(args)) + ` FOR UPDATE`
+	if len(setParts) > 0 {
+		query = `UPDATE diet SET ` + strings.Join(setParts, ", ") + ` WHERE id = $` + strconv.Itoa(len(args)) + ` RETURNING id`
+	}
+	var found string
+	err := tx.Get(&found, query, args...)
+	if err != nil {
+		tx.Rollback()
+		return dietError("Error updating diet", err)
+	}
+	if diet.ExIngCategory != nil {
+		apiErr := setCategories(tx, id, diet.ExIngCategory)
+		if apiErr != nil {
+			tx.Rollback()
+			return apiErr
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		return dietError("Error updating diet", err)
+	}
+	return nil
+}
+
+// Delete deletes the diet, recipes and users lose it
+func (dr *DietDBRepo) Delete(id string) *error_handler.APIError {
+	res, err := dr.DB.Exec(`DELETE FROM diet WHERE id = $1`, id)
+	if err != nil {
+		return dietError("Error deleting diet", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return dietError("Error deleting diet", sql.ErrNoRows)
+	}
+	return nil
+}
@@ -1,11 +1,43 @@
 package recipe
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 type DietSchema struct {
-	ID            string     `db:"id" json:"id"`
-	CreatedAt     time.Time  `db:"created_at" json:"created_at"`
-	Name          string     `db:"name" json:"name"`
-	Description   string     `db:"description" json:"description"`
+	ID          string    `db:"id" json:"id"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	Name        string    `db:"name" json:"name"`
+	Description string    `db:"description" json:"description"`
+	// ExIngCategory are the ingredient categories the diet excludes
 	ExIngCategory []Category `json:"exingcategory"`
+	// Inferred is set if the recipe isn't tagged with the diet but none of its ingredients is excluded
+	Inferred bool `db:"inferred" json:"inferred,omitempty"`
+	// Conflicts are the ingredients of a tagged recipe the diet excludes
+	Conflicts []string `db:"-" json:"conflicts,omitempty"`
+}
+
+type DietConflict struct {
+	DietID      string   `json:"diet_id"`
+	Diet        string   `json:"diet"`
+	Ingredients []string `json:"ingredients"`
+}
+
+// DietConflictError lists the diets a recipe is tagged with that exclude some of its ingredients
+type DietConflictError struct {
+	Conflicts []DietConflict
+}
+
+func (e *DietConflictError) Error() string {
+	parts := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		parts[i] = fmt.Sprintf("%s excludes %s", c.Diet, strings.Join(c.Ingredients, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func (e *DietConflictError) Details() any {
+	return map[string][]DietConflict{"diet_conflicts": e.Conflicts}
 }
@@ -0,0 +1,90 @@
+package recipe
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+)
+
+const (
+	EditorRoleEditor   = "editor"
+	EditorRoleCoauthor = "coauthor"
+)
+
+// Editor is a user the author shares a recipe with. Editors may change it, co-authors may also manage its editors.
+type Editor struct {
+	UserID    string    `db:"user_id" json:"user_id"`
+	Email     string    `db:"email" json:"email"`
+	Role      string    `db:"role" json:"role"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+func GetEditors(recipeID string, db database.SQLDB) ([]Editor, *error_handler.APIError) {
+	editors := []Editor{}
+	err := db.Select(&editors, `SELECT e.user_id, COALESCE(u.email, '') AS email, e.role, e.created_at
+		FROM recipe_editor e
+		JOIN "user" u ON u.id = e.user_id
+		WHERE e.recipe_id::text = $1
+		ORDER BY e.created_at, u.email`, recipeID)
+	if err != nil {
+		return nil, error_handler.New("Error getting editors: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return editors, nil
+}
+
+// AddEditor shares the recipe with the user, the role of an existing editor is changed. Users are
+// given by id, looking them up by email would tell anyone with a recipe which emails have an account.
+func AddEditor(recipeID string, userID string, role string, db database.SQLDB) (*Editor, *error_handler.APIError) {
+	if role == "" {
+		role = EditorRoleEditor
+	}
+	if role != EditorRoleEditor && role != EditorRoleCoauthor {
+		return nil, error_handler.New(`role has to be "editor" or "coauthor"`, http.StatusBadRequest, errors.New("invalid editor role"))
+	}
+
+	var u struct {
+		ID       string `db:"id"`
+		IsAuthor bool   `db:"is_author"`
+	}
+	err := db.Get(&u, `SELECT u.id, u.id = r.author AS is_author
+		FROM "user" u, recipes r
+		WHERE u.id::text = $1 AND r.id::text = $2`, strings.TrimSpace(userID), recipeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, error_handler.New("User doesn't exist", http.StatusNotFound, err)
+	}
+	if err != nil {
+		return nil, error_handler.New("Error getting user: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	if u.IsAuthor {
+		return nil, error_handler.New("The author can't be an editor", http.StatusBadRequest, errors.New("author as editor"))
+	}
+
+	editor := &Editor{}
+	err = db.Get(editor, `WITH e AS (
+			INSERT INTO recipe_editor (recipe_id, user_id, role) VALUES ($1, $2, $3)
+			ON CONFLICT (recipe_id, user_id) DO UPDATE SET role = EXCLUDED.role
+			RETURNING user_id, role, created_at
+		)
+		SELECT e.user_id, COALESCE(u.email, '') AS email, e.role, e.created_at FROM e JOIN "user" u ON u.id = e.user_id`,
+		recipeID, u.ID, role)
+	if err != nil {
+		return nil, error_handler.New("Error adding editor: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return editor, nil
+}
+
+func RemoveEditor(recipeID string, userID string, db database.SQLDB) *error_handler.APIError {
+	result, err := db.Exec(`DELETE FROM recipe_editor WHERE recipe_id::text = $1 AND user_id::text = $2`, recipeID, userID)
+	if err != nil {
+		return error_handler.New("Error removing editor: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return error_handler.New("Editor doesn't exist", http.StatusNotFound, errors.New("editor not found"))
+	}
+	return nil
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/madswillem/recipeApp/internal/database"
 	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/units"
 )
 
 type IngredientRepository struct {
@@ -19,18 +20,27 @@ func NewIngredientRepo() *IngredientRepository {
 }
 
 func (ir *IngredientRepository) Create(ingredient *IngredientsSchema, db database.SQLDB) *error_handler.APIError {
-	var err *error_handler.APIError
-	ingredient.IngredientID, err = GetIngIDByName(ingredient.Name, db)
-	if err != nil {
-		return err
+	if ingredient.IngredientID == "" {
+		id, err := GetIngIDByName(ingredient.Name, db)
+		if err != nil && err.Code == http.StatusNotFound {
+			return unknownIngredients([]string{ingredient.Name}, db)
+		}
+		if err != nil {
+			return err
+		}
+		ingredient.IngredientID = id
 	}
 
 	query := `INSERT INTO recipe_ingredient
-    (recipe_id, ingredient_id, amount, unit)
+    (recipe_id, ingredient_id, amount, amount_max, unit, note)
     VALUES
-    (:recipe_id, :ingredient_id, :amount, :unit)`
+    (:recipe_id, :ingredient_id, :amount, :amount_max, :unit, :note) RETURNING id`
 
-	_, db_err := db.NamedExec(query, &ingredient)
+	stmt, db_err := db.PrepareNamed(query)
+	if db_err == nil {
+		db_err = stmt.Get(&ingredient.ID, ingredient)
+		stmt.Close()
+	}
 	if db_err != nil {
 		return error_handler.New("Error creating "+ingredient.Name+": "+db_err.Error(), http.StatusInternalServerError, db_err)
 	}
@@ -46,11 +56,25 @@ func (ir *IngredientRepository) Update(id string, recipe_id string, ingredient *
 		setParts = append(setParts, "ingredient_id = $"+strconv.Itoa(len(args)+1))
 		args = append(args, ingredient.IngredientID)
 	}
+	if ingredient.Amount < 0 {
+		return error_handler.New("amount can't be negative", http.StatusBadRequest, errors.New("negative amount"))
+	}
 	if ingredient.Amount != 0 {
 		setParts = append(setParts, "amount = $"+strconv.Itoa(len(args)+1))
 		args = append(args, ingredient.Amount)
 	}
+	if ingredient.AmountMax != nil {
+		setParts = append(setParts, "amount_max = $"+strconv.Itoa(len(args)+1))
+		args = append(args, ingredient.AmountMax)
+	}
+	if ingredient.Note != "" {
+		setParts = append(setParts, "note = $"+strconv.Itoa(len(args)+1))
+		args = append(args, ingredient.Note)
+	}
 	if ingredient.Unit != "" {
+		if _, ok := units.Lookup(ingredient.Unit); !ok {
+			return error_handler.New("unknown unit "+ingredient.Unit, http.StatusBadRequest, errors.New("unknown unit"))
+		}
 		setParts = append(setParts, "unit = $"+strconv.Itoa(len(args)+1))
 		args = append(args, ingredient.Unit)
 	}
@@ -2,21 +2,30 @@ package recipe
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/jmoiron/sqlx"
-	"github.com/madswillem/recipeApp/internal/database"
 	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/units"
 )
 
 type IngredientDB struct {
-	ID               string           `db:"id" json:"id"`
-	CreatedAt        time.Time        `db:"created_at" json:"created_at"`
-	Name             string           `db:"name" json:"name"`
-	StandardUnit     string           `db:"standard_unit" json:"standard_unit,omitempty"`
-	NdbNumber        int64            `db:"ndb_number" json:"ndb_number,omitempty"`
-	Category         string           `db:"category" json:"category,omitempty"`
-	FdicID           int64            `db:"fdic_id" json:"fdic_id,omitempty"`
+	ID           string    `db:"id" json:"id"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	Name         string    `db:"name" json:"name"`
+	StandardUnit string    `db:"standard_unit" json:"standard_unit,omitempty"`
+	NdbNumber    int64     `db:"ndb_number" json:"ndb_number,omitempty"`
+	Category     string    `db:"category" json:"category,omitempty"`
+	FdicID       int64     `db:"fdic_id" json:"fdic_id,omitempty"`
+	// Density in g per ml, water is assumed if it is nil
+	Density *float64 `db:"density" json:"density,omitempty"`
+	// PieceWeights maps count units like piece or large to their weight in g
+	PieceWeights map[string]float64 `db:"-" json:"piece_weights,omitempty"`
+	// Aliases are other names the ingredient is found by, like parmesan for parmesan cheese
+	Aliases []string `db:"-" json:"aliases,omitempty"`
+	// Allergens are the ids of the allergens linked to the ingredient itself, not through its category
+	Allergens        []string         `db:"-" json:"allergens,omitempty"`
 	NutritionalValue NutritionalValue `json:"nv"`
 	Rating           RatingStruct     `json:"rating"`
 }
@@ -28,8 +37,8 @@ type Category struct {
 func (ingredient *IngredientDB) Create(db *sqlx.DB) *error_handler.APIError {
 	tx := db.MustBegin()
 	// Create ingredient
-	query := `INSERT INTO ingredient (name, standard_unit, ndb_number, category, fdic_id)
-              VALUES (:name, :standard_unit, :ndb_number, :category, :fdic_id) RETURNING id`
+	query := `INSERT INTO ingredient (name, standard_unit, ndb_number, category, fdic_id, density)
+              VALUES (:name, :standard_unit, :ndb_number, :category, :fdic_id, :density) RETURNING id`
 	stmt, err := tx.PrepareNamed(query)
 	if err != nil {
 		return error_handler.New("Query error: "+err.Error(), http.StatusInternalServerError, err)
@@ -38,7 +47,28 @@ func (ingredient *IngredientDB) Create(db *sqlx.DB) *error_handler.APIError {
 	stmt.Close()
 	if err != nil {
 		tx.Rollback()
-		return error_handler.New("Dtabase error: "+err.Error(), http.StatusInternalServerError, err)
+		return ingredientError("Dtabase error", err)
+	}
+	for unit, grams := range ingredient.PieceWeights {
+		_, err = tx.Exec(`INSERT INTO ingredient_piece_weight (ingredient_id, unit, grams) VALUES ($1, $2, $3)`,
+			ingredient.ID, units.Normalize(unit), grams)
+		if err != nil {
+			tx.Rollback()
+			return ingredientError("Error inserting piece weight", err)
+		}
+	}
+	for _, alias := range ingredient.Aliases {
+		_, err = tx.Exec(`INSERT INTO ingredient_alias (ingredient_id, alias) VALUES ($1, $2)`,
+			ingredient.ID, strings.TrimSpace(alias))
+		if err != nil {
+			tx.Rollback()
+			return ingredientError("Error inserting alias "+alias, err)
+		}
+	}
+	apiErr := setIngredientAllergens(tx, ingredient.ID, ingredient.Allergens)
+	if apiErr != nil {
+		tx.Rollback()
+		return apiErr
 	}
 
 	// Create Rating
@@ -60,12 +90,28 @@ func (ingredient *IngredientDB) Create(db *sqlx.DB) *error_handler.APIError {
 	return nil
 }
 
-func GetIngIDByName(name string, db database.SQLDB) (string, *error_handler.APIError) {
-	var id string
-	err := db.QueryRow("SELECT id FROM ingredient WHERE LOWER(name) = LOWER($1)", name).Scan(&id)
-	if err != nil {
-		return "", error_handler.New("database error getting "+name+" : "+err.Error(), http.StatusInternalServerError, err)
+// Grams converts an amount of the ingredient into grams using its density and piece weights.
+// Counted amounts fall back to the weight of one piece, ok is false if the weight is unknown.
+func (ingredient *IngredientDB) Grams(amount float64, unit string) (float64, bool) {
+	u, ok := units.Lookup(unit)
+	if !ok {
+		return 0, false
 	}
-
-	return id, nil
+	switch u.Kind {
+	case units.Mass:
+		return amount * u.Base, true
+	case units.Volume:
+		density := 1.0
+		if ingredient.Density != nil {
+			density = *ingredient.Density
+		}
+		return amount * u.Base * density, true
+	}
+	if w, ok := ingredient.PieceWeights[u.Name]; ok {
+		return amount * w, true
+	}
+	if w, ok := ingredient.PieceWeights["piece"]; ok {
+		return amount * w, true
+	}
+	return 0, false
 }
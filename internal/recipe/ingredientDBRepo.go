@@ -0,0 +1,422 @@
+package recipe
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/units"
+)
+
+// IngredientDBRepository manages the ingredient table, IngredientRepository the ingredients of recipes
+type IngredientDBRepository interface {
+	List(limit int, cursor string) (*IngredientPage, *error_handler.APIError)
+	Search(q string, limit int) ([]IngredientMatch, *error_handler.APIError)
+	GetByID(id string) (*IngredientDB, *error_handler.APIError)
+	Create(ingredient *IngredientDB) *error_handler.APIError
+	Update(id string, ingredient *IngredientDB) *error_handler.APIError
+	Merge(intoID string, fromID string) ([]string, *error_handler.APIError)
+	Delete(id string) *error_handler.APIError
+}
+
+type IngredientDBRepo struct {
+	DB *sqlx.DB
+}
+
+func NewIngredientDBRepo(db *sqlx.DB) *IngredientDBRepo {
+	return &IngredientDBRepo{DB: db}
+}
+
+type IngredientPage struct {
+	Ingredients []IngredientDB `json:"ingredients"`
+	NextCursor  string         `json:"next_cursor,omitempty"`
+	Total       int            `json:"total"`
+}
+
+const ingredientColumns = `ingredient.id, ingredient.created_at, ingredient.name,
+	COALESCE(ingredient.standard_unit, '') AS standard_unit, COALESCE(ingredient.ndb_number, 0) AS ndb_number,
+	COALESCE(ingredient.category, '') AS category, COALESCE(ingredient.fdic_id, 0) AS fdic_id, ingredient.density`
+
+// ingredientCursorSort is the sort stored in ingredient cursors, they are always sorted by name
+const ingredientCursorSort = "name"
+
+// ingredientError turns database errors about an ingredient into API errors with a fitting status
+func ingredientError(msg string, err error) *error_handler.APIError {
+	if errors.Is(err, sql.ErrNoRows) {
+		return error_handler.New("Ingredient doesn't exist", http.StatusNotFound, err)
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "22P02": // invalid_text_representation, the id isn't a uuid
+			return error_handler.New("Ingredient doesn't exist", http.StatusNotFound, err)
+		case "23505": // unique_violation
+			return error_handler.New("An ingredient or alias with this name already exists", http.StatusConflict, err)
+		case "23503": // foreign_key_violation
+			return error_handler.New("Ingredient is still used by recipes, merge it instead", http.StatusConflict, err)
+		case "23514": // check_violation
+			return error_handler.New(msg+": "+pqErr.Message, http.StatusBadRequest, err)
+		}
+	}
+	return error_handler.New(msg+": "+err.Error(), http.StatusInternalServerError, err)
+}
+
+func (ingredient *IngredientDB) validate() *error_handler.APIError {
+	ingredient.Name = strings.TrimSpace(ingredient.Name)
+	if ingredient.Name == "" {
+		return error_handler.New("missing ingredient name", http.StatusBadRequest, errors.New("missing name"))
+	}
+	if ingredient.StandardUnit != "" {
+		if _, ok := units.Lookup(ingredient.StandardUnit); !ok {
+			return error_handler.New("unknown unit "+ingredient.StandardUnit, http.StatusBadRequest, errors.New("unknown unit"))
+		}
+		ingredient.StandardUnit = units.Normalize(ingredient.StandardUnit)
+	}
+	if ingredient.Density != nil && *ingredient.Density <= 0 {
+		return error_handler.New("density has to be positive", http.StatusBadRequest, errors.New("invalid density"))
+	}
+	for unit, grams := range ingredient.PieceWeights {
+		if grams <= 0 {
+			return error_handler.New("weight of "+unit+" has to be positive", http.StatusBadRequest, errors.New("invalid piece weight"))
+		}
+	}
+	return nil
+}
+
+// checkDuplicate returns a 409 if another ingredient is already found by the name
+func checkDuplicate(name string, id string, db database.SQLDB) *error_handler.APIError {
+	existing, err := GetIngIDByName(name, db)
+	if err != nil && err.Code == http.StatusNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing == id {
+		return nil
+	}
+	return error_handler.New(fmt.Sprintf("Ingredient %s already exists as %s", name, existing), http.StatusConflict, errors.New("duplicate ingredient"))
+}
+
+func (ir *IngredientDBRepo) List(limit int, cursorString string) (*IngredientPage, *error_handler.APIError) {
+	if limit == 0 {
+		limit = DefaultPageLimit
+	}
+	if limit < 0 || limit > MaxPageLimit {
+		return nil, error_handler.New(fmt.Sprintf("limit has to be between 1 and %d", MaxPageLimit), http.StatusBadRequest, errors.New("invalid limit"))
+	}
+
+	page := &IngredientPage{Ingredients: []IngredientDB{}}
+	err := ir.DB.Get(&page.Total, `SELECT COUNT(*) FROM ingredient`)
+	if err != nil {
+		return nil, ingredientError("Error counting ingredients", err)
+	}
+
+	where, args := "", []interface{}{}
+	if cursorString != "" {
+		c, apiErr := decodeCursor(cursorString, ingredientCursorSort)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		where = `WHERE (LOWER(ingredient.name), ingredient.id) > ($1, $2::uuid)`
+		args = append(args, c.Value, c.ID)
+	}
+	args = append(args, limit+1)
+	err = ir.DB.Select(&page.Ingredients, `SELECT `+ingredientColumns+` FROM ingredient `+where+`
+		ORDER BY LOWER(ingredient.name), ingredient.id LIMIT $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, ingredientError("Error getting ingredients", err)
+	}
+
+	if len(page.Ingredients) > limit {
+		page.Ingredients = page.Ingredients[:limit]
+		last := page.Ingredients[limit-1]
+		page.NextCursor = (&cursor{Sort: ingredientCursorSort, Value: strings.ToLower(last.Name), ID: last.ID}).encode()
+	}
+	return page, nil
+}
+
+// Search finds ingredients for autocompletion, names and aliases starting with q come first,
+// then the ones with a similar name.
+func (ir *IngredientDBRepo) Search(q string, limit int) ([]IngredientMatch, *error_handler.APIError) {
+	q = strings.ToLower(strings.TrimSpace(q))
+	if q == "" {
+		return nil, error_handler.New("missing search query", http.StatusBadRequest, errors.New("missing query"))
+	}
+	if limit <= 0 || limit > MaxPageLimit {
+		limit = DefaultPageLimit
+	}
+	prefix := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(q) + "%"
+
+	matches := []IngredientMatch{}
+	err := ir.DB.Select(&matches, `SELECT i.id, i.name, MAX(GREATEST(
+				similarity(LOWER(i.name), $1), word_similarity($1, LOWER(i.name)),
+				COALESCE(similarity(LOWER(a.alias), $1), 0), COALESCE(word_similarity($1, LOWER(a.alias)), 0)
+			)) AS score
+			FROM ingredient i
+			LEFT JOIN ingredient_alias a ON a.ingredient_id = i.id
+			WHERE LOWER(i.name) LIKE $2 OR LOWER(a.alias) LIKE $2
+				OR LOWER(i.name) % $1 OR $1 <% LOWER(i.name) OR LOWER(a.alias) % $1 OR $1 <% LOWER(a.alias)
+			GROUP BY i.id, i.name
+			ORDER BY BOOL_OR(LOWER(i.name) LIKE $2 OR LOWER(a.alias) LIKE $2) DESC, score DESC, i.name
+			LIMIT $3`, q, prefix, limit)
+	if err != nil {
+		return nil, ingredientError("Error searching ingredients", err)
+	}
+	return matches, nil
+}
+
+// GetByID returns the ingredient with its nutritional values, rating, piece weights and aliases
+func (ir *IngredientDBRepo) GetByID(id string) (*IngredientDB, *error_handler.APIError) {
+	ingredient := &IngredientDB{}
+	err := ir.DB.Get(ingredient, `SELECT `+ingredientColumns+` FROM ingredient WHERE ingredient.id = $1`, id)
+	if err != nil {
+		return nil, ingredientError("Error getting ingredient", err)
+	}
+
+	err = ir.DB.Get(&ingredient.NutritionalValue, `SELECT nv.created_at, `+nutritionColumns("")+`
+		FROM nutritional_value nv WHERE nv.ingredient_id = $1`, id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, ingredientError("Error getting nutritional values", err)
+	}
+	err = ir.DB.Get(&ingredient.Rating, `SELECT * FROM rating WHERE ingredient_id = $1`, id)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, ingredientError("Error getting rating", err)
+	}
+
+	var weights []struct {
+		Unit  string  `db:"unit"`
+		Grams float64 `db:"grams"`
+	}
+	err = ir.DB.Select(&weights, `SELECT unit, grams FROM ingredient_piece_weight WHERE ingredient_id = $1`, id)
+	if err != nil {
+		return nil, ingredientError("Error getting piece weights", err)
+	}
+	for _, w := range weights {
+		if ingredient.PieceWeights == nil {
+			ingredient.PieceWeights = make(map[string]float64)
+		}
+		ingredient.PieceWeights[w.Unit] = w.Grams
+	}
+	err = ir.DB.Select(&ingredient.Aliases, `SELECT alias FROM ingredient_alias WHERE ingredient_id = $1 ORDER BY LOWER(alias)`, id)
+	if err != nil {
+		return nil, ingredientError("Error getting aliases", err)
+	}
+	err = ir.DB.Select(&ingredient.Allergens, `SELECT allergen_id FROM ingredient_allergen WHERE ingredient_id = $1 ORDER BY allergen_id`, id)
+	if err != nil {
+		return nil, ingredientError("Error getting allergens", err)
+	}
+	return ingredient, nil
+}
+
+// Create validates the ingredient and creates it if no ingredient with the same name or alias exists
+func (ir *IngredientDBRepo) Create(ingredient *IngredientDB) *error_handler.APIError {
+	apiErr := ingredient.validate()
+	if apiErr != nil {
+		return apiErr
+	}
+	for _, name := range append([]string{ingredient.Name}, ingredient.Aliases...) {
+		apiErr = checkDuplicate(name, "", ir.DB)
+		if apiErr != nil {
+			return apiErr
+		}
+	}
+	return ingredient.Create(ir.DB)
+}
+
+// Update changes the given fields. Piece weights, aliases and allergens replace the existing ones if they are set.
+func (ir *IngredientDBRepo) Update(id string, ingredient *IngredientDB) *error_handler.APIError {
+	var setParts []string
+	var args []interface{}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		setParts = append(setParts, column+" = $"+strconv.Itoa(len(args)))
+	}
+
+	if ingredient.Name != "" {
+		apiErr := checkDuplicate(ingredient.Name, id, ir.DB)
+		if apiErr != nil {
+			return apiErr
+		}
+		set("name", strings.TrimSpace(ingredient.Name))
+	}
+	if ingredient.StandardUnit != "" {
+		if _, ok := units.Lookup(ingredient.StandardUnit); !ok {
+			return error_handler.New("unknown unit "+ingredient.StandardUnit, http.StatusBadRequest, errors.New("unknown unit"))
+		}
+		set("standard_unit", units.Normalize(ingredient.StandardUnit))
+	}
+	if ingredient.Category != "" {
+		set("category", ingredient.Category)
+	}
+	if ingredient.NdbNumber != 0 {
+		set("ndb_number", ingredient.NdbNumber)
+	}
+	if ingredient.FdicID != 0 {
+		set("fdic_id", ingredient.FdicID)
+	}
+	if ingredient.Density != nil {
+		if *ingredient.Density <= 0 {
+			return error_handler.New("density has to be positive", http.StatusBadRequest, errors.New("invalid density"))
+		}
+		set("density", *ingredient.Density)
+	}
+	for _, alias := range ingredient.Aliases {
+		apiErr := checkDuplicate(alias, id, ir.DB)
+		if apiErr != nil {
+			return apiErr
+		}
+	}
+	if len(setParts) == 0 && ingredient.PieceWeights == nil && ingredient.Aliases == nil && ingredient.Allergens == nil {
+		return error_handler.New("Nothing to update", http.StatusExpectationFailed, errors.New("nothing to update"))
+	}
+
+	tx := ir.DB.MustBegin()
+	if len(setParts) > 0 {
+		args = append(args, id)
+		res, err := tx.Exec(`UPDATE ingredient SET `+strings.Join(setParts, ", ")+` WHERE id = $`+strconv.Itoa(len(args)), args...)
+		if err != nil {
+			tx.Rollback()
+			return ingredientError("Error updating ingredient", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			tx.Rollback()
+			return ingredientError("Error updating ingredient", sql.ErrNoRows)
+		}
+	}
+	if ingredient.PieceWeights != nil {
+		_, err := tx.Exec(`DELETE FROM ingredient_piece_weight WHERE ingredient_id = $1`, id)
+		if err != nil {
+			tx.Rollback()
+			return ingredientError("Error updating piece weights", err)
+		}
+		for unit, grams := range ingredient.PieceWeights {
+			_, err = tx.Exec(`INSERT INTO ingredient_piece_weight (ingredient_id, unit, grams) VALUES ($1, $2, $3)`, id, units.Normalize(unit), grams)
+			if err != nil {
+				tx.Rollback()
+				return ingredientError("Error updating piece weights", err)
+			}
+		}
+	}
+	if ingredient.Aliases != nil {
+		_, err := tx.Exec(`DELETE FROM ingredient_alias WHERE ingredient_id = $1`, id)
+		if err != nil {
+			tx.Rollback()
+			return ingredientError("Error updating aliases", err)
+		}
+		for _, alias := range ingredient.Aliases {
+			_, err = tx.Exec(`INSERT INTO ingredient_alias (ingredient_id, alias) VALUES ($1, $2)`, id, strings.TrimSpace(alias))
+			if err != nil {
+				tx.Rollback()
+				return ingredientError("Error updating aliases", err)
+			}
+		}
+	}
+	if ingredient.Allergens != nil {
+		apiErr := setIngredientAllergens(tx, id, ingredient.Allergens)
+		if apiErr != nil {
+			tx.Rollback()
+			return apiErr
+		}
+	}
+
+	err := tx.Commit()
+	if err != nil {
+		return ingredientError("Error updating ingredient", err)
+	}
+	return nil
+}
+
+// Merge moves everything using the ingredient fromID over to intoID and deletes it. Its name becomes an alias
+// of intoID. The ids of the recipes that used fromID are returned so their nutrition can be recalculated.
+func (ir *IngredientDBRepo) Merge(intoID string, fromID string) ([]string, *error_handler.APIError) {
+	if intoID == fromID {
+		return nil, error_handler.New("Can't merge an ingredient into itself", http.StatusBadRequest, errors.New("same ingredient"))
+	}
+	tx := ir.DB.MustBegin()
+
+	var from string
+	err := tx.Get(&from, `SELECT name FROM ingredient WHERE id = $1 FOR UPDATE`, fromID)
+	if err != nil {
+		tx.Rollback()
+		return nil, ingredientError("Error getting ingredient", err)
+	}
+	var into string
+	err = tx.Get(&into, `SELECT name FROM ingredient WHERE id = $1 FOR UPDATE`, intoID)
+	if err != nil {
+		tx.Rollback()
+		return nil, ingredientError("Error getting ingredient", err)
+	}
+
+	recipeIDs := []string{}
+	err = tx.Select(&recipeIDs, `WITH moved AS (
+			UPDATE recipe_ingredient SET ingredient_id = $1 WHERE ingredient_id = $2 RETURNING recipe_id
+		) SELECT DISTINCT recipe_id FROM moved`, intoID, fromID)
+	if err != nil {
+		tx.Rollback()
+		return nil, ingredientError("Error moving recipe ingredients", err)
+	}
+	queries := []string{
+		// Aliases and piece weights intoID already has win
+		`INSERT INTO ingredient_piece_weight (ingredient_id, unit, grams)
+			SELECT $1, unit, grams FROM ingredient_piece_weight WHERE ingredient_id = $2
+			ON CONFLICT DO NOTHING`,
+		`DELETE FROM ingredient_alias a USING ingredient_alias b
+			WHERE a.ingredient_id = $2 AND b.ingredient_id = $1 AND LOWER(a.alias) = LOWER(b.alias)`,
+		`UPDATE ingredient_alias SET ingredient_id = $1 WHERE ingredient_id = $2`,
+		`INSERT INTO user_disliked_ingredient (user_id, ingredient_id)
+			SELECT user_id, $1 FROM user_disliked_ingredient WHERE ingredient_id = $2
+			ON CONFLICT DO NOTHING`,
+		// Substitutes between the two ingredients are dropped
+		`INSERT INTO ingredient_substitute (ingredient_id, substitute_id, ratio, context, note)
+			SELECT CASE WHEN ingredient_id = $2 THEN $1 ELSE ingredient_id END,
+				CASE WHEN substitute_id = $2 THEN $1 ELSE substitute_id END, ratio, context, note
+			FROM ingredient_substitute
+			WHERE (ingredient_id = $2 OR substitute_id = $2) AND ingredient_id <> $1 AND substitute_id <> $1
+			ON CONFLICT DO NOTHING`,
+		// The merged ingredient contains the allergens of both
+		`INSERT INTO ingredient_allergen (ingredient_id, allergen_id)
+			SELECT $1, allergen_id FROM ingredient_allergen WHERE ingredient_id = $2
+			ON CONFLICT DO NOTHING`,
+		`DELETE FROM ingredient WHERE id = $2`,
+	}
+	for _, q := range queries {
+		_, err = tx.Exec(q, intoID, fromID)
+		if err != nil {
+			tx.Rollback()
+			return nil, ingredientError("Error merging ingredients", err)
+		}
+	}
+	if !strings.EqualFold(from, into) {
+		_, err = tx.Exec(`INSERT INTO ingredient_alias (ingredient_id, alias) VALUES ($1, $2) ON CONFLICT DO NOTHING`, intoID, from)
+		if err != nil {
+			tx.Rollback()
+			return nil, ingredientError("Error merging ingredients", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, ingredientError("Error merging ingredients", err)
+	}
+	return recipeIDs, nil
+}
+
+// Delete deletes an ingredient no recipe uses, its nutrition, rating, weights and aliases go with it
+func (ir *IngredientDBRepo) Delete(id string) *error_handler.APIError {
+	res, err := ir.DB.Exec(`DELETE FROM ingredient WHERE id = $1`, id)
+	if err != nil {
+		return ingredientError("Error deleting ingredient", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ingredientError("Error deleting ingredient", sql.ErrNoRows)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package recipe
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+)
+
+// MaxSuggestions is the number of similar ingredients suggested for an unknown one
+const MaxSuggestions = 3
+
+type IngredientMatch struct {
+	ID   string `db:"id" json:"id"`
+	Name string `db:"name" json:"name"`
+	// Score is the trigram similarity between 0 and 1, 1 meaning the names are the same
+	Score float64 `db:"score" json:"score"`
+}
+
+type UnknownIngredient struct {
+	Name        string            `json:"name"`
+	Suggestions []IngredientMatch `json:"suggestions"`
+}
+
+// UnknownIngredientsError lists all ingredients of a recipe that couldn't be found
+type UnknownIngredientsError struct {
+	Ingredients []UnknownIngredient
+}
+
+func (e *UnknownIngredientsError) Error() string {
+	names := make([]string, len(e.Ingredients))
+	for i, ing := range e.Ingredients {
+		names[i] = ing.Name
+	}
+	return "unknown ingredients: " + strings.Join(names, ", ")
+}
+
+func (e *UnknownIngredientsError) Details() any {
+	return map[string][]UnknownIngredient{"unknown_ingredients": e.Ingredients}
+}
+
+// NameVariants returns the lower cased name followed by its singular and plural forms,
+// "Tomatoes" gives tomatoes, tomato and tomatoe.
+func NameVariants(name string) []string {
+	name = strings.ToLower(strings.Join(strings.Fields(name), " "))
+	if name == "" {
+		return nil
+	}
+	variants := []string{name}
+	add := func(v string) {
+		for _, existing := range variants {
+			if existing == v {
+				return
+			}
+		}
+		variants = append(variants, v)
+	}
+
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 4:
+		add(name[:len(name)-3] + "y")
+	case strings.HasSuffix(name, "ves"):
+		add(name[:len(name)-3] + "f")
+	}
+	for _, suffix := range []string{"oes", "ches", "shes", "sses", "xes", "zes"} {
+		if strings.HasSuffix(name, suffix) {
+			add(name[:len(name)-2])
+		}
+	}
+	if strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") {
+		add(name[:len(name)-1])
+	}
+
+	if !strings.HasSuffix(name, "s") {
+		switch {
+		case strings.HasSuffix(name, "y") && len(name) > 2 && !strings.ContainsRune("aeiou", rune(name[len(name)-2])):
+			add(name[:len(name)-1] + "ies")
+		case strings.HasSuffix(name, "o"), strings.HasSuffix(name, "ch"), strings.HasSuffix(name, "sh"), strings.HasSuffix(name, "x"):
+			add(name + "es")
+		}
+		add(name + "s")
+	}
+	return variants
+}
+
+// GetIngIDByName finds an ingredient by its name or one of its aliases, ignoring case and plurals
+func GetIngIDByName(name string, db database.SQLDB) (string, *error_handler.APIError) {
+	variants := NameVariants(name)
+	if len(variants) == 0 {
+		return "", error_handler.New("ingredient name is empty", http.StatusNotFound, errors.New("empty ingredient name"))
+	}
+
+	// Names win over aliases and the name as written over its other forms
+	query := `SELECT id FROM (
+				SELECT id, array_position($1::text[], LOWER(name)) AS pos, 0 AS alias
+				FROM ingredient WHERE LOWER(name) = ANY($1)
+				UNION ALL
+				SELECT ingredient_id, array_position($1::text[], LOWER(alias)), 1
+				FROM ingredient_alias WHERE LOWER(alias) = ANY($1)
+			) m ORDER BY pos, alias LIMIT 1`
+	var id string
+	err := db.QueryRow(query, pq.Array(variants)).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", error_handler.New("ingredient "+name+" doesn't exist", http.StatusNotFound, err)
+	}
+	if err != nil {
+		return "", error_handler.New("database error getting "+name+" : "+err.Error(), http.StatusInternalServerError, err)
+	}
+
+	return id, nil
+}
+
+// SuggestIngredients returns the ingredients whose name or alias is most similar to name, best first.
+// Only ingredients above the pg_trgm similarity thresholds are returned.
+func SuggestIngredients(name string, limit int, db database.SQLDB) ([]IngredientMatch, *error_handler.APIError) {
+	query := `SELECT i.id, i.name, MAX(GREATEST(
+				similarity(LOWER(i.name), $1), word_similarity($1, LOWER(i.name)),
+				COALESCE(similarity(LOWER(a.alias), $1), 0), COALESCE(word_similarity($1, LOWER(a.alias)), 0)
+			)) AS score
+			FROM ingredient i
+			LEFT JOIN ingredient_alias a ON a.ingredient_id = i.id
+			WHERE LOWER(i.name) % $1 OR $1 <% LOWER(i.name) OR LOWER(a.alias) % $1 OR $1 <% LOWER(a.alias)
+			GROUP BY i.id, i.name
+			ORDER BY score DESC, i.name
+			LIMIT $2`
+	matches := []IngredientMatch{}
+	err := db.Select(&matches, query, strings.ToLower(strings.TrimSpace(name)), limit)
+	if err != nil {
+		return nil, error_handler.New("database error suggesting ingredients for "+name+": "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return matches, nil
+}
+
+// unknownIngredients returns an error listing the names with suggestions for each of them
+func unknownIngredients(names []string, db database.SQLDB) *error_handler.APIError {
+	unknown := &UnknownIngredientsError{}
+	for _, name := range names {
+		suggestions, err := SuggestIngredients(name, MaxSuggestions, db)
+		if err != nil {
+			return err
+		}
+		unknown.Ingredients = append(unknown.Ingredients, UnknownIngredient{Name: name, Suggestions: suggestions})
+	}
+	return error_handler.New("Couldn't find "+strings.Join(names, ", "), http.StatusUnprocessableEntity, unknown)
+}
+
+// ResolveIngredients looks up the ids of all ingredients that don't have one yet.
+// All unknown ingredients are reported together with suggestions in a 422.
+func (recipe *RecipeSchema) ResolveIngredients(db database.SQLDB) *error_handler.APIError {
+	var unknown []string
+	for i, ing := range recipe.Ingredients {
+		if ing.IngredientID != "" {
+			continue
+		}
+		id, err := GetIngIDByName(ing.Name, db)
+		if err != nil {
+			if err.Code != http.StatusNotFound {
+				return err
+			}
+			unknown = append(unknown, ing.Name)
+			continue
+		}
+		recipe.Ingredients[i].IngredientID = id
+	}
+	if len(unknown) > 0 {
+		return unknownIngredients(unknown, db)
+	}
+	return nil
+}
@@ -2,17 +2,26 @@ package recipe
 
 import (
 	"errors"
+	"fmt"
 	"time"
+
+	"github.com/madswillem/recipeApp/internal/units"
 )
 
 type IngredientsSchema struct {
-	ID               string           `db:"id" json:"id"`
-	CreatedAt        time.Time        `db:"created_at" json:"created_at"`
-	RecipeID         string           `db:"recipe_id" json:"recipe_id"`
-	IngredientID     string           `db:"ingredient_id" json:"ingredient_id"`
-	Amount           int              `db:"amount" json:"amount"`
-	Unit             string           `db:"unit" json:"unit"`
-	Name             string           `db:"name" json:"name"`
+	ID           string    `db:"id" json:"id"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	RecipeID     string    `db:"recipe_id" json:"recipe_id"`
+	IngredientID string    `db:"ingredient_id" json:"ingredient_id"`
+	Amount       float64   `db:"amount" json:"amount"`
+	// AmountMax is the upper end of ranges like 2-3 cloves
+	AmountMax *float64 `db:"amount_max" json:"amount_max,omitempty"`
+	Unit      string   `db:"unit" json:"unit"`
+	Name      string   `db:"name" json:"name"`
+	// Note describes the preparation like minced
+	Note string `db:"note" json:"note,omitempty"`
+	// Raw is an unparsed line like "2 cloves garlic, minced", it is parsed if no name is given
+	Raw              string           `db:"-" json:"raw,omitempty"`
 	NutritionalValue NutritionalValue `db:"nv" json:"nv"`
 	Rating           RatingStruct     `db:"rating" json:"rating"`
 }
@@ -24,8 +33,26 @@ func (ingredient *IngredientsSchema) CheckForRequiredFields() error {
 	if ingredient.Amount == 0 {
 		return errors.New("missing amount")
 	}
+	if ingredient.Amount < 0 {
+		return errors.New("amount can't be negative")
+	}
+	if ingredient.AmountMax != nil && *ingredient.AmountMax < ingredient.Amount {
+		return errors.New("amount_max can't be smaller than amount")
+	}
 	if ingredient.Unit == "" {
 		return errors.New("missing measurement unit")
 	}
+	if _, ok := units.Lookup(ingredient.Unit); !ok {
+		return fmt.Errorf("unknown unit %s", ingredient.Unit)
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+// Quantity formats the amount, ranges like "2-3"
+func (ingredient *IngredientsSchema) Quantity() string {
+	q := units.FormatAmount(ingredient.Amount)
+	if ingredient.AmountMax != nil && *ingredient.AmountMax != ingredient.Amount {
+		q += "-" + units.FormatAmount(*ingredient.AmountMax)
+	}
+	return q
+}
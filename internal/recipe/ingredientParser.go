@@ -0,0 +1,173 @@
+package recipe
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/madswillem/recipeApp/internal/units"
+)
+
+var (
+	ErrNoAmount         = errors.New("no amount")
+	ErrNoIngredientName = errors.New("no ingredient name")
+)
+
+var unicodeFractions = map[rune]float64{
+	'½': 0.5, '⅓': 1.0 / 3, '⅔': 2.0 / 3, '¼': 0.25, '¾': 0.75, '⅕': 0.2,
+	'⅖': 0.4, '⅗': 0.6, '⅘': 0.8, '⅙': 1.0 / 6, '⅚': 5.0 / 6, '⅛': 0.125,
+	'⅜': 0.375, '⅝': 0.625, '⅞': 0.875,
+}
+
+var unicodeFraction = regexp.MustCompile(`(?:(\d+)\s*)?([½⅓⅔¼¾⅕⅖⅗⅘⅙⅚⅛⅜⅝⅞])`)
+
+// quantityPattern matches "1", "1.5", "1,5", "1/2" and "1 1/2"
+const quantityPattern = `(\d+(?:[.,]\d+)?(?:\s+\d+/\d+|/\d+)?)`
+
+var amountPattern = regexp.MustCompile(`^` + quantityPattern + `(?:\s*(?:-|–|to)\s*` + quantityPattern + `)?\s*`)
+
+var parenthesis = regexp.MustCompile(`\(([^)]*)\)`)
+
+// preparations are words describing how an ingredient is prepared, they are moved into the note
+var preparations = map[string]bool{
+	"chopped": true, "minced": true, "diced": true, "sliced": true, "grated": true, "crushed": true,
+	"peeled": true, "melted": true, "softened": true, "beaten": true, "shredded": true, "cubed": true,
+	"finely": true, "roughly": true, "thinly": true, "freshly": true,
+}
+
+// ParseIngredientLine splits a line like "2-3 cloves garlic, minced" into amount, unit, name and note.
+// Amounts without a unit are counted in pieces.
+func ParseIngredientLine(line string) (IngredientsSchema, error) {
+	ing := IngredientsSchema{Raw: strings.TrimSpace(line)}
+	rest := replaceFractions(ing.Raw)
+
+	var notes []string
+	for _, m := range parenthesis.FindAllStringSubmatch(rest, -1) {
+		notes = append(notes, strings.TrimSpace(m[1]))
+	}
+	rest = parenthesis.ReplaceAllString(rest, "")
+	// The note starts at the first comma that isn't a decimal comma
+	if i := noteComma(rest); i >= 0 {
+		notes = append([]string{strings.TrimSpace(rest[i+1:])}, notes...)
+		rest = rest[:i]
+	}
+	for _, suffix := range []string{"to taste", "as needed"} {
+		if strings.HasSuffix(strings.ToLower(strings.TrimSpace(rest)), suffix) {
+			rest = strings.TrimSpace(rest)[:len(strings.TrimSpace(rest))-len(suffix)]
+			notes = append(notes, suffix)
+		}
+	}
+
+	match := amountPattern.FindStringSubmatch(rest)
+	if match != nil {
+		ing.Amount = parseQuantity(match[1])
+		if match[2] != "" {
+			max := parseQuantity(match[2])
+			ing.AmountMax = &max
+		}
+		rest = rest[len(match[0]):]
+	}
+
+	words := strings.Fields(rest)
+	if match != nil {
+		ing.Unit = "piece"
+		// Two word units like "fl oz" first
+		for n := 2; n >= 1; n-- {
+			if len(words) < n {
+				continue
+			}
+			if u, ok := units.Lookup(strings.Join(words[:n], " ")); ok {
+				ing.Unit = u.Name
+				words = words[n:]
+				break
+			}
+		}
+	}
+	if len(words) > 0 && strings.ToLower(words[0]) == "of" {
+		words = words[1:]
+	}
+
+	var name, prep []string
+	for _, w := range words {
+		if preparations[strings.ToLower(w)] {
+			prep = append(prep, w)
+		} else {
+			name = append(name, w)
+		}
+	}
+	if len(prep) > 0 {
+		notes = append([]string{strings.Join(prep, " ")}, notes...)
+	}
+	ing.Name = strings.Join(name, " ")
+	ing.Note = strings.Join(notes, ", ")
+
+	if ing.Name == "" {
+		return ing, ErrNoIngredientName
+	}
+	if ing.Amount <= 0 {
+		return ing, ErrNoAmount
+	}
+	return ing, nil
+}
+
+// noteComma returns the index of the first comma that isn't between two digits, -1 if there is none
+func noteComma(s string) int {
+	isDigit := func(i int) bool { return i >= 0 && i < len(s) && s[i] >= '0' && s[i] <= '9' }
+	for i := 0; i < len(s); i++ {
+		if s[i] == ',' && !(isDigit(i-1) && isDigit(i+1)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// replaceFractions turns unicode fractions into decimals, "1½" and "1 ½" become "1.5"
+func replaceFractions(s string) string {
+	return unicodeFraction.ReplaceAllStringFunc(s, func(m string) string {
+		match := unicodeFraction.FindStringSubmatch(m)
+		whole, _ := strconv.ParseFloat(match[1], 64)
+		return strconv.FormatFloat(whole+unicodeFractions[[]rune(match[2])[0]], 'f', -1, 64)
+	})
+}
+
+// parseQuantity parses a quantity matched by quantityPattern
+func parseQuantity(s string) float64 {
+	s = strings.Replace(s, ",", ".", 1)
+	whole, frac, mixed := strings.Cut(s, " ")
+	if !mixed {
+		frac, whole = whole, ""
+	}
+	var total float64
+	if whole != "" {
+		total, _ = strconv.ParseFloat(whole, 64)
+	}
+	if n, d, ok := strings.Cut(strings.TrimSpace(frac), "/"); ok {
+		numerator, _ := strconv.ParseFloat(n, 64)
+		denominator, _ := strconv.ParseFloat(d, 64)
+		if denominator != 0 {
+			total += numerator / denominator
+		}
+	} else {
+		v, _ := strconv.ParseFloat(frac, 64)
+		total += v
+	}
+	return total
+}
+
+// ParseRawIngredients fills the ingredients only given as a raw line from the parsed line
+func (recipe *RecipeSchema) ParseRawIngredients() error {
+	for i, ing := range recipe.Ingredients {
+		if ing.Raw == "" || ing.Name != "" {
+			continue
+		}
+		parsed, err := ParseIngredientLine(ing.Raw)
+		if err != nil {
+			return fmt.Errorf("%q: %w", ing.Raw, err)
+		}
+		parsed.ID, parsed.RecipeID, parsed.Rating = ing.ID, ing.RecipeID, ing.Rating
+		recipe.Ingredients[i] = parsed
+	}
+	return nil
+}
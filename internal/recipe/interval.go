@@ -0,0 +1,25 @@
+package recipe
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var intervalPattern = regexp.MustCompile(`^(?:(\d+) days? ?)?(?:(\d+):(\d+):(\d+)(?:\.\d+)?)?$`)
+
+// ParseInterval reads a postgres interval like "01:30:00" or "1 day 02:00:00" as stored in prep_time
+func ParseInterval(interval string) (time.Duration, bool) {
+	interval = strings.TrimSpace(interval)
+	match := intervalPattern.FindStringSubmatch(interval)
+	if match == nil || interval == "" {
+		return 0, false
+	}
+	n := make([]int, 4)
+	for i := range n {
+		n[i], _ = strconv.Atoi(match[i+1])
+	}
+	return time.Duration(n[0])*24*time.Hour + time.Duration(n[1])*time.Hour +
+		time.Duration(n[2])*time.Minute + time.Duration(n[3])*time.Second, true
+}
@@ -0,0 +1,95 @@
+package recipe
+
+import "strings"
+
+// NutriScoreInput holds the values the Nutri-Score is calculated from, all per 100 g.
+// FruitVegNut is the share of fruits, vegetables, legumes and nuts in percent.
+type NutriScoreInput struct {
+	EnergyKj     float64
+	Sugar        float64
+	SaturatedFat float64
+	Salt         float64
+	Fiber        float64
+	Protein      float64
+	FruitVegNut  float64
+}
+
+// Thresholds of the general food table, a value earns one point for every threshold it exceeds
+var (
+	energyThresholds       = []float64{335, 670, 1005, 1340, 1675, 2010, 2345, 2680, 3015, 3350}
+	sugarThresholds        = []float64{4.5, 9, 13.5, 18, 22.5, 27, 31, 36, 40, 45}
+	saturatedFatThresholds = []float64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	sodiumThresholds       = []float64{90, 180, 270, 360, 450, 540, 630, 720, 810, 900}
+	fiberThresholds        = []float64{0.9, 1.9, 2.8, 3.7, 4.7}
+	proteinThresholds      = []float64{1.6, 3.2, 4.8, 6.4, 8.0}
+)
+
+// fruitVegNutCategories are the ingredient categories counting towards the fruit, vegetable and nut share
+var fruitVegNutCategories = []string{"fruit", "vegetable", "legume", "nut and seed"}
+
+func IsFruitVegNut(category string) bool {
+	category = strings.ToLower(category)
+	for _, c := range fruitVegNutCategories {
+		if strings.Contains(category, c) {
+			return true
+		}
+	}
+	return false
+}
+
+func thresholdPoints(value float64, thresholds []float64) int {
+	points := 0
+	for _, t := range thresholds {
+		if value > t {
+			points++
+		}
+	}
+	return points
+}
+
+func fruitVegNutPoints(percent float64) int {
+	switch {
+	case percent > 80:
+		return 5
+	case percent > 60:
+		return 2
+	case percent > 40:
+		return 1
+	}
+	return 0
+}
+
+// NutriScore calculates the Nutri-Score of a general food and returns the points and the letter A to E
+func NutriScore(in NutriScoreInput) (int, string) {
+	sodium := in.Salt / 2.5 * 1000 // salt in g to sodium in mg
+	negative := thresholdPoints(in.EnergyKj, energyThresholds) +
+		thresholdPoints(in.Sugar, sugarThresholds) +
+		thresholdPoints(in.SaturatedFat, saturatedFatThresholds) +
+		thresholdPoints(sodium, sodiumThresholds)
+
+	fvn := fruitVegNutPoints(in.FruitVegNut)
+	fiber := thresholdPoints(in.Fiber, fiberThresholds)
+	protein := thresholdPoints(in.Protein, proteinThresholds)
+
+	// Protein doesn't count for foods high in negative points unless they are mostly fruit and vegetables
+	points := negative - fvn - fiber
+	if negative < 11 || fvn >= 5 {
+		points -= protein
+	}
+
+	return points, NutriScoreLetter(points)
+}
+
+func NutriScoreLetter(points int) string {
+	switch {
+	case points <= -1:
+		return "A"
+	case points <= 2:
+		return "B"
+	case points <= 10:
+		return "C"
+	case points <= 18:
+		return "D"
+	}
+	return "E"
+}
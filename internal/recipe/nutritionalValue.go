@@ -0,0 +1,161 @@
+package recipe
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+)
+
+// nutritionColumns selects the nutritional_value joined as nv, prefix is prepended to the column names
+func nutritionColumns(prefix string) string {
+	columns := []string{"kcal", "kj", "fat", "saturated_fat", "carbohydrate", "sugar", "protein", "salt", "fiber"}
+	selects := []string{
+		fmt.Sprintf(`COALESCE(nv.id::text, '') AS "%sid"`, prefix),
+		fmt.Sprintf(`COALESCE(nv.nutriscore, '') AS "%snutriscore"`, prefix),
+		fmt.Sprintf(`COALESCE(nv.incomplete, false) AS "%sincomplete"`, prefix),
+		fmt.Sprintf(`nv.missing AS "%smissing"`, prefix),
+		fmt.Sprintf(`nv.nutriscore_points AS "%snutriscore_points"`, prefix),
+		fmt.Sprintf(`COALESCE(nv.source, '') AS "%ssource"`, prefix),
+		fmt.Sprintf(`nv.source_id AS "%ssource_id"`, prefix),
+		fmt.Sprintf(`nv.imported_at AS "%simported_at"`, prefix),
+	}
+	for _, c := range columns {
+		selects = append(selects, fmt.Sprintf(`COALESCE(nv.%s, 0) AS "%s%s"`, c, prefix, c))
+	}
+	return strings.Join(selects, ", ")
+}
+
+// ingredientNutrition loads the ingredients of the recipe with their nutrition data, keyed by the lower case
+// name used in the recipe. Ingredients are found by their id, or by their name if they don't have one yet.
+func ingredientNutrition(ingredients []IngredientsSchema, db database.SQLDB) (map[string]IngredientDB, *error_handler.APIError) {
+	result := make(map[string]IngredientDB, len(ingredients))
+	if len(ingredients) == 0 {
+		return result, nil
+	}
+	// The placeholder values keep the IN lists from being empty
+	ids, lower := []string{"00000000-0000-0000-0000-000000000000"}, []string{""}
+	for _, ing := range ingredients {
+		if ing.IngredientID != "" {
+			ids = append(ids, ing.IngredientID)
+		}
+		lower = append(lower, strings.ToLower(ing.Name))
+	}
+
+	query, args, err := sqlx.In(`SELECT ingredient.id, ingredient.name, COALESCE(ingredient.standard_unit, '') AS standard_unit,
+			COALESCE(ingredient.category, '') AS category, ingredient.density, `+nutritionColumns("nv.")+`
+		FROM ingredient
+		LEFT JOIN nutritional_value nv ON nv.ingredient_id = ingredient.id
+		WHERE ingredient.id::text IN (?) OR LOWER(ingredient.name) IN (?)`, ids, lower)
+	if err != nil {
+		return nil, error_handler.New("error building nutrition query: "+err.Error(), http.StatusInternalServerError, err)
+	}
+
+	var rows []struct {
+		ID           string           `db:"id"`
+		Name         string           `db:"name"`
+		StandardUnit string           `db:"standard_unit"`
+		Category     string           `db:"category"`
+		Density      *float64         `db:"density"`
+		Nutrition    NutritionalValue `db:"nv"`
+	}
+	err = db.Select(&rows, db.Rebind(query), args...)
+	if err != nil {
+		return nil, error_handler.New("error fetching nutrition data: "+err.Error(), http.StatusInternalServerError, err)
+	}
+
+	byID := make(map[string]IngredientDB, len(rows))
+	byName := make(map[string]string, len(rows))
+	loaded := make([]string, 0, len(rows))
+	for _, row := range rows {
+		byID[row.ID] = IngredientDB{
+			ID:               row.ID,
+			Name:             row.Name,
+			StandardUnit:     row.StandardUnit,
+			Category:         row.Category,
+			Density:          row.Density,
+			NutritionalValue: row.Nutrition,
+		}
+		byName[strings.ToLower(row.Name)] = row.ID
+		loaded = append(loaded, row.ID)
+	}
+	if len(loaded) == 0 {
+		return result, nil
+	}
+
+	query, args, err = sqlx.In(`SELECT ingredient_id, unit, grams FROM ingredient_piece_weight WHERE ingredient_id::text IN (?)`, loaded)
+	if err != nil {
+		return nil, error_handler.New("error building piece weight query: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	var weights []struct {
+		IngredientID string  `db:"ingredient_id"`
+		Unit         string  `db:"unit"`
+		Grams        float64 `db:"grams"`
+	}
+	err = db.Select(&weights, db.Rebind(query), args...)
+	if err != nil {
+		return nil, error_handler.New("error fetching piece weights: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	for _, w := range weights {
+		ing := byID[w.IngredientID]
+		if ing.PieceWeights == nil {
+			ing.PieceWeights = make(map[string]float64)
+		}
+		ing.PieceWeights[w.Unit] = w.Grams
+		byID[w.IngredientID] = ing
+	}
+
+	for _, ing := range ingredients {
+		id := ing.IngredientID
+		if _, ok := byID[id]; !ok {
+			id = byName[strings.ToLower(ing.Name)]
+		}
+		if data, ok := byID[id]; ok {
+			result[strings.ToLower(ing.Name)] = data
+		}
+	}
+	return result, nil
+}
+
+// saveNutrition calculates the nutritional values of the recipe and stores them as its nutritional_value row
+func saveNutrition(recipe *RecipeSchema, db database.SQLDB) *error_handler.APIError {
+	nutrition, apiErr := ingredientNutrition(recipe.Ingredients, db)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	nv := CalculateNutrition(recipe, nutrition)
+	stmt, err := db.PrepareNamed(`INSERT INTO nutritional_value
+			(recipe_id, kcal, kj, fat, saturated_fat, carbohydrate, sugar, protein, salt, fiber, nutriscore, nutriscore_points, incomplete, missing)
+		VALUES
+			(:recipe_id, :kcal, :kj, :fat, :saturated_fat, :carbohydrate, :sugar, :protein, :salt, :fiber, NULLIF(:nutriscore, ''), :nutriscore_points, :incomplete, :missing)
+		ON CONFLICT (recipe_id) DO UPDATE SET
+			kcal = EXCLUDED.kcal, kj = EXCLUDED.kj, fat = EXCLUDED.fat, saturated_fat = EXCLUDED.saturated_fat,
+			carbohydrate = EXCLUDED.carbohydrate, sugar = EXCLUDED.sugar, protein = EXCLUDED.protein,
+			salt = EXCLUDED.salt, fiber = EXCLUDED.fiber, nutriscore = EXCLUDED.nutriscore,
+			nutriscore_points = EXCLUDED.nutriscore_points, incomplete = EXCLUDED.incomplete, missing = EXCLUDED.missing
+		RETURNING id, created_at`)
+	if err != nil {
+		return error_handler.New("Query error: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	defer stmt.Close()
+	err = stmt.QueryRowx(nv).Scan(&nv.ID, &nv.CreatedAt)
+	if err != nil {
+		return error_handler.New("Error saving nutritional values: "+err.Error(), http.StatusInternalServerError, err)
+	}
+
+	recipe.NutritionalValue = nv
+	return nil
+}
+
+// RefreshNutrition recalculates the stored nutritional values of a recipe after its ingredients changed
+func (rp *RecipeRepo) RefreshNutrition(id string) *error_handler.APIError {
+	recipe, apiErr := rp.GetRecipeByID(id)
+	if apiErr != nil {
+		return apiErr
+	}
+	return saveNutrition(recipe, rp.DB)
+}
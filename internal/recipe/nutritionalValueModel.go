@@ -1,6 +1,12 @@
 package recipe
 
-import "time"
+import (
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/madswillem/recipeApp/internal/units"
+)
 
 type NutritionalValue struct {
 	ID           string    `db:"id" json:"id"`
@@ -15,6 +21,134 @@ type NutritionalValue struct {
 	Sugar        float64   `db:"sugar" json:"sugar"`
 	Protein      float64   `db:"protein" json:"protein"`
 	Salt         float64   `db:"salt" json:"salt"`
+	Fiber        float64   `db:"fiber" json:"fiber"`
 	Nutriscore   string    `db:"nutriscore" json:"nutriscore"`
-	Edited       bool      `json:"edited"`
+	// NutriscorePoints is the score the letter is derived from, nil if it couldn't be calculated
+	NutriscorePoints *int `db:"nutriscore_points" json:"nutriscore_points"`
+	Edited           bool `json:"edited"`
+	// Incomplete is set on recipes using ingredients without nutrition data, they are listed in Missing
+	Incomplete bool           `db:"incomplete" json:"incomplete"`
+	Missing    pq.StringArray `db:"missing" json:"missing,omitempty"`
+	// Source names the database imported ingredient values come from, SourceID is their id there
+	Source     string     `db:"source" json:"source,omitempty"`
+	SourceID   *int64     `db:"source_id" json:"source_id,omitempty"`
+	ImportedAt *time.Time `db:"imported_at" json:"imported_at,omitempty"`
+}
+
+// referenceAmounts returns how many times the nutrition data of an ingredient is contained in amount unit of it.
+// The nutrition data of an ingredient refers to 100 g when its standard unit is a mass or volume and to one
+// piece when it is counted. ok is false if the units can't be converted into each other.
+func (ingredient *IngredientDB) referenceAmounts(amount float64, unit string) (float64, bool) {
+	standard, ok := units.Lookup(ingredient.StandardUnit)
+	if strings.TrimSpace(ingredient.StandardUnit) == "" {
+		standard, ok = units.Lookup("g")
+	}
+	if !ok {
+		return 0, false
+	}
+
+	if standard.Kind != units.Count {
+		grams, ok := ingredient.Grams(amount, unit)
+		return grams / 100, ok
+	}
+	if u, ok := units.Lookup(unit); ok && u.Kind == units.Count {
+		return amount, true
+	}
+	// Weighed amounts of counted ingredients need the weight of a piece
+	grams, ok := ingredient.Grams(amount, unit)
+	if !ok {
+		return 0, false
+	}
+	piece, ok := ingredient.Grams(1, standard.Name)
+	if !ok || piece == 0 {
+		return 0, false
+	}
+	return grams / piece, true
+}
+
+// Servings returns the number of portions the nutritional values of the recipe are given for.
+// A yield given as a mass or volume is split into portions of 100 g.
+func (recipe *RecipeSchema) Servings() float64 {
+	if recipe.Yield <= 0 {
+		return 1
+	}
+	if u, ok := units.Lookup(recipe.YieldUnit); ok && u.Kind != units.Count {
+		return float64(recipe.Yield) * u.Base / 100
+	}
+	return float64(recipe.Yield)
+}
+
+// CalculateNutrition sums up the nutritional values of the recipes ingredients per serving and scores them.
+// nutrition maps lower case ingredient names to the ingredient with its nutrition data.
+func CalculateNutrition(recipe *RecipeSchema, nutrition map[string]IngredientDB) NutritionalValue {
+	nv := NutritionalValue{RecipeID: &recipe.ID}
+	// Only weighable ingredients count towards the Nutri-Score as it is based on 100 g
+	per100g := NutriScoreInput{}
+	var grams, fruitVegNutGrams float64
+
+	for _, ing := range recipe.Ingredients {
+		data, ok := nutrition[strings.ToLower(ing.Name)]
+		if !ok || data.NutritionalValue.ID == "" {
+			nv.Missing = append(nv.Missing, ing.Name)
+			continue
+		}
+		factor, ok := data.referenceAmounts(ing.Amount, ing.Unit)
+		if !ok {
+			nv.Missing = append(nv.Missing, ing.Name)
+			continue
+		}
+
+		v := data.NutritionalValue
+		nv.Kcal += v.Kcal * factor
+		nv.Kj += v.Kj * factor
+		nv.Fat += v.Fat * factor
+		nv.SaturatedFat += v.SaturatedFat * factor
+		nv.Carbohydrate += v.Carbohydrate * factor
+		nv.Sugar += v.Sugar * factor
+		nv.Protein += v.Protein * factor
+		nv.Salt += v.Salt * factor
+		nv.Fiber += v.Fiber * factor
+
+		if g, ok := data.Grams(ing.Amount, ing.Unit); ok {
+			grams += g
+			if IsFruitVegNut(data.Category) {
+				fruitVegNutGrams += g
+			}
+			per100g.EnergyKj += v.Kj * factor
+			per100g.Sugar += v.Sugar * factor
+			per100g.SaturatedFat += v.SaturatedFat * factor
+			per100g.Salt += v.Salt * factor
+			per100g.Fiber += v.Fiber * factor
+			per100g.Protein += v.Protein * factor
+		}
+	}
+
+	nv.Incomplete = len(nv.Missing) > 0
+	// A score from partial data would look authoritative, so there is none
+	if grams > 0 && !nv.Incomplete {
+		per100g.EnergyKj *= 100 / grams
+		per100g.Sugar *= 100 / grams
+		per100g.SaturatedFat *= 100 / grams
+		per100g.Salt *= 100 / grams
+		per100g.Fiber *= 100 / grams
+		per100g.Protein *= 100 / grams
+		per100g.FruitVegNut = fruitVegNutGrams / grams * 100
+
+		points, letter := NutriScore(per100g)
+		nv.NutriscorePoints = &points
+		nv.Nutriscore = letter
+	}
+
+	servings := recipe.Servings()
+	nv.Kcal /= servings
+	nv.Kj /= servings
+	nv.Fat /= servings
+	nv.SaturatedFat /= servings
+	nv.Carbohydrate /= servings
+	nv.Sugar /= servings
+	nv.Protein /= servings
+	nv.Salt /= servings
+	nv.Fiber /= servings
+
+	return nv
 }
@@ -0,0 +1,118 @@
+package recipe
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/madswillem/recipeApp/internal/error_handler"
+)
+
+const (
+	SortPopular    = "popular"
+	SortNewest     = "newest"
+	SortViews      = "views"
+	SortSelects    = "selects"
+	SortRating     = "rating"
+	SortContextual = "contextual"
+	SortPrepTime   = "prep_time"
+)
+
+const DefaultPageLimit = 20
+const MaxPageLimit = 100
+
+type RecipePage struct {
+	Recipes    []RecipeSchema `json:"recipes"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+	Total      int            `json:"total"`
+}
+
+// sortOption describes how a sort is done in sql. key is the expression sorted by, ties are
+// broken by created_at and id in the same direction. cast is the type the cursor value is cast back to.
+// Nullable columns are coalesced, the keyset comparison never matches NULL.
+type sortOption struct {
+	key  string
+	cast string
+	asc  bool
+}
+
+var sortOptions = map[string]sortOption{
+	SortPopular:  {key: "COALESCE(log.view_change, 0)", cast: "bigint"},
+	SortNewest:   {key: "COALESCE(recipes.created_at, 'epoch'::timestamp)", cast: "timestamp"},
+	SortViews:    {key: "COALESCE(recipes.views, 0)", cast: "bigint"},
+	SortSelects:  {key: "COALESCE(recipes.selects, 0)", cast: "bigint"},
+	SortRating:   {key: "COALESCE(rt.overall, 0)", cast: "numeric"},
+	SortPrepTime: {key: "COALESCE(recipes.prep_time, '0'::interval)", cast: "interval", asc: true},
+}
+
+// sortBy returns the sort option of the filter, the contextual one is built from the filters context
+func (f *Filter) sortBy() (sortOption, *error_handler.APIError) {
+	if f.Sort == "" {
+		f.Sort = SortPopular
+	}
+	if f.Sort != SortContextual {
+		opt, ok := sortOptions[f.Sort]
+		if !ok {
+			return opt, error_handler.New(fmt.Sprintf("Unknown sort %s", f.Sort), http.StatusBadRequest, errors.New("unknown sort"))
+		}
+		return opt, nil
+	}
+
+	opt := sortOption{key: "COALESCE(rt.overall, 0)", cast: "numeric"}
+	if f.Context == nil {
+		return opt, nil
+	}
+	// The bucket names are the rating columns
+	buckets := (&RatingStruct{}).ContextBuckets(*f.Context)
+	if len(buckets) > 0 {
+		columns := make([]string, len(buckets))
+		for i, b := range buckets {
+			columns[i] = "COALESCE(rt." + b.Name + ", 0)"
+		}
+		opt.key = fmt.Sprintf("(%s) / %d.0", strings.Join(columns, " + "), len(buckets))
+	}
+	return opt, nil
+}
+
+func (f *Filter) limit() (int, *error_handler.APIError) {
+	if f.Limit == 0 {
+		return DefaultPageLimit, nil
+	}
+	if f.Limit < 0 || f.Limit > MaxPageLimit {
+		return 0, error_handler.New(fmt.Sprintf("limit has to be between 1 and %d", MaxPageLimit), http.StatusBadRequest, errors.New("invalid limit"))
+	}
+	return f.Limit, nil
+}
+
+// cursor points behind the last recipe of a page. It is only valid for the sort it was created with.
+type cursor struct {
+	Sort      string    `json:"s"`
+	Value     string    `json:"v"`
+	CreatedAt time.Time `json:"c"`
+	ID        string    `json:"i"`
+}
+
+func (c *cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string, sort string) (*cursor, *error_handler.APIError) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, error_handler.New("Invalid cursor", http.StatusBadRequest, err)
+	}
+	c := &cursor{}
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return nil, error_handler.New("Invalid cursor", http.StatusBadRequest, err)
+	}
+	if c.Sort != sort {
+		return nil, error_handler.New("Cursor belongs to a different sort", http.StatusBadRequest, errors.New("cursor sort mismatch"))
+	}
+	return c, nil
+}
@@ -114,3 +114,72 @@ func UpdateRating(change int) (*error_handler.APIError, []string) {
 
 	return nil, result
 }
+
+type RatingBucket struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// ContextBuckets returns the rating buckets that match the given weekday, season and temperature.
+// Buckets that can't be determined are skipped.
+func (rating *RatingStruct) ContextBuckets(data tools.CurrentData) []RatingBucket {
+	var buckets []RatingBucket
+
+	switch data.Day {
+	case "Mon":
+		buckets = append(buckets, RatingBucket{"mon", rating.Mon})
+	case "Tue":
+		buckets = append(buckets, RatingBucket{"tue", rating.Tue})
+	case "Wed":
+		buckets = append(buckets, RatingBucket{"wed", rating.Wed})
+	case "Thu":
+		buckets = append(buckets, RatingBucket{"thu", rating.Thu})
+	case "Fri":
+		buckets = append(buckets, RatingBucket{"fri", rating.Fri})
+	case "Sat":
+		buckets = append(buckets, RatingBucket{"sat", rating.Sat})
+	case "Sun":
+		buckets = append(buckets, RatingBucket{"sun", rating.Sun})
+	}
+
+	switch data.Season {
+	case "Win":
+		buckets = append(buckets, RatingBucket{"win", rating.Win})
+	case "Spr":
+		buckets = append(buckets, RatingBucket{"spr", rating.Spr})
+	case "Sum":
+		buckets = append(buckets, RatingBucket{"sum", rating.Sum})
+	case "Aut":
+		buckets = append(buckets, RatingBucket{"aut", rating.Aut})
+	}
+
+	switch data.Temp {
+	case "subzerodegree":
+		buckets = append(buckets, RatingBucket{"subzerodegree", rating.SubZeroDegree})
+	case "zerodegree":
+		buckets = append(buckets, RatingBucket{"zerodegree", rating.ZeroDegree})
+	case "tendegree":
+		buckets = append(buckets, RatingBucket{"tendegree", rating.TenDegree})
+	case "twentiedegree":
+		buckets = append(buckets, RatingBucket{"twentiedegree", rating.TwentyDegree})
+	case "thirtydegree":
+		buckets = append(buckets, RatingBucket{"thirtydegree", rating.ThirtyDegree})
+	}
+
+	return buckets
+}
+
+// Contextual averages the rating buckets that match the given weekday, season and temperature.
+// If none of them can be determined the overall rating is returned.
+func (rating *RatingStruct) Contextual(data tools.CurrentData) float64 {
+	buckets := rating.ContextBuckets(data)
+	if len(buckets) == 0 {
+		return rating.Overall
+	}
+
+	values := make([]float64, len(buckets))
+	for i, b := range buckets {
+		values[i] = b.Value
+	}
+	return tools.CalculateAverage(values)
+}
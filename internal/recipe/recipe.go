@@ -8,18 +8,19 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/lib/pq"
 	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/tools"
 )
 
 type RecipeRepository interface {
 	GetAllRecipes() ([]RecipeSchema, *error_handler.APIError)
-	GetByFilter(f *Filter) ([]RecipeSchema, *error_handler.APIError)
+	GetByFilter(f *Filter) (*RecipePage, *error_handler.APIError)
 	GetRecipeByID(id string) (*RecipeSchema, *error_handler.APIError)
 	GetRecipeAuthorbyID(id string) (string, *error_handler.APIError)
+	GetRecipeIDsByAuthor(author string) ([]string, *error_handler.APIError)
 	Create(recipe *RecipeSchema) *error_handler.APIError
 	DeleteRecipe(id string) *error_handler.APIError
 	UpdateRecipe(id string, recipe *RecipeSchema) *error_handler.APIError
@@ -27,6 +28,9 @@ type RecipeRepository interface {
 	UpdateRecipeSelect(id string) *error_handler.APIError
 	AddIngredient(id string, ingredient *IngredientsSchema) *error_handler.APIError
 	DeleteIngredient(id string, ingredientID string) *error_handler.APIError
+	RefreshNutrition(id string) *error_handler.APIError
+	Substitutions(id string, o *SubstitutionOptions) (*SubstitutionPlan, *error_handler.APIError)
+	CreateVariant(id string, author string, v *VariantRequest) (*RecipeSchema, *error_handler.APIError)
 }
 
 type Filter struct {
@@ -38,6 +42,17 @@ type Filter struct {
 	CookingTime *string   `db:"cooking_time" json:"cooking_time"`
 	Ingredients *[]string `json:"ingredients"`
 	Diets       *[]string `json:"diets"`
+	Sort        string    `json:"sort" form:"sort"`
+	Limit       int       `json:"limit" form:"limit"`
+	Cursor      string    `json:"cursor" form:"cursor"`
+	// ExcludeAllergens removes recipes containing any of the allergens
+	ExcludeAllergens []string `json:"exclude_allergens" form:"exclude_allergens"`
+	// ExcludeIngredients removes recipes using any of the ingredient ids
+	ExcludeIngredients []string `json:"exclude_ingredients" form:"exclude_ingredients"`
+	// IgnorePreferences turns off the defaults from the users preferences, allergies are still excluded
+	IgnorePreferences bool `json:"ignore_preferences" form:"ignore_preferences"`
+	// Context is needed by the contextual sort
+	Context *tools.CurrentData `json:"-" form:"-"`
 }
 
 type RecipeRepo struct {
@@ -88,20 +103,43 @@ func (rp *RecipeRepo) GetAllRecipes() ([]RecipeSchema, *error_handler.APIError)
 	return recipes, nil
 }
 
-func (rp *RecipeRepo) GetByFilter(f *Filter) ([]RecipeSchema, *error_handler.APIError) {
-	recipes := []RecipeSchema{}
+const ratingColumns = `rt.id AS "rating.id", rt.created_at AS "rating.created_at",
+								rt.recipe_id AS "rating.recipe_id", rt.overall AS "rating.overall", rt.mon AS "rating.mon",
+								rt.tue AS "rating.tue", rt.wed AS "rating.wed", rt.thu AS "rating.thu", rt.fri AS "rating.fri",
+								rt.sat AS "rating.sat", rt.sun AS "rating.sun", rt.win AS "rating.win",
+								rt.spr AS "rating.spr", rt.sum AS "rating.sum", rt.aut AS "rating.aut",
+								rt.thirtydegree AS "rating.thirtydegree", rt.twentiedegree AS "rating.twentiedegree",
+								rt.tendegree AS "rating.tendegree", rt.zerodegree AS "rating.zerodegree",
+								rt.subzerodegree AS "rating.subzerodegree"`
+
+// GetByFilter returns one page of the recipes matching the filter, sorted by f.Sort.
+// The next page is requested by setting f.Cursor to the NextCursor of the page.
+func (rp *RecipeRepo) GetByFilter(f *Filter) (*RecipePage, *error_handler.APIError) {
+	sort, apiErr := f.sortBy()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	limit, apiErr := f.limit()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+
 	var where []string
 	var args []interface{}
 
 	if f.SearchText != nil {
-		where = append(where, `to_tsvector('english', recipes.name) @@ websearch_to_tsquery('english', $1)
-					OR to_tsvector('english', ingredient.name) @@ websearch_to_tsquery('english', $1)
-					OR to_tsvector('english', step.step) @@ websearch_to_tsquery('english', $1)`)
 		args = append(args, f.SearchText)
+		where = append(where, fmt.Sprintf(`(to_tsvector('english', recipes.name) @@ websearch_to_tsquery('english', $%[1]d)
+					OR to_tsvector('english', ingredient.name) @@ websearch_to_tsquery('english', $%[1]d)
+					OR to_tsvector('english', step.step) @@ websearch_to_tsquery('english', $%[1]d))`, len(args)))
 	}
 	if f.NutriScore != nil {
-		args = append(args, f.NutriScore)
-		where = append(where, fmt.Sprintf(`nutritional_value.nutriscore = :$%d`, len(args)))
+		letter := strings.ToUpper(strings.TrimSpace(*f.NutriScore))
+		if len(letter) != 1 || !strings.Contains("ABCDE", letter) {
+			return nil, error_handler.New("nutriscore has to be one of A, B, C, D or E", http.StatusBadRequest, errors.New("invalid nutriscore"))
+		}
+		args = append(args, letter)
+		where = append(where, fmt.Sprintf(`nutritional_value.nutriscore = $%d`, len(args)))
 	}
 	if f.Cuisine != nil {
 		args = append(args, f.Cuisine)
@@ -124,50 +162,95 @@ func (rp *RecipeRepo) GetByFilter(f *Filter) ([]RecipeSchema, *error_handler.API
 	if f.Diets != nil {
 		for _, d := range *f.Diets {
 			args = append(args, d)
-			where = append(where, fmt.Sprintf(`diet.id = $%d`, len(args)))
+			// Explicit tags that contradict the ingredients don't count
+			where = append(where, fmt.Sprintf(`EXISTS (SELECT 1 FROM recipe_diet rd WHERE rd.recipe_id = recipes.id AND rd.diet_id::text = $%[1]d
+					AND NOT EXISTS (SELECT 1 FROM diet_conflict c WHERE c.recipe_id = recipes.id AND c.diet_id = rd.diet_id))`, len(args)))
 		}
 	}
 
-	query := fmt.Sprintf(
-		`SELECT id, created_at, author, name, cuisine, yield, yield_unit, prep_time, cooking_time, version, selects, views
-	    FROM (
-	        SELECT DISTINCT ON (recipes.id) recipes.*, log.view_change
+	if len(f.ExcludeIngredients) > 0 {
+		args = append(args, pq.Array(f.ExcludeIngredients))
+		where = append(where, fmt.Sprintf(`NOT EXISTS (SELECT 1 FROM recipe_ingredient ri
+					WHERE ri.recipe_id = recipes.id AND ri.ingredient_id::text = ANY($%d))`, len(args)))
+	}
+	if len(f.ExcludeAllergens) > 0 {
+		args = append(args, pq.Array(f.ExcludeAllergens))
+		where = append(where, fmt.Sprintf(`NOT EXISTS (SELECT 1 FROM recipe_allergen ra
+					WHERE ra.recipe_id = recipes.id AND ra.allergen_id = ANY($%d))`, len(args)))
+	}
+
+	whereClause := ""
+	if len(where) > 0 {
+		whereClause = "WHERE " + strings.Join(where, " AND ")
+	}
+	// Every recipe once with its latest log entry
+	filtered := fmt.Sprintf(`SELECT DISTINCT ON (recipes.id) recipes.*, %s, %s AS sort_value
 	        FROM recipes
+	        LEFT JOIN rating rt ON rt.recipe_id = recipes.id
 	        LEFT JOIN recipe_ingredient ON recipes.id = recipe_ingredient.recipe_id
 	        LEFT JOIN ingredient ON ingredient.id = recipe_ingredient.ingredient_id
 	        LEFT JOIN nutritional_value ON recipes.id = nutritional_value.recipe_id
 	        LEFT JOIN step ON recipes.id = step.recipe_id
 	        LEFT JOIN recipe_selects_views_log log ON recipes.id = log.recipe_id
-	        LEFT JOIN rel_diet_recipe rel ON recipes.id = rel.recipe_id
-	        LEFT JOIN diet ON rel.diet_id = diet.id
 	        %s
-	        ORDER BY recipes.id, log.day DESC, log.view_change DESC
-	    ) subquery
-	    ORDER BY subquery.view_change DESC;`,
-		func() string {
-			if len(where) > 0 {
-				return "WHERE " + strings.Join(where, " AND ")
-			}
-			return ""
-		}(),
-	)
+	        ORDER BY recipes.id, log.day DESC, log.view_change DESC`, ratingColumns, sort.key, whereClause)
 
-	err := rp.DB.Select(&recipes, query, args...)
+	page := &RecipePage{Recipes: []RecipeSchema{}}
+	err := rp.DB.Get(&page.Total, fmt.Sprintf(`SELECT COUNT(*) FROM (%s) subquery`, filtered), args...)
 	if err != nil {
 		return nil, error_handler.New("Dtabase error: "+err.Error(), http.StatusInternalServerError, err)
 	}
 
-	if len(recipes) <= 0 {
-		return nil, nil
+	direction, compare := "DESC", "<"
+	if sort.asc {
+		direction, compare = "ASC", ">"
+	}
+	after := ""
+	if f.Cursor != "" {
+		c, apiErr := decodeCursor(f.Cursor, f.Sort)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		args = append(args, c.Value, c.CreatedAt, c.ID)
+		after = fmt.Sprintf(`WHERE (subquery.sort_value, subquery.created_at, subquery.id) %s ($%d::%s, $%d::timestamp, $%d::uuid)`,
+			compare, len(args)-2, sort.cast, len(args)-1, len(args))
 	}
+	args = append(args, limit+1)
 
-	apierr := rp.completeRecipes(recipes)
+	query := fmt.Sprintf(`SELECT * FROM (%s) subquery
+	    %s
+	    ORDER BY subquery.sort_value %[3]s, subquery.created_at %[3]s, subquery.id %[3]s
+	    LIMIT $%d;`, filtered, after, direction, len(args))
+
+	var rows []struct {
+		RecipeSchema
+		SortValue string `db:"sort_value"`
+	}
+	err = rp.DB.Select(&rows, query, args...)
+	if err != nil {
+		return nil, error_handler.New("Dtabase error: "+err.Error(), http.StatusInternalServerError, err)
+	}
+
+	if len(rows) > limit {
+		last := rows[limit-1]
+		page.NextCursor = (&cursor{Sort: f.Sort, Value: last.SortValue, CreatedAt: last.CreatedAt, ID: last.ID}).encode()
+		rows = rows[:limit]
+	}
+	if len(rows) <= 0 {
+		return page, nil
+	}
+
+	page.Recipes = make([]RecipeSchema, len(rows))
+	for i := range rows {
+		page.Recipes[i] = rows[i].RecipeSchema
+	}
+	apierr := rp.completeRecipes(page.Recipes)
 	if apierr != nil {
 		return nil, apierr
 	}
 
-	return recipes, nil
-} 
+	return page, nil
+}
 
 func (rp *RecipeRepo) completeRecipes(recipes []RecipeSchema) *error_handler.APIError {
 	// Prepare
@@ -220,47 +303,31 @@ func (rp *RecipeRepo) completeRecipes(recipes []RecipeSchema) *error_handler.API
 		}
 	}
 
-
-
-	//Get Diets
-	var diets []struct {
-		RecipeID      string            `db:"recipe_id"`
-		ID            string            `db:"id" json:"id"`
-		CreatedAt     time.Time         `db:"created_at" json:"created_at"`
-		Name          string            `db:"name" json:"name"`
-		Description   string            `db:"description" json:"description"`
-		ExIngCategory []Category 		`json:"exingcategory"`
-	}
-	query, args, err = sqlx.In(`
-		SELECT diet.*, rel.recipe_id
-		FROM rel_diet_recipe rel
-		JOIN diet ON rel.diet_id = diet.id
-		WHERE rel.recipe_id IN (?)
-	`, id_array)
+	// Get nutritional values
+	nutrition := []NutritionalValue{}
+	query, args, err = sqlx.In(`SELECT nv.created_at, nv.recipe_id, `+nutritionColumns("")+`
+		FROM nutritional_value nv WHERE nv.recipe_id IN (?)`, id_array)
 	if err != nil {
-		return error_handler.New("Error while getting diets", http.StatusInternalServerError, err)
+		return error_handler.New("error building nutritional values query: "+err.Error(), http.StatusInternalServerError, err)
 	}
 
-	query = rp.DB.Rebind(query)
-
-	err = rp.DB.Select(&diets, query, args...)
+	err = rp.DB.Select(&nutrition, rp.DB.Rebind(query), args...)
 	if err != nil {
-		return error_handler.New("error fetching steps: "+err.Error(), http.StatusInternalServerError, err)
+		return error_handler.New("error fetching nutritional values: "+err.Error(), http.StatusInternalServerError, err)
 	}
 
-
-	for _, rd := range diets {
-		if recipe, exists := recipeMap[rd.RecipeID]; exists {
-			recipe.Diet = append(recipe.Diet, DietSchema{
-				ID:          rd.ID,
-				CreatedAt:   rd.CreatedAt,
-				Name:        rd.Name,
-				Description: rd.Description,
-			})
+	for _, nv := range nutrition {
+		if recipe, found := recipeMap[*nv.RecipeID]; found {
+			recipe.NutritionalValue = nv
 		}
 	}
 
-	return nil
+	apierr := loadDiets(recipeMap, rp.DB)
+	if apierr != nil {
+		return apierr
+	}
+
+	return loadAllergens(recipeMap, rp.DB)
 }
 
 func (rp *RecipeRepo) GetRecipeByID(id string) (*RecipeSchema, *error_handler.APIError) {
@@ -277,6 +344,9 @@ func (rp *RecipeRepo) GetRecipeByID(id string) (*RecipeSchema, *error_handler.AP
 							FROM recipes
 							LEFT JOIN rating rt ON rt.recipe_id = recipes.id
 							WHERE recipes.id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, error_handler.New("Recipe doesn't exist", http.StatusNotFound, err)
+	}
 	if err != nil {
 		return nil, error_handler.New("An error ocurred fetching the recipe: "+err.Error(), http.StatusInternalServerError, err)
 	}
@@ -294,14 +364,20 @@ func (rp *RecipeRepo) GetRecipeByID(id string) (*RecipeSchema, *error_handler.AP
 		return nil, error_handler.New("An error ocurred fetching the ingredients: "+err.Error(), http.StatusInternalServerError, err)
 	}
 
-	err = rp.DB.Select(&recipe.Diet, `
-		SELECT diet.*
-		FROM rel_diet_recipe rel
-		JOIN diet  ON rel.diet_id = diet.id
-		WHERE rel.recipe_id = $1
-	`, recipe.ID)
-	if err != nil {
-		return nil, error_handler.New("Error while getting nutritional values", http.StatusBadRequest, err)
+	err = rp.DB.Get(&recipe.NutritionalValue, `SELECT nv.created_at, nv.recipe_id, `+nutritionColumns("")+`
+		FROM nutritional_value nv WHERE nv.recipe_id = $1`, recipe.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, error_handler.New("Error while getting nutritional values", http.StatusInternalServerError, err)
+	}
+
+	recipeMap := map[string]*RecipeSchema{recipe.ID: recipe}
+	apiErr := loadDiets(recipeMap, rp.DB)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	apiErr = loadAllergens(recipeMap, rp.DB)
+	if apiErr != nil {
+		return nil, apiErr
 	}
 
 	return recipe, nil
@@ -323,11 +399,27 @@ func (rp *RecipeRepo) GetRecipeAuthorbyID(id string) (string, *error_handler.API
 	return owner, nil
 }
 
+// GetRecipeIDsByAuthor returns the ids of all recipes of the author, oldest first
+func (rp *RecipeRepo) GetRecipeIDsByAuthor(author string) ([]string, *error_handler.APIError) {
+	ids := []string{}
+	err := rp.DB.Select(&ids, `SELECT id FROM recipes WHERE author = $1 ORDER BY created_at, id`, author)
+	if err != nil {
+		return nil, error_handler.New("Error while getting recipes: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return ids, nil
+}
+
 func (rp *RecipeRepo) Create(recipe *RecipeSchema) *error_handler.APIError {
+	// Looked up before the transaction so all unknown ingredients are reported at once
+	apiErr := recipe.ResolveIngredients(rp.DB)
+	if apiErr != nil {
+		return apiErr
+	}
+
 	tx := rp.DB.MustBegin()
 	// Insert recipe
-	query := `INSERT INTO recipes (author, name, cuisine, yield, yield_unit, prep_time, cooking_time, version)
-              VALUES (:author, :name, :cuisine, :yield, :yield_unit, :prep_time, :cooking_time, :version) RETURNING id`
+	query := `INSERT INTO recipes (author, name, cuisine, yield, yield_unit, prep_time, cooking_time, version, variant_of)
+              VALUES (:author, :name, :cuisine, :yield, :yield_unit, :prep_time, :cooking_time, :version, :variant_of) RETURNING id`
 	stmt, err := tx.PrepareNamed(query)
 	if err != nil {
 		return error_handler.New("Query error: "+err.Error(), http.StatusInternalServerError, err)
@@ -356,18 +448,29 @@ func (rp *RecipeRepo) Create(recipe *RecipeSchema) *error_handler.APIError {
 	}
 
 	// Insert Ingredient
-	for _, ing := range recipe.Ingredients {
+	// Steps may reference the ingredients by the ids they had before, like the ones of a copied recipe
+	ingredientIDs := map[string]string{}
+	for i := range recipe.Ingredients {
+		ing := &recipe.Ingredients[i]
+		previous := ing.ID
 		ing.RecipeID = recipe.ID
-		err := rp.IngRep.Create(&ing, tx)
+		err := rp.IngRep.Create(ing, tx)
 		if err != nil {
 			tx.Rollback()
 			return err
 		}
+		if previous != "" {
+			ingredientIDs[previous] = ing.ID
+		}
 	}
 
 	//Insert Steps
 	for _, s := range recipe.Steps {
 		s.RecipeID = recipe.ID
+		if s.IngredientID != nil && ingredientIDs[*s.IngredientID] != "" {
+			id := ingredientIDs[*s.IngredientID]
+			s.IngredientID = &id
+		}
 		err := rp.StepRepo.Create(&s, tx)
 		if err != nil {
 			tx.Rollback()
@@ -383,6 +486,17 @@ func (rp *RecipeRepo) Create(recipe *RecipeSchema) *error_handler.APIError {
 			return err
 		}
 	}
+	apiErr = rp.DietRepo.CheckConflicts(recipe.ID, tx)
+	if apiErr != nil {
+		tx.Rollback()
+		return apiErr
+	}
+
+	apiErr = saveNutrition(recipe, tx)
+	if apiErr != nil {
+		tx.Rollback()
+		return apiErr
+	}
 
 	err = tx.Commit()
 	if err != nil {
@@ -437,7 +551,7 @@ func (rp *RecipeRepo) UpdateRecipe(id string, recipe *RecipeSchema) *error_handl
 		args = append(args, recipe.CookingTime)
 	}
 
-	if len(setParts) == 0 && len(recipe.Ingredients) == 0 {
+	if len(setParts) == 0 && len(recipe.Ingredients) == 0 && recipe.Diet == nil {
 		// No fields to body
 		return error_handler.New("Nothing to update", http.StatusExpectationFailed, errors.New("nothing to update"))
 	}
@@ -465,12 +579,28 @@ func (rp *RecipeRepo) UpdateRecipe(id string, recipe *RecipeSchema) *error_handl
 		}
 	}
 
+	if recipe.Diet != nil {
+		apiErr := rp.DietRepo.Replace(recipe.Diet, id, tx)
+		if apiErr != nil {
+			tx.Rollback()
+			return apiErr
+		}
+	}
+	// Recipes tagged before conflicts were checked stay editable until their diets or ingredients change
+	if recipe.Diet != nil || len(recipe.Ingredients) != 0 {
+		apiErr := rp.DietRepo.CheckConflicts(id, tx)
+		if apiErr != nil {
+			tx.Rollback()
+			return apiErr
+		}
+	}
+
 	err := tx.Commit()
 	if err != nil {
 		return error_handler.New("Error updating recipe", http.StatusInternalServerError, err)
 	}
 
-	return nil
+	return rp.RefreshNutrition(id)
 }
 
 func (rp *RecipeRepo) UpdateRecipeView(id string) *error_handler.APIError {
@@ -491,9 +621,29 @@ func (rp *RecipeRepo) UpdateRecipeSelect(id string) *error_handler.APIError {
 
 func (rp *RecipeRepo) AddIngredient(id string, ingredient *IngredientsSchema) *error_handler.APIError {
 	ingredient.RecipeID = id
-	return rp.IngRep.Create(ingredient, rp.DB)
+	tx := rp.DB.MustBegin()
+	err := rp.IngRep.Create(ingredient, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	// The recipe may be tagged with a diet that excludes the ingredient
+	err = rp.DietRepo.CheckConflicts(id, tx)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		return error_handler.New("Error adding ingredient", http.StatusInternalServerError, commitErr)
+	}
+	return rp.RefreshNutrition(id)
 }
 
 func (rp *RecipeRepo) DeleteIngredient(id string, ingredientID string) *error_handler.APIError {
-	return rp.IngRep.Delete(id, ingredientID, rp.DB)
+	err := rp.IngRep.Delete(id, ingredientID, rp.DB)
+	if err != nil {
+		return err
+	}
+	return rp.RefreshNutrition(id)
 }
\ No newline at end of file
@@ -24,8 +24,11 @@ type RecipeSchema struct {
 	Selects          int       `db:"selects"`
 	Views            int       `db:"views"`
 	Version          int64     `db:"version"`
+	// VariantOf is the id of the recipe this one was derived from by substituting ingredients
+	VariantOf        *string   `db:"variant_of"`
 	Ingredients      []IngredientsSchema
 	Diet             []DietSchema
+	Allergens        []Allergen
 	NutritionalValue NutritionalValue
 	Rating           RatingStruct `db:"rating"`
 	Steps            []StepsStruct
@@ -83,6 +86,10 @@ func (recipe *RecipeSchema) checkForRequiredFields() *error_handler.APIError {
 }
 
 func (recipe *RecipeSchema) Build(authorid string) *error_handler.APIError {
+	err := recipe.ParseRawIngredients()
+	if err != nil {
+		return error_handler.New("couldn't parse ingredient "+err.Error(), http.StatusBadRequest, err)
+	}
 	// Ensure Recipe has all required fields
 	apiErr := recipe.checkForRequiredFields()
 	if apiErr != nil {
@@ -0,0 +1,67 @@
+package recipe
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/units"
+)
+
+const MaxServings = 1000
+
+type ScaledRecipe struct {
+	RecipeSchema
+	Servings float64 `json:"servings"`
+	Factor   float64 `json:"factor"`
+	// NutritionTotal is the nutrition of all servings together, NutritionalValue stays per serving
+	NutritionTotal NutritionalValue `json:"nutrition_total"`
+}
+
+// Scale returns a copy of the recipe with its ingredients scaled to the given number of servings
+func (recipe *RecipeSchema) Scale(servings float64) (*ScaledRecipe, *error_handler.APIError) {
+	if servings <= 0 || servings > MaxServings || math.IsNaN(servings) {
+		return nil, error_handler.New(fmt.Sprintf("servings has to be between 0 and %d", MaxServings), http.StatusBadRequest, errors.New("invalid servings"))
+	}
+	factor := servings / recipe.Servings()
+
+	scaled := &ScaledRecipe{RecipeSchema: *recipe, Servings: servings, Factor: factor}
+	scaled.Yield = int(math.Max(1, math.Round(float64(recipe.Yield)*factor)))
+	scaled.Ingredients = make([]IngredientsSchema, len(recipe.Ingredients))
+	for i, ing := range recipe.Ingredients {
+		unit := ing.Unit
+		ing.Amount, ing.Unit = units.Scale(ing.Amount, ing.Unit, factor)
+		if ing.AmountMax != nil {
+			// The upper end stays in the unit of the lower one
+			max, err := units.Convert(*ing.AmountMax*factor, unit, ing.Unit)
+			if err != nil {
+				max = *ing.AmountMax * factor
+			}
+			max = units.Round(max, ing.Unit)
+			ing.AmountMax = &max
+		}
+		scaled.Ingredients[i] = ing
+	}
+
+	nv := recipe.NutritionalValue
+	scaled.NutritionTotal = NutritionalValue{
+		RecipeID:     nv.RecipeID,
+		Kcal:         nv.Kcal * servings,
+		Kj:           nv.Kj * servings,
+		Fat:          nv.Fat * servings,
+		SaturatedFat: nv.SaturatedFat * servings,
+		Carbohydrate: nv.Carbohydrate * servings,
+		Sugar:        nv.Sugar * servings,
+		Protein:      nv.Protein * servings,
+		Salt:         nv.Salt * servings,
+		Fiber:        nv.Fiber * servings,
+		// The score is per 100 g and doesn't change with the amount
+		Nutriscore:       nv.Nutriscore,
+		NutriscorePoints: nv.NutriscorePoints,
+		Incomplete:       nv.Incomplete,
+		Missing:          nv.Missing,
+	}
+	return scaled, nil
+}
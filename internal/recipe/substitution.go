@@ -0,0 +1,357 @@
+package recipe
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/units"
+)
+
+const (
+	ReasonDiet     = "diet"
+	ReasonExcluded = "excluded"
+)
+
+// Substitute replaces an ingredient by Ratio times its amount in the same unit
+type Substitute struct {
+	ID           string  `db:"id" json:"id"`
+	IngredientID string  `db:"ingredient_id" json:"ingredient_id"`
+	SubstituteID string  `db:"substitute_id" json:"substitute_id"`
+	Name         string  `db:"name" json:"name"`
+	Ratio        float64 `db:"ratio" json:"ratio"`
+	// Context like baking limits where the substitute works, empty means everywhere
+	Context string `db:"context" json:"context,omitempty"`
+	Note    string `db:"note" json:"note,omitempty"`
+	// Diets are the ids of the diets with exclusions the substitute follows
+	Diets pq.StringArray `db:"diets" json:"diets"`
+}
+
+type SubstitutionOptions struct {
+	// Diet is the id of a diet whose excluded ingredients are replaced
+	Diet string `json:"diet" form:"diet"`
+	// Exclude are the ids of ingredients that are replaced, like ones the user doesn't have
+	Exclude []string `json:"exclude" form:"exclude"`
+	// Choose picks another substitute than the suggested one, keyed by the id of the replaced ingredient
+	Choose map[string]string `json:"choose" form:"-"`
+}
+
+type Substitution struct {
+	Original IngredientsSchema `json:"original"`
+	Reason   string            `json:"reason"`
+	// Substitute is nil if none of the alternatives can be used
+	Substitute   *Substitute  `json:"substitute"`
+	Alternatives []Substitute `json:"alternatives"`
+}
+
+type SubstitutionPlan struct {
+	RecipeID string `json:"recipe_id"`
+	Diet     string `json:"diet,omitempty"`
+	// Ingredients is the ingredient list with the substitutes, unresolved ingredients stay in it
+	Ingredients   []IngredientsSchema `json:"ingredients"`
+	Substitutions []Substitution      `json:"substitutions"`
+	// Unresolved are the names of the ingredients that have to be replaced but have no usable substitute
+	Unresolved []string `json:"unresolved"`
+}
+
+// NoSubstituteError lists the ingredients a variant can't be saved without
+type NoSubstituteError struct {
+	Ingredients []string
+}
+
+func (e *NoSubstituteError) Error() string {
+	return "no substitute for " + strings.Join(e.Ingredients, ", ")
+}
+
+func (e *NoSubstituteError) Details() any {
+	return map[string][]string{"unresolved": e.Ingredients}
+}
+
+// GetSubstitutes returns the substitutes of the ingredients, the ones working everywhere first
+func GetSubstitutes(ingredientIDs []string, db database.SQLDB) ([]Substitute, *error_handler.APIError) {
+	subs := []Substitute{}
+	err := db.Select(&subs, `SELECT s.id, s.ingredient_id, s.substitute_id, i.name, s.ratio, s.context, s.note,
+			ARRAY(SELECT d.id::text FROM diet d
+				WHERE COALESCE(i.category, '') <> ''
+				  AND EXISTS (SELECT 1 FROM diet_excluded_category ex WHERE ex.diet_id = d.id)
+				  AND NOT EXISTS (SELECT 1 FROM diet_excluded_category ex
+					WHERE ex.diet_id = d.id AND LOWER(ex.name) = LOWER(i.category))
+				ORDER BY d.id) AS diets
+		FROM ingredient_substitute s
+		JOIN ingredient i ON i.id = s.substitute_id
+		WHERE s.ingredient_id::text = ANY($1)
+		ORDER BY s.ingredient_id, s.context <> '', LOWER(i.name), LOWER(s.context)`, pq.Array(ingredientIDs))
+	if err != nil {
+		return nil, error_handler.New("Error getting substitutes: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	for i := range subs {
+		if subs[i].Diets == nil {
+			subs[i].Diets = pq.StringArray{}
+		}
+	}
+	return subs, nil
+}
+
+// AddSubstitute adds or updates the substitute of the ingredient ingredientID
+func AddSubstitute(ingredientID string, sub *Substitute, db database.SQLDB) *error_handler.APIError {
+	sub.IngredientID = ingredientID
+	sub.Context = strings.TrimSpace(sub.Context)
+	if sub.Ratio == 0 {
+		sub.Ratio = 1
+	}
+	if sub.Ratio < 0 {
+		return error_handler.New("ratio has to be positive", http.StatusBadRequest, errors.New("negative ratio"))
+	}
+	if sub.SubstituteID == ingredientID {
+		return error_handler.New("An ingredient can't substitute itself", http.StatusBadRequest, errors.New("self substitute"))
+	}
+	for _, id := range []string{ingredientID, sub.SubstituteID} {
+		var exists bool
+		err := db.Get(&exists, `SELECT EXISTS (SELECT 1 FROM ingredient WHERE id::text = $1)`, id)
+		if err != nil {
+			return ingredientError("Error getting ingredient", err)
+		}
+		if !exists {
+			return error_handler.New("Ingredient "+id+" doesn't exist", http.StatusNotFound, errors.New("unknown ingredient"))
+		}
+	}
+
+	_, err := db.Exec(`INSERT INTO ingredient_substitute (ingredient_id, substitute_id, ratio, context, note)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (ingredient_id, substitute_id, LOWER(context)) DO UPDATE SET ratio = EXCLUDED.ratio, note = EXCLUDED.note`,
+		ingredientID, sub.SubstituteID, sub.Ratio, sub.Context, sub.Note)
+	if err != nil {
+		return ingredientError("Error adding substitute", err)
+	}
+	return nil
+}
+
+// DeleteSubstitute removes the substitute with the id from the ingredient
+func DeleteSubstitute(ingredientID string, id string, db database.SQLDB) *error_handler.APIError {
+	result, err := db.Exec(`DELETE FROM ingredient_substitute WHERE ingredient_id::text = $1 AND id::text = $2`, ingredientID, id)
+	if err != nil {
+		return ingredientError("Error deleting substitute", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return error_handler.New("Substitute doesn't exist", http.StatusNotFound, errors.New("substitute not found"))
+	}
+	return nil
+}
+
+// dietExcludedIngredients returns the ids of the recipe ingredients the diet excludes
+func dietExcludedIngredients(recipeID string, dietID string, db database.SQLDB) (map[string]bool, *error_handler.APIError) {
+	var exists bool
+	err := db.Get(&exists, `SELECT EXISTS (SELECT 1 FROM diet WHERE id::text = $1)`, dietID)
+	if err != nil {
+		return nil, dietError("Error getting diet", err)
+	}
+	if !exists {
+		return nil, error_handler.New("Diet doesn't exist", http.StatusNotFound, errors.New("diet not found"))
+	}
+
+	ids := []string{}
+	err = db.Select(&ids, `SELECT DISTINCT ri.ingredient_id
+		FROM recipe_ingredient ri
+		JOIN ingredient ON ingredient.id = ri.ingredient_id
+		JOIN diet_excluded_category ex ON ex.diet_id::text = $2 AND LOWER(ex.name) = LOWER(ingredient.category)
+		WHERE ri.recipe_id::text = $1`, recipeID, dietID)
+	if err != nil {
+		return nil, error_handler.New("Error checking diet: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	excluded := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		excluded[id] = true
+	}
+	return excluded, nil
+}
+
+// Substitutions proposes an ingredient list for the recipe without the excluded ingredients and the
+// ones the diet excludes
+func (rp *RecipeRepo) Substitutions(id string, o *SubstitutionOptions) (*SubstitutionPlan, *error_handler.APIError) {
+	recipe, apiErr := rp.GetRecipeByID(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return substitutionPlan(recipe, o, rp.DB)
+}
+
+func substitutionPlan(recipe *RecipeSchema, o *SubstitutionOptions, db database.SQLDB) (*SubstitutionPlan, *error_handler.APIError) {
+	reasons := map[string]string{}
+	for _, id := range o.Exclude {
+		reasons[id] = ReasonExcluded
+	}
+	if o.Diet != "" {
+		excluded, apiErr := dietExcludedIngredients(recipe.ID, o.Diet, db)
+		if apiErr != nil {
+			return nil, apiErr
+		}
+		for id := range excluded {
+			reasons[id] = ReasonDiet
+		}
+	}
+
+	replaced := []string{}
+	for _, ing := range recipe.Ingredients {
+		if reasons[ing.IngredientID] != "" {
+			replaced = append(replaced, ing.IngredientID)
+		}
+	}
+	for original := range o.Choose {
+		if reasons[original] == "" {
+			return nil, error_handler.New("Ingredient "+original+" isn't replaced", http.StatusBadRequest, errors.New("unknown choice"))
+		}
+	}
+	subs, apiErr := GetSubstitutes(replaced, db)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	byIngredient := map[string][]Substitute{}
+	for _, s := range subs {
+		byIngredient[s.IngredientID] = append(byIngredient[s.IngredientID], s)
+	}
+
+	plan := &SubstitutionPlan{
+		RecipeID:      recipe.ID,
+		Diet:          o.Diet,
+		Ingredients:   []IngredientsSchema{},
+		Substitutions: []Substitution{},
+		Unresolved:    []string{},
+	}
+	for _, ing := range recipe.Ingredients {
+		reason := reasons[ing.IngredientID]
+		if reason == "" {
+			plan.Ingredients = append(plan.Ingredients, ing)
+			continue
+		}
+
+		s := Substitution{Original: ing, Reason: reason, Alternatives: []Substitute{}}
+		for _, alt := range byIngredient[ing.IngredientID] {
+			if reasons[alt.SubstituteID] != "" || (o.Diet != "" && !contains(alt.Diets, o.Diet)) {
+				continue
+			}
+			s.Alternatives = append(s.Alternatives, alt)
+		}
+		chosen, ok := o.Choose[ing.IngredientID]
+		for i := range s.Alternatives {
+			if !ok || s.Alternatives[i].SubstituteID == chosen || s.Alternatives[i].ID == chosen {
+				s.Substitute = &s.Alternatives[i]
+				break
+			}
+		}
+		if ok && s.Substitute == nil {
+			msg := fmt.Sprintf("%s can't be replaced by %s", ing.Name, chosen)
+			return nil, error_handler.New(msg, http.StatusBadRequest, errors.New(msg))
+		}
+		plan.Substitutions = append(plan.Substitutions, s)
+
+		if s.Substitute == nil {
+			plan.Unresolved = append(plan.Unresolved, ing.Name)
+			plan.Ingredients = append(plan.Ingredients, ing)
+			continue
+		}
+		plan.Ingredients = append(plan.Ingredients, s.Substitute.apply(ing))
+	}
+	return plan, nil
+}
+
+// apply returns the substitute for the recipe ingredient
+func (sub *Substitute) apply(ing IngredientsSchema) IngredientsSchema {
+	replaced := IngredientsSchema{
+		RecipeID:     ing.RecipeID,
+		IngredientID: sub.SubstituteID,
+		Name:         sub.Name,
+		Amount:       units.Round(ing.Amount*sub.Ratio, ing.Unit),
+		Unit:         ing.Unit,
+		Note:         ing.Note,
+	}
+	if ing.AmountMax != nil {
+		max := units.Round(*ing.AmountMax*sub.Ratio, ing.Unit)
+		replaced.AmountMax = &max
+	}
+	return replaced
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+type VariantRequest struct {
+	SubstitutionOptions
+	// Name defaults to the name of the recipe followed by (variant)
+	Name string `json:"name"`
+}
+
+// CreateVariant saves a copy of the recipe with the substitutes as a recipe of the author
+func (rp *RecipeRepo) CreateVariant(id string, author string, v *VariantRequest) (*RecipeSchema, *error_handler.APIError) {
+	original, apiErr := rp.GetRecipeByID(id)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	plan, apiErr := substitutionPlan(original, &v.SubstitutionOptions, rp.DB)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if len(plan.Unresolved) > 0 {
+		err := &NoSubstituteError{Ingredients: plan.Unresolved}
+		return nil, error_handler.New("Can't save the variant, there is "+err.Error(), http.StatusUnprocessableEntity, err)
+	}
+	if len(plan.Substitutions) == 0 {
+		return nil, error_handler.New("The recipe has none of the ingredients to substitute", http.StatusBadRequest, errors.New("nothing to substitute"))
+	}
+
+	variant := &RecipeSchema{
+		Author:      author,
+		Name:        strings.TrimSpace(v.Name),
+		Cuisine:     original.Cuisine,
+		Yield:       original.Yield,
+		YieldUnit:   original.YieldUnit,
+		PrepTime:    original.PrepTime,
+		CookingTime: original.CookingTime,
+		VariantOf:   &original.ID,
+	}
+	if variant.Name == "" {
+		variant.Name = original.Name + " (variant)"
+	}
+	variant.Rating.DefaultRatingStruct(&variant.ID, nil)
+
+	// The plan has the ingredients in the order of the recipe, the ids of the originals let
+	// Create point the copied steps at the variant's ingredients
+	for i, ing := range plan.Ingredients {
+		variant.Ingredients = append(variant.Ingredients, IngredientsSchema{
+			ID:           original.Ingredients[i].ID,
+			IngredientID: ing.IngredientID,
+			Name:         ing.Name,
+			Amount:       ing.Amount,
+			AmountMax:    ing.AmountMax,
+			Unit:         ing.Unit,
+			Note:         ing.Note,
+		})
+	}
+	for _, step := range original.Steps {
+		variant.Steps = append(variant.Steps, StepsStruct{Step: step.Step, TechniqueID: step.TechniqueID, IngredientID: step.IngredientID})
+	}
+	// The explicit diets are kept, inferred ones are inferred again
+	for _, d := range original.Diet {
+		if !d.Inferred && d.ID != v.Diet {
+			variant.Diet = append(variant.Diet, DietSchema{ID: d.ID})
+		}
+	}
+	if v.Diet != "" {
+		variant.Diet = append(variant.Diet, DietSchema{ID: v.Diet})
+	}
+
+	apiErr = rp.Create(variant)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	return rp.GetRecipeByID(variant.ID)
+}
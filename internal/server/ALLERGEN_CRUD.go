@@ -0,0 +1,81 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/user"
+)
+
+type allergiesBody struct {
+	Allergies []string `json:"allergies" binding:"required"`
+}
+
+type allergenCategoriesBody struct {
+	Categories []string `json:"categories" binding:"required"`
+}
+
+func (s *Server) GetAllergens(c *gin.Context) {
+	allergens, err := recipe.GetAllergens(s.NewDB)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, allergens)
+}
+
+// SetAllergenCategories replaces the ingredient categories that contain the allergen
+func (s *Server) SetAllergenCategories(c *gin.Context) {
+	var body allergenCategoriesBody
+	binderr := c.ShouldBindJSON(&body)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
+		return
+	}
+
+	err := recipe.SetAllergenCategories(c.Param("id"), body.Categories, s.NewDB)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	s.GetAllergens(c)
+}
+
+func (s *Server) GetAllergies(c *gin.Context) {
+	u := user.UserModel{}
+	err := u.GetFromGinContext(c.Get("user"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	err = u.LoadAllergies(s.NewDB)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, allergiesBody{Allergies: u.Settings.Allergies})
+}
+
+func (s *Server) SetAllergies(c *gin.Context) {
+	u := user.UserModel{}
+	err := u.GetFromGinContext(c.Get("user"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	var body allergiesBody
+	binderr := c.ShouldBindJSON(&body)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
+		return
+	}
+
+	err = u.SetAllergies(s.NewDB, body.Allergies)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, allergiesBody{Allergies: u.Settings.Allergies})
+}
@@ -3,32 +3,62 @@ package server
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/exporter"
+	"github.com/madswillem/recipeApp/internal/importer"
 	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/tools"
 	"github.com/madswillem/recipeApp/internal/user"
 )
 
-func (s *Server) GetAll(c *gin.Context) {
-	recipes, err := s.RecipeRepo.GetAllRecipes()
+// listRecipes writes the page of recipes matching the filter with the defaults of the logged in user
+func (s *Server) listRecipes(c *gin.Context, f *recipe.Filter) {
+	if f.Sort == recipe.SortContextual {
+		data, err := tools.GetCurrentData()
+		if err != nil {
+			log.Default().Println("couldn't get the current weather, sorting without it:", err)
+		}
+		f.Context = &data
+	}
+
+	err := s.applySettings(c, f)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	page, err := s.RecipeRepo.GetByFilter(f)
 	if err != nil {
 		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
 		return
 	}
-	c.JSON(http.StatusOK, recipes)
+	c.JSON(http.StatusOK, page)
+}
+
+func (s *Server) GetAll(c *gin.Context) {
+	f := recipe.Filter{Sort: recipe.SortNewest}
+	err := c.ShouldBindQuery(&f)
+	if err != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read query", []error{err})
+		return
+	}
+	s.listRecipes(c, &f)
 }
 
 func (s *Server) GetPopular(c *gin.Context) {
-	recipes, err := s.RecipeRepo.GetByFilter(&recipe.Filter{})
+	f := recipe.Filter{Sort: recipe.SortPopular}
+	err := c.ShouldBindQuery(&f)
 	if err != nil {
-		print(err.Errors)
-		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read query", []error{err})
 		return
 	}
-	c.JSON(http.StatusOK, recipes)
+	s.listRecipes(c, &f)
 }
 
 func (s *Server) AddRecipe(c *gin.Context) {
@@ -47,7 +77,11 @@ func (s *Server) AddRecipe(c *gin.Context) {
 		return
 	}
 
-	body.Build(user.ID)
+	err = body.Build(user.ID)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
 
 	err = s.RecipeRepo.Create(&body)
 	if err != nil {
@@ -58,42 +92,74 @@ func (s *Server) AddRecipe(c *gin.Context) {
 	c.JSON(http.StatusCreated, body)
 }
 
-func (s *Server) AddIngredient(c *gin.Context) {
-	var body recipe.IngredientDB
+// maxImportSize limits the size of imported JSON-LD documents and HTML pages
+const maxImportSize = 5 << 20
 
-	binderr := c.ShouldBindJSON(&body)
-	if binderr != nil {
-		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
+func (s *Server) ImportRecipe(c *gin.Context) {
+	user := user.UserModel{}
+	err := user.GetFromGinContext(c.Get("user"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	data, readErr := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize))
+	if readErr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{readErr})
 		return
 	}
 
-	err := body.Create(s.NewDB)
+	result, err := importer.New(s.RecipeRepo, s.NewDB).Import(data, user.ID, c.Query("dry_run") == "true")
 	if err != nil {
+		if result != nil && err.Code != http.StatusInternalServerError {
+			c.AbortWithStatusJSON(err.Code, gin.H{
+				"errors":     err.Errors[0].Error(),
+				"errMessage": err.Message,
+				"unmatched":  result.Unmatched,
+			})
+			return
+		}
 		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
 		return
 	}
 
-	c.JSON(http.StatusAccepted, body)
+	status := http.StatusOK
+	if result.Created {
+		status = http.StatusCreated
+	}
+	c.JSON(status, result)
 }
 
-func (s *Server) UpdateRecipe(c *gin.Context) {
-	user := user.UserModel{}
-	usrerr := user.GetFromGinContext(c.Get("user"))
-	if usrerr != nil {
-		error_handler.HandleError(c, usrerr.Code, usrerr.Message, usrerr.Errors)
-		return
-	}
+type parsedLine struct {
+	Line       string                    `json:"line"`
+	Ingredient *recipe.IngredientsSchema `json:"ingredient"`
+	Error      string                    `json:"error,omitempty"`
+}
 
-	ok, accesserr := s.Auth.AccessControl(user.ID, c.Param("id"), "update", s.RecipeRepo)
-	if accesserr != nil {
-		error_handler.HandleError(c, accesserr.Code, accesserr.Message, accesserr.Errors)
-		return
+// ParseIngredients splits free text ingredient lines into amount, unit, name and note
+func (s *Server) ParseIngredients(c *gin.Context) {
+	var body struct {
+		Lines []string `json:"lines" binding:"required"`
 	}
-	if !ok {
-		error_handler.HandleError(c, http.StatusUnauthorized, "User is not the owner of the recipe", []error{errors.New("user is not the owner of the recipe")})
+	binderr := c.ShouldBindJSON(&body)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
 		return
 	}
 
+	result := make([]parsedLine, len(body.Lines))
+	for i, line := range body.Lines {
+		ing, err := recipe.ParseIngredientLine(line)
+		result[i] = parsedLine{Line: line, Ingredient: &ing}
+		if err != nil {
+			result[i].Error = err.Error()
+		}
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// UpdateRecipe changes the recipe, the Authorize middleware checks the user may do so
+func (s *Server) UpdateRecipe(c *gin.Context) {
 	var body recipe.RecipeSchema
 	err := c.ShouldBindJSON(&body)
 	if err != nil {
@@ -101,8 +167,6 @@ func (s *Server) UpdateRecipe(c *gin.Context) {
 		return
 	}
 
-	log.Default().Println(body.Ingredients[0].Amount)
-
 	updateerr := s.RecipeRepo.UpdateRecipe(c.Param("id"), &body)
 	if updateerr != nil {
 		error_handler.HandleError(c, updateerr.Code, updateerr.Message, updateerr.Errors)
@@ -110,26 +174,9 @@ func (s *Server) UpdateRecipe(c *gin.Context) {
 	}
 }
 
+// DeleteRecipe deletes the recipe, the Authorize middleware checks the user may do so
 func (s *Server) DeleteRecipe(c *gin.Context) {
-	user := user.UserModel{}
-	usrerr := user.GetFromGinContext(c.Get("user"))
-	if usrerr != nil {
-		error_handler.HandleError(c, usrerr.Code, usrerr.Message, usrerr.Errors)
-		return
-	}
-
-	i := c.Param("id")
-	owner, ownererr := s.RecipeRepo.GetRecipeAuthorbyID(i)
-	if ownererr != nil {
-		error_handler.HandleError(c, ownererr.Code, ownererr.Message, ownererr.Errors)
-		return
-	}
-	if owner != user.ID {
-		error_handler.HandleError(c, http.StatusUnauthorized, "User is not the owner of the recipe", []error{errors.New("user is not the owner of the recipe")})
-		return
-	}
-
-	err := s.RecipeRepo.DeleteRecipe(i)
+	err := s.RecipeRepo.DeleteRecipe(c.Param("id"))
 	if err != nil {
 		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
 		return
@@ -154,42 +201,98 @@ func (s *Server) GetById(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
-func (s *Server) Filter(c *gin.Context) {
-	middleware_user, _ := c.Get("user")
-	user, ok := middleware_user.(user.UserModel)
-	if !ok {
-		fmt.Println("type assertion failed")
+func scale(r *recipe.RecipeSchema, servings string) (*recipe.ScaledRecipe, *error_handler.APIError) {
+	n, err := strconv.ParseFloat(servings, 64)
+	if err != nil {
+		return nil, error_handler.New("servings has to be a number", http.StatusBadRequest, err)
 	}
+	return r.Scale(n)
+}
 
-	var body recipe.Filter
-	err := c.ShouldBindJSON(&body)
+func (s *Server) GetScaled(c *gin.Context) {
+	result, err := s.RecipeRepo.GetRecipeByID(c.Param("id"))
 	if err != nil {
-		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{err})
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	scaled, err := scale(result, c.Query("servings"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, scaled)
+}
+
+func (s *Server) ExportRecipe(c *gin.Context) {
+	format := c.Query("format")
+	if !exporter.ValidFormat(format) {
+		error_handler.HandleError(c, http.StatusBadRequest, "Unknown export format "+format, []error{errors.New("unknown format")})
+		return
+	}
+	result, err := s.RecipeRepo.GetRecipeByID(c.Param("id"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	c.Header("Content-Type", exporter.ContentType(format))
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, exporter.FileName(result, format)))
+	c.Status(http.StatusOK)
+	writeErr := exporter.Write(c.Writer, result, format)
+	if writeErr != nil {
+		log.Default().Println("error exporting recipe:", writeErr)
+	}
+}
+
+// ExportRecipes writes all recipes of the user into a zip archive
+func (s *Server) ExportRecipes(c *gin.Context) {
+	format := c.Query("format")
+	if !exporter.ValidFormat(format) {
+		error_handler.HandleError(c, http.StatusBadRequest, "Unknown export format "+format, []error{errors.New("unknown format")})
+		return
+	}
+	user := user.UserModel{}
+	err := user.GetFromGinContext(c.Get("user"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
 		return
 	}
 
-	if body.Diets == nil && ok {
-		var diets []string
-		err := s.NewDB.Select(&diets, `
-			SELECT d.diet_id
-			FROM rel_user_diet rel
-			JOIN diet ON rel.diet_id = diet.diet_id
-			WHERE rek.user_id = $1
-		`, user.ID)
+	ids, err := s.RecipeRepo.GetRecipeIDsByAuthor(user.ID)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	recipes := make([]*recipe.RecipeSchema, len(ids))
+	for i, id := range ids {
+		recipes[i], err = s.RecipeRepo.GetRecipeByID(id)
 		if err != nil {
-			print(err.Error())
-		} else {
-			body.Diets = &diets
+			error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+			return
 		}
 	}
 
-	recipes, apiErr := s.RecipeRepo.GetByFilter(&body)
-	if apiErr != nil {
-		error_handler.HandleError(c, apiErr.Code, apiErr.Message, apiErr.Errors)
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="recipes.zip"`)
+	c.Status(http.StatusOK)
+	writeErr := exporter.Zip(c.Writer, recipes, format)
+	if writeErr != nil {
+		log.Default().Println("error exporting recipes:", writeErr)
+	}
+}
+
+// Filter lists the recipes matching the filter in the body. The preferences of a logged in user are the
+// defaults for what the filter leaves open unless ignore_preferences is set.
+func (s *Server) Filter(c *gin.Context) {
+	var body recipe.Filter
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{err})
 		return
 	}
 
-	c.JSON(http.StatusOK, recipes)
+	s.listRecipes(c, &body)
 }
 
 func (s *Server) Select(c *gin.Context) {
@@ -218,7 +321,19 @@ func (s *Server) Select(c *gin.Context) {
 		return
 	}
 
-	err = user.AddToGroup(s.NewDB, response)
+	cfg, err := s.groupingConfig(c)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	err = user.LogSelect(s.NewDB, response.ID)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	err = s.GroupRepo.AddRecipe(user.ID, response, cfg)
 	if err != nil {
 		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
 		return
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/recipe"
+)
+
+func (s *Server) GetDiets(c *gin.Context) {
+	diets, err := s.DietRepo.List()
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, diets)
+}
+
+func (s *Server) GetDiet(c *gin.Context) {
+	diet, err := s.DietRepo.GetByID(c.Param("id"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, diet)
+}
+
+func (s *Server) AddDiet(c *gin.Context) {
+	var body recipe.DietSchema
+	binderr := c.ShouldBindJSON(&body)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
+		return
+	}
+
+	err := s.DietRepo.Create(&body)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	diet, err := s.DietRepo.GetByID(body.ID)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusCreated, diet)
+}
+
+func (s *Server) UpdateDiet(c *gin.Context) {
+	var body recipe.DietSchema
+	binderr := c.ShouldBindJSON(&body)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
+		return
+	}
+
+	err := s.DietRepo.Update(c.Param("id"), &body)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	s.GetDiet(c)
+}
+
+func (s *Server) DeleteDiet(c *gin.Context) {
+	err := s.DietRepo.Delete(c.Param("id"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.Status(http.StatusOK)
+}
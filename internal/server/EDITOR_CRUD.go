@@ -0,0 +1,46 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/recipe"
+)
+
+func (s *Server) GetEditors(c *gin.Context) {
+	editors, err := recipe.GetEditors(c.Param("id"), s.NewDB)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, editors)
+}
+
+func (s *Server) AddEditor(c *gin.Context) {
+	var body struct {
+		UserID string `json:"user_id" binding:"required"`
+		Role   string `json:"role"`
+	}
+	binderr := c.ShouldBindJSON(&body)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
+		return
+	}
+
+	editor, err := recipe.AddEditor(c.Param("id"), body.UserID, body.Role, s.NewDB)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, editor)
+}
+
+func (s *Server) RemoveEditor(c *gin.Context) {
+	err := recipe.RemoveEditor(c.Param("id"), c.Param("user"), s.NewDB)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
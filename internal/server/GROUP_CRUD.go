@@ -0,0 +1,226 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/user"
+)
+
+// MigrateJSONGroups is an InnitFunc moving the groups still stored as JSON on the user into the recipe_group table
+func MigrateJSONGroups(s *Server) error {
+	repo, ok := s.GroupRepo.(*user.GroupRepo)
+	if !ok {
+		return nil
+	}
+	err := repo.MigrateJSONGroups()
+	if err != nil {
+		return err.Errors[0]
+	}
+	return nil
+}
+
+type groupBody struct {
+	Name      string   `json:"name"`
+	Version   int64    `json:"version"`
+	RecipeIDs []string `json:"recipe_ids"`
+}
+
+func (s *Server) recipes(ids []string) ([]*recipe.RecipeSchema, *error_handler.APIError) {
+	recipes := make([]*recipe.RecipeSchema, len(ids))
+	for i, id := range ids {
+		r, err := s.RecipeRepo.GetRecipeByID(id)
+		if err != nil {
+			return nil, err
+		}
+		recipes[i] = r
+	}
+	return recipes, nil
+}
+
+func (s *Server) GetGroups(c *gin.Context) {
+	u := user.UserModel{}
+	err := u.GetFromGinContext(c.Get("user"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	groups, err := s.GroupRepo.GetByUser(u.ID)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+func (s *Server) CreateGroup(c *gin.Context) {
+	var body groupBody
+	binderr := c.ShouldBindJSON(&body)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
+		return
+	}
+	if len(body.RecipeIDs) < 1 {
+		error_handler.HandleError(c, http.StatusBadRequest, "A group needs at least one recipe", []error{errors.New("no recipe_ids")})
+		return
+	}
+
+	u := user.UserModel{}
+	err := u.GetFromGinContext(c.Get("user"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	recipes, err := s.recipes(body.RecipeIDs)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	group := user.BuildGroup(recipes...)
+	group.Name = body.Name
+	err = s.GroupRepo.Create(u.ID, &group)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	c.JSON(http.StatusCreated, group)
+}
+
+func (s *Server) RenameGroup(c *gin.Context) {
+	var body groupBody
+	binderr := c.ShouldBindJSON(&body)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
+		return
+	}
+
+	u := user.UserModel{}
+	err := u.GetFromGinContext(c.Get("user"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	err = s.GroupRepo.Rename(u.ID, c.Param("id"), body.Version, body.Name)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	group, err := s.GroupRepo.GetByID(u.ID, c.Param("id"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
+// SplitGroup moves the given recipes out of the group into a new one. Both groups are rebuilt from their recipes.
+func (s *Server) SplitGroup(c *gin.Context) {
+	var body groupBody
+	binderr := c.ShouldBindJSON(&body)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
+		return
+	}
+
+	u := user.UserModel{}
+	err := u.GetFromGinContext(c.Get("user"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	group, err := s.GroupRepo.GetByID(u.ID, c.Param("id"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	if group.Version != body.Version {
+		error_handler.HandleError(c, http.StatusConflict, "Recipe group was changed in the meantime", []error{errors.New("version conflict")})
+		return
+	}
+
+	split := make(map[string]bool, len(body.RecipeIDs))
+	for _, id := range body.RecipeIDs {
+		split[id] = true
+	}
+	var keep, moved []string
+	for _, id := range group.RecipeIDs {
+		if split[id] {
+			moved = append(moved, id)
+		} else {
+			keep = append(keep, id)
+		}
+	}
+	if len(moved) < 1 || len(keep) < 1 || len(moved) != len(split) {
+		error_handler.HandleError(c, http.StatusBadRequest, "recipe_ids have to be a non empty part of the groups recipes, leaving at least one", []error{errors.New("invalid split")})
+		return
+	}
+
+	keptRecipes, err := s.recipes(keep)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	splitRecipes, err := s.recipes(moved)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	rebuilt := user.BuildGroup(keptRecipes...)
+	rebuilt.ID = group.ID
+	rebuilt.Name = group.Name
+	rebuilt.Version = group.Version
+	newGroup := user.BuildGroup(splitRecipes...)
+	newGroup.Name = body.Name
+
+	err = s.GroupRepo.Split(u.ID, &rebuilt, &newGroup)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	c.JSON(http.StatusOK, []user.RecipeGroupSchema{rebuilt, newGroup})
+}
+
+// DeleteGroup takes the version from ?version= or the If-Match header, bodies of DELETE requests
+// are dropped by many clients and proxies
+func (s *Server) DeleteGroup(c *gin.Context) {
+	raw := c.Query("version")
+	if raw == "" {
+		raw = strings.Trim(strings.TrimPrefix(c.GetHeader("If-Match"), "W/"), `"`)
+	}
+	version, converr := strconv.ParseInt(raw, 10, 64)
+	if converr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "The version of the group is required as ?version= or If-Match", []error{converr})
+		return
+	}
+
+	u := user.UserModel{}
+	err := u.GetFromGinContext(c.Get("user"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	err = s.GroupRepo.Delete(u.ID, c.Param("id"), version)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
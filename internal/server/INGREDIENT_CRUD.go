@@ -0,0 +1,139 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/recipe"
+)
+
+type ingredientQuery struct {
+	Q      string `form:"q"`
+	Limit  int    `form:"limit"`
+	Cursor string `form:"cursor"`
+}
+
+type mergeBody struct {
+	From string `json:"from"`
+}
+
+func (s *Server) GetIngredients(c *gin.Context) {
+	var q ingredientQuery
+	binderr := c.ShouldBindQuery(&q)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read query", []error{binderr})
+		return
+	}
+
+	page, err := s.IngredientRepo.List(q.Limit, q.Cursor)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+func (s *Server) SearchIngredients(c *gin.Context) {
+	var q ingredientQuery
+	binderr := c.ShouldBindQuery(&q)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read query", []error{binderr})
+		return
+	}
+
+	matches, err := s.IngredientRepo.Search(q.Q, q.Limit)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, matches)
+}
+
+func (s *Server) GetIngredient(c *gin.Context) {
+	ingredient, err := s.IngredientRepo.GetByID(c.Param("id"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, ingredient)
+}
+
+func (s *Server) AddIngredient(c *gin.Context) {
+	var body recipe.IngredientDB
+
+	binderr := c.ShouldBindJSON(&body)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
+		return
+	}
+
+	err := s.IngredientRepo.Create(&body)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+
+	c.JSON(http.StatusCreated, body)
+}
+
+func (s *Server) UpdateIngredient(c *gin.Context) {
+	var body recipe.IngredientDB
+	binderr := c.ShouldBindJSON(&body)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
+		return
+	}
+
+	err := s.IngredientRepo.Update(c.Param("id"), &body)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	s.GetIngredient(c)
+}
+
+// MergeIngredients merges the duplicate ingredient from into the one in the path
+func (s *Server) MergeIngredients(c *gin.Context) {
+	var body mergeBody
+	binderr := c.ShouldBindJSON(&body)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
+		return
+	}
+	if body.From == "" {
+		error_handler.HandleError(c, http.StatusBadRequest, "from is required", []error{errors.New("missing from")})
+		return
+	}
+
+	recipeIDs, err := s.IngredientRepo.Merge(c.Param("id"), body.From)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	// The nutrition of the merged ingredient may differ
+	for _, id := range recipeIDs {
+		err = s.RecipeRepo.RefreshNutrition(id)
+		if err != nil {
+			error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+			return
+		}
+	}
+
+	ingredient, err := s.IngredientRepo.GetByID(c.Param("id"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ingredient": ingredient, "updated_recipes": recipeIDs})
+}
+
+func (s *Server) DeleteIngredient(c *gin.Context) {
+	err := s.IngredientRepo.Delete(c.Param("id"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/user"
+)
+
+// applySettings applies the allergies and preferences of the logged in user to the filter
+func (s *Server) applySettings(c *gin.Context, f *recipe.Filter) *error_handler.APIError {
+	data, exists := c.Get("user")
+	if !exists {
+		return nil
+	}
+	u := user.UserModel{}
+	err := u.GetFromGinContext(data, exists)
+	if err != nil {
+		return err
+	}
+	err = u.LoadPreferences(s.NewDB)
+	if err != nil {
+		return err
+	}
+	u.Settings.ApplyTo(f)
+	return nil
+}
+
+// preferencesUser returns the logged in user with their preferences, it writes the error itself
+func (s *Server) preferencesUser(c *gin.Context) (*user.UserModel, bool) {
+	u := user.UserModel{}
+	err := u.GetFromGinContext(c.Get("user"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return nil, false
+	}
+	err = u.LoadPreferences(s.NewDB)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return nil, false
+	}
+	return &u, true
+}
+
+func (s *Server) GetPreferences(c *gin.Context) {
+	u, ok := s.preferencesUser(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, u.Settings)
+}
+
+func (s *Server) UpdatePreferences(c *gin.Context) {
+	u, ok := s.preferencesUser(c)
+	if !ok {
+		return
+	}
+	var body user.PreferencesUpdate
+	binderr := c.ShouldBindJSON(&body)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
+		return
+	}
+
+	err := u.SetPreferences(s.NewDB, body)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, u.Settings)
+}
+
+// changePreference runs one of the add or remove methods of the user with the id of the path
+func (s *Server) changePreference(c *gin.Context, change func(u *user.UserModel, id string) *error_handler.APIError) {
+	u, ok := s.preferencesUser(c)
+	if !ok {
+		return
+	}
+	err := change(u, c.Param("id"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, u.Settings)
+}
+
+func (s *Server) AddPreferredDiet(c *gin.Context) {
+	s.changePreference(c, func(u *user.UserModel, id string) *error_handler.APIError {
+		return u.AddDiet(s.NewDB, id)
+	})
+}
+
+func (s *Server) RemovePreferredDiet(c *gin.Context) {
+	s.changePreference(c, func(u *user.UserModel, id string) *error_handler.APIError {
+		return u.RemoveDiet(s.NewDB, id)
+	})
+}
+
+func (s *Server) AddDislikedIngredient(c *gin.Context) {
+	s.changePreference(c, func(u *user.UserModel, id string) *error_handler.APIError {
+		return u.AddDislikedIngredient(s.NewDB, id)
+	})
+}
+
+func (s *Server) RemoveDislikedIngredient(c *gin.Context) {
+	s.changePreference(c, func(u *user.UserModel, id string) *error_handler.APIError {
+		return u.RemoveDislikedIngredient(s.NewDB, id)
+	})
+}
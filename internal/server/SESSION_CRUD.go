@@ -0,0 +1,62 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madswillem/recipeApp/internal/auth"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/user"
+)
+
+// GetSessions lists the devices the user is logged in with
+func (s *Server) GetSessions(c *gin.Context) {
+	u := user.UserModel{}
+	err := u.GetFromGinContext(c.Get("user"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	sessions, err := auth.ListSessions(s.NewDB, u.ID, u.SessionID)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// RevokeSession logs out one device of the user
+func (s *Server) RevokeSession(c *gin.Context) {
+	u := user.UserModel{}
+	err := u.GetFromGinContext(c.Get("user"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	err = auth.RevokeSession(s.NewDB, u.ID, c.Param("id"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	if c.Param("id") == u.SessionID {
+		auth.ClearCookies(c)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// LogoutAll logs out every device of the user, this one included
+func (s *Server) LogoutAll(c *gin.Context) {
+	u := user.UserModel{}
+	err := u.GetFromGinContext(c.Get("user"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	n, err := auth.RevokeAllSessions(s.NewDB, u.ID)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	auth.ClearCookies(c)
+	c.JSON(http.StatusOK, gin.H{"message": "Successfully logged out", "revoked": n})
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/user"
+)
+
+func (s *Server) GetSubstitutes(c *gin.Context) {
+	_, err := s.IngredientRepo.GetByID(c.Param("id"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	subs, err := recipe.GetSubstitutes([]string{c.Param("id")}, s.NewDB)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+func (s *Server) AddSubstitute(c *gin.Context) {
+	var body recipe.Substitute
+	binderr := c.ShouldBindJSON(&body)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
+		return
+	}
+
+	err := recipe.AddSubstitute(c.Param("id"), &body, s.NewDB)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	s.GetSubstitutes(c)
+}
+
+func (s *Server) DeleteSubstitute(c *gin.Context) {
+	err := recipe.DeleteSubstitute(c.Param("id"), c.Param("substitute"), s.NewDB)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	s.GetSubstitutes(c)
+}
+
+// GetSubstitutions proposes the ingredients of the recipe for ?diet= without the ingredients in ?exclude=
+func (s *Server) GetSubstitutions(c *gin.Context) {
+	var o recipe.SubstitutionOptions
+	binderr := c.ShouldBindQuery(&o)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read query", []error{binderr})
+		return
+	}
+	o.Exclude = splitIDs(o.Exclude)
+
+	plan, err := s.RecipeRepo.Substitutions(c.Param("id"), &o)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
+// CreateVariant saves the recipe with the substitutes as a recipe of the user
+func (s *Server) CreateVariant(c *gin.Context) {
+	u := user.UserModel{}
+	err := u.GetFromGinContext(c.Get("user"))
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	var body recipe.VariantRequest
+	binderr := c.ShouldBindJSON(&body)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
+		return
+	}
+	body.Exclude = splitIDs(body.Exclude)
+
+	variant, err := s.RecipeRepo.CreateVariant(c.Param("id"), u.ID, &body)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusCreated, variant)
+}
+
+// splitIDs accepts ids given as exclude=a,b as well as exclude=a&exclude=b
+func splitIDs(values []string) []string {
+	ids := []string{}
+	for _, v := range values {
+		for _, id := range strings.Split(v, ",") {
+			if id = strings.TrimSpace(id); id != "" {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
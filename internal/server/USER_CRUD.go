@@ -2,14 +2,44 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/tools"
 	"github.com/madswillem/recipeApp/internal/user"
 )
 
+const defaultRecommendationLimit = 10
+const maxRecommendationLimit = 50
+
+// groupingConfig returns the servers grouping config, overridden by the fields of the
+// JSON encoded "grouping" query parameter if present. Meant for experimenting with the grouping.
+func (s *Server) groupingConfig(c *gin.Context) (user.GroupingConfig, *error_handler.APIError) {
+	cfg := s.Grouping
+	if cfg == (user.GroupingConfig{}) {
+		cfg = user.DefaultGroupingConfig()
+	}
+
+	override := c.Query("grouping")
+	if override == "" {
+		return cfg, nil
+	}
+	err := json.Unmarshal([]byte(override), &cfg)
+	if err != nil {
+		return cfg, error_handler.New("Failed to read grouping config", http.StatusBadRequest, err)
+	}
+	err = cfg.Validate()
+	if err != nil {
+		return cfg, error_handler.New("Invalid grouping config: "+err.Error(), http.StatusBadRequest, err)
+	}
+	return cfg, nil
+}
+
 func (s *Server) GetRecommendation(c *gin.Context) {
 	middleware_user, _ := c.Get("user")
 	user, ok := middleware_user.(user.UserModel)
@@ -17,42 +47,77 @@ func (s *Server) GetRecommendation(c *gin.Context) {
 		fmt.Println("type assertion failed")
 	}
 
-	err := user.GetByCookie(s.NewDB)
+	page, converr := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if converr != nil || page < 1 {
+		error_handler.HandleError(c, http.StatusBadRequest, "page has to be a positive number", []error{errors.New("invalid page")})
+		return
+	}
+	limit, converr := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultRecommendationLimit)))
+	if converr != nil || limit < 1 || limit > maxRecommendationLimit {
+		error_handler.HandleError(c, http.StatusBadRequest, fmt.Sprintf("limit has to be between 1 and %d", maxRecommendationLimit), []error{errors.New("invalid limit")})
+		return
+	}
+
+	cfg, err := s.groupingConfig(c)
 	if err != nil {
 		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
 		return
 	}
 
-	err, recipes := user.GetRecomendation(s.NewDB)
+	user.RecipeGroups, err = s.GroupRepo.GetByUser(user.ID)
 	if err != nil {
 		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
 		return
 	}
 
-	c.JSON(http.StatusOK, recipes)
-}
-func (s *Server) CreateGroup(c *gin.Context) {
-	middleware_user, _ := c.Get("user")
-	u, ok := middleware_user.(user.UserModel)
-	if !ok {
-		fmt.Println("type assertion failed")
+	err = user.LoadPreferences(s.NewDB)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	if c.Query("ignore_preferences") == "true" {
+		user.Settings = user.Settings.WithoutPreferences()
+	}
+
+	data, dataerr := tools.GetCurrentData()
+	if dataerr != nil {
+		log.Default().Println("couldn't get the current weather, recommending without it:", dataerr)
 	}
 
-	r, apiErr := s.RecipeRepo.GetRecipeByID("aa85daf1-dbc5-462d-a6fe-3fbb358b08dd")
-	if apiErr != nil {
-		c.AbortWithStatusJSON(http.StatusNotFound, apiErr)
+	err, recipes := user.GetRecomendation(s.RecipeRepo, cfg, data, page, limit)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
 		return
 	}
 
-	rp := user.RecipeGroupSchema{}
-	rp.Create(r)
-	u.RecipeGroups = append(u.RecipeGroups, rp)
+	c.JSON(http.StatusOK, recipes)
+}
 
-	v, err := json.Marshal(u.RecipeGroups)
+// SetRole changes the role of another user, admins can't demote themselves so one always remains
+func (s *Server) SetRole(c *gin.Context) {
+	u := user.UserModel{}
+	err := u.GetFromGinContext(c.Get("user"))
 	if err != nil {
-		error_handler.HandleError(c, http.StatusInternalServerError, "Couldnt Marshal recipe group", []error{err})
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	var body struct {
+		Role string `json:"role" binding:"required"`
+	}
+	binderr := c.ShouldBindJSON(&body)
+	if binderr != nil {
+		error_handler.HandleError(c, http.StatusBadRequest, "Failed to read body", []error{binderr})
+		return
+	}
+	if c.Param("id") == u.ID {
+		error_handler.HandleError(c, http.StatusBadRequest, "You can't change your own role", []error{errors.New("own role")})
+		return
 	}
 
-	s.NewDB.MustExec(`UPDATE "user" SET groups = $1 WHERE id = $2`, v, u.ID)
-	c.JSON(http.StatusAccepted, u)
+	err = user.SetRole(s.NewDB, c.Param("id"), body.Role)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": c.Param("id"), "role": body.Role})
 }
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/policy"
+	"github.com/madswillem/recipeApp/internal/user"
+)
+
+// Authorize enforces the policy of the action, it has to run after UserMiddleware. If the route has an
+// :id the object is looked up first: a missing one is a 404, one the user may not touch a 403.
+func (s *Server) Authorize(res policy.Resource, act policy.Action) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		u := user.UserModel{}
+		err := u.GetFromGinContext(c.Get("user"))
+		if err != nil {
+			error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+			return
+		}
+		err = u.LoadRole(s.NewDB)
+		if err != nil {
+			error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+			return
+		}
+
+		rel := policy.None
+		if id := c.Param("id"); id != "" {
+			rel, err = policy.Lookup(s.NewDB, res, id, u.ID)
+			if err != nil {
+				error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+				return
+			}
+		}
+
+		p := s.Policy
+		if p == nil {
+			p = policy.Default
+		}
+		ok, perr := p.Allowed(u.Role, rel, res, act)
+		if perr != nil {
+			error_handler.HandleError(c, http.StatusInternalServerError, "Error checking permissions", []error{perr})
+			return
+		}
+		if !ok {
+			error_handler.HandleError(c, http.StatusForbidden, fmt.Sprintf("You aren't allowed to %s this %s", act, res), []error{fmt.Errorf("%s on %s forbidden", act, res)})
+			return
+		}
+		c.Set("user", u)
+		c.Next()
+	}
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,6 +16,8 @@ import (
 	"github.com/madswillem/recipeApp/internal/database"
 	"github.com/madswillem/recipeApp/internal/error_handler"
 	"github.com/madswillem/recipeApp/internal/initializers"
+	"github.com/madswillem/recipeApp/internal/mail"
+	"github.com/madswillem/recipeApp/internal/policy"
 	"github.com/madswillem/recipeApp/internal/recipe"
 	"github.com/madswillem/recipeApp/internal/user"
 	"github.com/madswillem/recipeApp/internal/workers"
@@ -28,8 +31,9 @@ type Auth interface {
 	Login(c *gin.Context, db *sqlx.DB)
 	Signup(c *gin.Context, db *sqlx.DB)
 	Verify(db *sqlx.DB, tokenString string) (*error_handler.APIError, user.UserModel)
-	Logout(c *gin.Context)
-	AccessControl(sub string, obj string, act string, repo recipe.RecipeRepository) (bool, *error_handler.APIError)
+	// Refresh swaps a refresh token for a new access and refresh token
+	Refresh(c *gin.Context, db *sqlx.DB)
+	Logout(c *gin.Context, db *sqlx.DB)
 }
 
 type InnitFuncs func(*Server) error
@@ -44,35 +48,62 @@ type Config struct {
 	Innit       []InnitFuncs
 	Auth        Auth
 	Controllers []ExtraControllers
+	Grouping    *user.GroupingConfig
+	// AutoMigrate applies pending migrations on start instead of refusing to start
+	AutoMigrate bool
+	// Dev allows starting without a JWT signing key and without a mailer, tokens are then signed with a
+	// well known secret and mails are logged
+	Dev bool
+	// Keys sign the tokens of the default Auth, they are read with auth.KeyConfigFromEnv if nil
+	Keys *auth.KeyConfig
+	// Policy decides who may change what, policy.Default if nil
+	Policy policy.Policy
+	// Mailer sends the mails of the default Auth, mail.FromEnv if nil
+	Mailer mail.Mailer
+	// RequireVerifiedEmail refuses logins of the default Auth until the email is verified,
+	// REQUIRE_VERIFIED_EMAIL=true sets it too
+	RequireVerifiedEmail bool
+	// OIDC are the providers users of the default Auth can sign in with, auth.OIDCProvidersFromEnv if nil
+	OIDC []auth.OIDCProviderConfig
 }
 
 type Server struct {
-	port       int
-	NewDB      *sqlx.DB
-	Registry   *gocron.Registry
-	RecipeRepo recipe.RecipeRepository
-	Auth       Auth
-	config     *Config
+	port           int
+	NewDB          *sqlx.DB
+	Registry       *gocron.Registry
+	RecipeRepo     recipe.RecipeRepository
+	GroupRepo      user.GroupRepository
+	IngredientRepo recipe.IngredientDBRepository
+	DietRepo       recipe.DietDBRepository
+	Auth           Auth
+	Grouping       user.GroupingConfig
+	Policy         policy.Policy
+	config         *Config
 }
 
 func NewServer(config *Config) *http.Server {
 	port, _ := strconv.Atoi(os.Getenv("PORT"))
 	initializers.LoadEnvVariables()
 	NewServer := &Server{
-		port: port,
-		NewDB: database.ConnectToDB(
-			&sqlx.Conn{},
-			fmt.Sprintf(
-				"user=%s password=%s database=%s sslmode=disable",
-				os.Getenv("POSTGRES_USER"),
-				os.Getenv("POSTGRES_PASSWORD"),
-				os.Getenv("POSTGRES_DB"),
-			),
-		),
+		port:     port,
+		NewDB:    database.ConnectToDB(&sqlx.Conn{}, database.ConnectionStringFromEnv()),
 		Registry: gocron.New(),
 		config:   config,
 	}
+	if config.AutoMigrate {
+		_, err := database.MigrateUp(NewServer.NewDB, 0)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+	err := database.CheckSchemaVersion(NewServer.NewDB)
+	if err != nil {
+		log.Fatalln(err)
+	}
 	NewServer.RecipeRepo = recipe.NewRecipeRepo(NewServer.NewDB)
+	NewServer.GroupRepo = user.NewGroupRepo(NewServer.NewDB)
+	NewServer.IngredientRepo = recipe.NewIngredientDBRepo(NewServer.NewDB)
+	NewServer.DietRepo = recipe.NewDietDBRepo(NewServer.NewDB)
 	w := workers.Worker{DB: NewServer.NewDB}
 	NewServer.Registry.Add(
 		gocron.Job{
@@ -81,11 +112,46 @@ func NewServer(config *Config) *http.Server {
 		},
 	)
 
+	NewServer.Policy = policy.Default
+	if config.Policy != nil {
+		NewServer.Policy = config.Policy
+	}
+
+	NewServer.Grouping = user.DefaultGroupingConfig()
+	if config.Grouping != nil {
+		err := config.Grouping.Validate()
+		if err != nil {
+			log.Fatalln("invalid grouping config:", err)
+		}
+		NewServer.Grouping = *config.Grouping
+	}
+
 	if config.Auth != nil {
 		NewServer.Auth = config.Auth
 	} else {
-		log.Default().Println("No Auth provided")
-		NewServer.Auth = auth.NewAuth([]byte("secret"))
+		keys, err := loadKeys(config)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		a := auth.NewAuthWithKeys(keys)
+		a.Mailer, err = loadMailer(config)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		a.RequireVerifiedEmail = config.RequireVerifiedEmail || os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true"
+		if publicURL := os.Getenv("PUBLIC_URL"); publicURL != "" {
+			a.BaseURL = publicURL
+		}
+		a.ResetPasswordURL = os.Getenv("RESET_PASSWORD_URL")
+		NewServer.Auth = a
+
+		oidc, err := newOIDCAuth(a, config)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if oidc != nil {
+			NewServer.Auth = oidc
+		}
 	}
 
 	for _, fnc := range NewServer.config.Innit {
@@ -104,6 +170,82 @@ func NewServer(config *Config) *http.Server {
 
 	return server
 }
+
+// loadKeys reads the signing keys of the default Auth, only dev mode may start without one
+func loadKeys(config *Config) (*auth.KeySet, error) {
+	cfg := auth.KeyConfigFromEnv()
+	if config.Keys != nil {
+		cfg = *config.Keys
+	}
+	if cfg.Empty() && config.Dev {
+		log.Default().Println("No JWT signing key configured, signing with the dev secret")
+		cfg.Secret = "secret"
+	}
+	return auth.LoadKeys(cfg)
+}
+
+// loadMailer returns the mailer of the default Auth. Only dev mode may log the mails, elsewhere
+// anyone reading the logs could use the tokens in them.
+func loadMailer(config *Config) (mail.Mailer, error) {
+	if config.Mailer != nil {
+		return config.Mailer, nil
+	}
+	m := mail.FromEnv()
+	if m == nil && config.Dev {
+		log.Default().Println("No mailer configured, logging mails")
+		return &mail.LogMailer{}, nil
+	}
+	if m == nil {
+		return nil, errors.New("no mailer configured, set SMTP_HOST or MAIL_DIR")
+	}
+	return m, nil
+}
+
+// newOIDCAuth wraps the Auth if OIDC providers are configured, OIDC_ONLY=true turns password logins off
+func newOIDCAuth(a *auth.Auth, config *Config) (*auth.OIDCAuth, error) {
+	configs := config.OIDC
+	if configs == nil {
+		configs = auth.OIDCProvidersFromEnv()
+	}
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	providers := []*auth.OIDCProvider{}
+	for _, cfg := range configs {
+		p, err := auth.NewOIDCProvider(cfg, nil)
+		if err != nil {
+			return nil, err
+		}
+		providers = append(providers, p)
+	}
+	o, err := auth.NewOIDCAuth(a, providers...)
+	if err != nil {
+		return nil, err
+	}
+	o.PasswordLogin = os.Getenv("OIDC_ONLY") != "true"
+	o.AfterLoginURL = os.Getenv("OIDC_AFTER_LOGIN_URL")
+	return o, nil
+}
+
+// jwksProvider is an Auth whose tokens can be verified by others with its public keys
+type jwksProvider interface {
+	JWKS() auth.JWKS
+}
+
+// oidcLogin is an Auth that sends users to sign in with other providers, they come back to Callback
+type oidcLogin interface {
+	Callback(c *gin.Context, db *sqlx.DB)
+	Providers() []string
+}
+
+// accountRecovery is an Auth that verifies emails and resets passwords by mail
+type accountRecovery interface {
+	VerifyEmail(c *gin.Context, db *sqlx.DB)
+	ResendVerification(c *gin.Context, db *sqlx.DB)
+	RequestPasswordReset(c *gin.Context, db *sqlx.DB)
+	ResetPassword(c *gin.Context, db *sqlx.DB)
+}
+
 func (s *Server) RegisterRoutes() http.Handler {
 	r := gin.Default()
 	r.Use(s.CORSMiddleware())
@@ -120,6 +262,14 @@ func (s *Server) RegisterRoutes() http.Handler {
 			c.String(apiErr.Code, apiErr.Message)
 			return
 		}
+		if c.Query("servings") != "" {
+			scaled, apiErr := scale(recipe, c.Query("servings"))
+			if apiErr != nil {
+				c.String(apiErr.Code, apiErr.Message)
+				return
+			}
+			recipe = &scaled.RecipeSchema
+		}
 		views.RecipePage(recipe, "https://external-content.duckduckgo.com/iu/?u=https%3A%2F%2Ftse1.mm.bing.net%2Fth%3Fid%3DOIP.GUtzz3zgkImN3_ikBYuNfgHaE8%26pid%3DApi&f=1&ipt=e9db03ac01ccf7feb502d49d09aecfb45975d8873716e6dfa2b53c69ca00cc9c&ipo=images").Render(c.Request.Context(), c.Writer)
 	})
 
@@ -131,7 +281,47 @@ func (s *Server) RegisterRoutes() http.Handler {
 		r.POST("/signup", func(c *gin.Context) {
 			s.Auth.Signup(c, s.NewDB)
 		})
-		r.GET("/logout", s.Auth.Logout)
+		r.POST("/token/refresh", func(c *gin.Context) {
+			s.Auth.Refresh(c, s.NewDB)
+		})
+		logout := func(c *gin.Context) {
+			s.Auth.Logout(c, s.NewDB)
+		}
+		r.GET("/logout", logout)
+		r.POST("/logout", logout)
+		if p, ok := s.Auth.(jwksProvider); ok {
+			r.GET("/.well-known/jwks.json", func(c *gin.Context) {
+				c.JSON(http.StatusOK, p.JWKS())
+			})
+		}
+		if o, ok := s.Auth.(oidcLogin); ok {
+			// Browsers are sent to the provider with GET /login?provider=
+			r.GET("/login", func(c *gin.Context) {
+				s.Auth.Login(c, s.NewDB)
+			})
+			r.GET("/login/callback", func(c *gin.Context) {
+				o.Callback(c, s.NewDB)
+			})
+			r.GET("/login/providers", func(c *gin.Context) {
+				c.JSON(http.StatusOK, gin.H{"providers": o.Providers()})
+			})
+		}
+		if a, ok := s.Auth.(accountRecovery); ok {
+			verify := func(c *gin.Context) {
+				a.VerifyEmail(c, s.NewDB)
+			}
+			r.GET("/email/verify", verify)
+			r.POST("/email/verify", verify)
+			r.POST("/email/verify/resend", func(c *gin.Context) {
+				a.ResendVerification(c, s.NewDB)
+			})
+			r.POST("/password/reset", func(c *gin.Context) {
+				a.RequestPasswordReset(c, s.NewDB)
+			})
+			r.POST("/password/reset/confirm", func(c *gin.Context) {
+				a.ResetPassword(c, s.NewDB)
+			})
+		}
 	}
 
 	for _, controller := range s.config.Controllers {
@@ -143,20 +333,65 @@ func (s *Server) RegisterRoutes() http.Handler {
 		}
 	}
 
-	r.POST("/create", s.UserMiddleware, s.AddRecipe)
-	r.POST("/create_ingredient", s.AddIngredient)
+	r.POST("/logout/all", s.UserMiddleware, s.LogoutAll)
+	r.GET("/sessions", s.UserMiddleware, s.GetSessions)
+	r.DELETE("/sessions/:id", s.UserMiddleware, s.RevokeSession)
+
+	r.POST("/create", s.UserMiddleware, s.Authorize(policy.Recipe, policy.Create), s.AddRecipe)
+	r.POST("/import", s.UserMiddleware, s.Authorize(policy.Recipe, policy.Create), s.ImportRecipe)
+	r.POST("/create_ingredient", s.UserMiddleware, s.Authorize(policy.Ingredient, policy.Create), s.AddIngredient)
+	r.POST("/ingredients/parse", s.ParseIngredients)
+	r.GET("/ingredients", s.GetIngredients)
+	r.GET("/ingredients/search", s.SearchIngredients)
+	r.GET("/ingredients/:id", s.GetIngredient)
+	r.POST("/ingredients", s.UserMiddleware, s.Authorize(policy.Ingredient, policy.Create), s.AddIngredient)
+	r.PATCH("/ingredients/:id", s.UserMiddleware, s.Authorize(policy.Ingredient, policy.Update), s.UpdateIngredient)
+	r.POST("/ingredients/:id/merge", s.UserMiddleware, s.Authorize(policy.Ingredient, policy.Merge), s.MergeIngredients)
+	r.DELETE("/ingredients/:id", s.UserMiddleware, s.Authorize(policy.Ingredient, policy.Delete), s.DeleteIngredient)
+	r.GET("/ingredients/:id/substitutes", s.GetSubstitutes)
+	r.POST("/ingredients/:id/substitutes", s.UserMiddleware, s.Authorize(policy.Ingredient, policy.Update), s.AddSubstitute)
+	r.DELETE("/ingredients/:id/substitutes/:substitute", s.UserMiddleware, s.Authorize(policy.Ingredient, policy.Update), s.DeleteSubstitute)
+	r.GET("/diets", s.GetDiets)
+	r.GET("/diets/:id", s.GetDiet)
+	r.POST("/diets", s.UserMiddleware, s.Authorize(policy.Diet, policy.Create), s.AddDiet)
+	r.PATCH("/diets/:id", s.UserMiddleware, s.Authorize(policy.Diet, policy.Update), s.UpdateDiet)
+	r.DELETE("/diets/:id", s.UserMiddleware, s.Authorize(policy.Diet, policy.Delete), s.DeleteDiet)
+	r.GET("/allergens", s.GetAllergens)
+	r.PUT("/allergens/:id/categories", s.UserMiddleware, s.Authorize(policy.Allergen, policy.Update), s.SetAllergenCategories)
+	r.GET("/user/allergies", s.UserMiddleware, s.GetAllergies)
+	r.PUT("/user/allergies", s.UserMiddleware, s.SetAllergies)
+	r.GET("/user/preferences", s.UserMiddleware, s.GetPreferences)
+	r.PATCH("/user/preferences", s.UserMiddleware, s.UpdatePreferences)
+	r.POST("/user/preferences/diets/:id", s.UserMiddleware, s.AddPreferredDiet)
+	r.DELETE("/user/preferences/diets/:id", s.UserMiddleware, s.RemovePreferredDiet)
+	r.POST("/user/preferences/disliked_ingredients/:id", s.UserMiddleware, s.AddDislikedIngredient)
+	r.DELETE("/user/preferences/disliked_ingredients/:id", s.UserMiddleware, s.RemoveDislikedIngredient)
 	r.GET("/get", s.GetAll)
-	r.GET("/popular", s.GetPopular)
+	r.GET("/popular", s.OptionalUserMiddleware, s.GetPopular)
 	r.GET("/getbyid/:id", s.GetById)
-	r.PATCH("/update/:id", s.UserMiddleware, s.UpdateRecipe)
-	r.DELETE("/delete/:id", s.UserMiddleware, s.DeleteRecipe)
-	r.POST("/filter", s.Filter)
+	r.GET("/recipes/:id/scaled", s.GetScaled)
+	r.GET("/recipes/:id/export", s.ExportRecipe)
+	r.GET("/recipes/:id/substitutions", s.GetSubstitutions)
+	r.POST("/recipes/:id/variants", s.UserMiddleware, s.Authorize(policy.Recipe, policy.Create), s.CreateVariant)
+	r.GET("/recipes/:id/editors", s.UserMiddleware, s.Authorize(policy.Recipe, policy.Update), s.GetEditors)
+	r.POST("/recipes/:id/editors", s.UserMiddleware, s.Authorize(policy.Recipe, policy.ManageEditors), s.AddEditor)
+	r.DELETE("/recipes/:id/editors/:user", s.UserMiddleware, s.Authorize(policy.Recipe, policy.ManageEditors), s.RemoveEditor)
+	r.GET("/recipes/export", s.UserMiddleware, s.ExportRecipes)
+	r.PATCH("/update/:id", s.UserMiddleware, s.Authorize(policy.Recipe, policy.Update), s.UpdateRecipe)
+	r.DELETE("/delete/:id", s.UserMiddleware, s.Authorize(policy.Recipe, policy.Delete), s.DeleteRecipe)
+	r.PUT("/users/:id/role", s.UserMiddleware, s.Authorize(policy.User, policy.UpdateRole), s.SetRole)
+	r.POST("/filter", s.OptionalUserMiddleware, s.Filter)
 	r.GET("/select/:id", s.UserMiddleware, s.Select)
 	r.GET("/deselect/:id", s.UserMiddleware, s.Deselect)
 	r.GET("/colormode/:type", s.Colormode)
 
-	r.GET("/creategroup", s.UserMiddleware, s.CreateGroup)
 	r.GET("/recommendation", s.UserMiddleware, s.GetRecommendation)
 
+	r.GET("/groups", s.UserMiddleware, s.GetGroups)
+	r.POST("/groups", s.UserMiddleware, s.CreateGroup)
+	r.PATCH("/groups/:id", s.UserMiddleware, s.RenameGroup)
+	r.POST("/groups/:id/split", s.UserMiddleware, s.SplitGroup)
+	r.DELETE("/groups/:id", s.UserMiddleware, s.DeleteGroup)
+
 	return r
 }
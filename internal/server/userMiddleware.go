@@ -25,3 +25,24 @@ func (s *Server) UserMiddleware(c *gin.Context) {
 	c.Set("user", user)
 	c.Next()
 }
+
+// OptionalUserMiddleware sets the user if a token is sent and lets anonymous requests through.
+// A token that doesn't verify is still rejected, the user would otherwise silently lose their settings.
+func (s *Server) OptionalUserMiddleware(c *gin.Context) {
+	tokenString := c.GetHeader("Authorization")
+	if tokenString == "" {
+		tokenString, _ = c.Cookie("token")
+	}
+	if tokenString == "" {
+		c.Next()
+		return
+	}
+
+	err, user := s.Auth.Verify(s.NewDB, tokenString)
+	if err != nil {
+		error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+		return
+	}
+	c.Set("user", user)
+	c.Next()
+}
@@ -39,10 +39,14 @@ func getTemp() (float64, error) {
 	req.Header.Add("Accept", "*/*")
 	req.Header.Add("User-Agent", "recipeapp")
 
-	res, _ := http.DefaultClient.Do(req)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
 
 	var data WeatherData
-	err := json.NewDecoder(res.Body).Decode(&data)
+	err = json.NewDecoder(res.Body).Decode(&data)
 	if err != nil {
 		return 0, err
 	}
@@ -68,6 +72,9 @@ func GetCurrentData() (CurrentData, error) {
 		season = "non"
 	}
 
+	res.Season = season
+	res.Day = time.Now().Weekday().String()[:3]
+
 	temp, err := getTemp()
 	if err != nil {
 		return res, err
@@ -86,8 +93,5 @@ func GetCurrentData() (CurrentData, error) {
 		res.Temp = "thirtydegree"
 	}
 
-	res.Season = season
-	res.Day = time.Now().Weekday().String()[:3]
-
 	return res, err
 }
@@ -1,7 +1,9 @@
 package tools
 
 import (
+	"fmt"
 	"math"
+	"sort"
 )
 
 func RoundFloat(val float64, precision uint) float64 {
@@ -63,23 +65,50 @@ type Matrix struct {
 	Len  int
 }
 
-func MergeMatrix(a, b Matrix) Matrix {
-	for k := range b.Dict {
-		if a.Dict[k] == 0 {
-			a.Dict[k] = len(a.Dict) + 1
+// MergeMatrix adds b to the average a. Dict indices start at 1 and point into Vec, gaps are
+// allowed but indices outside of Vec are an error. a is not modified.
+func MergeMatrix(a, b Matrix) (Matrix, error) {
+	dict := make(map[string]int, len(a.Dict)+len(b.Dict))
+	next := 0
+	for k, v := range a.Dict {
+		if v < 1 {
+			return a, fmt.Errorf("index %d of %q is out of range", v, k)
 		}
+		dict[k] = v
+		next = max(next, v)
 	}
-
-	//Merge Vec of Ingredient
-	a.Vec = append(a.Vec, make([]float64, len(a.Dict)-len(a.Vec))...)
-	b_vec := make([]float64, len(a.Dict))
+	type entry struct {
+		key   string
+		index int
+	}
+	entries := make([]entry, 0, len(b.Dict))
 	for k, v := range b.Dict {
-		i := a.Dict[k]
-		b_vec[i-1] = b.Vec[v-1]
+		if v < 1 || v > len(b.Vec) {
+			return a, fmt.Errorf("index %d of %q is out of range of %d values", v, k, len(b.Vec))
+		}
+		entries = append(entries, entry{k, v})
+	}
+	// Walk b in index order so merged dicts are deterministic
+	sort.Slice(entries, func(i, j int) bool { return entries[i].index < entries[j].index })
+	for _, e := range entries {
+		if _, ok := dict[e.key]; !ok {
+			next++
+			dict[e.key] = next
+		}
 	}
 
-	vec := AddVectors(MultiplyVectorByNum(float64(a.Len), a.Vec), MultiplyVectorByNum(float64(b.Len), b_vec))
-	a.Vec = MultiplyVectorByNum(1.0/float64(a.Len+1), vec)
+	//Merge Vec of Ingredient
+	a_vec := append(make([]float64, 0, next), a.Vec...)
+	a_vec = append(a_vec, make([]float64, max(next-len(a_vec), 0))...)
+	b_vec := make([]float64, len(a_vec))
+	for _, e := range entries {
+		b_vec[dict[e.key]-1] = b.Vec[e.index-1]
+	}
 
-	return a
+	vec := AddVectors(MultiplyVectorByNum(float64(a.Len), a_vec), MultiplyVectorByNum(float64(b.Len), b_vec))
+	return Matrix{
+		Dict: dict,
+		Vec:  MultiplyVectorByNum(1.0/float64(a.Len+1), vec),
+		Len:  a.Len,
+	}, nil
 }
@@ -0,0 +1,238 @@
+package units
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type Kind int
+
+const (
+	Mass Kind = iota
+	Volume
+	Count
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Mass:
+		return "mass"
+	case Volume:
+		return "volume"
+	}
+	return "count"
+}
+
+type System string
+
+const (
+	Metric   System = "metric"
+	Imperial System = "imperial"
+)
+
+type Unit struct {
+	Name   string
+	Kind   Kind
+	System System
+	// Base is how many g, ml or pieces one of the unit is
+	Base float64
+	// Preferred units are the ones amounts are converted into when changing the system
+	Preferred bool
+}
+
+// Spoons and cups use the rounded metric sizes most recipes are written with
+var registry = []Unit{
+	{Name: "mg", Kind: Mass, System: Metric, Base: 0.001},
+	{Name: "g", Kind: Mass, System: Metric, Base: 1, Preferred: true},
+	{Name: "kg", Kind: Mass, System: Metric, Base: 1000, Preferred: true},
+	{Name: "oz", Kind: Mass, System: Imperial, Base: 28.349523125, Preferred: true},
+	{Name: "lb", Kind: Mass, System: Imperial, Base: 453.59237, Preferred: true},
+
+	{Name: "ml", Kind: Volume, System: Metric, Base: 1, Preferred: true},
+	{Name: "cl", Kind: Volume, System: Metric, Base: 10},
+	{Name: "dl", Kind: Volume, System: Metric, Base: 100},
+	{Name: "l", Kind: Volume, System: Metric, Base: 1000, Preferred: true},
+	{Name: "pinch", Kind: Volume, System: Imperial, Base: 0.3},
+	{Name: "tsp", Kind: Volume, System: Imperial, Base: 5, Preferred: true},
+	{Name: "tbsp", Kind: Volume, System: Imperial, Base: 15, Preferred: true},
+	{Name: "fl oz", Kind: Volume, System: Imperial, Base: 29.5735},
+	{Name: "cup", Kind: Volume, System: Imperial, Base: 240, Preferred: true},
+	{Name: "pint", Kind: Volume, System: Imperial, Base: 473.176},
+	{Name: "quart", Kind: Volume, System: Imperial, Base: 946.353},
+	{Name: "gallon", Kind: Volume, System: Imperial, Base: 3785.41},
+
+	{Name: "piece", Kind: Count, Base: 1},
+	{Name: "small", Kind: Count, Base: 1},
+	{Name: "medium", Kind: Count, Base: 1},
+	{Name: "large", Kind: Count, Base: 1},
+	{Name: "clove", Kind: Count, Base: 1},
+	{Name: "slice", Kind: Count, Base: 1},
+	{Name: "can", Kind: Count, Base: 1},
+	{Name: "bunch", Kind: Count, Base: 1},
+	{Name: "sprig", Kind: Count, Base: 1},
+	{Name: "leaf", Kind: Count, Base: 1},
+}
+
+var aliases = map[string]string{
+	"milligram": "mg", "milligrams": "mg",
+	"gram": "g", "grams": "g", "gr": "g",
+	"kilogram": "kg", "kilograms": "kg", "kilo": "kg", "kilos": "kg",
+	"ounce": "oz", "ounces": "oz",
+	"pound": "lb", "pounds": "lb", "lbs": "lb",
+	"milliliter": "ml", "milliliters": "ml", "millilitre": "ml", "millilitres": "ml",
+	"centiliter": "cl", "centiliters": "cl", "centilitre": "cl", "centilitres": "cl",
+	"deciliter": "dl", "deciliters": "dl", "decilitre": "dl", "decilitres": "dl",
+	"liter": "l", "liters": "l", "litre": "l", "litres": "l",
+	"pinches":  "pinch",
+	"teaspoon": "tsp", "teaspoons": "tsp", "tsps": "tsp",
+	"tablespoon": "tbsp", "tablespoons": "tbsp", "tbsps": "tbsp", "tbs": "tbsp",
+	"fluid ounce": "fl oz", "fluid ounces": "fl oz", "floz": "fl oz",
+	"cups":  "cup",
+	"pints": "pint", "quarts": "quart", "gallons": "gallon",
+	"pieces": "piece", "pc": "piece", "pcs": "piece",
+	"cloves": "clove", "slices": "slice", "cans": "can", "bunches": "bunch",
+	"sprigs": "sprig", "leaves": "leaf",
+}
+
+var byName = func() map[string]Unit {
+	m := make(map[string]Unit, len(registry))
+	for _, u := range registry {
+		m[u.Name] = u
+	}
+	return m
+}()
+
+// Lookup finds a unit by its name, abbreviation or plural, case insensitive
+func Lookup(name string) (Unit, bool) {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if alias, ok := aliases[name]; ok {
+		name = alias
+	}
+	u, ok := byName[name]
+	return u, ok
+}
+
+// Normalize returns the registered name of a unit, unknown units are returned unchanged
+func Normalize(name string) string {
+	if u, ok := Lookup(name); ok {
+		return u.Name
+	}
+	return name
+}
+
+// Units lists all registered units
+func Units() []Unit {
+	return append([]Unit(nil), registry...)
+}
+
+// Convert converts amount between two units of the same kind
+func Convert(amount float64, from string, to string) (float64, error) {
+	f, ok := Lookup(from)
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %s", from)
+	}
+	t, ok := Lookup(to)
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %s", to)
+	}
+	if f.Kind != t.Kind {
+		return 0, fmt.Errorf("can't convert %s (%s) to %s (%s)", f.Name, f.Kind, t.Name, t.Kind)
+	}
+	if f.Kind == Count && f.Name != t.Name {
+		return 0, fmt.Errorf("can't convert %s to %s", f.Name, t.Name)
+	}
+	return amount * f.Base / t.Base, nil
+}
+
+// ToSystem converts amount into the largest preferred unit of system that keeps it at least 1.
+// Counted amounts and units already in the system are returned unchanged.
+func ToSystem(amount float64, unit string, system System) (float64, string, error) {
+	u, ok := Lookup(unit)
+	if !ok {
+		return 0, "", fmt.Errorf("unknown unit %s", unit)
+	}
+	if u.Kind == Count || u.System == system {
+		return amount, u.Name, nil
+	}
+
+	base := amount * u.Base
+	best, ok := largest(base, u.Kind, system)
+	if !ok {
+		best = smallest(u.Kind, system)
+	}
+	return base / best.Base, best.Name, nil
+}
+
+// largest returns the largest preferred unit of kind and system that base is at least one of
+func largest(base float64, kind Kind, system System) (Unit, bool) {
+	var best Unit
+	found := false
+	for _, c := range registry {
+		if c.Kind == kind && c.System == system && c.Preferred && base/c.Base >= 1 && (!found || c.Base > best.Base) {
+			best, found = c, true
+		}
+	}
+	return best, found
+}
+
+func smallest(kind Kind, system System) Unit {
+	var best Unit
+	for _, c := range registry {
+		if c.Kind == kind && c.System == system && c.Preferred && (best.Name == "" || c.Base < best.Base) {
+			best = c
+		}
+	}
+	return best
+}
+
+// Simplify moves an amount into the largest preferred unit of its system it is at least one of,
+// so 1000 g become 1 kg and 3 tsp 1 tbsp. Units are only ever made larger, 0.75 cup stay 0.75 cup
+// instead of becoming 12 tbsp.
+func Simplify(amount float64, unit string) (float64, string) {
+	u, ok := Lookup(unit)
+	if !ok || u.Kind == Count {
+		return amount, unit
+	}
+	best, ok := largest(amount*u.Base, u.Kind, u.System)
+	if !ok || best.Base <= u.Base {
+		return amount, unit
+	}
+	return amount * u.Base / best.Base, best.Name
+}
+
+// Round rounds an amount to what can be measured in its unit. Pieces are rounded to halves,
+// spoons and cups to quarters and everything else to about three significant digits.
+func Round(amount float64, unit string) float64 {
+	step := 0.0
+	if u, ok := Lookup(unit); ok {
+		if u.Kind == Count {
+			step = 0.5
+		} else if u.Kind == Volume && u.System == Imperial {
+			step = 0.25
+		}
+	}
+	if step > 0 {
+		return math.Max(step, math.Round(amount/step)*step)
+	}
+
+	switch {
+	case amount >= 100:
+		return math.Round(amount)
+	case amount >= 10:
+		return math.Round(amount*10) / 10
+	}
+	return math.Round(amount*100) / 100
+}
+
+// Scale multiplies an amount by factor, moves it into a larger unit if it fits one and rounds it
+func Scale(amount float64, unit string, factor float64) (float64, string) {
+	amount, unit = Simplify(amount*factor, unit)
+	return Round(amount, unit), unit
+}
+
+// FormatAmount formats an amount with at most two decimals
+func FormatAmount(amount float64) string {
+	return strconv.FormatFloat(math.Round(amount*100)/100, 'f', -1, 64)
+}
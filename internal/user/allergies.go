@@ -0,0 +1,46 @@
+package user
+
+import (
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/recipe"
+)
+
+// LoadAllergies reads the allergies of the user into its settings
+func (user *UserModel) LoadAllergies(db *sqlx.DB) *error_handler.APIError {
+	user.Settings.Allergies = []string{}
+	err := db.Select(&user.Settings.Allergies, `SELECT allergen_id FROM user_allergy WHERE user_id = $1 ORDER BY allergen_id`, user.ID)
+	if err != nil {
+		return error_handler.New("Error getting allergies: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// SetAllergies replaces the allergies of the user, unknown allergens are a bad request
+func (user *UserModel) SetAllergies(db *sqlx.DB, allergies []string) *error_handler.APIError {
+	apiErr := recipe.CheckAllergens(allergies, db)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	tx := db.MustBegin()
+	_, err := tx.Exec(`DELETE FROM user_allergy WHERE user_id = $1`, user.ID)
+	if err != nil {
+		tx.Rollback()
+		return error_handler.New("Error removing allergies: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	_, err = tx.Exec(`INSERT INTO user_allergy (user_id, allergen_id)
+		SELECT $1, a FROM unnest($2::text[]) a ON CONFLICT DO NOTHING`, user.ID, pq.Array(allergies))
+	if err != nil {
+		tx.Rollback()
+		return error_handler.New("Error saving allergies: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return error_handler.New("Error saving allergies: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return user.LoadAllergies(db)
+}
@@ -0,0 +1,114 @@
+package user
+
+import (
+	"errors"
+	"log"
+	"sort"
+
+	"github.com/madswillem/recipeApp/internal/recipe"
+)
+
+type SimilarityWeights struct {
+	Ingredients float64 `json:"ingredients"`
+	Cuisine     float64 `json:"cuisine"`
+	Preperation float64 `json:"preperation"`
+	Techniques  float64 `json:"techniques"`
+}
+
+// GroupingConfig controls how selected recipes are clustered into recipe groups.
+// A recipe is added to the most similar group if it reaches AddThreshold, every other group
+// reaching MergeThreshold is merged into that group. Recipes matching no group start a new one
+// unless the user already has MaxGroups groups, then they join the most similar group. A MaxGroups of 0 means no limit.
+type GroupingConfig struct {
+	Weights        SimilarityWeights `json:"weights"`
+	AddThreshold   float64           `json:"add_threshold"`
+	MergeThreshold float64           `json:"merge_threshold"`
+	MaxGroups      int               `json:"max_groups"`
+}
+
+func DefaultGroupingConfig() GroupingConfig {
+	return GroupingConfig{
+		Weights: SimilarityWeights{
+			Ingredients: 2,
+			Cuisine:     3,
+			Preperation: 1,
+			Techniques:  2,
+		},
+		AddThreshold:   .9,
+		MergeThreshold: .9,
+		MaxGroups:      0,
+	}
+}
+
+func (cfg *GroupingConfig) Validate() error {
+	w := cfg.Weights
+	if w.Ingredients < 0 || w.Cuisine < 0 || w.Preperation < 0 || w.Techniques < 0 {
+		return errors.New("weights can't be negative")
+	}
+	if w.Ingredients+w.Cuisine+w.Preperation+w.Techniques == 0 {
+		return errors.New("at least one weight has to be positive")
+	}
+	if cfg.AddThreshold < 0 || cfg.AddThreshold > 1 {
+		return errors.New("add_threshold has to be between 0 and 1")
+	}
+	if cfg.MergeThreshold < 0 || cfg.MergeThreshold > 1 {
+		return errors.New("merge_threshold has to be between 0 and 1")
+	}
+	if cfg.MaxGroups < 0 {
+		return errors.New("max_groups can't be negative")
+	}
+	return nil
+}
+
+// Assign adds the recipe to the fitting group and returns the updated groups
+func (cfg *GroupingConfig) Assign(groups []RecipeGroupSchema, r *recipe.RecipeSchema) []RecipeGroupSchema {
+	if len(groups) < 1 {
+		rp := RecipeGroupSchema{}
+		rp.Create(r)
+		return append(groups, rp)
+	}
+
+	ranking := make([]struct {
+		Index int
+		Sim   float64
+	}, len(groups))
+	for i := range groups {
+		ranking[i].Index = i
+		ranking[i].Sim = groups[i].CompareDetailed(r, cfg.Weights).Total
+	}
+	sort.SliceStable(ranking, func(i, j int) bool {
+		return ranking[i].Sim > ranking[j].Sim
+	})
+
+	if ranking[0].Sim < cfg.AddThreshold && (cfg.MaxGroups == 0 || len(groups) < cfg.MaxGroups) {
+		rp := RecipeGroupSchema{}
+		rp.Create(r)
+		return append(groups, rp)
+	}
+
+	best := &groups[ranking[0].Index]
+	merged := make(map[int]bool)
+	for _, rank := range ranking[1:] {
+		if rank.Sim < cfg.MergeThreshold || ranking[0].Sim < cfg.AddThreshold {
+			break
+		}
+		// Groups with broken vectors stay on their own
+		if err := best.Merge(&groups[rank.Index]); err != nil {
+			log.Default().Println("Error merging recipe groups:", err)
+			continue
+		}
+		merged[rank.Index] = true
+	}
+	best.Add(r)
+
+	if len(merged) == 0 {
+		return groups
+	}
+	result := make([]RecipeGroupSchema, 0, len(groups)-len(merged))
+	for i := range groups {
+		if !merged[i] {
+			result = append(result, groups[i])
+		}
+	}
+	return result
+}
@@ -0,0 +1,82 @@
+package user
+
+import (
+	"sort"
+
+	"github.com/madswillem/recipeApp/internal/recipe"
+)
+
+type GroupingReport struct {
+	Config    GroupingConfig       `json:"config"`
+	Users     []UserGroupingReport `json:"users"`
+	Selects   int                  `json:"selects"`
+	Groups    int                  `json:"groups"`
+	AvgGroups float64              `json:"avg_groups"`
+	Purity    float64              `json:"purity"`
+}
+
+type UserGroupingReport struct {
+	UserID  string  `json:"user_id"`
+	Selects int     `json:"selects"`
+	Groups  int     `json:"groups"`
+	Purity  float64 `json:"purity"`
+}
+
+// EvaluateGrouping replays the select history of every user with the given config.
+// history maps user ids to the selected recipes in the order they were selected,
+// label returns the class a recipe should be grouped by to calculate the purity of the groups.
+func EvaluateGrouping(cfg GroupingConfig, history map[string][]*recipe.RecipeSchema, label func(*recipe.RecipeSchema) string) GroupingReport {
+	report := GroupingReport{Config: cfg, Users: []UserGroupingReport{}}
+
+	ids := make([]string, 0, len(history))
+	for id := range history {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	pure := 0
+	for _, id := range ids {
+		labels := make(map[string]string)
+		var groups []RecipeGroupSchema
+		for _, r := range history[id] {
+			labels[r.ID] = label(r)
+			groups = cfg.Assign(groups, r)
+		}
+
+		p := groupingPurity(groups, labels)
+		report.Users = append(report.Users, UserGroupingReport{
+			UserID:  id,
+			Selects: len(history[id]),
+			Groups:  len(groups),
+			Purity:  float64(p) / float64(max(len(history[id]), 1)),
+		})
+		report.Selects += len(history[id])
+		report.Groups += len(groups)
+		pure += p
+	}
+
+	if len(report.Users) > 0 {
+		report.AvgGroups = float64(report.Groups) / float64(len(report.Users))
+	}
+	if report.Selects > 0 {
+		report.Purity = float64(pure) / float64(report.Selects)
+	}
+	return report
+}
+
+// groupingPurity returns the number of recipes that share the most common label of their group
+func groupingPurity(groups []RecipeGroupSchema, labels map[string]string) int {
+	pure := 0
+	for _, g := range groups {
+		count := make(map[string]int)
+		most := 0
+		for _, id := range g.RecipeIDs {
+			count[labels[id]]++
+			if count[labels[id]] > most {
+				most = count[labels[id]]
+			}
+		}
+		pure += most
+	}
+	return pure
+}
@@ -0,0 +1,234 @@
+package user
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/recipe"
+)
+
+type DislikedIngredient struct {
+	ID   string `db:"id" json:"id"`
+	Name string `db:"name" json:"name"`
+}
+
+// PreferencesUpdate replaces the preferences that are set, an empty MaxPrepTime removes it
+type PreferencesUpdate struct {
+	Diets               *[]string `json:"diets"`
+	DislikedIngredients *[]string `json:"disliked_ingredients"`
+	MaxPrepTime         *string   `json:"max_prep_time"`
+}
+
+// LoadPreferences reads the allergies, diets, disliked ingredients and max prep time of the user into its settings
+func (user *UserModel) LoadPreferences(db *sqlx.DB) *error_handler.APIError {
+	apiErr := user.LoadAllergies(db)
+	if apiErr != nil {
+		return apiErr
+	}
+
+	user.Settings.Diets = []recipe.DietSchema{}
+	err := db.Select(&user.Settings.Diets, `SELECT diet.*
+		FROM rel_user_diet rel
+		JOIN diet ON rel.diet_id = diet.id
+		WHERE rel.user_id = $1
+		ORDER BY diet.name, diet.id`, user.ID)
+	if err != nil {
+		return error_handler.New("Error getting diets: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	user.Settings.DislikedIngredients = []DislikedIngredient{}
+	err = db.Select(&user.Settings.DislikedIngredients, `SELECT ingredient.id, ingredient.name
+		FROM user_disliked_ingredient d
+		JOIN ingredient ON ingredient.id = d.ingredient_id
+		WHERE d.user_id = $1
+		ORDER BY LOWER(ingredient.name)`, user.ID)
+	if err != nil {
+		return error_handler.New("Error getting disliked ingredients: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	err = db.Get(&user.Settings.MaxPrepTime, `SELECT max_prep_time::text FROM "user" WHERE id = $1`, user.ID)
+	if err != nil {
+		return error_handler.New("Error getting max prep time: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// unknownIDs returns the ids that aren't in the table
+func unknownIDs(db sqlx.Queryer, table string, ids []string) ([]string, error) {
+	unknown := []string{}
+	err := sqlx.Select(db, &unknown, `SELECT i FROM unnest($1::text[]) i
+		WHERE NOT EXISTS (SELECT 1 FROM `+table+` WHERE id::text = i) ORDER BY i`, pq.Array(ids))
+	return unknown, err
+}
+
+func checkIDs(db sqlx.Queryer, table string, what string, ids []string) *error_handler.APIError {
+	unknown, err := unknownIDs(db, table, ids)
+	if err != nil {
+		return error_handler.New("Error checking "+what+": "+err.Error(), http.StatusInternalServerError, err)
+	}
+	if len(unknown) > 0 {
+		msg := "Unknown " + what + " " + strings.Join(unknown, ", ")
+		return error_handler.New(msg, http.StatusBadRequest, errors.New(strings.ToLower(msg)))
+	}
+	return nil
+}
+
+// SetPreferences replaces the preferences that are set in the update and reloads them
+func (user *UserModel) SetPreferences(db *sqlx.DB, update PreferencesUpdate) *error_handler.APIError {
+	if update.Diets != nil {
+		apiErr := checkIDs(db, "diet", "diets", *update.Diets)
+		if apiErr != nil {
+			return apiErr
+		}
+	}
+	if update.DislikedIngredients != nil {
+		apiErr := checkIDs(db, "ingredient", "ingredients", *update.DislikedIngredients)
+		if apiErr != nil {
+			return apiErr
+		}
+	}
+	var maxPrepTime *string
+	if update.MaxPrepTime != nil && *update.MaxPrepTime != "" {
+		d, ok := recipe.ParseInterval(*update.MaxPrepTime)
+		if !ok || d <= 0 {
+			return error_handler.New("max_prep_time has to be a positive time like 00:30:00", http.StatusBadRequest, errors.New("invalid max_prep_time"))
+		}
+		maxPrepTime = update.MaxPrepTime
+	}
+
+	tx := db.MustBegin()
+	var err error
+	if update.Diets != nil {
+		_, err = tx.Exec(`DELETE FROM rel_user_diet WHERE user_id = $1`, user.ID)
+		if err == nil {
+			_, err = tx.Exec(`INSERT INTO rel_user_diet (user_id, diet_id)
+				SELECT $1, d::uuid FROM unnest($2::text[]) d ON CONFLICT DO NOTHING`, user.ID, pq.Array(*update.Diets))
+		}
+	}
+	if err == nil && update.DislikedIngredients != nil {
+		_, err = tx.Exec(`DELETE FROM user_disliked_ingredient WHERE user_id = $1`, user.ID)
+		if err == nil {
+			_, err = tx.Exec(`INSERT INTO user_disliked_ingredient (user_id, ingredient_id)
+				SELECT $1, i::uuid FROM unnest($2::text[]) i ON CONFLICT DO NOTHING`, user.ID, pq.Array(*update.DislikedIngredients))
+		}
+	}
+	if err == nil && update.MaxPrepTime != nil {
+		_, err = tx.Exec(`UPDATE "user" SET max_prep_time = $2::interval WHERE id = $1`, user.ID, maxPrepTime)
+	}
+	if err != nil {
+		tx.Rollback()
+		return error_handler.New("Error saving preferences: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		return error_handler.New("Error saving preferences: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return user.LoadPreferences(db)
+}
+
+// AddDiet adds a diet to the preferred ones
+func (user *UserModel) AddDiet(db *sqlx.DB, dietID string) *error_handler.APIError {
+	apiErr := checkIDs(db, "diet", "diet", []string{dietID})
+	if apiErr != nil {
+		apiErr.Code = http.StatusNotFound
+		return apiErr
+	}
+	_, err := db.Exec(`INSERT INTO rel_user_diet (user_id, diet_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, user.ID, dietID)
+	if err != nil {
+		return error_handler.New("Error adding diet: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return user.LoadPreferences(db)
+}
+
+func (user *UserModel) RemoveDiet(db *sqlx.DB, dietID string) *error_handler.APIError {
+	_, err := db.Exec(`DELETE FROM rel_user_diet WHERE user_id = $1 AND diet_id::text = $2`, user.ID, dietID)
+	if err != nil {
+		return error_handler.New("Error removing diet: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return user.LoadPreferences(db)
+}
+
+// AddDislikedIngredient leaves recipes with the ingredient out by default
+func (user *UserModel) AddDislikedIngredient(db *sqlx.DB, ingredientID string) *error_handler.APIError {
+	apiErr := checkIDs(db, "ingredient", "ingredient", []string{ingredientID})
+	if apiErr != nil {
+		apiErr.Code = http.StatusNotFound
+		return apiErr
+	}
+	_, err := db.Exec(`INSERT INTO user_disliked_ingredient (user_id, ingredient_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, user.ID, ingredientID)
+	if err != nil {
+		return error_handler.New("Error adding disliked ingredient: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return user.LoadPreferences(db)
+}
+
+func (user *UserModel) RemoveDislikedIngredient(db *sqlx.DB, ingredientID string) *error_handler.APIError {
+	_, err := db.Exec(`DELETE FROM user_disliked_ingredient WHERE user_id = $1 AND ingredient_id::text = $2`, user.ID, ingredientID)
+	if err != nil {
+		return error_handler.New("Error removing disliked ingredient: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	return user.LoadPreferences(db)
+}
+
+// WithoutPreferences returns the settings with only the allergies, which can't be ignored
+func (s UserSettings) WithoutPreferences() UserSettings {
+	return UserSettings{Allergies: s.Allergies}
+}
+
+// ApplyTo sets the preferences as defaults of the filter, the allergies are always excluded
+func (s UserSettings) ApplyTo(f *recipe.Filter) {
+	f.ExcludeAllergens = append(f.ExcludeAllergens, s.Allergies...)
+	if f.IgnorePreferences {
+		return
+	}
+	if f.Diets == nil && len(s.Diets) > 0 {
+		diets := make([]string, len(s.Diets))
+		for i, d := range s.Diets {
+			diets[i] = d.ID
+		}
+		f.Diets = &diets
+	}
+	if f.PrepTime == nil && s.MaxPrepTime != nil {
+		f.PrepTime = s.MaxPrepTime
+	}
+	for _, ing := range s.DislikedIngredients {
+		f.ExcludeIngredients = append(f.ExcludeIngredients, ing.ID)
+	}
+}
+
+// Allows reports if the recipe may be recommended, it has to follow all diets, contain
+// no disliked ingredient or allergen and be prepared in time
+func (s UserSettings) Allows(r *recipe.RecipeSchema) bool {
+	if len(r.ContainsAny(s.Allergies)) > 0 {
+		return false
+	}
+	for _, d := range s.Diets {
+		follows := false
+		for _, rd := range r.Diet {
+			if rd.ID == d.ID && len(rd.Conflicts) == 0 {
+				follows = true
+				break
+			}
+		}
+		if !follows {
+			return false
+		}
+	}
+	for _, disliked := range s.DislikedIngredients {
+		for _, ing := range r.Ingredients {
+			if ing.IngredientID == disliked.ID {
+				return false
+			}
+		}
+	}
+	if s.MaxPrepTime != nil {
+		max, _ := recipe.ParseInterval(*s.MaxPrepTime)
+		prep, ok := recipe.ParseInterval(r.PrepTime)
+		if !ok || prep > max {
+			return false
+		}
+	}
+	return true
+}
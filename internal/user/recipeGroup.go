@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
@@ -14,6 +15,8 @@ import (
 
 type RecipeGroupSchema struct {
 	ID              string
+	Name            string
+	Version         int64
 	IngredientDict  map[string]int
 	IngredientVec   []float64
 	PreperationDict map[string]int
@@ -33,6 +36,19 @@ func (rp *RecipeGroupSchema) Value() (driver.Value, error) {
 	return json.Marshal(rp)
 }
 
+// BuildGroup creates a group out of the given recipes
+func BuildGroup(recipes ...*recipe.RecipeSchema) RecipeGroupSchema {
+	rp := RecipeGroupSchema{}
+	for i, r := range recipes {
+		if i == 0 {
+			rp.Create(r)
+			continue
+		}
+		rp.Add(r)
+	}
+	return rp
+}
+
 func (rp *RecipeGroupSchema) Create(r *recipe.RecipeSchema) {
 	// Vectorize the Recipes
 	// Vectorize Ingredients
@@ -56,7 +72,7 @@ func (rp *RecipeGroupSchema) Create(r *recipe.RecipeSchema) {
 	rp.CuisineDict = h.OutputMatrix.Dict
 	rp.CuisineVec = h.OutputMatrix.Vec[0]
 
-	technique_list := make([]string, len(r.Ingredients))
+	technique_list := make([]string, len(r.Steps))
 	for n, i := range r.Steps {
 		if i.TechniqueID == nil {
 			t := ""
@@ -88,10 +104,23 @@ func (rp *RecipeGroupSchema) Create(r *recipe.RecipeSchema) {
 	rp.PreperationVec = h.OutputMatrix.Vec[0]
 }
 
+type Similarity struct {
+	Ingredients float64 `json:"ingredients"`
+	Cuisine     float64 `json:"cuisine"`
+	Preperation float64 `json:"preperation"`
+	Techniques  float64 `json:"techniques"`
+	Total       float64 `json:"total"`
+}
+
 func (rp *RecipeGroupSchema) Compare(r *recipe.RecipeSchema) float64 {
+	return rp.CompareDetailed(r, DefaultGroupingConfig().Weights).Total
+}
+
+// CompareDetailed returns the similarity of every dimension of the recipe to the group and the weighted total
+func (rp *RecipeGroupSchema) CompareDetailed(r *recipe.RecipeSchema, w SimilarityWeights) Similarity {
 	h := gompare.New(gompare.Config{
 		Matrix: gompare.Matrix{
-			Dict: rp.IngredientDict,
+			Dict: copyDict(rp.IngredientDict),
 			Vec:  [][]float64{rp.IngredientVec},
 		},
 	})
@@ -102,22 +131,22 @@ func (rp *RecipeGroupSchema) Compare(r *recipe.RecipeSchema) float64 {
 	h.InputStrings = append(h.InputStrings, ingredient_list)
 	h.NormalMatrix()
 	h.CosineSimilarity(0, 1)
-	simIngs := h.Similarity
+	simIngs := similarity(h)
 
 	h = gompare.New(gompare.Config{
 		Matrix: gompare.Matrix{
-			Dict: rp.CuisineDict,
+			Dict: copyDict(rp.CuisineDict),
 			Vec:  [][]float64{rp.CuisineVec},
 		},
 	})
 	h.Add(r.Cuisine)
 	h.NormalMatrix()
 	h.CosineSimilarity(0, 1)
-	simCuisine := h.Similarity
+	simCuisine := similarity(h)
 
 	h = gompare.New(gompare.Config{
 		Matrix: gompare.Matrix{
-			Dict: rp.PreperationDict,
+			Dict: copyDict(rp.PreperationDict),
 			Vec:  [][]float64{rp.PreperationVec},
 		},
 	})
@@ -129,9 +158,9 @@ func (rp *RecipeGroupSchema) Compare(r *recipe.RecipeSchema) float64 {
 	h.Add(prep)
 	h.NormalMatrix()
 	h.CosineSimilarity(0, 1)
-	simPrep := h.Similarity
+	simPrep := similarity(h)
 
-	technique_list := make([]string, len(r.Ingredients))
+	technique_list := make([]string, len(r.Steps))
 	for n, i := range r.Steps {
 		if i.TechniqueID == nil {
 			t := ""
@@ -141,17 +170,40 @@ func (rp *RecipeGroupSchema) Compare(r *recipe.RecipeSchema) float64 {
 	}
 	h = gompare.New(gompare.Config{
 		Matrix: gompare.Matrix{
-			Dict: rp.TechniquesDict,
+			Dict: copyDict(rp.TechniquesDict),
 			Vec:  [][]float64{rp.TechniquesVec},
 		},
 	})
 	h.InputStrings = append(h.InputStrings, technique_list)
 	h.NormalMatrix()
 	h.CosineSimilarity(0, 1)
-	simTech := h.Similarity
+	simTech := similarity(h)
+
+	sim := simIngs*w.Ingredients + simCuisine*w.Cuisine + simPrep*w.Preperation + simTech*w.Techniques
+	return Similarity{
+		Ingredients: simIngs,
+		Cuisine:     simCuisine,
+		Preperation: simPrep,
+		Techniques:  simTech,
+		Total:       sim / (w.Ingredients + w.Cuisine + w.Preperation + w.Techniques),
+	}
+}
+
+// copyDict copies a word dict, gompare adds unknown words to the dict it is given
+func copyDict(dict map[string]int) map[string]int {
+	c := make(map[string]int, len(dict))
+	for k, v := range dict {
+		c[k] = v
+	}
+	return c
+}
 
-	sim := simIngs*2 + simCuisine*3 + simPrep + simTech*2
-	return sim / 8
+// similarity returns the last computed similarity of h, empty vectors count as not similar
+func similarity(h *gompare.Handler) float64 {
+	if math.IsNaN(h.Similarity) {
+		return 0
+	}
+	return h.Similarity
 }
 
 func (rp *RecipeGroupSchema) Add(r *recipe.RecipeSchema) {
@@ -210,62 +262,39 @@ func (rp *RecipeGroupSchema) Add(r *recipe.RecipeSchema) {
 	rp.CookingTime /= time.Duration(len(rp.RecipeIDs) + 1)
 }
 
-func (rp *RecipeGroupSchema) Merge(rp2 *RecipeGroupSchema) {
+// Merge adds the recipes of rp2 to the group, on an error the group is left unchanged
+func (rp *RecipeGroupSchema) Merge(rp2 *RecipeGroupSchema) error {
+	merge := func(dict map[string]int, vec []float64, dict2 map[string]int, vec2 []float64) (tools.Matrix, error) {
+		return tools.MergeMatrix(
+			tools.Matrix{Dict: dict, Vec: vec, Len: len(rp.RecipeIDs)},
+			tools.Matrix{Dict: dict2, Vec: vec2, Len: len(rp2.RecipeIDs)},
+		)
+	}
 	//Merge Ingredients
-	m := tools.MergeMatrix(tools.Matrix{
-		Dict: rp.IngredientDict,
-		Vec:  rp.IngredientVec,
-		Len:  len(rp.RecipeIDs),
-	}, tools.Matrix{
-		Dict: rp2.IngredientDict,
-		Vec:  rp2.IngredientVec,
-		Len:  len(rp2.RecipeIDs),
-	})
-
-	rp.IngredientDict = m.Dict
-	rp.IngredientVec = m.Vec
-
+	ingredients, err := merge(rp.IngredientDict, rp.IngredientVec, rp2.IngredientDict, rp2.IngredientVec)
+	if err != nil {
+		return fmt.Errorf("merging ingredients: %w", err)
+	}
 	//Merge Cuisine
-	m = tools.MergeMatrix(tools.Matrix{
-		Dict: rp.CuisineDict,
-		Vec:  rp.CuisineVec,
-		Len:  len(rp.RecipeIDs),
-	}, tools.Matrix{
-		Dict: rp2.CuisineDict,
-		Vec:  rp2.CuisineVec,
-		Len:  len(rp2.RecipeIDs),
-	})
-
-	rp.CuisineDict = m.Dict
-	rp.CuisineVec = m.Vec
-
+	cuisine, err := merge(rp.CuisineDict, rp.CuisineVec, rp2.CuisineDict, rp2.CuisineVec)
+	if err != nil {
+		return fmt.Errorf("merging cuisines: %w", err)
+	}
 	//Merge Preperation
-	m = tools.MergeMatrix(tools.Matrix{
-		Dict: rp.PreperationDict,
-		Vec:  rp.PreperationVec,
-		Len:  len(rp.RecipeIDs),
-	}, tools.Matrix{
-		Dict: rp2.PreperationDict,
-		Vec:  rp2.PreperationVec,
-		Len:  len(rp2.RecipeIDs),
-	})
-
-	rp.PreperationDict = m.Dict
-	rp.PreperationVec = m.Vec
-
+	preperation, err := merge(rp.PreperationDict, rp.PreperationVec, rp2.PreperationDict, rp2.PreperationVec)
+	if err != nil {
+		return fmt.Errorf("merging preperation: %w", err)
+	}
 	//Merge Techniques
-	m = tools.MergeMatrix(tools.Matrix{
-		Dict: rp.TechniquesDict,
-		Vec:  rp.TechniquesVec,
-		Len:  len(rp.RecipeIDs),
-	}, tools.Matrix{
-		Dict: rp2.TechniquesDict,
-		Vec:  rp2.TechniquesVec,
-		Len:  len(rp2.RecipeIDs),
-	})
+	techniques, err := merge(rp.TechniquesDict, rp.TechniquesVec, rp2.TechniquesDict, rp2.TechniquesVec)
+	if err != nil {
+		return fmt.Errorf("merging techniques: %w", err)
+	}
 
-	rp.TechniquesDict = m.Dict
-	rp.TechniquesVec = m.Vec
+	rp.IngredientDict, rp.IngredientVec = ingredients.Dict, ingredients.Vec
+	rp.CuisineDict, rp.CuisineVec = cuisine.Dict, cuisine.Vec
+	rp.PreperationDict, rp.PreperationVec = preperation.Dict, preperation.Vec
+	rp.TechniquesDict, rp.TechniquesVec = techniques.Dict, techniques.Vec
 
 	//Merge PrepTime
 	rp.PrepTime *= time.Duration(len(rp.RecipeIDs))
@@ -279,4 +308,5 @@ func (rp *RecipeGroupSchema) Merge(rp2 *RecipeGroupSchema) {
 
 	//Merge RecipeIDs
 	rp.RecipeIDs = append(rp.RecipeIDs, rp2.RecipeIDs...)
+	return nil
 }
@@ -0,0 +1,417 @@
+package user
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/recipe"
+)
+
+type GroupRepository interface {
+	GetByUser(userID string) ([]RecipeGroupSchema, *error_handler.APIError)
+	GetByID(userID string, id string) (*RecipeGroupSchema, *error_handler.APIError)
+	Create(userID string, group *RecipeGroupSchema) *error_handler.APIError
+	Rename(userID string, id string, version int64, name string) *error_handler.APIError
+	Split(userID string, group *RecipeGroupSchema, split *RecipeGroupSchema) *error_handler.APIError
+	Delete(userID string, id string, version int64) *error_handler.APIError
+	AddRecipe(userID string, r *recipe.RecipeSchema, cfg GroupingConfig) *error_handler.APIError
+}
+
+type GroupRepo struct {
+	DB *sqlx.DB
+}
+
+func NewGroupRepo(db *sqlx.DB) *GroupRepo {
+	return &GroupRepo{DB: db}
+}
+
+type groupRow struct {
+	ID              string          `db:"id"`
+	CreatedAt       time.Time       `db:"created_at"`
+	UserID          string          `db:"user_id"`
+	Name            string          `db:"name"`
+	Version         int64           `db:"version"`
+	IngredientDict  []byte          `db:"ingredient_dict"`
+	IngredientVec   pq.Float64Array `db:"ingredient_vec"`
+	PreperationDict []byte          `db:"preperation_dict"`
+	PreperationVec  pq.Float64Array `db:"preperation_vec"`
+	CuisineDict     []byte          `db:"cuisine_dict"`
+	CuisineVec      pq.Float64Array `db:"cuisine_vec"`
+	DietDict        []byte          `db:"diet_dict"`
+	DietVec         pq.Float64Array `db:"diet_vec"`
+	TechniquesDict  []byte          `db:"techniques_dict"`
+	TechniquesVec   pq.Float64Array `db:"techniques_vec"`
+	PrepTime        int64           `db:"prep_time"`
+	CookingTime     int64           `db:"cooking_time"`
+}
+
+func newGroupRow(userID string, g *RecipeGroupSchema) (*groupRow, error) {
+	row := &groupRow{
+		ID:             g.ID,
+		UserID:         userID,
+		Name:           g.Name,
+		Version:        g.Version,
+		IngredientVec:  g.IngredientVec,
+		PreperationVec: g.PreperationVec,
+		CuisineVec:     g.CuisineVec,
+		DietVec:        g.DietVec,
+		TechniquesVec:  g.TechniquesVec,
+		PrepTime:       int64(g.PrepTime),
+		CookingTime:    int64(g.CookingTime),
+	}
+	dicts := []struct {
+		dict map[string]int
+		dst  *[]byte
+	}{
+		{g.IngredientDict, &row.IngredientDict},
+		{g.PreperationDict, &row.PreperationDict},
+		{g.CuisineDict, &row.CuisineDict},
+		{g.DietDict, &row.DietDict},
+		{g.TechniquesDict, &row.TechniquesDict},
+	}
+	for _, d := range dicts {
+		if d.dict == nil {
+			continue
+		}
+		v, err := json.Marshal(d.dict)
+		if err != nil {
+			return nil, err
+		}
+		*d.dst = v
+	}
+	return row, nil
+}
+
+func (row *groupRow) group() (RecipeGroupSchema, error) {
+	g := RecipeGroupSchema{
+		ID:             row.ID,
+		Name:           row.Name,
+		Version:        row.Version,
+		IngredientVec:  row.IngredientVec,
+		PreperationVec: row.PreperationVec,
+		CuisineVec:     row.CuisineVec,
+		DietVec:        row.DietVec,
+		TechniquesVec:  row.TechniquesVec,
+		PrepTime:       time.Duration(row.PrepTime),
+		CookingTime:    time.Duration(row.CookingTime),
+	}
+	dicts := []struct {
+		src []byte
+		dst *map[string]int
+	}{
+		{row.IngredientDict, &g.IngredientDict},
+		{row.PreperationDict, &g.PreperationDict},
+		{row.CuisineDict, &g.CuisineDict},
+		{row.DietDict, &g.DietDict},
+		{row.TechniquesDict, &g.TechniquesDict},
+	}
+	for _, d := range dicts {
+		if len(d.src) == 0 {
+			continue
+		}
+		err := json.Unmarshal(d.src, d.dst)
+		if err != nil {
+			return g, err
+		}
+	}
+	return g, nil
+}
+
+func (gr *GroupRepo) load(db sqlx.Queryer, query string, args ...interface{}) ([]RecipeGroupSchema, *error_handler.APIError) {
+	rows := []groupRow{}
+	err := sqlx.Select(db, &rows, query, args...)
+	if err != nil {
+		return nil, error_handler.New("Error fetching recipe groups", http.StatusInternalServerError, err)
+	}
+
+	groups := make([]RecipeGroupSchema, len(rows))
+	groupMap := make(map[string]*RecipeGroupSchema, len(rows))
+	ids := make([]string, len(rows))
+	for i := range rows {
+		groups[i], err = rows[i].group()
+		if err != nil {
+			return nil, error_handler.New("Error unmarshaling group "+rows[i].ID, http.StatusInternalServerError, err)
+		}
+		groupMap[rows[i].ID] = &groups[i]
+		ids[i] = rows[i].ID
+	}
+	if len(ids) == 0 {
+		return groups, nil
+	}
+
+	var members []struct {
+		GroupID  string `db:"group_id"`
+		RecipeID string `db:"recipe_id"`
+	}
+	query, args, err = sqlx.In(`SELECT group_id, recipe_id FROM recipe_group_recipe WHERE group_id IN (?) ORDER BY group_id, position`, ids)
+	if err != nil {
+		return nil, error_handler.New("error building members query: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	err = sqlx.Select(db, &members, sqlx.Rebind(sqlx.DOLLAR, query), args...)
+	if err != nil {
+		return nil, error_handler.New("Error fetching recipe group members", http.StatusInternalServerError, err)
+	}
+	for _, m := range members {
+		g := groupMap[m.GroupID]
+		g.RecipeIDs = append(g.RecipeIDs, m.RecipeID)
+	}
+
+	return groups, nil
+}
+
+func (gr *GroupRepo) GetByUser(userID string) ([]RecipeGroupSchema, *error_handler.APIError) {
+	return gr.load(gr.DB, `SELECT * FROM recipe_group WHERE user_id = $1 ORDER BY created_at`, userID)
+}
+
+func (gr *GroupRepo) GetByID(userID string, id string) (*RecipeGroupSchema, *error_handler.APIError) {
+	groups, err := gr.load(gr.DB, `SELECT * FROM recipe_group WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, error_handler.New("Recipe group doesn't exist", http.StatusNotFound, sql.ErrNoRows)
+	}
+	return &groups[0], nil
+}
+
+func (gr *GroupRepo) insert(tx *sqlx.Tx, userID string, g *RecipeGroupSchema) *error_handler.APIError {
+	row, err := newGroupRow(userID, g)
+	if err != nil {
+		return error_handler.New("Failed to marshal recipe group", http.StatusInternalServerError, err)
+	}
+
+	query := `INSERT INTO recipe_group (user_id, name, version, ingredient_dict, ingredient_vec, preperation_dict, preperation_vec,
+				cuisine_dict, cuisine_vec, diet_dict, diet_vec, techniques_dict, techniques_vec, prep_time, cooking_time)
+			VALUES (:user_id, :name, 0, :ingredient_dict, :ingredient_vec, :preperation_dict, :preperation_vec,
+				:cuisine_dict, :cuisine_vec, :diet_dict, :diet_vec, :techniques_dict, :techniques_vec, :prep_time, :cooking_time)
+			RETURNING id`
+	stmt, err := tx.PrepareNamed(query)
+	if err != nil {
+		return error_handler.New("Query error: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	err = stmt.Get(&g.ID, row)
+	stmt.Close()
+	if err != nil {
+		return error_handler.New("Error inserting recipe group: "+err.Error(), http.StatusInternalServerError, err)
+	}
+	g.Version = 0
+
+	return gr.setRecipes(tx, g)
+}
+
+// update writes the group if it still has the version it was read with and increments the version
+func (gr *GroupRepo) update(tx *sqlx.Tx, userID string, g *RecipeGroupSchema) *error_handler.APIError {
+	row, err := newGroupRow(userID, g)
+	if err != nil {
+		return error_handler.New("Failed to marshal recipe group", http.StatusInternalServerError, err)
+	}
+
+	result, err := tx.NamedExec(`UPDATE recipe_group SET name = :name, version = version + 1,
+				ingredient_dict = :ingredient_dict, ingredient_vec = :ingredient_vec,
+				preperation_dict = :preperation_dict, preperation_vec = :preperation_vec,
+				cuisine_dict = :cuisine_dict, cuisine_vec = :cuisine_vec,
+				diet_dict = :diet_dict, diet_vec = :diet_vec,
+				techniques_dict = :techniques_dict, techniques_vec = :techniques_vec,
+				prep_time = :prep_time, cooking_time = :cooking_time
+			WHERE id = :id AND user_id = :user_id AND version = :version`, row)
+	if err != nil {
+		return error_handler.New("Error updating recipe group", http.StatusInternalServerError, err)
+	}
+	apiErr := checkVersion(result)
+	if apiErr != nil {
+		return apiErr
+	}
+	g.Version++
+
+	return gr.setRecipes(tx, g)
+}
+
+func checkVersion(result sql.Result) *error_handler.APIError {
+	if rows, _ := result.RowsAffected(); rows <= 0 {
+		return error_handler.New("Recipe group doesn't exist or was changed in the meantime", http.StatusConflict, errors.New("version conflict"))
+	}
+	return nil
+}
+
+func (gr *GroupRepo) setRecipes(tx *sqlx.Tx, g *RecipeGroupSchema) *error_handler.APIError {
+	_, err := tx.Exec(`DELETE FROM recipe_group_recipe WHERE group_id = $1`, g.ID)
+	if err != nil {
+		return error_handler.New("Error updating recipe group members", http.StatusInternalServerError, err)
+	}
+	for i, id := range g.RecipeIDs {
+		_, err = tx.Exec(`INSERT INTO recipe_group_recipe (group_id, recipe_id, position) VALUES ($1, $2, $3)`, g.ID, id, i)
+		if err != nil {
+			return error_handler.New("Error inserting recipe group member "+id, http.StatusInternalServerError, err)
+		}
+	}
+	return nil
+}
+
+func (gr *GroupRepo) Create(userID string, group *RecipeGroupSchema) *error_handler.APIError {
+	tx := gr.DB.MustBegin()
+	err := gr.insert(tx, userID, group)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		return error_handler.New("Error creating recipe group", http.StatusInternalServerError, commitErr)
+	}
+	return nil
+}
+
+func (gr *GroupRepo) Rename(userID string, id string, version int64, name string) *error_handler.APIError {
+	result, err := gr.DB.Exec(`UPDATE recipe_group SET name = $1, version = version + 1 WHERE id = $2 AND user_id = $3 AND version = $4`,
+		name, id, userID, version)
+	if err != nil {
+		return error_handler.New("Error renaming recipe group", http.StatusInternalServerError, err)
+	}
+	return checkVersion(result)
+}
+
+// Split saves the shrunk group and creates the split off one in one transaction
+func (gr *GroupRepo) Split(userID string, group *RecipeGroupSchema, split *RecipeGroupSchema) *error_handler.APIError {
+	tx := gr.DB.MustBegin()
+	err := gr.update(tx, userID, group)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	err = gr.insert(tx, userID, split)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	commitErr := tx.Commit()
+	if commitErr != nil {
+		return error_handler.New("Error splitting recipe group", http.StatusInternalServerError, commitErr)
+	}
+	return nil
+}
+
+func (gr *GroupRepo) Delete(userID string, id string, version int64) *error_handler.APIError {
+	result, err := gr.DB.Exec(`DELETE FROM recipe_group WHERE id = $1 AND user_id = $2 AND version = $3`, id, userID, version)
+	if err != nil {
+		return error_handler.New("Error deleting recipe group", http.StatusInternalServerError, err)
+	}
+	return checkVersion(result)
+}
+
+// AddRecipe adds a selected recipe to the users groups. The user row is locked for the
+// duration so concurrent selects of the same user are applied one after another.
+func (gr *GroupRepo) AddRecipe(userID string, r *recipe.RecipeSchema, cfg GroupingConfig) *error_handler.APIError {
+	tx := gr.DB.MustBegin()
+	_, err := tx.Exec(`SELECT id FROM "user" WHERE id = $1 FOR UPDATE`, userID)
+	if err != nil {
+		tx.Rollback()
+		return error_handler.New("Error locking user", http.StatusInternalServerError, err)
+	}
+
+	groups, apiErr := gr.load(tx, `SELECT * FROM recipe_group WHERE user_id = $1 ORDER BY created_at`, userID)
+	if apiErr != nil {
+		tx.Rollback()
+		return apiErr
+	}
+
+	// Assign only changes the group the recipe is added to, the others keep their version
+	before := make(map[string]int, len(groups))
+	for _, g := range groups {
+		before[g.ID] = len(g.RecipeIDs)
+	}
+
+	groups = cfg.Assign(groups, r)
+	for i := range groups {
+		size, existed := before[groups[i].ID]
+		delete(before, groups[i].ID)
+		switch {
+		case groups[i].ID == "":
+			apiErr = gr.insert(tx, userID, &groups[i])
+		case existed && size == len(groups[i].RecipeIDs):
+			continue
+		default:
+			apiErr = gr.update(tx, userID, &groups[i])
+		}
+		if apiErr != nil {
+			tx.Rollback()
+			return apiErr
+		}
+	}
+
+	// Whatever is left was merged into another group
+	for id := range before {
+		_, err = tx.Exec(`DELETE FROM recipe_group WHERE id = $1`, id)
+		if err != nil {
+			tx.Rollback()
+			return error_handler.New("Error deleting merged recipe group", http.StatusInternalServerError, err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return error_handler.New("Error updating recipe groups", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// MigrateJSONGroups moves the groups still stored in the legacy "user".groups column into recipe_group.
+// Migrated users have their column cleared so running it again is a no-op.
+func (gr *GroupRepo) MigrateJSONGroups() *error_handler.APIError {
+	var users []struct {
+		ID     string `db:"id"`
+		Groups []byte `db:"groups"`
+	}
+	err := gr.DB.Select(&users, `SELECT id, groups FROM "user" WHERE groups IS NOT NULL`)
+	if err != nil {
+		return error_handler.New("Error fetching legacy recipe groups", http.StatusInternalServerError, err)
+	}
+
+	for _, u := range users {
+		var groups []RecipeGroupSchema
+		err = json.Unmarshal(u.Groups, &groups)
+		if err != nil {
+			return error_handler.New("Error unmarshaling groups of user "+u.ID, http.StatusInternalServerError, err)
+		}
+
+		tx := gr.DB.MustBegin()
+		for i := range groups {
+			groups[i].RecipeIDs = existingRecipes(tx, groups[i].RecipeIDs)
+			apiErr := gr.insert(tx, u.ID, &groups[i])
+			if apiErr != nil {
+				tx.Rollback()
+				return apiErr
+			}
+		}
+		_, err = tx.Exec(`UPDATE "user" SET groups = NULL WHERE id = $1`, u.ID)
+		if err != nil {
+			tx.Rollback()
+			return error_handler.New("Error clearing legacy recipe groups", http.StatusInternalServerError, err)
+		}
+		err = tx.Commit()
+		if err != nil {
+			return error_handler.New("Error migrating recipe groups", http.StatusInternalServerError, err)
+		}
+	}
+	return nil
+}
+
+// existingRecipes drops ids of recipes that were deleted since they were grouped
+func existingRecipes(tx *sqlx.Tx, ids []string) []string {
+	var existing []string
+	for _, id := range ids {
+		var found bool
+		err := tx.Get(&found, `SELECT EXISTS (SELECT 1 FROM recipes WHERE id::text = $1)`, id)
+		if err == nil && found {
+			existing = append(existing, id)
+		}
+	}
+	return existing
+}
@@ -0,0 +1,146 @@
+package user
+
+import (
+	"sort"
+
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/tools"
+)
+
+const (
+	groupScoreWeight  = 0.7
+	ratingScoreWeight = 0.3
+	defaultRating     = 1000.0
+)
+
+type Recommendation struct {
+	Recipe      recipe.RecipeSchema `json:"recipe"`
+	Score       float64             `json:"score"`
+	GroupScore  float64             `json:"group_score"`
+	RatingScore float64             `json:"rating_score"`
+	Explanation Explanation         `json:"explanation"`
+}
+
+// Explanation tells why a recipe was recommended
+type Explanation struct {
+	Group   *GroupExplanation  `json:"group,omitempty"`
+	Context ContextExplanation `json:"context"`
+}
+
+type GroupExplanation struct {
+	ID         string        `json:"id,omitempty"`
+	Recipes    []GroupRecipe `json:"recipes"`
+	Similarity Similarity    `json:"similarity"`
+}
+
+type GroupRecipe struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// ContextExplanation lists the rating buckets used for the contextual rating.
+// Strongest is the bucket rated highest above the default rating, empty if none is.
+type ContextExplanation struct {
+	Day       string                `json:"day,omitempty"`
+	Season    string                `json:"season,omitempty"`
+	Temp      string                `json:"temp,omitempty"`
+	Buckets   []recipe.RatingBucket `json:"buckets"`
+	Strongest string                `json:"strongest,omitempty"`
+}
+
+type Recommendations struct {
+	Page    int              `json:"page"`
+	Limit   int              `json:"limit"`
+	Total   int              `json:"total"`
+	Recipes []Recommendation `json:"recipes"`
+}
+
+// ratingScore maps a contextual rating onto 0..1, a recipe with the default rating scores .5
+func ratingScore(rating float64) float64 {
+	if rating <= 0 {
+		return 0
+	}
+	return rating / (rating + defaultRating)
+}
+
+func (user *UserModel) selectedRecipes() map[string]bool {
+	selected := make(map[string]bool)
+	for _, g := range user.RecipeGroups {
+		for _, id := range g.RecipeIDs {
+			selected[id] = true
+		}
+	}
+	return selected
+}
+
+// score rates a recipe against the best matching group of the user and blends it with the contextual rating.
+// Users without groups are ranked by the contextual rating only. names maps recipe ids to their names.
+func (user *UserModel) score(r *recipe.RecipeSchema, w SimilarityWeights, data tools.CurrentData, names map[string]string) Recommendation {
+	rec := Recommendation{
+		Recipe:      *r,
+		RatingScore: ratingScore(r.Rating.Contextual(data)),
+		Explanation: Explanation{Context: explainContext(r, data)},
+	}
+
+	for i := range user.RecipeGroups {
+		sim := user.RecipeGroups[i].CompareDetailed(r, w)
+		if rec.Explanation.Group == nil || sim.Total > rec.GroupScore {
+			rec.GroupScore = sim.Total
+			rec.Explanation.Group = explainGroup(&user.RecipeGroups[i], sim, names)
+		}
+	}
+
+	if len(user.RecipeGroups) < 1 {
+		rec.Score = rec.RatingScore
+	} else {
+		rec.Score = rec.GroupScore*groupScoreWeight + rec.RatingScore*ratingScoreWeight
+	}
+	return rec
+}
+
+func explainGroup(g *RecipeGroupSchema, sim Similarity, names map[string]string) *GroupExplanation {
+	e := &GroupExplanation{
+		ID:         g.ID,
+		Recipes:    make([]GroupRecipe, len(g.RecipeIDs)),
+		Similarity: sim,
+	}
+	for i, id := range g.RecipeIDs {
+		e.Recipes[i] = GroupRecipe{ID: id, Name: names[id]}
+	}
+	return e
+}
+
+func explainContext(r *recipe.RecipeSchema, data tools.CurrentData) ContextExplanation {
+	e := ContextExplanation{
+		Day:     data.Day,
+		Season:  data.Season,
+		Temp:    data.Temp,
+		Buckets: r.Rating.ContextBuckets(data),
+	}
+	strongest := defaultRating
+	for _, b := range e.Buckets {
+		if b.Value > strongest {
+			strongest = b.Value
+			e.Strongest = b.Name
+		}
+	}
+	return e
+}
+
+func rankRecommendations(recs []Recommendation) {
+	sort.SliceStable(recs, func(i, j int) bool {
+		return recs[i].Score > recs[j].Score
+	})
+}
+
+func paginateRecommendations(recs []Recommendation, page int, limit int) []Recommendation {
+	start := (page - 1) * limit
+	if start >= len(recs) {
+		return []Recommendation{}
+	}
+	end := start + limit
+	if end > len(recs) {
+		end = len(recs)
+	}
+	return recs[start:end]
+}
@@ -1,9 +1,8 @@
 package user
 
 import (
-	"encoding/json"
+	"database/sql"
 	"errors"
-	"fmt"
 	"net/http"
 	"time"
 
@@ -18,26 +17,62 @@ type UserModel struct {
 	CreatedAt    time.Time `db:"created_at"`
 	Email        string    `db:"email" json:"email"`
 	Password     string    `db:"password" json:"-"`
+	Role         string    `db:"role" json:"role"`
 	LastLogin    time.Time `database:"last_login"`
 	Cookie       string    `database:"cookie"`
 	IP           string    `database:"ip"`
+	// SessionID is the session of the access token the user was verified with
+	SessionID    string    `db:"-" json:"-"`
 	RecipeGroups []RecipeGroupSchema
-	Groups       []byte       `database:"groups"`
 	Settings     UserSettings `database:"settings"`
 }
+// UserSettings are loaded by LoadPreferences
 type UserSettings struct {
-	Allergies []*string `database:"allergies"`
-	Diet      recipe.DietSchema      `gorm:"polymorphic:Owner"`
+	// Allergies are the ids of the allergens the user avoids, they are always applied
+	Allergies []string `json:"allergies"`
+	// Diets, disliked ingredients and the max prep time are defaults that can be ignored
+	Diets               []recipe.DietSchema  `json:"diets"`
+	DislikedIngredients []DislikedIngredient `json:"disliked_ingredients"`
+	MaxPrepTime         *string              `json:"max_prep_time"`
 }
 
-func (user *UserModel) GetByCookie(db *sqlx.DB) *error_handler.APIError {
-	err := db.Get(user, `SELECT id, created_at, ip, groups FROM "user" WHERE cookie = $1`, user.Cookie)
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// LoadRole reads the role of the user from the database, it isn't part of the token so changes apply at once
+func (user *UserModel) LoadRole(db *sqlx.DB) *error_handler.APIError {
+	err := db.Get(&user.Role, `SELECT role FROM "user" WHERE id::text = $1`, user.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return error_handler.New("User doesn't exist", http.StatusUnauthorized, err)
+	}
+	if err != nil {
+		return error_handler.New("database error", http.StatusInternalServerError, err)
+	}
+	return nil
+}
+
+// SetRole changes the role of the user with the id
+func SetRole(db *sqlx.DB, id string, role string) *error_handler.APIError {
+	if role != RoleUser && role != RoleModerator && role != RoleAdmin {
+		return error_handler.New(`role has to be "user", "moderator" or "admin"`, http.StatusBadRequest, errors.New("invalid role"))
+	}
+	result, err := db.Exec(`UPDATE "user" SET role = $2 WHERE id::text = $1`, id, role)
 	if err != nil {
 		return error_handler.New("database error", http.StatusInternalServerError, err)
 	}
-	err = json.Unmarshal(user.Groups, &user.RecipeGroups)
+	if n, _ := result.RowsAffected(); n == 0 {
+		return error_handler.New("User doesn't exist", http.StatusNotFound, errors.New("user not found"))
+	}
+	return nil
+}
+
+func (user *UserModel) GetByCookie(db *sqlx.DB) *error_handler.APIError {
+	err := db.Get(user, `SELECT id, created_at, ip FROM "user" WHERE cookie = $1`, user.Cookie)
 	if err != nil {
-		return error_handler.New("Error unmarshaling groups", http.StatusInternalServerError, err)
+		return error_handler.New("database error", http.StatusInternalServerError, err)
 	}
 	return nil
 }
@@ -87,75 +122,46 @@ func (user *UserModel) GetFromGinContext(data any, exists bool) *error_handler.A
 	return nil
 }
 
-func (user *UserModel) AddGroup(db *sqlx.DB, r *recipe.RecipeSchema) *error_handler.APIError {
-	rp := RecipeGroupSchema{}
-	rp.Create(r)
-	user.RecipeGroups = append(user.RecipeGroups, rp)
-
-	v, err := json.Marshal(user.RecipeGroups)
+// LogSelect records the selection so the grouping can be replayed later
+func (user *UserModel) LogSelect(db *sqlx.DB, recipeID string) *error_handler.APIError {
+	_, err := db.Exec(`INSERT INTO user_select_log (user_id, recipe_id) VALUES ($1, $2)`, user.ID, recipeID)
 	if err != nil {
-		return error_handler.New("Failed to marshal recipe groups", http.StatusInternalServerError, err)
+		return error_handler.New("Error logging select", http.StatusInternalServerError, err)
 	}
-
-	db.MustExec(`UPDATE "user" SET groups = $1 WHERE id = $2`, v, user.ID)
 	return nil
 }
-func (user *UserModel) AddToGroup(db *sqlx.DB, r *recipe.RecipeSchema) *error_handler.APIError {
-	var groups []byte
-	err := db.Get(&groups, `SELECT "groups" FROM "user" WHERE id=$1`, user.ID)
-	if err != nil {
-		return error_handler.New("Error fetching recipe_groups", http.StatusInternalServerError, err)
-	}
-	err = json.Unmarshal(groups, &user.RecipeGroups)
-	if err != nil {
-		return error_handler.New("Error unmarshaling groups", http.StatusInternalServerError, err)
-	}
-	if len(user.RecipeGroups) < 1 {
-		return user.AddGroup(db, r)
-	}
 
-	group_ranking := make([]struct {
-		Group *RecipeGroupSchema
-		Sim   float64
-	}, len(user.RecipeGroups))
+// GetRecomendation ranks every recipe the user hasn't selected yet by its similarity to the users recipe groups
+// and its contextual rating and explains each ranking. The groups have to be loaded beforehand, see GroupRepository.GetByUser,
+// and so do the settings, see LoadPreferences.
+func (user *UserModel) GetRecomendation(repo recipe.RecipeRepository, cfg GroupingConfig, data tools.CurrentData, page int, limit int) (*error_handler.APIError, Recommendations) {
+	result := Recommendations{Page: page, Limit: limit, Recipes: []Recommendation{}}
 
-	for i := range user.RecipeGroups {
-		group_ranking[i].Group = &user.RecipeGroups[i]
-		group_ranking[i].Sim = user.RecipeGroups[i].Compare(r)
+	recipes, err := repo.GetAllRecipes()
+	if err != nil {
+		return err, result
 	}
 
-	group_addble := make([]struct {
-		Group *RecipeGroupSchema
-		Sim   float64
-	}, 0)
-	fmt.Printf("%+v\n", group_ranking)
-	for i := range group_ranking {
-		if group_ranking[i].Sim >= .9 {
-			group_addble = append(group_addble, group_ranking[i])
-		}
+	names := make(map[string]string, len(recipes))
+	for _, r := range recipes {
+		names[r.ID] = r.Name
 	}
-	fmt.Printf("%+v\n", group_ranking)
 
-	if len(group_addble) > 1 {
-		for i := 1; i < len(group_addble); i++ {
-			group_addble[0].Group.Merge(group_addble[i].Group)
+	selected := user.selectedRecipes()
+	recs := make([]Recommendation, 0, len(recipes))
+	for i := range recipes {
+		if selected[recipes[i].ID] {
+			continue
 		}
+		if !user.Settings.Allows(&recipes[i]) {
+			continue
+		}
+		recs = append(recs, user.score(&recipes[i], cfg.Weights, data, names))
 	}
 
-	group_addble[0].Group.Add(r)
-	user.Groups, err = json.Marshal(user.RecipeGroups)
-	if err != nil {
-		return error_handler.New("failed to marshal", http.StatusInternalServerError, err)
-	}
-	_, err = db.Exec(`UPDATE "user" SET groups = $1 WHERE id = $2`, user.Groups, user.ID)
-	if err != nil {
-		return error_handler.New("database error", http.StatusInternalServerError, err)
-	}
-
-	return nil
-}
+	rankRecommendations(recs)
+	result.Total = len(recs)
+	result.Recipes = paginateRecommendations(recs, page, limit)
 
-// Using db to extend an existing db like a search to show recipes similar to your intrests
-func (user *UserModel) GetRecomendation(db *sqlx.DB) (*error_handler.APIError, []recipe.RecipeSchema) {
-	return nil, nil
+	return nil, result
 }
@@ -1,11 +1,11 @@
 package test
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -16,11 +16,11 @@ import (
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/jmoiron/sqlx"
 	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/policy"
 	"github.com/madswillem/recipeApp/internal/recipe"
 	"github.com/madswillem/recipeApp/internal/server"
 	"github.com/madswillem/recipeApp/internal/tools"
 	"github.com/madswillem/recipeApp/internal/user"
-	"github.com/testcontainers/testcontainers-go/modules/postgres"
 )
 
 func assertRecipesEqual(t *testing.T, expected recipe.RecipeSchema, actual recipe.RecipeSchema) {
@@ -82,8 +82,10 @@ func assertRecipesEqual(t *testing.T, expected recipe.RecipeSchema, actual recip
 	if actual.CookingTime != expected.CookingTime {
 		errors = append(errors, fmt.Sprintf("Expected cooking_time %s but got %s", expected.CookingTime, actual.CookingTime))
 	}
-	if actual.NutritionalValue != expected.NutritionalValue {
-		errors = append(errors, fmt.Sprintf("Expected NutritionalValue %v but got %v", expected.NutritionalValue, actual.NutritionalValue))
+	nutritionDiff := cmp.Diff(expected.NutritionalValue, actual.NutritionalValue,
+		cmpopts.IgnoreFields(recipe.NutritionalValue{}, "ID", "CreatedAt", "RecipeID"), cmpopts.EquateApprox(0, 0.001))
+	if nutritionDiff != "" {
+		errors = append(errors, fmt.Sprintf("NutritionalValue differs: %s", nutritionDiff))
 	}
 	if actual.Rating.Overall != expected.Rating.Overall {
 		errors = append(errors, fmt.Sprintf("Expected recipe rating %v but got %v", expected.Rating, actual.Rating))
@@ -95,30 +97,8 @@ func assertRecipesEqual(t *testing.T, expected recipe.RecipeSchema, actual recip
 }
 
 func TestServer_AddRecipe(t *testing.T) {
-	ctx := context.Background()
-
-	container, err := postgres.Run(
-		ctx,
-		"docker.io/postgres:16-alpine",
-		postgres.WithDatabase("test"),
-		postgres.WithUsername("mads"),
-		postgres.WithPassword("1234"),
-		postgres.BasicWaitStrategies(),
-		postgres.WithInitScripts("./testdata/innit-db.sql"),
-		postgres.WithSQLDriver("pgx"),
-	)
-	t.Cleanup(func() {
-		if err := container.Terminate(ctx); err != nil {
-			t.Fatalf("failed to terminate container: %s", err)
-		}
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
-	err = container.Snapshot(ctx, postgres.WithSnapshotName("test-snapshot"))
-	if err != nil {
-		t.Fatal(err)
-	}
+	container, ctxp := InitTestContainer(t)
+	ctx := *ctxp
 
 	dbURL, err := container.ConnectionString(ctx, "sslmode=disable")
 	if err != nil {
@@ -207,30 +187,8 @@ func TestServer_AddRecipe(t *testing.T) {
 }
 
 func TestServer_GetById(t *testing.T) {
-	ctx := context.Background()
-
-	container, err := postgres.Run(
-		ctx,
-		"docker.io/postgres:16-alpine",
-		postgres.WithDatabase("test"),
-		postgres.WithUsername("mads"),
-		postgres.WithPassword("1234"),
-		postgres.BasicWaitStrategies(),
-		postgres.WithInitScripts("./testdata/innit-db.sql"),
-		postgres.WithSQLDriver("pgx"),
-	)
-	t.Cleanup(func() {
-		if err := container.Terminate(ctx); err != nil {
-			t.Fatalf("failed to terminate container: %s", err)
-		}
-	})
-	if err != nil {
-		t.Fatal(err)
-	}
-	err = container.Snapshot(ctx, postgres.WithSnapshotName("test-snapshot"))
-	if err != nil {
-		t.Fatal(err)
-	}
+	container, ctxp := InitTestContainer(t)
+	ctx := *ctxp
 
 	dbURL, err := container.ConnectionString(ctx, "sslmode=disable")
 	if err != nil {
@@ -336,29 +294,29 @@ func TestServer_Delete(t *testing.T) {
 			name:           "test sql injection",
 			id:             `c5ef5707-1577-4f8c-99ef-0f492e82b895"; SELECT * FROM recipes;`,
 			user:           user.UserModel{ID: "f85a98f8-2572-420a-9ae5-2c997ad96b6d"},
-			expectedStatus: http.StatusBadRequest,
-			expectedBody:   `{"errMessage":"Value \"c5ef5707-1577-4f8c-99ef-0f492e82b895\"; SELECT * FROM recipes;\" is not an ID","errors":"pq: invalid input syntax for type uuid: \"c5ef5707-1577-4f8c-99ef-0f492e82b895\"; SELECT * FROM recipes;\""}`,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"errMessage":"Recipe doesn't exist","errors":"sql: no rows in result set"}`,
 		},
 		{
 			name:           "delete recipe with invalid UUID format",
 			id:             "invalid-uuid-format",
 			user:           user.UserModel{ID: "f85a98f8-2572-420a-9ae5-2c997ad96b6d"},
-			expectedStatus: http.StatusBadRequest,
-			expectedBody:   `{"errMessage":"Value \"invalid-uuid-format\" is not an ID","errors":"pq: invalid input syntax for type uuid: \"invalid-uuid-format\""}`,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   `{"errMessage":"Recipe doesn't exist","errors":"sql: no rows in result set"}`,
 		},
 		{
 			name:           "delete recipe with empty ID",
 			id:             "",
 			user:           user.UserModel{ID: "f85a98f8-2572-420a-9ae5-2c997ad96b6d"},
-			expectedStatus: http.StatusBadRequest,
-			expectedBody:   `{"errMessage":"Value \"\" is not an ID","errors":"pq: invalid input syntax for type uuid: \"\""}`,
+			expectedStatus: http.StatusNotFound,
+			expectedBody:   "404 page not found",
 		},
 		{
-			name:           "delete recipe with unauthorized user",
+			name:           "delete recipe with unknown user",
 			id:             "c4ef5707-1577-4f8c-99ef-0f492e82b895",
 			user:           user.UserModel{ID: "wrong-user-id"},
 			expectedStatus: http.StatusUnauthorized,
-			expectedBody:   `{"errMessage":"User is not the owner of the recipe","errors":"user is not the owner of the recipe"}`,
+			expectedBody:   `{"errMessage":"User doesn't exist","errors":"sql: no rows in result set"}`,
 		},
 	}
 
@@ -366,13 +324,11 @@ func TestServer_Delete(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			w := httptest.NewRecorder()
 			gin.SetMode(gin.TestMode)
-			c, _ := gin.CreateTestContext(w)
-
-			c.Request = httptest.NewRequest(http.MethodDelete, "/delete", nil)
-			c.Params = gin.Params{gin.Param{Key: "id", Value: tt.id}}
-			c.Set("user", tt.user)
+			r := gin.New()
+			setUser := func(c *gin.Context) { c.Set("user", tt.user) }
+			r.DELETE("/delete/:id", setUser, s.Authorize(policy.Recipe, policy.Delete), s.DeleteRecipe)
 
-			s.DeleteRecipe(c)
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/delete/"+url.PathEscape(tt.id), nil))
 
 			if w.Code != tt.expectedStatus {
 				t.Errorf("Expected status code %d but got %d. \n Body: %s", tt.expectedStatus, w.Code, w.Body.String())
@@ -395,3 +351,62 @@ func TestServer_Delete(t *testing.T) {
 		})
 	}
 }
+
+func TestServer_GetAllPaginated(t *testing.T) {
+	container, ctx := InitTestContainer(t)
+	URL, err := container.ConnectionString(*ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := server.Server{NewDB: database.ConnectToDB(&sqlx.Conn{}, URL)}
+	s.RecipeRepo = recipe.NewRecipeRepo(s.NewDB)
+
+	get := func(query string) (*httptest.ResponseRecorder, recipe.RecipePage) {
+		w := httptest.NewRecorder()
+		gin.SetMode(gin.TestMode)
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/get?"+query, nil)
+		s.GetAll(c)
+
+		var page recipe.RecipePage
+		json.Unmarshal(w.Body.Bytes(), &page)
+		return w, page
+	}
+
+	for _, sort := range []string{"newest", "popular", "views", "selects", "rating", "contextual", "prep_time"} {
+		t.Run(sort, func(t *testing.T) {
+			w, first := get("limit=1&sort=" + sort)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status code %d but got %d. \n Body: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+			if first.Total != 2 || len(first.Recipes) != 1 || first.NextCursor == "" {
+				t.Fatalf("unexpected first page: %s", w.Body.String())
+			}
+
+			w, second := get("limit=1&sort=" + sort + "&cursor=" + first.NextCursor)
+			if w.Code != http.StatusOK {
+				t.Fatalf("Expected status code %d but got %d. \n Body: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+			if len(second.Recipes) != 1 || second.NextCursor != "" || second.Recipes[0].ID == first.Recipes[0].ID {
+				t.Errorf("unexpected second page: %s", w.Body.String())
+			}
+		})
+	}
+
+	t.Run("newest first", func(t *testing.T) {
+		_, page := get("sort=newest")
+		if len(page.Recipes) != 2 || page.Recipes[0].ID != "c4ef5707-1577-4f8c-99ef-0f492e82b895" {
+			t.Errorf("expected the newest recipe first but got %+v", page.Recipes)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, first := get("limit=1&sort=newest")
+		for _, query := range []string{"sort=tastiest", "limit=1000", "cursor=nope", "sort=views&cursor=" + first.NextCursor} {
+			w, _ := get(query)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s: expected status code %d but got %d", query, http.StatusBadRequest, w.Code)
+			}
+		}
+	})
+}
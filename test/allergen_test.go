@@ -0,0 +1,151 @@
+package test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/server"
+	"github.com/madswillem/recipeApp/internal/user"
+	views "github.com/madswillem/recipeApp/web/view"
+)
+
+func TestRecipePageAllergens(t *testing.T) {
+	r := &recipe.RecipeSchema{
+		ID:        "carbonara",
+		Name:      "Spaghetti Carbonara",
+		Allergens: []recipe.Allergen{{ID: "eggs", Name: "Eggs"}, {ID: "milk", Name: "Milk", Description: "Milk including lactose"}},
+	}
+	var buf bytes.Buffer
+	err := views.RecipePage(r, "").Render(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`<span class="allergen-badge" title="">Eggs</span>`, `title="Milk including lactose">Milk</span>`} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("Expected the page to contain %s", expected)
+		}
+	}
+
+	buf.Reset()
+	r.Allergens = nil
+	err = views.RecipePage(r, "").Render(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), `class="allergen-badges"`) {
+		t.Error("Expected no badges for a recipe without allergens")
+	}
+}
+
+func TestAllergens(t *testing.T) {
+	container, ctxp := InitTestContainer(t)
+	dbURL, err := container.ConnectionString(*ctxp, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := database.ConnectToDB(&sqlx.Conn{}, dbURL)
+	repo := recipe.NewRecipeRepo(db)
+	s := server.Server{NewDB: db, RecipeRepo: repo}
+	const carbonara = "aa85daf1-dbc5-462d-a6fe-3fbb358b08dd"
+
+	t.Run("derived from ingredients and categories", func(t *testing.T) {
+		r, apiErr := repo.GetRecipeByID(carbonara)
+		if apiErr != nil {
+			t.Fatal(apiErr.Message)
+		}
+		var ids []string
+		for _, a := range r.Allergens {
+			ids = append(ids, a.ID)
+		}
+		// Gluten comes from the category of the spaghetti
+		if strings.Join(ids, ",") != "eggs,gluten,milk" {
+			t.Errorf("Expected eggs, gluten and milk but got %v", ids)
+		}
+	})
+
+	t.Run("mixed categories flag every allergen", func(t *testing.T) {
+		for category, expected := range map[string]string{
+			"Finfish and Shellfish Products": "crustaceans,fish,molluscs",
+			"Nut and Seed Products":          "nuts,sesame",
+			"Dairy and Egg Products":         "eggs,milk",
+		} {
+			var ids []string
+			err := db.Select(&ids, `SELECT allergen_id FROM category_allergen WHERE category = $1 ORDER BY allergen_id`, category)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(ids, ",") != expected {
+				t.Errorf("Expected %s to contain %s but got %v", category, expected, ids)
+			}
+		}
+	})
+
+	t.Run("filter excludes allergens", func(t *testing.T) {
+		page, apiErr := repo.GetByFilter(&recipe.Filter{ExcludeAllergens: []string{"eggs"}})
+		if apiErr != nil {
+			t.Fatal(apiErr.Message)
+		}
+		if page.Total != 0 {
+			t.Errorf("Expected both carbonaras to contain eggs but got %d recipes", page.Total)
+		}
+		page, apiErr = repo.GetByFilter(&recipe.Filter{ExcludeAllergens: []string{"peanuts"}})
+		if apiErr != nil {
+			t.Fatal(apiErr.Message)
+		}
+		if page.Total != 2 {
+			t.Errorf("Expected 2 recipes without peanuts but got %d", page.Total)
+		}
+	})
+
+	t.Run("user allergies", func(t *testing.T) {
+		u := user.UserModel{ID: testUserID}
+		apiErr := u.SetAllergies(db, []string{"milk", "chocolate"})
+		if apiErr == nil || apiErr.Code != http.StatusBadRequest {
+			t.Fatalf("Expected an unknown allergen to be a bad request but got %v", apiErr)
+		}
+		apiErr = u.SetAllergies(db, []string{"milk"})
+		if apiErr != nil {
+			t.Fatal(apiErr.Message)
+		}
+
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.GET("/popular", func(c *gin.Context) { c.Set("user", u) }, s.GetPopular)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/popular", nil))
+		var page recipe.RecipePage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusOK || page.Total != 0 {
+			t.Errorf("Expected no popular recipes with milk but got %d %+v", w.Code, page)
+		}
+	})
+
+	t.Run("allergen categories", func(t *testing.T) {
+		apiErr := recipe.SetAllergenCategories("gluten", []string{"Baked Products"}, db)
+		if apiErr != nil {
+			t.Fatal(apiErr.Message)
+		}
+		r, apiErr := repo.GetRecipeByID(carbonara)
+		if apiErr != nil {
+			t.Fatal(apiErr.Message)
+		}
+		if len(r.ContainsAny([]string{"gluten"})) != 0 {
+			t.Error("Expected the spaghetti to no longer contain gluten")
+		}
+		apiErr = recipe.SetAllergenCategories("chocolate", nil, db)
+		if apiErr == nil || apiErr.Code != http.StatusNotFound {
+			t.Errorf("Expected an unknown allergen to be not found but got %v", apiErr)
+		}
+	})
+}
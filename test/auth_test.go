@@ -0,0 +1,277 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/auth"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/mail"
+	"github.com/madswillem/recipeApp/internal/server"
+)
+
+type tokenResponse struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	SessionID    string `json:"session_id"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+func TestVerifyRejectsTokens(t *testing.T) {
+	key := []byte("test-key")
+	a := auth.NewAuth(key)
+	sign := func(method jwt.SigningMethod, key any, claims jwt.MapClaims) string {
+		s, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	exp := time.Now().Add(time.Hour).Unix()
+
+	tokens := map[string]string{
+		"expired":         sign(jwt.SigningMethodHS256, key, jwt.MapClaims{"user_id": testUserID, "sid": "s", "exp": time.Now().Add(-time.Minute).Unix()}),
+		"without session": sign(jwt.SigningMethodHS256, key, jwt.MapClaims{"user_id": testUserID, "exp": exp}),
+		"other key":       sign(jwt.SigningMethodHS256, []byte("other"), jwt.MapClaims{"user_id": testUserID, "sid": "s", "exp": exp}),
+		"none":            sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, jwt.MapClaims{"user_id": testUserID, "sid": "s", "exp": exp}),
+		"garbage":         "not.a.token",
+	}
+	// None of them may reach the database
+	for name, token := range tokens {
+		apiErr, _ := a.Verify(nil, token)
+		if apiErr == nil || apiErr.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected %d but got %v", name, http.StatusUnauthorized, apiErr)
+		}
+	}
+}
+
+func TestSessions(t *testing.T) {
+	container, ctxp := InitTestContainer(t)
+	dbURL, err := container.ConnectionString(*ctxp, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := database.ConnectToDB(&sqlx.Conn{}, dbURL)
+	a := auth.NewAuth([]byte("test-key"))
+	s := server.Server{NewDB: db, Auth: a}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/signup", func(c *gin.Context) { a.Signup(c, db) })
+	r.POST("/login", func(c *gin.Context) { a.Login(c, db) })
+	r.POST("/token/refresh", func(c *gin.Context) { a.Refresh(c, db) })
+	r.POST("/logout", func(c *gin.Context) { a.Logout(c, db) })
+	r.POST("/logout/all", s.UserMiddleware, s.LogoutAll)
+	r.GET("/sessions", s.UserMiddleware, s.GetSessions)
+	r.DELETE("/sessions/:id", s.UserMiddleware, s.RevokeSession)
+	do := func(method string, path string, body string, token string, agent string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", agent)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	login := func(agent string) tokenResponse {
+		w := do(http.MethodPost, "/login", `{"email": "session@example.com", "password": "secret123"}`, "", agent)
+		var tokens tokenResponse
+		if err := json.NewDecoder(w.Body).Decode(&tokens); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("Expected to be logged in but got %d %s", w.Code, w.Body.String())
+		}
+		return tokens
+	}
+
+	if w := do(http.MethodPost, "/signup", `{"email": "session@example.com", "password": "secret123"}`, "", ""); w.Code != http.StatusCreated {
+		t.Fatalf("Expected the user to be created but got %d %s", w.Code, w.Body.String())
+	}
+	phone := login("phone")
+	laptop := login("laptop")
+	if phone.RefreshToken == "" || phone.ExpiresIn != int64(auth.DefaultAccessTTL/time.Second) {
+		t.Errorf("Expected a refresh token and a short lived access token but got %+v", phone)
+	}
+
+	t.Run("refresh rotates the token", func(t *testing.T) {
+		w := do(http.MethodPost, "/token/refresh", `{"refresh_token": "`+phone.RefreshToken+`"}`, "", "phone")
+		var refreshed tokenResponse
+		json.NewDecoder(w.Body).Decode(&refreshed)
+		if w.Code != http.StatusOK || refreshed.RefreshToken == phone.RefreshToken || refreshed.SessionID != phone.SessionID {
+			t.Fatalf("Expected a new refresh token for the session but got %d %s", w.Code, w.Body.String())
+		}
+
+		// The old token was used, using it again means it was stolen
+		if w := do(http.MethodPost, "/token/refresh", `{"refresh_token": "`+phone.RefreshToken+`"}`, "", "thief"); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected the reused token to be rejected but got %d", w.Code)
+		}
+		if w := do(http.MethodPost, "/token/refresh", `{"refresh_token": "`+refreshed.RefreshToken+`"}`, "", "phone"); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected the session to be revoked after the reuse but got %d", w.Code)
+		}
+		if apiErr, _ := a.Verify(db, refreshed.Token); apiErr == nil {
+			t.Error("Expected the access token of the revoked session to be rejected")
+		}
+	})
+
+	t.Run("list and revoke sessions", func(t *testing.T) {
+		phone = login("phone")
+		w := do(http.MethodGet, "/sessions", "", laptop.Token, "laptop")
+		var sessions []auth.Session
+		json.NewDecoder(w.Body).Decode(&sessions)
+		if len(sessions) != 2 {
+			t.Fatalf("Expected the phone and the laptop but got %s", w.Body.String())
+		}
+		for _, session := range sessions {
+			if session.Current != (session.ID == laptop.SessionID) || session.UserAgent == "" {
+				t.Errorf("Expected only the laptop to be the current session but got %+v", session)
+			}
+		}
+
+		if w := do(http.MethodDelete, "/sessions/"+phone.SessionID, "", laptop.Token, "laptop"); w.Code != http.StatusNoContent {
+			t.Errorf("Expected the phone to be logged out but got %d", w.Code)
+		}
+		if w := do(http.MethodGet, "/sessions", "", phone.Token, "phone"); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected the phone token to be rejected at once but got %d", w.Code)
+		}
+		if w := do(http.MethodDelete, "/sessions/"+phone.SessionID, "", laptop.Token, "laptop"); w.Code != http.StatusNotFound {
+			t.Errorf("Expected a revoked session to be %d but got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("logout", func(t *testing.T) {
+		if w := do(http.MethodPost, "/logout", "", laptop.Token, "laptop"); w.Code != http.StatusOK {
+			t.Fatalf("Expected %d but got %d", http.StatusOK, w.Code)
+		}
+		if apiErr, _ := a.Verify(db, laptop.Token); apiErr == nil {
+			t.Error("Expected the token to be revoked by the logout")
+		}
+
+		first, second := login("phone"), login("laptop")
+		w := do(http.MethodPost, "/logout/all", "", first.Token, "phone")
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"revoked":2`) {
+			t.Errorf("Expected both sessions to be revoked but got %d %s", w.Code, w.Body.String())
+		}
+		if w := do(http.MethodPost, "/token/refresh", `{"refresh_token": "`+second.RefreshToken+`"}`, "", "laptop"); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected the other device to be logged out too but got %d", w.Code)
+		}
+	})
+}
+
+func TestAccountRecovery(t *testing.T) {
+	container, ctxp := InitTestContainer(t)
+	dbURL, err := container.ConnectionString(*ctxp, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := database.ConnectToDB(&sqlx.Conn{}, dbURL)
+	mailer := &mail.FileMailer{Dir: t.TempDir()}
+	a := auth.NewAuth([]byte("test-key"))
+	a.Mailer = mailer
+	a.RequireVerifiedEmail = true
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/signup", func(c *gin.Context) { a.Signup(c, db) })
+	r.POST("/login", func(c *gin.Context) { a.Login(c, db) })
+	r.GET("/email/verify", func(c *gin.Context) { a.VerifyEmail(c, db) })
+	r.POST("/email/verify/resend", func(c *gin.Context) { a.ResendVerification(c, db) })
+	r.POST("/password/reset", func(c *gin.Context) { a.RequestPasswordReset(c, db) })
+	r.POST("/password/reset/confirm", func(c *gin.Context) { a.ResetPassword(c, db) })
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+	// lastToken returns the token of the newest mail, links end with it and codes stand on their own line
+	lastToken := func() string {
+		msgs, err := mailer.Messages()
+		if err != nil || len(msgs) == 0 {
+			t.Fatalf("Expected a mail but got %v %v", msgs, err)
+		}
+		body := msgs[len(msgs)-1].Body
+		if i := strings.Index(body, "token="); i >= 0 {
+			return strings.Fields(body[i+len("token="):])[0]
+		}
+		return strings.Fields(strings.SplitN(body, "\n\n", 2)[1])[0]
+	}
+	const credentials = `{"email": "recovery@example.com", "password": "secret123"}`
+
+	if w := do(http.MethodPost, "/signup", credentials); w.Code != http.StatusCreated {
+		t.Fatalf("Expected the user to be created but got %d %s", w.Code, w.Body.String())
+	}
+	first := lastToken()
+
+	t.Run("login waits for the verification", func(t *testing.T) {
+		if w := do(http.MethodPost, "/login", credentials); w.Code != http.StatusForbidden {
+			t.Errorf("Expected an unverified login to be forbidden but got %d", w.Code)
+		}
+		if w := do(http.MethodPost, "/email/verify/resend", `{"email": "recovery@example.com"}`); w.Code != http.StatusAccepted {
+			t.Fatalf("Expected a new link but got %d", w.Code)
+		}
+		if w := do(http.MethodGet, "/email/verify?token="+first, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected the first link to stop working after the resend but got %d", w.Code)
+		}
+		token := lastToken()
+		if w := do(http.MethodGet, "/email/verify?token="+token, ""); w.Code != http.StatusOK {
+			t.Fatalf("Expected the email to be verified but got %d %s", w.Code, w.Body.String())
+		}
+		if w := do(http.MethodGet, "/email/verify?token="+token, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected the link to be single use but got %d", w.Code)
+		}
+		if w := do(http.MethodPost, "/login", credentials); w.Code != http.StatusOK {
+			t.Errorf("Expected the verified user to log in but got %d", w.Code)
+		}
+	})
+
+	t.Run("password reset", func(t *testing.T) {
+		w := do(http.MethodPost, "/login", credentials)
+		var tokens tokenResponse
+		json.NewDecoder(w.Body).Decode(&tokens)
+
+		msgs, _ := mailer.Messages()
+		if w := do(http.MethodPost, "/password/reset", `{"email": "nobody@example.com"}`); w.Code != http.StatusAccepted {
+			t.Errorf("Expected unknown emails to get the same answer but got %d", w.Code)
+		}
+		if after, _ := mailer.Messages(); len(after) != len(msgs) {
+			t.Error("Expected no mail for an unknown email")
+		}
+
+		if w := do(http.MethodPost, "/password/reset", `{"email": "recovery@example.com"}`); w.Code != http.StatusAccepted {
+			t.Fatalf("Expected a reset mail but got %d", w.Code)
+		}
+		token := lastToken()
+		if w := do(http.MethodPost, "/password/reset/confirm", `{"token": "`+token+`", "password": "newsecret"}`); w.Code != http.StatusOK {
+			t.Fatalf("Expected the password to be reset but got %d %s", w.Code, w.Body.String())
+		}
+		if w := do(http.MethodPost, "/password/reset/confirm", `{"token": "`+token+`", "password": "othersecret"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected the token to be single use but got %d", w.Code)
+		}
+		if apiErr, _ := a.Verify(db, tokens.Token); apiErr == nil {
+			t.Error("Expected the reset to log out every session")
+		}
+		if w := do(http.MethodPost, "/login", credentials); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected the old password to be rejected but got %d", w.Code)
+		}
+		if w := do(http.MethodPost, "/login", `{"email": "recovery@example.com", "password": "newsecret"}`); w.Code != http.StatusOK {
+			t.Errorf("Expected the new password to work but got %d", w.Code)
+		}
+	})
+
+	t.Run("expired tokens", func(t *testing.T) {
+		do(http.MethodPost, "/password/reset", `{"email": "recovery@example.com"}`)
+		token := lastToken()
+		db.MustExec(`UPDATE auth_token SET expires_at = now() - interval '1 minute' WHERE used_at IS NULL`)
+		if w := do(http.MethodPost, "/password/reset/confirm", `{"token": "`+token+`", "password": "othersecret"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected the expired token to be rejected but got %d", w.Code)
+		}
+	})
+}
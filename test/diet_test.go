@@ -0,0 +1,161 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/policy"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/server"
+	"github.com/madswillem/recipeApp/internal/user"
+)
+
+const vegetarian = "bbadd945-5557-459f-951e-9ad3ad277059"
+
+func TestDietConflictError(t *testing.T) {
+	conflictErr := &recipe.DietConflictError{Conflicts: []recipe.DietConflict{
+		{DietID: vegetarian, Diet: "Vegetarien", Ingredients: []string{"Pancetta", "Chicken"}},
+	}}
+	if conflictErr.Error() != "Vegetarien excludes Pancetta, Chicken" {
+		t.Errorf("Unexpected message %s", conflictErr.Error())
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	error_handler.HandleError(c, http.StatusUnprocessableEntity, "Recipe contradicts its diets", []error{conflictErr})
+	if !strings.Contains(w.Body.String(), `"diet_conflicts":[{"diet_id":"`+vegetarian+`","diet":"Vegetarien","ingredients":["Pancetta","Chicken"]}]`) {
+		t.Errorf("Expected the conflicts as details but got %s", w.Body.String())
+	}
+}
+
+func TestDiets(t *testing.T) {
+	container, ctxp := InitTestContainer(t)
+	dbURL, err := container.ConnectionString(*ctxp, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := database.ConnectToDB(&sqlx.Conn{}, dbURL)
+	repo := recipe.NewRecipeRepo(db)
+	s := server.Server{NewDB: db, RecipeRepo: repo, DietRepo: recipe.NewDietDBRepo(db)}
+	const carbonara = "aa85daf1-dbc5-462d-a6fe-3fbb358b08dd"
+	const carbonara2 = "c4ef5707-1577-4f8c-99ef-0f492e82b895"
+
+	t.Run("tags contradicting the ingredients are conflicts", func(t *testing.T) {
+		r, apiErr := repo.GetRecipeByID(carbonara2)
+		if apiErr != nil {
+			t.Fatal(apiErr.Message)
+		}
+		if len(r.Diet) != 1 || r.Diet[0].ID != vegetarian || strings.Join(r.Diet[0].Conflicts, ",") != "Pancetta" {
+			t.Errorf("Expected the vegetarian tag to conflict with the pancetta but got %+v", r.Diet)
+		}
+		diets := []string{vegetarian}
+		page, apiErr := repo.GetByFilter(&recipe.Filter{Diets: &diets})
+		if apiErr != nil {
+			t.Fatal(apiErr.Message)
+		}
+		if page.Total != 0 {
+			t.Errorf("Expected no vegetarian recipes but got %d", page.Total)
+		}
+
+		// Only changes to the diets or ingredients are checked, the recipe can still be renamed
+		apiErr = repo.UpdateRecipe(carbonara2, &recipe.RecipeSchema{Name: "Carbonara with pancetta"})
+		if apiErr != nil {
+			t.Errorf("Expected renaming the conflicting recipe to work but got %v", apiErr)
+		}
+	})
+
+	t.Run("create rejects contradicting tags", func(t *testing.T) {
+		r := recipe.RecipeSchema{
+			Author:      testUserID,
+			Name:        "Pancetta pasta",
+			Ingredients: []recipe.IngredientsSchema{{Name: "Spaghetti", Amount: 100, Unit: "g"}, {Name: "Pancetta", Amount: 50, Unit: "g"}},
+			Steps:       []recipe.StepsStruct{{Step: "Cook"}},
+			Diet:        []recipe.DietSchema{{ID: vegetarian}},
+		}
+		r.Rating.DefaultRatingStruct(nil, nil)
+		apiErr := repo.Create(&r)
+		if apiErr == nil || apiErr.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Expected %d but got %v", http.StatusUnprocessableEntity, apiErr)
+		}
+		var conflictErr *recipe.DietConflictError
+		if len(apiErr.Errors) == 0 || !errors.As(apiErr.Errors[0], &conflictErr) || conflictErr.Conflicts[0].Ingredients[0] != "Pancetta" {
+			t.Errorf("Expected the pancetta to be reported but got %v", apiErr.Errors)
+		}
+
+		apiErr = repo.UpdateRecipe(carbonara, &recipe.RecipeSchema{Diet: []recipe.DietSchema{{ID: vegetarian}}})
+		if apiErr == nil || apiErr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("Expected tagging the carbonara as vegetarian to be rejected but got %v", apiErr)
+		}
+	})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	setUser := func(c *gin.Context) { c.Set("user", user.UserModel{ID: testAdminID}) }
+	router.GET("/diets", s.GetDiets)
+	router.GET("/diets/:id", s.GetDiet)
+	router.POST("/diets", setUser, s.Authorize(policy.Diet, policy.Create), s.AddDiet)
+	router.PATCH("/diets/:id", setUser, s.Authorize(policy.Diet, policy.Update), s.UpdateDiet)
+	router.DELETE("/diets/:id", setUser, s.Authorize(policy.Diet, policy.Delete), s.DeleteDiet)
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("crud and inference", func(t *testing.T) {
+		w := do(http.MethodPost, "/diets", `{"name": "vegetarien"}`)
+		if w.Code != http.StatusConflict {
+			t.Errorf("Expected a duplicate name to conflict but got %d", w.Code)
+		}
+		w = do(http.MethodPost, "/diets", `{"name": "No pork", "description": "Without pork", "exingcategory": [{"name": "Pork Products"}]}`)
+		var diet recipe.DietSchema
+		if err := json.NewDecoder(w.Body).Decode(&diet); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusCreated || len(diet.ExIngCategory) != 1 {
+			t.Fatalf("Expected the diet to be created but got %d %+v", w.Code, diet)
+		}
+
+		// All ingredients of the carbonara have a category and none of them is pork
+		r, apiErr := repo.GetRecipeByID(carbonara)
+		if apiErr != nil {
+			t.Fatal(apiErr.Message)
+		}
+		if len(r.Diet) != 1 || r.Diet[0].ID != diet.ID || !r.Diet[0].Inferred {
+			t.Errorf("Expected the carbonara to be inferred as no pork but got %+v", r.Diet)
+		}
+
+		w = do(http.MethodPatch, "/diets/"+diet.ID, `{"exingcategory": [{"name": "Pork Products"}, {"name": "Pepperoni, Salami & Cold Cuts"}]}`)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Pepperoni") {
+			t.Errorf("Expected the categories to be replaced but got %d %s", w.Code, w.Body.String())
+		}
+		r, apiErr = repo.GetRecipeByID(carbonara)
+		if apiErr != nil {
+			t.Fatal(apiErr.Message)
+		}
+		if len(r.Diet) != 0 {
+			t.Errorf("Expected the pancetta to exclude the carbonara but got %+v", r.Diet)
+		}
+
+		if w := do(http.MethodDelete, "/diets/"+diet.ID, ""); w.Code != http.StatusOK {
+			t.Errorf("Expected the diet to be deleted but got %d", w.Code)
+		}
+		if w := do(http.MethodGet, "/diets/"+diet.ID, ""); w.Code != http.StatusNotFound {
+			t.Errorf("Expected the diet to be gone but got %d", w.Code)
+		}
+		if w := do(http.MethodGet, "/diets/not-an-id", ""); w.Code != http.StatusNotFound {
+			t.Errorf("Expected an invalid id to be not found but got %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madswillem/recipeApp/internal/exporter"
+	"github.com/madswillem/recipeApp/internal/importer"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/server"
+	"github.com/madswillem/recipeApp/internal/user"
+)
+
+func exportTestRecipe() recipe.RecipeSchema {
+	r := scaleTestRecipe()
+	r.Author = testUserID
+	r.Cuisine = "italian"
+	r.PrepTime = "00:15:00"
+	r.CookingTime = "1 day 01:05:00"
+	r.Steps = []recipe.StepsStruct{{Step: "Cook the spaghetti."}, {Step: "Mix everything."}}
+	return r
+}
+
+func TestISODuration(t *testing.T) {
+	cases := map[string]string{
+		"00:15:00":        "PT15M",
+		"01:30:00":        "PT1H30M",
+		"1 day 02:00:00":  "PT26H",
+		"00:00:45":        "PT45S",
+		"00:00:00":        "",
+		"":                "",
+		"half an hour":    "",
+		"2 days 00:00:00": "PT48H",
+	}
+	for interval, expected := range cases {
+		if got := exporter.ISODuration(interval); got != expected {
+			t.Errorf("%q: expected %q but got %q", interval, expected, got)
+		}
+	}
+}
+
+func TestExport(t *testing.T) {
+	r := exportTestRecipe()
+
+	t.Run("jsonld round trip", func(t *testing.T) {
+		var b bytes.Buffer
+		err := exporter.Write(&b, &r, exporter.FormatJSONLD)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := importer.Parse(b.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Recipe.Name != r.Name || parsed.Recipe.PrepTime != "00:15:00" || parsed.Recipe.CookingTime != "25:05:00" || parsed.Recipe.Yield != 4 {
+			t.Errorf("Unexpected recipe after round trip %+v", parsed.Recipe)
+		}
+		if len(parsed.IngredientLines) != 5 || parsed.IngredientLines[4] != "250 ml Water" || len(parsed.Recipe.Steps) != 2 {
+			t.Errorf("Unexpected ingredients %v or steps %v", parsed.IngredientLines, parsed.Recipe.Steps)
+		}
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		var b bytes.Buffer
+		exporter.Write(&b, &r, exporter.FormatMarkdown)
+		for _, expected := range []string{"# Carbonara\n", "*Cuisine: italian · Prep: 00:15:00", "- 400 g Spaghetti\n", "2. Mix everything.\n"} {
+			if !strings.Contains(b.String(), expected) {
+				t.Errorf("Expected markdown to contain %q but got:\n%s", expected, b.String())
+			}
+		}
+	})
+
+	t.Run("text", func(t *testing.T) {
+		var b bytes.Buffer
+		exporter.Write(&b, &r, exporter.FormatText)
+		for _, expected := range []string{"Carbonara\n=========\n", "  4 large Egg\n", "  1. Cook the spaghetti.\n"} {
+			if !strings.Contains(b.String(), expected) {
+				t.Errorf("Expected text to contain %q but got:\n%s", expected, b.String())
+			}
+		}
+	})
+}
+
+func TestServer_Export(t *testing.T) {
+	other := exportTestRecipe()
+	other.ID, other.Name, other.Author = "other", "Lasagna", "someone else"
+	second := exportTestRecipe()
+	second.ID, second.Name = "amatriciana", "Pasta all'Amatriciana"
+	s := server.Server{RecipeRepo: &fakeRecipeRepo{recipes: []recipe.RecipeSchema{exportTestRecipe(), other, second}}}
+
+	request := func(handler func(*gin.Context), id string, format string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		gin.SetMode(gin.TestMode)
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/recipes/export?format="+format, nil)
+		c.Params = gin.Params{gin.Param{Key: "id", Value: id}}
+		c.Set("user", user.UserModel{ID: testUserID})
+		handler(c)
+		return w
+	}
+
+	w := request(s.ExportRecipe, "carbonara", "markdown")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/markdown; charset=utf-8" {
+		t.Fatalf("Expected markdown but got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if w.Header().Get("Content-Disposition") != `attachment; filename="carbonara-carbonara.md"` {
+		t.Errorf("Unexpected file name %s", w.Header().Get("Content-Disposition"))
+	}
+
+	if w := request(s.ExportRecipe, "carbonara", "pdf"); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an unknown format to be rejected but got %d", w.Code)
+	}
+	if w := request(s.ExportRecipe, "missing", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected %d for an unknown recipe but got %d", http.StatusNotFound, w.Code)
+	}
+
+	w = request(s.ExportRecipes, "", "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("Expected a zip but got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{"carbonara-carbonara.jsonld", "pasta-all-amatriciana-amatriciana.jsonld"}
+	if len(archive.File) != len(names) {
+		t.Fatalf("Expected only the users %d recipes but got %d files", len(names), len(archive.File))
+	}
+	for i, f := range archive.File {
+		if f.Name != names[i] {
+			t.Errorf("Expected file %s but got %s", names[i], f.Name)
+		}
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		if _, err := importer.Parse(data); err != nil {
+			t.Errorf("%s: %v", f.Name, err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/fdc"
+)
+
+func TestFDCReadJSON(t *testing.T) {
+	file, err := os.Open("./testdata/fdc/sr_legacy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	foods, err := fdc.ReadJSON(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(foods) != 5 {
+		t.Fatalf("Expected 5 foods but got %d", len(foods))
+	}
+	salt := foods[0]
+	if salt.FdcID != 746775 || salt.Description != "Salt, table" || salt.NdbNumber != 2047 {
+		t.Errorf("Expected salt but got %+v", salt)
+	}
+	if salt.Nutrients[fdc.NutrientSodium] != 38758 {
+		t.Errorf("Expected 38758 mg sodium but got %v", salt.Nutrients[fdc.NutrientSodium])
+	}
+	if _, ok := salt.Nutrients[1087]; ok {
+		t.Error("Expected calcium to be skipped")
+	}
+	if foods[1].NdbNumber != 1123 {
+		t.Errorf("Expected the ndb number given as a string to be read but got %d", foods[1].NdbNumber)
+	}
+
+	file.Seek(0, 0)
+	foods, err = fdc.ReadJSON(file, map[int64]bool{748967: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(foods) != 1 || foods[0].FdcID != 748967 {
+		t.Errorf("Expected only the wanted egg but got %+v", foods)
+	}
+
+	_, err = fdc.ReadJSON(strings.NewReader(`{"SRLegacyFoods": {}}`), nil)
+	if err == nil {
+		t.Error("Expected an error for a file without a list of foods")
+	}
+}
+
+func TestFDCReadCSV(t *testing.T) {
+	foods, err := fdc.Load("./testdata/fdc/csv", map[int64]bool{1999634: true, 746775: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(foods) != 2 {
+		t.Fatalf("Expected the 2 wanted foods but got %+v", foods)
+	}
+	tomato, salt := foods[0], foods[1]
+	if tomato.Description != "Tomato, roma" || tomato.Nutrients[fdc.NutrientEnergyAtwaterGeneral] != 18.2 {
+		t.Errorf("Expected the tomato but got %+v", tomato)
+	}
+	if salt.NdbNumber != 2047 {
+		t.Errorf("Expected the ndb number from sr_legacy_food.csv but got %d", salt.NdbNumber)
+	}
+	if len(salt.Conflicts) != 1 {
+		t.Errorf("Expected the two protein amounts to conflict but got %v", salt.Conflicts)
+	}
+
+	_, err = fdc.Load("./testdata/fdc", nil)
+	if err == nil {
+		t.Error("Expected an error for a directory without food_nutrient.csv")
+	}
+}
+
+func TestFDCPlan(t *testing.T) {
+	file, err := os.Open("./testdata/fdc/sr_legacy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	foods, err := fdc.ReadJSON(file, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manual := ""
+	targets := []fdc.Target{
+		{ID: "salt", Name: "salt", FdcID: 746775, NdbNumber: 2047, StandardUnit: "g", Category: "Spices and Herbs"},
+		{ID: "egg", Name: "Egg", FdcID: 748967, NdbNumber: 1123, StandardUnit: "piece", PieceGrams: 50},
+		{ID: "garlic", Name: "Garlic", FdcID: 1104647, NdbNumber: 11215, StandardUnit: "g"},
+		{ID: "spaghetti", Name: "Spaghetti", FdcID: 2099117, StandardUnit: "g", Source: &manual},
+		{ID: "tomato", Name: "tomato", FdcID: 1999634, StandardUnit: "piece"},
+	}
+	now := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+	report := fdc.Plan(foods, targets, fdc.Options{Now: now})
+
+	if len(report.Imported) != 2 || len(report.Conflicts) != 2 || len(report.Unmatched) != 1 || report.UnusedFoods != 1 {
+		t.Fatalf("Expected 2 imported, 2 conflicts, 1 unmatched and 1 unused food but got %+v", report)
+	}
+
+	salt := report.Imported[0].Nutrition
+	if salt.Salt != 96.895 || salt.Kj != 0 || salt.Incomplete {
+		t.Errorf("Expected the salt to be calculated from the sodium but got %+v", salt)
+	}
+	if salt.Source != fdc.Source || *salt.SourceID != 746775 || !salt.ImportedAt.Equal(now) {
+		t.Errorf("Expected the source to be recorded but got %+v", salt)
+	}
+
+	egg := report.Imported[1].Nutrition
+	if egg.Kcal != 71.5 || egg.Kj != 299.5 || egg.Protein != 6.3 {
+		t.Errorf("Expected the values of one 50 g egg but got %+v", egg)
+	}
+	if !egg.Incomplete || len(egg.Missing) != 1 || egg.Missing[0] != "fiber" {
+		t.Errorf("Expected the egg to be missing fiber but got %v", egg.Missing)
+	}
+
+	if !strings.Contains(report.Conflicts[0].Reason, "ndb number") {
+		t.Errorf("Expected garlic to conflict on the ndb number but got %s", report.Conflicts[0].Reason)
+	}
+	if !strings.Contains(report.Conflicts[1].Reason, "-overwrite") {
+		t.Errorf("Expected the values entered by hand to be kept but got %s", report.Conflicts[1].Reason)
+	}
+	if report.Unmatched[0].Ingredient != "tomato" {
+		t.Errorf("Expected the tomato to be unmatched but got %+v", report.Unmatched[0])
+	}
+
+	report = fdc.Plan(foods, targets, fdc.Options{Overwrite: true, Now: now})
+	if len(report.Imported) != 3 || !report.Imported[2].Replaced {
+		t.Errorf("Expected the spaghetti to be replaced with overwrite but got %+v", report.Imported)
+	}
+
+	targets[1].PieceGrams = 0
+	report = fdc.Plan(foods, targets[1:2], fdc.Options{Now: now})
+	if len(report.Conflicts) != 1 || !strings.Contains(report.Conflicts[0].Reason, "without a weight") {
+		t.Errorf("Expected an egg without a weight to conflict but got %+v", report)
+	}
+}
+
+func TestFDCApply(t *testing.T) {
+	container, ctxp := InitTestContainer(t)
+	dbURL, err := container.ConnectionString(*ctxp, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := database.ConnectToDB(&sqlx.Conn{}, dbURL)
+
+	targets, apiErr := fdc.LoadTargets(db)
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	if len(targets) != 8 {
+		t.Fatalf("Expected all 8 ingredients but got %d", len(targets))
+	}
+	foods, err := fdc.Load("./testdata/fdc/sr_legacy.json", fdc.Wanted(targets))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := fdc.Plan(foods, targets, fdc.Options{Now: time.Now().UTC()})
+	// Salt and spaghetti are imported, the egg has no piece weight and garlic a different ndb number
+	if len(report.Imported) != 2 || len(report.Conflicts) != 2 {
+		t.Fatalf("Expected 2 imported and 2 conflicts but got %+v", report)
+	}
+
+	recipeIDs, apiErr := fdc.Apply(report, db)
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	if len(recipeIDs) != 2 {
+		t.Errorf("Expected both carbonaras to need a recalculation but got %v", recipeIDs)
+	}
+
+	var source string
+	err = db.Get(&source, `SELECT source FROM nutritional_value WHERE ingredient_id = '8d7de19b-30f3-4cfd-ae93-c33a8f19a18d'`)
+	if err != nil || source != fdc.Source {
+		t.Errorf("Expected the salt to be imported but got %q %v", source, err)
+	}
+
+	// A second import updates the values instead of adding rows
+	_, apiErr = fdc.Apply(report, db)
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	var count int
+	db.Get(&count, `SELECT COUNT(*) FROM nutritional_value WHERE ingredient_id IS NOT NULL`)
+	if count != 3 {
+		t.Errorf("Expected 3 ingredient nutritional values but got %d", count)
+	}
+}
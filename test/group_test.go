@@ -0,0 +1,200 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/server"
+	"github.com/madswillem/recipeApp/internal/user"
+)
+
+const testUserID = "f85a98f8-2572-420a-9ae5-2c997ad96b6d"
+
+func groupRequest(s *server.Server, handler func(*gin.Context), method string, id string, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = httptest.NewRequest(method, "/groups", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Params = gin.Params{gin.Param{Key: "id", Value: id}}
+	c.Set("user", user.UserModel{ID: testUserID})
+
+	handler(c)
+	return w
+}
+
+func TestServer_Groups(t *testing.T) {
+	container, ctx := InitTestContainer(t)
+	URL, err := container.ConnectionString(*ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := server.Server{NewDB: database.ConnectToDB(&sqlx.Conn{}, URL)}
+	s.RecipeRepo = recipe.NewRecipeRepo(s.NewDB)
+	s.GroupRepo = user.NewGroupRepo(s.NewDB)
+
+	w := groupRequest(&s, s.CreateGroup, http.MethodPost, "", `{"name":"pasta","recipe_ids":["aa85daf1-dbc5-462d-a6fe-3fbb358b08dd","c4ef5707-1577-4f8c-99ef-0f492e82b895"]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: expected %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	var group user.RecipeGroupSchema
+	json.Unmarshal(w.Body.Bytes(), &group)
+
+	t.Run("rename with stale version", func(t *testing.T) {
+		w := groupRequest(&s, s.RenameGroup, http.MethodPatch, group.ID, `{"name":"noodles","version":3}`)
+		if w.Code != http.StatusConflict {
+			t.Errorf("expected %d but got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		w := groupRequest(&s, s.RenameGroup, http.MethodPatch, group.ID, `{"name":"noodles","version":0}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		json.Unmarshal(w.Body.Bytes(), &group)
+		if group.Name != "noodles" || group.Version != 1 || len(group.RecipeIDs) != 2 {
+			t.Errorf("unexpected group after rename: %+v", group)
+		}
+	})
+
+	t.Run("split", func(t *testing.T) {
+		w := groupRequest(&s, s.SplitGroup, http.MethodPost, group.ID, `{"name":"second","version":1,"recipe_ids":["c4ef5707-1577-4f8c-99ef-0f492e82b895"]}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		groups, apiErr := s.GroupRepo.GetByUser(testUserID)
+		if apiErr != nil {
+			t.Fatal(apiErr.Errors[0])
+		}
+		if len(groups) != 2 || len(groups[0].RecipeIDs) != 1 || len(groups[1].RecipeIDs) != 1 {
+			t.Errorf("expected two groups with one recipe each but got %+v", groups)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		deleteGroup := func(query string, ifMatch string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodDelete, "/groups/"+group.ID+query, nil)
+			if ifMatch != "" {
+				c.Request.Header.Set("If-Match", ifMatch)
+			}
+			c.Params = gin.Params{gin.Param{Key: "id", Value: group.ID}}
+			c.Set("user", user.UserModel{ID: testUserID})
+			s.DeleteGroup(c)
+			return w
+		}
+		if w := deleteGroup("", ""); w.Code != http.StatusBadRequest {
+			t.Errorf("expected a delete without a version to be %d but got %d", http.StatusBadRequest, w.Code)
+		}
+		if w := deleteGroup("", `"1"`); w.Code != http.StatusConflict {
+			t.Errorf("expected a stale If-Match to be %d but got %d", http.StatusConflict, w.Code)
+		}
+		w := deleteGroup("?version=2", "")
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		w = groupRequest(&s, s.GetGroups, http.MethodGet, "", "")
+		var groups []user.RecipeGroupSchema
+		json.Unmarshal(w.Body.Bytes(), &groups)
+		if len(groups) != 1 || groups[0].Name != "second" {
+			t.Errorf("expected only the split off group to be left but got %+v", groups)
+		}
+	})
+
+	t.Run("add recipe", func(t *testing.T) {
+		r, apiErr := s.RecipeRepo.GetRecipeByID("aa85daf1-dbc5-462d-a6fe-3fbb358b08dd")
+		if apiErr != nil {
+			t.Fatal(apiErr.Errors[0])
+		}
+		apiErr = s.GroupRepo.AddRecipe(testUserID, r, user.DefaultGroupingConfig())
+		if apiErr != nil {
+			t.Fatal(apiErr.Errors[0])
+		}
+		groups, apiErr := s.GroupRepo.GetByUser(testUserID)
+		if apiErr != nil {
+			t.Fatal(apiErr.Errors[0])
+		}
+		total := 0
+		for _, g := range groups {
+			total += len(g.RecipeIDs)
+		}
+		if total != 2 {
+			t.Errorf("expected 2 grouped recipes but got %d", total)
+		}
+	})
+
+	t.Run("add recipe keeps the versions of untouched groups", func(t *testing.T) {
+		w := groupRequest(&s, s.CreateGroup, http.MethodPost, "", `{"name":"other","recipe_ids":["c4ef5707-1577-4f8c-99ef-0f492e82b895"]}`)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("create: expected %d but got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		groups, apiErr := s.GroupRepo.GetByUser(testUserID)
+		if apiErr != nil {
+			t.Fatal(apiErr.Errors[0])
+		}
+		versions := map[string]int64{}
+		for _, g := range groups {
+			versions[g.ID] = g.Version
+		}
+
+		r, _ := s.RecipeRepo.GetRecipeByID("aa85daf1-dbc5-462d-a6fe-3fbb358b08dd")
+		// The recipe always joins the most similar group and nothing is merged
+		cfg := user.DefaultGroupingConfig()
+		cfg.AddThreshold, cfg.MergeThreshold = 0, 2
+		apiErr = s.GroupRepo.AddRecipe(testUserID, r, cfg)
+		if apiErr != nil {
+			t.Fatal(apiErr.Errors[0])
+		}
+		groups, apiErr = s.GroupRepo.GetByUser(testUserID)
+		if apiErr != nil {
+			t.Fatal(apiErr.Errors[0])
+		}
+		bumped := 0
+		for _, g := range groups {
+			switch g.Version {
+			case versions[g.ID]:
+			case versions[g.ID] + 1:
+				bumped++
+			default:
+				t.Errorf("expected group %s to keep version %d or get %d but got %d", g.Name, versions[g.ID], versions[g.ID]+1, g.Version)
+			}
+		}
+		if len(groups) != len(versions) || bumped != 1 {
+			t.Errorf("expected only the group with the recipe to get a new version but %d of %d did", bumped, len(groups))
+		}
+	})
+
+	t.Run("migrate json groups", func(t *testing.T) {
+		r, _ := s.RecipeRepo.GetRecipeByID("aa85daf1-dbc5-462d-a6fe-3fbb358b08dd")
+		legacy := []user.RecipeGroupSchema{user.BuildGroup(r)}
+		legacy[0].RecipeIDs = append(legacy[0].RecipeIDs, "00000000-0000-0000-0000-000000000000")
+		v, _ := json.Marshal(legacy)
+		s.NewDB.MustExec(`UPDATE "user" SET groups = $1 WHERE id = $2`, v, testUserID)
+
+		before, _ := s.GroupRepo.GetByUser(testUserID)
+		err := server.MigrateJSONGroups(&s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		after, _ := s.GroupRepo.GetByUser(testUserID)
+		if len(after) != len(before)+1 || len(after[len(after)-1].RecipeIDs) != 1 {
+			t.Errorf("expected the legacy group without the deleted recipe to be migrated but got %+v", after)
+		}
+
+		var left []byte
+		s.NewDB.Get(&left, `SELECT groups FROM "user" WHERE id = $1`, testUserID)
+		if left != nil {
+			t.Errorf("expected legacy groups to be cleared but got %s", left)
+		}
+	})
+}
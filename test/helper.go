@@ -2,8 +2,15 @@ package test
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"os"
 	"testing"
 
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/recipe"
 	"github.com/testcontainers/testcontainers-go/modules/postgres"
 )
 
@@ -17,7 +24,6 @@ func InitTestContainer(t *testing.T) (*postgres.PostgresContainer, *context.Cont
 		postgres.WithUsername("mads"),
 		postgres.WithPassword("1234"),
 		postgres.BasicWaitStrategies(),
-		postgres.WithInitScripts("./testdata/innit-db.sql"),
 		postgres.WithSQLDriver("pgx"),
 	)
 	t.Cleanup(func() {
@@ -28,6 +34,10 @@ func InitTestContainer(t *testing.T) (*postgres.PostgresContainer, *context.Cont
 	if err != nil {
 		t.Fatal(err)
 	}
+	err = migrateAndSeed(ctx, container)
+	if err != nil {
+		t.Fatal(err)
+	}
 	err = container.Snapshot(ctx, postgres.WithSnapshotName("test-snapshot"))
 	if err != nil {
 		t.Fatal(err)
@@ -35,3 +45,84 @@ func InitTestContainer(t *testing.T) (*postgres.PostgresContainer, *context.Cont
 
 	return container, &ctx
 }
+
+// migrateAndSeed brings the schema of the test database up to date and inserts testdata/seed.sql.
+// The connection is closed again so the database can be snapshotted.
+func migrateAndSeed(ctx context.Context, container *postgres.PostgresContainer) error {
+	url, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		return err
+	}
+	db := database.ConnectToDB(&sqlx.Conn{}, url)
+	defer db.Close()
+
+	_, err = database.MigrateUp(db, 0)
+	if err != nil {
+		return err
+	}
+	seed, err := os.ReadFile("./testdata/seed.sql")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(string(seed))
+	return err
+}
+
+// fakeRecipeRepo is an in memory recipe.RecipeRepository for tests that don't need a database
+type fakeRecipeRepo struct {
+	recipes []recipe.RecipeSchema
+}
+
+func (f *fakeRecipeRepo) GetAllRecipes() ([]recipe.RecipeSchema, *error_handler.APIError) {
+	return f.recipes, nil
+}
+func (f *fakeRecipeRepo) GetByFilter(fl *recipe.Filter) (*recipe.RecipePage, *error_handler.APIError) {
+	return &recipe.RecipePage{Recipes: f.recipes, Total: len(f.recipes)}, nil
+}
+func (f *fakeRecipeRepo) GetRecipeByID(id string) (*recipe.RecipeSchema, *error_handler.APIError) {
+	for i := range f.recipes {
+		if f.recipes[i].ID == id {
+			return &f.recipes[i], nil
+		}
+	}
+	return nil, error_handler.New("Recipe doesn't exist", http.StatusNotFound, errors.New("recipe doesn't exist"))
+}
+func (f *fakeRecipeRepo) GetRecipeAuthorbyID(id string) (string, *error_handler.APIError) {
+	r, err := f.GetRecipeByID(id)
+	if err != nil {
+		return "", err
+	}
+	return r.Author, nil
+}
+func (f *fakeRecipeRepo) GetRecipeIDsByAuthor(author string) ([]string, *error_handler.APIError) {
+	ids := []string{}
+	for _, r := range f.recipes {
+		if r.Author == author {
+			ids = append(ids, r.ID)
+		}
+	}
+	return ids, nil
+}
+func (f *fakeRecipeRepo) Create(r *recipe.RecipeSchema) *error_handler.APIError {
+	f.recipes = append(f.recipes, *r)
+	return nil
+}
+func (f *fakeRecipeRepo) DeleteRecipe(id string) *error_handler.APIError { return nil }
+func (f *fakeRecipeRepo) UpdateRecipe(id string, r *recipe.RecipeSchema) *error_handler.APIError {
+	return nil
+}
+func (f *fakeRecipeRepo) UpdateRecipeView(id string) *error_handler.APIError   { return nil }
+func (f *fakeRecipeRepo) UpdateRecipeSelect(id string) *error_handler.APIError { return nil }
+func (f *fakeRecipeRepo) AddIngredient(id string, ingredient *recipe.IngredientsSchema) *error_handler.APIError {
+	return nil
+}
+func (f *fakeRecipeRepo) DeleteIngredient(id string, ingredientID string) *error_handler.APIError {
+	return nil
+}
+func (f *fakeRecipeRepo) RefreshNutrition(id string) *error_handler.APIError { return nil }
+func (f *fakeRecipeRepo) Substitutions(id string, o *recipe.SubstitutionOptions) (*recipe.SubstitutionPlan, *error_handler.APIError) {
+	return nil, error_handler.New("not supported", http.StatusNotImplemented, errors.New("not supported"))
+}
+func (f *fakeRecipeRepo) CreateVariant(id string, author string, v *recipe.VariantRequest) (*recipe.RecipeSchema, *error_handler.APIError) {
+	return nil, error_handler.New("not supported", http.StatusNotImplemented, errors.New("not supported"))
+}
@@ -0,0 +1,116 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/importer"
+	"github.com/madswillem/recipeApp/internal/recipe"
+)
+
+func TestParseDuration(t *testing.T) {
+	cases := map[string]time.Duration{
+		"PT15M":    15 * time.Minute,
+		"PT1H30M":  90 * time.Minute,
+		"P1DT2H":   26 * time.Hour,
+		"PT0.5H":   30 * time.Minute,
+		"pt45s":    45 * time.Second,
+		"PT1H5M3S": time.Hour + 5*time.Minute + 3*time.Second,
+	}
+	for s, expected := range cases {
+		d, err := importer.ParseDuration(s)
+		if err != nil || d != expected {
+			t.Errorf("%s: expected %s but got %s (%v)", s, expected, d, err)
+		}
+	}
+	for _, s := range []string{"", "P", "PT", "15 minutes", "PT15X"} {
+		if _, err := importer.ParseDuration(s); err == nil {
+			t.Errorf("Expected %q to be invalid", s)
+		}
+	}
+}
+
+func TestImport(t *testing.T) {
+	page, err := os.ReadFile("./testdata/import/carbonara.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	known := map[string]string{"spaghetti": "1", "pancetta": "2", "egg": "3", "parmesan cheese": "4", "garlic": "5", "salt": "6"}
+	newImporter := func(repo *fakeRecipeRepo) *importer.Importer {
+		return &importer.Importer{
+			Recipes: repo,
+			LookupIngredient: func(name string) (string, *error_handler.APIError) {
+				if id, ok := known[strings.ToLower(name)]; ok {
+					return id, nil
+				}
+				return "", error_handler.New("ingredient "+name+" doesn't exist", http.StatusNotFound, errors.New("not found"))
+			},
+			SuggestIngredients: func(name string) ([]recipe.IngredientMatch, *error_handler.APIError) {
+				return []recipe.IngredientMatch{{ID: "7", Name: "Flat leaf " + name, Score: 0.6}}, nil
+			},
+		}
+	}
+
+	repo := &fakeRecipeRepo{}
+	result, apiErr := newImporter(repo).Import(page, testUserID, false)
+	if apiErr != nil {
+		t.Fatal(apiErr.Errors[0])
+	}
+	if !result.Created || len(repo.recipes) != 1 {
+		t.Fatalf("Expected the recipe to be created")
+	}
+
+	r := repo.recipes[0]
+	if r.Name != "Spaghetti Carbonara" || r.Cuisine != "italian" || r.Author != testUserID {
+		t.Errorf("Unexpected recipe %s (%s) by %s", r.Name, r.Cuisine, r.Author)
+	}
+	if r.Yield != 4 || r.YieldUnit != "servings" || r.PrepTime != "00:15:00" || r.CookingTime != "01:05:00" {
+		t.Errorf("Unexpected yield %d %s or times %s %s", r.Yield, r.YieldUnit, r.PrepTime, r.CookingTime)
+	}
+	if len(r.Ingredients) != 6 || r.Ingredients[2].Name != "egg" || r.Ingredients[2].IngredientID != "3" {
+		t.Errorf("Expected 6 matched ingredients with eggs matched as egg but got %+v", r.Ingredients)
+	}
+	steps := []string{"Cook the spaghetti until al dente.", "Fry the pancetta & garlic.", "Toss everything with the eggs and cheese."}
+	if len(r.Steps) != len(steps) {
+		t.Fatalf("Expected %d steps but got %+v", len(steps), r.Steps)
+	}
+	for i, s := range steps {
+		if r.Steps[i].Step != s {
+			t.Errorf("Expected step %d to be %q but got %q", i, s, r.Steps[i].Step)
+		}
+	}
+
+	if len(result.Unmatched) != 2 || result.Unmatched[0].Reason != "no amount" || result.Unmatched[1].Name != "parsley" {
+		t.Errorf("Expected pepper and parsley to be unmatched but got %+v", result.Unmatched)
+	} else if s := result.Unmatched[1].Suggestions; len(s) != 1 || s[0].Name != "Flat leaf parsley" {
+		t.Errorf("Expected flat leaf parsley to be suggested for parsley but got %+v", s)
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		repo := &fakeRecipeRepo{}
+		result, apiErr := newImporter(repo).Import(page, testUserID, true)
+		if apiErr != nil || result.Created || len(repo.recipes) != 0 {
+			t.Errorf("Expected a dry run not to create the recipe")
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		cases := map[string]int{
+			`<html><body>no recipe</body></html>`:                                    http.StatusBadRequest,
+			`{"@type": "Person", "name": "Mads"}`:                                    http.StatusBadRequest,
+			`{"@type": "Recipe", "name": "Soup", "prepTime": "half an hour"}`:        http.StatusBadRequest,
+			`{"@type": "Recipe", "name": "Soup", "recipeIngredient": ["1 l broth"]}`: http.StatusUnprocessableEntity,
+		}
+		for body, code := range cases {
+			_, apiErr := newImporter(&fakeRecipeRepo{}).Import([]byte(body), testUserID, false)
+			if apiErr == nil || apiErr.Code != code {
+				t.Errorf("%s: expected %d but got %+v", body, code, apiErr)
+			}
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/server"
+	"github.com/madswillem/recipeApp/internal/user"
+)
+
+func TestNameVariants(t *testing.T) {
+	cases := map[string][]string{
+		"Eggs":             {"eggs", "egg"},
+		"tomatoes":         {"tomatoes", "tomato", "tomatoe"},
+		"Cherries":         {"cherries", "cherry", "cherrie"},
+		"bay leaves":       {"bay leaves", "bay leaf", "bay leave"},
+		"radish":           {"radish", "radishes", "radishs"},
+		"berry":            {"berry", "berries", "berrys"},
+		"  Black  pepper ": {"black pepper", "black peppers"},
+		"swiss":            {"swiss"},
+		"":                 nil,
+	}
+	for name, expected := range cases {
+		if variants := recipe.NameVariants(name); !slices.Equal(variants, expected) {
+			t.Errorf("Expected %q to give %v but got %v", name, expected, variants)
+		}
+	}
+}
+
+func TestHandleErrorDetails(t *testing.T) {
+	w := httptest.NewRecorder()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(w)
+
+	unknown := &recipe.UnknownIngredientsError{Ingredients: []recipe.UnknownIngredient{
+		{Name: "parmesn", Suggestions: []recipe.IngredientMatch{{ID: "1", Name: "Parmesan cheese", Score: 0.6}}},
+		{Name: "unobtainium", Suggestions: []recipe.IngredientMatch{}},
+	}}
+	err := error_handler.New("Couldn't find parmesn, unobtainium", http.StatusUnprocessableEntity, unknown)
+	error_handler.HandleError(c, err.Code, err.Message, err.Errors)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected %d but got %d", http.StatusUnprocessableEntity, w.Code)
+	}
+	var body struct {
+		Errors  string `json:"errors"`
+		Details struct {
+			UnknownIngredients []recipe.UnknownIngredient `json:"unknown_ingredients"`
+		} `json:"details"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Errors != "unknown ingredients: parmesn, unobtainium" {
+		t.Errorf("Unexpected error %q", body.Errors)
+	}
+	if len(body.Details.UnknownIngredients) != 2 || body.Details.UnknownIngredients[0].Suggestions[0].Name != "Parmesan cheese" {
+		t.Errorf("Expected both unknown ingredients with suggestions but got %+v", body.Details.UnknownIngredients)
+	}
+}
+
+func TestGetIngIDByName(t *testing.T) {
+	container, ctxp := InitTestContainer(t)
+	dbURL, err := container.ConnectionString(*ctxp, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := database.ConnectToDB(&sqlx.Conn{}, dbURL)
+
+	found := map[string]string{
+		"Egg":                 "ea3f9073-6a75-4625-80d1-19dc42aca7ef",
+		"eggs":                "ea3f9073-6a75-4625-80d1-19dc42aca7ef",
+		"Tomatoes":            "84eb6da1-25b9-40ec-97a1-c0db1844ca54",
+		"parmesan":            "db630404-6115-4ca1-91cd-f9ed8981676f",
+		"Parmigiano Reggiano": "db630404-6115-4ca1-91cd-f9ed8981676f",
+	}
+	for name, expected := range found {
+		id, apiErr := recipe.GetIngIDByName(name, db)
+		if apiErr != nil || id != expected {
+			t.Errorf("Expected %s to be found as %s but got %s %v", name, expected, id, apiErr)
+		}
+	}
+	_, apiErr := recipe.GetIngIDByName("parmesn", db)
+	if apiErr == nil || apiErr.Code != http.StatusNotFound {
+		t.Errorf("Expected parmesn not to be found but got %v", apiErr)
+	}
+
+	suggestions, apiErr := recipe.SuggestIngredients("parmesn", recipe.MaxSuggestions, db)
+	if apiErr != nil {
+		t.Fatal(apiErr.Errors[0])
+	}
+	if len(suggestions) == 0 || suggestions[0].Name != "Parmesan cheese" || suggestions[0].Score <= 0 || suggestions[0].Score > 1 {
+		t.Errorf("Expected Parmesan cheese to be suggested first but got %+v", suggestions)
+	}
+
+	t.Run("create with unknown ingredients", func(t *testing.T) {
+		s := server.Server{NewDB: db}
+		s.RecipeRepo = recipe.NewRecipeRepo(db)
+		w := httptest.NewRecorder()
+		gin.SetMode(gin.TestMode)
+		c, _ := gin.CreateTestContext(w)
+		body := `{"name": "Pasta", "cuisine": "italian", "yield": 2, "yield_unit": "servings", "prep_time": "00:10:00", "cooking_time": "00:10:00",
+			"ingredients": [{"raw": "200 g spaghetti"}, {"raw": "50 g parmesn"}, {"raw": "1 unobtainium"}],
+			"steps": [{"step": "Cook"}]}`
+		c.Request = httptest.NewRequest(http.MethodPost, "/create", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "application/json")
+		c.Set("user", user.UserModel{ID: "f85a98f8-2572-420a-9ae5-2c997ad96b6d"})
+		s.AddRecipe(c)
+
+		if w.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("Expected %d but got %d: %s", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+		}
+		for _, expected := range []string{`"name":"parmesn"`, `"name":"Parmesan cheese"`, `"name":"unobtainium","suggestions":[]`} {
+			if !strings.Contains(w.Body.String(), expected) {
+				t.Errorf("Expected the response to contain %s but got %s", expected, w.Body.String())
+			}
+		}
+	})
+}
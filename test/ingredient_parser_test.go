@@ -0,0 +1,100 @@
+package test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/server"
+)
+
+func TestParseIngredientLine(t *testing.T) {
+	cases := []struct {
+		line   string
+		amount float64
+		max    float64
+		unit   string
+		name   string
+		note   string
+		err    error
+	}{
+		{"400 g spaghetti", 400, 0, "g", "spaghetti", "", nil},
+		{"150g pancetta (diced)", 150, 0, "g", "pancetta", "diced", nil},
+		{"4 large eggs", 4, 0, "large", "eggs", "", nil},
+		{"2 cloves garlic, minced", 2, 0, "clove", "garlic", "minced", nil},
+		{"2 cloves minced garlic", 2, 0, "clove", "garlic", "minced", nil},
+		{"2-3 cloves garlic", 2, 3, "clove", "garlic", "", nil},
+		{"1 to 2 tbsp olive oil", 1, 2, "tbsp", "olive oil", "", nil},
+		{"1/2-1 tsp chili flakes", 0.5, 1, "tsp", "chili flakes", "", nil},
+		{"1 ½ cups Parmesan cheese, finely grated", 1.5, 0, "cup", "Parmesan cheese", "finely grated", nil},
+		{"1½ cups flour (sifted)", 1.5, 0, "cup", "flour", "sifted", nil},
+		{"1 1/2 tablespoons of olive oil", 1.5, 0, "tbsp", "olive oil", "", nil},
+		{"0,25 l milk", 0.25, 0, "l", "milk", "", nil},
+		{"3 fl oz cream", 3, 0, "fl oz", "cream", "", nil},
+		{"2 tomatoes", 2, 0, "piece", "tomatoes", "", nil},
+		{"500 g ground beef", 500, 0, "g", "ground beef", "", nil},
+		{"Black pepper to taste", 0, 0, "", "Black pepper", "to taste", recipe.ErrNoAmount},
+		{"2 cups", 2, 0, "cup", "", "", recipe.ErrNoIngredientName},
+	}
+	for _, c := range cases {
+		ing, err := recipe.ParseIngredientLine(c.line)
+		max := 0.0
+		if ing.AmountMax != nil {
+			max = *ing.AmountMax
+		}
+		if !errors.Is(err, c.err) || ing.Amount != c.amount || max != c.max || ing.Unit != c.unit || ing.Name != c.name || ing.Note != c.note {
+			t.Errorf("%q: expected %v-%v %q %q (%q, %v) but got %v-%v %q %q (%q, %v)", c.line,
+				c.amount, c.max, c.unit, c.name, c.note, c.err, ing.Amount, max, ing.Unit, ing.Name, ing.Note, err)
+		}
+		if ing.Raw != c.line {
+			t.Errorf("%q: expected the raw line to be kept but got %q", c.line, ing.Raw)
+		}
+	}
+}
+
+func TestBuildRawIngredients(t *testing.T) {
+	r := recipe.RecipeSchema{
+		Name:  "Garlic bread",
+		Steps: []recipe.StepsStruct{{Step: "Bake"}},
+		Ingredients: []recipe.IngredientsSchema{
+			{Raw: "2-3 cloves garlic, minced"},
+			{Name: "Bread", Amount: 1, Unit: "piece"},
+		},
+	}
+	apiErr := r.Build(testUserID)
+	if apiErr != nil {
+		t.Fatal(apiErr.Errors[0])
+	}
+	if ing := r.Ingredients[0]; ing.Name != "garlic" || ing.Unit != "clove" || ing.Note != "minced" || *ing.AmountMax != 3 {
+		t.Errorf("Expected the raw line to be parsed but got %+v", ing)
+	}
+
+	r.Ingredients = append(r.Ingredients, recipe.IngredientsSchema{Raw: "salt to taste"})
+	apiErr = r.Build(testUserID)
+	if apiErr == nil || apiErr.Code != http.StatusBadRequest {
+		t.Errorf("Expected a line without an amount to be rejected but got %+v", apiErr)
+	}
+}
+
+func TestServer_ParseIngredients(t *testing.T) {
+	s := server.Server{}
+	w := httptest.NewRecorder()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/ingredients/parse", strings.NewReader(`{"lines": ["2 cloves garlic, minced", "salt to taste"]}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+	s.ParseIngredients(c)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	for _, expected := range []string{`"name":"garlic"`, `"note":"minced"`, `"error":"no amount"`} {
+		if !strings.Contains(w.Body.String(), expected) {
+			t.Errorf("Expected the response to contain %s but got %s", expected, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/policy"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/server"
+	"github.com/madswillem/recipeApp/internal/user"
+)
+
+const testAdminID = "0b7c1e52-3f4d-4b8e-9a61-2d5f8c9e7a10"
+
+func TestServer_Ingredients(t *testing.T) {
+	container, ctxp := InitTestContainer(t)
+	dbURL, err := container.ConnectionString(*ctxp, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := database.ConnectToDB(&sqlx.Conn{}, dbURL)
+	s := server.Server{NewDB: db}
+	s.RecipeRepo = recipe.NewRecipeRepo(db)
+	s.IngredientRepo = recipe.NewIngredientDBRepo(db)
+
+	gin.SetMode(gin.TestMode)
+	router := func(userID string) *gin.Engine {
+		r := gin.New()
+		setUser := func(c *gin.Context) { c.Set("user", user.UserModel{ID: userID}) }
+		r.GET("/ingredients", s.GetIngredients)
+		r.GET("/ingredients/search", s.SearchIngredients)
+		r.GET("/ingredients/:id", s.GetIngredient)
+		r.POST("/ingredients", setUser, s.Authorize(policy.Ingredient, policy.Create), s.AddIngredient)
+		r.PATCH("/ingredients/:id", setUser, s.Authorize(policy.Ingredient, policy.Update), s.UpdateIngredient)
+		r.POST("/ingredients/:id/merge", setUser, s.Authorize(policy.Ingredient, policy.Merge), s.MergeIngredients)
+		r.DELETE("/ingredients/:id", setUser, s.Authorize(policy.Ingredient, policy.Delete), s.DeleteIngredient)
+		return r
+	}
+	do := func(userID string, method string, path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router(userID).ServeHTTP(w, req)
+		return w
+	}
+	const parmesan = "db630404-6115-4ca1-91cd-f9ed8981676f"
+	const egg = "ea3f9073-6a75-4625-80d1-19dc42aca7ef"
+
+	t.Run("list", func(t *testing.T) {
+		w := do("", http.MethodGet, "/ingredients?limit=5", "")
+		var page recipe.IngredientPage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusOK || len(page.Ingredients) != 5 || page.Total != 8 || page.NextCursor == "" {
+			t.Fatalf("Expected the first 5 of 8 ingredients but got %d %+v", w.Code, page)
+		}
+		if page.Ingredients[0].Name != "Black pepper" {
+			t.Errorf("Expected the ingredients to be sorted by name but got %s first", page.Ingredients[0].Name)
+		}
+
+		w = do("", http.MethodGet, "/ingredients?limit=5&cursor="+page.NextCursor, "")
+		var next recipe.IngredientPage
+		if err := json.NewDecoder(w.Body).Decode(&next); err != nil {
+			t.Fatal(err)
+		}
+		if len(next.Ingredients) != 3 || next.NextCursor != "" {
+			t.Errorf("Expected the last 3 ingredients but got %+v", next)
+		}
+	})
+
+	t.Run("search", func(t *testing.T) {
+		w := do("", http.MethodGet, "/ingredients/search?q=parm", "")
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"name":"Parmesan cheese"`) {
+			t.Errorf("Expected parm to find Parmesan cheese but got %d %s", w.Code, w.Body.String())
+		}
+		w = do("", http.MethodGet, "/ingredients/search", "")
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected a search without q to be a bad request but got %d", w.Code)
+		}
+	})
+
+	t.Run("get", func(t *testing.T) {
+		w := do("", http.MethodGet, "/ingredients/"+parmesan, "")
+		var ing recipe.IngredientDB
+		if err := json.NewDecoder(w.Body).Decode(&ing); err != nil {
+			t.Fatal(err)
+		}
+		if ing.Name != "Parmesan cheese" || len(ing.Aliases) != 2 || ing.Rating.ID == "" {
+			t.Errorf("Expected parmesan with its aliases and rating but got %+v", ing)
+		}
+		if w := do("", http.MethodGet, "/ingredients/not-an-id", ""); w.Code != http.StatusNotFound {
+			t.Errorf("Expected %d but got %d", http.StatusNotFound, w.Code)
+		}
+	})
+
+	t.Run("moderators create and update, admins merge and delete", func(t *testing.T) {
+		const moderatorID = "5d2b8e61-9a3c-4f7e-b1d4-6c8a2e9f0b37"
+		db.MustExec(`INSERT INTO "user" (id, email, role) VALUES ($1, 'moderator@example.com', 'moderator')`, moderatorID)
+
+		if w := do(testUserID, http.MethodPost, "/ingredients", `{"name": "Saffron"}`); w.Code != http.StatusForbidden {
+			t.Errorf("Expected users not to create ingredients but got %d", w.Code)
+		}
+		if w := do(testUserID, http.MethodDelete, "/ingredients/"+parmesan, ""); w.Code != http.StatusForbidden {
+			t.Errorf("Expected users not to delete ingredients but got %d", w.Code)
+		}
+		if w := do(moderatorID, http.MethodPost, "/ingredients", `{"name": "Saffron", "standard_unit": "g"}`); w.Code != http.StatusCreated {
+			t.Errorf("Expected moderators to create ingredients but got %d %s", w.Code, w.Body.String())
+		}
+		if w := do(moderatorID, http.MethodPatch, "/ingredients/"+parmesan, `{"category": "Dairy and Egg Products"}`); w.Code != http.StatusOK {
+			t.Errorf("Expected moderators to update ingredients but got %d %s", w.Code, w.Body.String())
+		}
+		if w := do(moderatorID, http.MethodPost, "/ingredients/"+egg+"/merge", `{"from": "`+parmesan+`"}`); w.Code != http.StatusForbidden {
+			t.Errorf("Expected moderators not to merge ingredients but got %d", w.Code)
+		}
+		if w := do(moderatorID, http.MethodDelete, "/ingredients/"+parmesan, ""); w.Code != http.StatusForbidden {
+			t.Errorf("Expected moderators not to delete ingredients but got %d", w.Code)
+		}
+	})
+
+	t.Run("create", func(t *testing.T) {
+		w := do(testAdminID, http.MethodPost, "/ingredients", `{"name": "Eggs"}`)
+		if w.Code != http.StatusConflict {
+			t.Errorf("Expected a duplicate of Egg to conflict but got %d %s", w.Code, w.Body.String())
+		}
+		w = do(testAdminID, http.MethodPost, "/ingredients", `{"name": "Basil", "standard_unit": "bunches"}`)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("Expected an unknown unit to be a bad request but got %d", w.Code)
+		}
+		w = do(testAdminID, http.MethodPost, "/ingredients", `{"name": "Grana Padano", "standard_unit": "g", "aliases": ["grana"]}`)
+		if w.Code != http.StatusCreated {
+			t.Errorf("Expected %d but got %d %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("update", func(t *testing.T) {
+		w := do(testAdminID, http.MethodPatch, "/ingredients/"+egg, `{"category": "Eggs", "piece_weights": {"large": 60}}`)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"large":60`) {
+			t.Errorf("Expected the egg to be updated but got %d %s", w.Code, w.Body.String())
+		}
+		w = do(testAdminID, http.MethodPatch, "/ingredients/"+egg, `{"name": "Garlic"}`)
+		if w.Code != http.StatusConflict {
+			t.Errorf("Expected renaming to an existing name to conflict but got %d", w.Code)
+		}
+	})
+
+	t.Run("merge and delete", func(t *testing.T) {
+		var grana string
+		if err := db.Get(&grana, `SELECT id FROM ingredient WHERE name = 'Grana Padano'`); err != nil {
+			t.Fatal(err)
+		}
+		// Parmesan is used by the carbonara and can't be deleted
+		w := do(testAdminID, http.MethodDelete, "/ingredients/"+parmesan, "")
+		if w.Code != http.StatusConflict {
+			t.Errorf("Expected deleting a used ingredient to conflict but got %d %s", w.Code, w.Body.String())
+		}
+
+		w = do(testAdminID, http.MethodPost, "/ingredients/"+grana+"/merge", `{"from": "`+parmesan+`"}`)
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected %d but got %d %s", http.StatusOK, w.Code, w.Body.String())
+		}
+		for _, expected := range []string{`"aliases":["grana","parmesan","Parmesan cheese","parmigiano reggiano"]`, `"aa85daf1-dbc5-462d-a6fe-3fbb358b08dd"`} {
+			if !strings.Contains(w.Body.String(), expected) {
+				t.Errorf("Expected the response to contain %s but got %s", expected, w.Body.String())
+			}
+		}
+		if id, _ := recipe.GetIngIDByName("parmesan cheese", db); id != grana {
+			t.Errorf("Expected parmesan cheese to be found as grana padano but got %s", id)
+		}
+		if w := do("", http.MethodGet, "/ingredients/"+parmesan, ""); w.Code != http.StatusNotFound {
+			t.Errorf("Expected the merged ingredient to be gone but got %d", w.Code)
+		}
+
+		w = do(testAdminID, http.MethodPost, "/ingredients", `{"name": "Unused"}`)
+		var unused recipe.IngredientDB
+		if err := json.NewDecoder(w.Body).Decode(&unused); err != nil {
+			t.Fatal(err)
+		}
+		if w := do(testAdminID, http.MethodDelete, "/ingredients/"+unused.ID, ""); w.Code != http.StatusOK {
+			t.Errorf("Expected the unused ingredient to be deleted but got %d %s", w.Code, w.Body.String())
+		}
+	})
+}
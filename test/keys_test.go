@@ -0,0 +1,129 @@
+package test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/madswillem/recipeApp/internal/auth"
+)
+
+func writePEM(t *testing.T, typ string, der []byte) string {
+	path := filepath.Join(t.TempDir(), "key.pem")
+	err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestKeys(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edDER, _ := x509.MarshalPKCS8PrivateKey(edKey)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPrivate := writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	oldPublicDER, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	oldPublic := writePEM(t, "PUBLIC KEY", oldPublicDER)
+	current := writePEM(t, "PRIVATE KEY", edDER)
+
+	for name, cfg := range map[string]auth.KeyConfig{
+		"no key":           {},
+		"two signing keys": {Secret: "secret", PrivateKeyFile: current},
+		"public key only":  {PrivateKeyFile: oldPublic},
+		"missing file":     {PrivateKeyFile: filepath.Join(t.TempDir(), "missing.pem")},
+		"duplicate kid":    {PrivateKeyFile: current, VerifyKeyFiles: []string{current}},
+	} {
+		if _, err := auth.LoadKeys(cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	small, _ := rsa.GenerateKey(rand.Reader, 1024)
+	if _, err := auth.LoadKeys(auth.KeyConfig{PrivateKeyFile: writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(small))}); err == nil {
+		t.Error("Expected a 1024 bit RSA key to be rejected")
+	}
+
+	old, err := auth.LoadKeys(auth.KeyConfig{PrivateKeyFile: oldPrivate, KeyID: "old"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := auth.LoadKeys(auth.KeyConfig{PrivateKeyFile: current, VerifyKeyFiles: []string{oldPublic}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := jwt.MapClaims{"user_id": testUserID, "exp": time.Now().Add(time.Minute).Unix()}
+
+	t.Run("sign with kid", func(t *testing.T) {
+		token, err := rotated.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, _, err := jwt.NewParser().ParseUnverified(token, jwt.MapClaims{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if parsed.Header["alg"] != auth.AlgEdDSA || parsed.Header["kid"] == "" {
+			t.Errorf("Expected an EdDSA token naming its key but got %v", parsed.Header)
+		}
+		if _, err := rotated.Parse(token); err != nil {
+			t.Errorf("Expected the token to verify but got %v", err)
+		}
+		if _, err := old.Parse(token); err == nil {
+			t.Error("Expected the old key set not to know the new key")
+		}
+	})
+
+	t.Run("rotation", func(t *testing.T) {
+		token, _ := old.Sign(claims)
+		// The kid of the old key defaults to the hash of its public key in the verify file
+		if _, err := rotated.Parse(token); err == nil {
+			t.Error("Expected a token naming an unknown kid to be rejected")
+		}
+		renamed, _ := auth.LoadKeys(auth.KeyConfig{PrivateKeyFile: oldPrivate})
+		token, _ = renamed.Sign(claims)
+		if _, err := rotated.Parse(token); err != nil {
+			t.Errorf("Expected a token of the previous key to verify but got %v", err)
+		}
+	})
+
+	t.Run("algorithm confusion", func(t *testing.T) {
+		jwks := rotated.JWKS()
+		// An HS256 token keyed with the public RSA key must not verify
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		forged.Header["kid"] = jwks.Keys[1].KeyID
+		token, _ := forged.SignedString(oldPublicDER)
+		if _, err := rotated.Parse(token); err == nil {
+			t.Error("Expected an HS256 token for an RSA key to be rejected")
+		}
+	})
+
+	t.Run("jwks", func(t *testing.T) {
+		jwks := rotated.JWKS()
+		if len(jwks.Keys) != 2 {
+			t.Fatalf("Expected both public keys but got %+v", jwks)
+		}
+		ed, rs := jwks.Keys[0], jwks.Keys[1]
+		if ed.KeyType != "OKP" || ed.Curve != "Ed25519" || ed.Algorithm != auth.AlgEdDSA || ed.X == "" {
+			t.Errorf("Expected the signing Ed25519 key first but got %+v", ed)
+		}
+		if rs.KeyType != "RSA" || rs.E != "AQAB" || rs.N == "" || rs.Use != "sig" {
+			t.Errorf("Expected the RSA key but got %+v", rs)
+		}
+		secret, _ := auth.LoadKeys(auth.KeyConfig{Secret: "secret"})
+		if len(secret.JWKS().Keys) != 0 {
+			t.Error("Expected a shared secret not to be published")
+		}
+	})
+}
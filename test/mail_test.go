@@ -0,0 +1,48 @@
+package test
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/madswillem/recipeApp/internal/mail"
+)
+
+func TestMailer(t *testing.T) {
+	m := &mail.FileMailer{Dir: t.TempDir()}
+	for _, msg := range []mail.Message{
+		{To: "cook@example.com", Subject: "Verify your email", Body: "first\nline"},
+		{To: "cook@example.com", Subject: "Grüße", Body: "second"},
+	} {
+		if err := m.Send(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs, err := m.Messages()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Body != "first\nline" || msgs[1].Subject != "Grüße" || msgs[1].To != "cook@example.com" {
+		t.Errorf("Expected both mails in order but got %+v", msgs)
+	}
+
+	for _, msg := range []mail.Message{
+		{To: "cook@example.com\r\nBcc: all@example.com", Subject: "x"},
+		{To: "cook@example.com", Subject: "x\nBcc: all@example.com"},
+		{To: "not an address", Subject: "x"},
+	} {
+		if err := m.Send(msg); err == nil {
+			t.Errorf("Expected %+v to be rejected", msg)
+		}
+		if err := (&mail.SMTPMailer{Addr: "localhost:1", From: "app@example.com"}).Send(msg); err == nil || strings.Contains(err.Error(), "connect") {
+			t.Errorf("Expected %+v to be rejected before connecting but got %v", msg, err)
+		}
+	}
+
+	var b bytes.Buffer
+	(&mail.LogMailer{Logger: log.New(&b, "", 0)}).Send(mail.Message{To: "cook@example.com", Subject: "Hi", Body: "body"})
+	if !strings.Contains(b.String(), "cook@example.com") || !strings.Contains(b.String(), "body") {
+		t.Errorf("Expected the mail to be logged but got %q", b.String())
+	}
+}
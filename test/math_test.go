@@ -127,7 +127,10 @@ func TestMergeMatrix(t *testing.T) {
 			},
 		}
 
-		r := tools.MergeMatrix(a, b)
+		r, err := tools.MergeMatrix(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
 
 		if !reflect.DeepEqual(r.Dict, expected.Dict) {
 			t.Errorf("Expected %+v but got %+v", expected.Dict, r.Dict)
@@ -136,4 +139,32 @@ func TestMergeMatrix(t *testing.T) {
 			t.Errorf("Expected %+v but got %+v", expected.Vec, r.Vec)
 		}
 	})
+	t.Run("gaps in the indices", func(t *testing.T) {
+		a := tools.Matrix{Len: 1, Dict: map[string]int{"hi": 1, "ben": 3}, Vec: []float64{1, 0, 1}}
+		b := tools.Matrix{Len: 1, Dict: map[string]int{"you": 2, "ben": 4}, Vec: []float64{0, 1, 0, 1}}
+
+		r, err := tools.MergeMatrix(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedDict := map[string]int{"hi": 1, "ben": 3, "you": 4}
+		if !reflect.DeepEqual(r.Dict, expectedDict) {
+			t.Errorf("Expected %+v but got %+v", expectedDict, r.Dict)
+		}
+		if expectedVec := []float64{0.5, 0, 1, 0.5}; !reflect.DeepEqual(r.Vec, expectedVec) {
+			t.Errorf("Expected %+v but got %+v", expectedVec, r.Vec)
+		}
+		if len(a.Dict) != 2 {
+			t.Errorf("Expected a to be left unchanged but got %+v", a.Dict)
+		}
+	})
+	t.Run("indices out of range", func(t *testing.T) {
+		a := tools.Matrix{Len: 1, Dict: map[string]int{"hi": 1}, Vec: []float64{1}}
+		for _, dict := range []map[string]int{{"you": 0}, {"you": 2}} {
+			b := tools.Matrix{Len: 1, Dict: dict, Vec: []float64{1}}
+			if _, err := tools.MergeMatrix(a, b); err == nil {
+				t.Errorf("Expected %+v to be rejected", dict)
+			}
+		}
+	})
 }
@@ -0,0 +1,108 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/database"
+)
+
+func TestMigrations(t *testing.T) {
+	migrations, err := database.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("expected migration %d but got %d_%s", i+1, m.Version, m.Name)
+		}
+		if m.Up == "" || m.Down == "" {
+			t.Errorf("migration %d_%s is missing its up or down sql", m.Version, m.Name)
+		}
+	}
+
+	latest, err := database.LatestVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if latest != migrations[len(migrations)-1].Version {
+		t.Errorf("expected latest version %d but got %d", migrations[len(migrations)-1].Version, latest)
+	}
+}
+
+func TestCreateMigration(t *testing.T) {
+	dir := t.TempDir()
+	latest, _ := database.LatestVersion()
+
+	up, down, err := database.CreateMigration(dir, "add_things")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(up) != filepath.Base(down[:len(down)-len(".down.sql")])+".up.sql" {
+		t.Errorf("up %s and down %s don't belong together", up, down)
+	}
+	for _, f := range []string{up, down} {
+		if _, err := os.Stat(f); err != nil {
+			t.Error(err)
+		}
+	}
+
+	// a second migration created before rebuilding has to get the next version
+	up2, _, err := database.CreateMigration(dir, "more_things")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if up2 != filepath.Join(dir, fmt.Sprintf("%04d_more_things.up.sql", latest+2)) {
+		t.Errorf("unexpected file name %s", up2)
+	}
+
+	_, _, err = database.CreateMigration(dir, "no spaces")
+	if err == nil {
+		t.Error("expected an error for an invalid name")
+	}
+}
+
+func TestServer_Migrate(t *testing.T) {
+	container, ctx := InitTestContainer(t)
+	URL, err := container.ConnectionString(*ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := database.ConnectToDB(&sqlx.Conn{}, URL)
+	defer db.Close()
+
+	err = database.CheckSchemaVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	latest, _ := database.LatestVersion()
+	done, err := database.MigrateDown(db, int(latest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(done)) != latest {
+		t.Errorf("expected %d rollbacks but got %d", latest, len(done))
+	}
+	if database.CheckSchemaVersion(db) == nil {
+		t.Error("expected a version mismatch after rolling back")
+	}
+
+	done, err = database.MigrateUp(db, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(done)) != latest {
+		t.Errorf("expected %d migrations but got %d", latest, len(done))
+	}
+	version, err := database.SchemaVersion(db)
+	if err != nil || version != latest {
+		t.Errorf("expected version %d but got %d (%v)", latest, version, err)
+	}
+}
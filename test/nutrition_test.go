@@ -0,0 +1,158 @@
+package test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/madswillem/recipeApp/internal/recipe"
+)
+
+func TestCalculateNutrition(t *testing.T) {
+	nutrition := map[string]recipe.IngredientDB{
+		"spaghetti": {Name: "Spaghetti", StandardUnit: "g", NutritionalValue: recipe.NutritionalValue{
+			ID: "1", Kcal: 350, Kj: 1480, Fat: 1.5, Carbohydrate: 71, Sugar: 3, Protein: 13, Salt: 0.01}},
+		"egg": {Name: "Egg", StandardUnit: "piece", NutritionalValue: recipe.NutritionalValue{
+			ID: "2", Kcal: 80, Kj: 330, Fat: 5.5, SaturatedFat: 1.6, Protein: 7, Salt: 0.18}},
+		"salt": {Name: "salt", StandardUnit: "g", NutritionalValue: recipe.NutritionalValue{
+			ID: "3", Salt: 100}},
+		"garlic": {Name: "Garlic", StandardUnit: "g"},
+	}
+
+	r := &recipe.RecipeSchema{
+		ID:    "recipe",
+		Yield: 4,
+		Ingredients: []recipe.IngredientsSchema{
+			{Name: "Spaghetti", Amount: 400, Unit: "g"},
+			{Name: "Egg", Amount: 4, Unit: "large"},
+			{Name: "Salt", Amount: 1, Unit: "tsp"},
+		},
+	}
+
+	nv := recipe.CalculateNutrition(r, nutrition)
+	expected := recipe.NutritionalValue{
+		Kcal:         (350*4 + 80*4) / 4.0,
+		Kj:           (1480*4 + 330*4) / 4.0,
+		Fat:          (1.5*4 + 5.5*4) / 4.0,
+		SaturatedFat: 1.6,
+		Carbohydrate: 71,
+		Sugar:        3,
+		Protein:      (13*4 + 7*4) / 4.0,
+		Salt:         (0.01*4 + 0.18*4 + 5) / 4.0,
+	}
+	values := map[string][2]float64{
+		"kcal":          {expected.Kcal, nv.Kcal},
+		"kj":            {expected.Kj, nv.Kj},
+		"fat":           {expected.Fat, nv.Fat},
+		"saturated_fat": {expected.SaturatedFat, nv.SaturatedFat},
+		"carbohydrate":  {expected.Carbohydrate, nv.Carbohydrate},
+		"sugar":         {expected.Sugar, nv.Sugar},
+		"protein":       {expected.Protein, nv.Protein},
+		"salt":          {expected.Salt, nv.Salt},
+	}
+	for name, v := range values {
+		if math.Abs(v[0]-v[1]) > 1e-9 {
+			t.Errorf("Expected %s %f but got %f", name, v[0], v[1])
+		}
+	}
+	if nv.Incomplete || len(nv.Missing) != 0 {
+		t.Errorf("Expected complete nutrition but got missing %v", nv.Missing)
+	}
+	if nv.RecipeID == nil || *nv.RecipeID != "recipe" {
+		t.Errorf("Expected recipe id to be set")
+	}
+
+	t.Run("incomplete", func(t *testing.T) {
+		r := &recipe.RecipeSchema{
+			Ingredients: []recipe.IngredientsSchema{
+				{Name: "Spaghetti", Amount: 100, Unit: "g"},
+				{Name: "Garlic", Amount: 2, Unit: "cloves"},
+				{Name: "Pancetta", Amount: 150, Unit: "g"},
+				{Name: "Egg", Amount: 100, Unit: "g"},
+			},
+		}
+		nv := recipe.CalculateNutrition(r, nutrition)
+		if !nv.Incomplete {
+			t.Error("Expected nutrition to be incomplete")
+		}
+		// Garlic has no data, Pancetta is unknown and eggs are counted not weighed
+		if len(nv.Missing) != 3 || nv.Missing[0] != "Garlic" || nv.Missing[1] != "Pancetta" || nv.Missing[2] != "Egg" {
+			t.Errorf("Expected Garlic, Pancetta and Egg to be missing but got %v", nv.Missing)
+		}
+		if nv.NutriscorePoints != nil || nv.Nutriscore != "" {
+			t.Errorf("Expected no score from incomplete values but got %v (%s)", nv.NutriscorePoints, nv.Nutriscore)
+		}
+		if nv.Kcal != 350 {
+			t.Errorf("Expected the known ingredients to be summed up to 350 kcal but got %f", nv.Kcal)
+		}
+	})
+
+	t.Run("servings", func(t *testing.T) {
+		cases := []struct {
+			yield    int
+			unit     string
+			servings float64
+		}{
+			{0, "", 1},
+			{4, "", 4},
+			{4, "servings", 4},
+			{500, "g", 5},
+			{1, "kg", 10},
+		}
+		for _, c := range cases {
+			r := recipe.RecipeSchema{Yield: c.yield, YieldUnit: c.unit}
+			if r.Servings() != c.servings {
+				t.Errorf("Expected %d %s to be %f servings but got %f", c.yield, c.unit, c.servings, r.Servings())
+			}
+		}
+	})
+}
+
+func TestNutriScore(t *testing.T) {
+	cases := []struct {
+		name   string
+		in     recipe.NutriScoreInput
+		points int
+		letter string
+	}{
+		{"empty", recipe.NutriScoreInput{}, 0, "B"},
+		{"vegetables", recipe.NutriScoreInput{EnergyKj: 100, Sugar: 3, Fiber: 3, Protein: 2, FruitVegNut: 100}, -9, "A"},
+		// 3 energy + 1 sugar + 2 saturated fat + 4 sodium - 1 fiber - 5 protein
+		{"pasta", recipe.NutriScoreInput{EnergyKj: 1100, Sugar: 5, SaturatedFat: 2.5, Salt: 1, Fiber: 1, Protein: 10}, 4, "C"},
+		// 11 negative points without enough vegetables, protein doesn't count
+		{"sausage", recipe.NutriScoreInput{EnergyKj: 1400, SaturatedFat: 8, Salt: 1, Protein: 15}, 15, "D"},
+		{"cake", recipe.NutriScoreInput{EnergyKj: 2000, Sugar: 40, SaturatedFat: 12, Salt: 0.5}, 25, "E"},
+	}
+	for _, c := range cases {
+		points, letter := recipe.NutriScore(c.in)
+		if points != c.points || letter != c.letter {
+			t.Errorf("%s: expected %d (%s) but got %d (%s)", c.name, c.points, c.letter, points, letter)
+		}
+	}
+
+	t.Run("recipe", func(t *testing.T) {
+		nutrition := map[string]recipe.IngredientDB{
+			"tomato": {Name: "tomato", StandardUnit: "g", Category: "Vegetables and Vegetable Products",
+				NutritionalValue: recipe.NutritionalValue{ID: "1", Kj: 75, Sugar: 2.6, Protein: 0.9, Fiber: 1.2}},
+			"olive oil": {Name: "olive oil", StandardUnit: "ml", Category: "Fats and Oils",
+				NutritionalValue: recipe.NutritionalValue{ID: "2", Kj: 3700, Fat: 100, SaturatedFat: 14}},
+			"egg": {Name: "egg", StandardUnit: "piece", NutritionalValue: recipe.NutritionalValue{ID: "3", Kj: 330}},
+		}
+		r := &recipe.RecipeSchema{Ingredients: []recipe.IngredientsSchema{
+			{Name: "Tomato", Amount: 900, Unit: "g"},
+			{Name: "Olive oil", Amount: 100, Unit: "ml"},
+			{Name: "Egg", Amount: 2, Unit: "piece"},
+		}}
+		nv := recipe.CalculateNutrition(r, nutrition)
+		// per 100 g: 437.5 kJ, 2.34 g sugar, 1.4 g saturated fat, 1.08 g fiber, 90 % vegetables
+		expected, letter := recipe.NutriScore(recipe.NutriScoreInput{
+			EnergyKj: 437.5, Sugar: 2.34, SaturatedFat: 1.4, Fiber: 1.08, Protein: 0.81, FruitVegNut: 90})
+		if nv.NutriscorePoints == nil || *nv.NutriscorePoints != expected || nv.Nutriscore != letter {
+			t.Errorf("Expected %d (%s) but got %v (%s)", expected, letter, nv.NutriscorePoints, nv.Nutriscore)
+		}
+
+		nv = recipe.CalculateNutrition(&recipe.RecipeSchema{Ingredients: r.Ingredients[2:]}, nutrition)
+		if nv.NutriscorePoints != nil || nv.Nutriscore != "" {
+			t.Errorf("Expected no score without weighable ingredients but got %v (%s)", nv.NutriscorePoints, nv.Nutriscore)
+		}
+	})
+}
@@ -0,0 +1,352 @@
+package test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/auth"
+	"github.com/madswillem/recipeApp/internal/database"
+)
+
+// mockOIDC is an OpenID Connect provider that signs in whoever Subject, Email and EmailVerified name
+type mockOIDC struct {
+	*httptest.Server
+	t        *testing.T
+	key      *rsa.PrivateKey
+	kid      string
+	clientID string
+	secret   string
+
+	mu            sync.Mutex
+	issued        int
+	codes         map[string]url.Values
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+func newMockOIDC(t *testing.T) *mockOIDC {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockOIDC{t: t, key: key, kid: "mock-1", clientID: "recipe-app", secret: "s3cret", codes: map[string]url.Values{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                           m.URL,
+			"authorization_endpoint":           m.URL + "/authorize",
+			"token_endpoint":                   m.URL + "/token",
+			"jwks_uri":                         m.URL + "/jwks",
+			"code_challenge_methods_supported": []string{"S256"},
+		})
+	})
+	mux.HandleFunc("/authorize", m.authorize)
+	mux.HandleFunc("/token", m.token)
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(auth.JWKS{Keys: []auth.JWK{{
+			KeyType:   "RSA",
+			KeyID:     m.kid,
+			Use:       "sig",
+			Algorithm: "RS256",
+			N:         base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}}})
+	})
+	m.Server = httptest.NewServer(mux)
+	t.Cleanup(m.Close)
+	return m
+}
+
+// authorize signs the user in at once and sends them back with a code
+func (m *mockOIDC) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != m.clientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" ||
+		!strings.Contains(q.Get("scope"), "openid") || q.Get("nonce") == "" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	m.mu.Lock()
+	m.issued++
+	code := fmt.Sprintf("code-%d", m.issued)
+	m.codes[code] = q
+	m.mu.Unlock()
+	http.Redirect(w, r, q.Get("redirect_uri")+"?code="+code+"&state="+url.QueryEscape(q.Get("state")), http.StatusFound)
+}
+
+func (m *mockOIDC) token(w http.ResponseWriter, r *http.Request) {
+	user, pass, _ := r.BasicAuth()
+	r.ParseForm()
+	m.mu.Lock()
+	q, ok := m.codes[r.PostForm.Get("code")]
+	delete(m.codes, r.PostForm.Get("code"))
+	m.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case user != m.clientID || pass != m.secret:
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	case !ok || r.PostForm.Get("redirect_uri") != q.Get("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(sum[:]) != q.Get("code_challenge"):
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "unused",
+		"token_type":   "Bearer",
+		"id_token":     m.sign(m.claims(q.Get("nonce")), m.kid),
+	})
+}
+
+func (m *mockOIDC) claims(nonce string) jwt.MapClaims {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            m.URL,
+		"aud":            m.clientID,
+		"sub":            m.Subject,
+		"email":          m.Email,
+		"email_verified": m.EmailVerified,
+		"nonce":          nonce,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+	}
+}
+
+func (m *mockOIDC) sign(claims jwt.MapClaims, kid string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	s, err := token.SignedString(m.key)
+	if err != nil {
+		m.t.Fatal(err)
+	}
+	return s
+}
+
+func (m *mockOIDC) provider(t *testing.T) *auth.OIDCProvider {
+	p, err := auth.NewOIDCProvider(auth.OIDCProviderConfig{
+		Name:         "mock",
+		Issuer:       m.URL,
+		ClientID:     m.clientID,
+		ClientSecret: m.secret,
+		RedirectURL:  "http://app.test/login/callback",
+	}, m.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestOIDCProvider(t *testing.T) {
+	m := newMockOIDC(t)
+	m.Subject, m.Email, m.EmailVerified = "alice", "alice@example.com", true
+	p := m.provider(t)
+	ctx := context.Background()
+
+	t.Run("code flow with PKCE", func(t *testing.T) {
+		const verifier = "verifier-verifier-verifier-verifier-verifier"
+		code := func() string {
+			u, err := p.AuthCodeURL(ctx, "state", "nonce", verifier)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req, _ := http.NewRequest(http.MethodGet, u, nil)
+			res, err := m.Client().Transport.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			back, _ := url.Parse(res.Header.Get("Location"))
+			if back.Query().Get("state") != "state" {
+				t.Fatalf("Expected to be sent back with the state but got %s", back)
+			}
+			return back.Query().Get("code")
+		}
+
+		if _, err := p.Exchange(ctx, code(), "another-verifier"); err == nil {
+			t.Error("Expected the exchange to fail with the wrong verifier")
+		}
+		c := code()
+		idToken, err := p.Exchange(ctx, c, verifier)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims, err := p.VerifyIDToken(ctx, idToken, "nonce"); err != nil || claims.Subject != "alice" {
+			t.Errorf("Expected the ID token of alice but got %+v %v", claims, err)
+		}
+		if _, err := p.Exchange(ctx, c, verifier); err == nil {
+			t.Error("Expected the code to be single use")
+		}
+	})
+
+	t.Run("ID token validation", func(t *testing.T) {
+		valid := m.claims("nonce")
+		claims, err := p.VerifyIDToken(ctx, m.sign(valid, m.kid), "nonce")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims.Subject != "alice" || claims.Email != "alice@example.com" || !claims.EmailVerified {
+			t.Errorf("Expected alice with a verified email but got %+v", claims)
+		}
+
+		with := func(k string, v any) jwt.MapClaims {
+			c := jwt.MapClaims{}
+			for key, value := range valid {
+				c[key] = value
+			}
+			c[k] = v
+			return c
+		}
+		tokens := map[string]string{
+			"other nonce":    m.sign(with("nonce", "other"), m.kid),
+			"other audience": m.sign(with("aud", "other-client"), m.kid),
+			"other issuer":   m.sign(with("iss", "https://evil.example.com"), m.kid),
+			"expired":        m.sign(with("exp", time.Now().Add(-time.Hour).Unix()), m.kid),
+			"unknown key":    m.sign(valid, "mock-2"),
+			"foreign azp":    m.sign(with("aud", []string{m.clientID, "other-client"}), m.kid),
+		}
+		hs, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, valid).SignedString([]byte(m.secret))
+		tokens["HS256 with the client secret"] = hs
+		for name, token := range tokens {
+			if _, err := p.VerifyIDToken(ctx, token, "nonce"); err == nil {
+				t.Errorf("%s: expected the token to be rejected", name)
+			}
+		}
+	})
+}
+
+func TestOIDCLogin(t *testing.T) {
+	container, ctxp := InitTestContainer(t)
+	dbURL, err := container.ConnectionString(*ctxp, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := database.ConnectToDB(&sqlx.Conn{}, dbURL)
+	m := newMockOIDC(t)
+	o, err := auth.NewOIDCAuth(auth.NewAuth([]byte("test-key")), m.provider(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/signup", func(c *gin.Context) { o.Signup(c, db) })
+	r.POST("/login", func(c *gin.Context) { o.Login(c, db) })
+	r.GET("/login", func(c *gin.Context) { o.Login(c, db) })
+	r.GET("/login/callback", func(c *gin.Context) { o.Callback(c, db) })
+	do := func(method string, path string, body string, cookie string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "oidc_state", Value: cookie})
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+	// signIn goes through the provider and returns the callback path and the state cookie
+	signIn := func() (string, string) {
+		w := do(http.MethodGet, "/login?provider=mock", "", "")
+		if w.Code != http.StatusFound {
+			t.Fatalf("Expected to be sent to the provider but got %d %s", w.Code, w.Body.String())
+		}
+		var state string
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "oidc_state" {
+				state = c.Value
+			}
+		}
+		req, _ := http.NewRequest(http.MethodGet, w.Header().Get("Location"), nil)
+		res, err := m.Client().Transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		back, _ := url.Parse(res.Header.Get("Location"))
+		return back.Path + "?" + back.RawQuery, state
+	}
+	login := func() (tokenResponse, *httptest.ResponseRecorder) {
+		callback, state := signIn()
+		w := do(http.MethodGet, callback, "", state)
+		var tokens tokenResponse
+		json.NewDecoder(strings.NewReader(w.Body.String())).Decode(&tokens)
+		return tokens, w
+	}
+
+	t.Run("first sign in creates the user", func(t *testing.T) {
+		m.Subject, m.Email, m.EmailVerified = "new-user", "new@example.com", true
+		tokens, w := login()
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected to be signed in but got %d %s", w.Code, w.Body.String())
+		}
+		apiErr, u := o.Verify(db, tokens.Token)
+		if apiErr != nil || u.Email != "new@example.com" {
+			t.Fatalf("Expected a valid token of the new user but got %v %+v", apiErr, u)
+		}
+
+		again, _ := login()
+		_, u2 := o.Verify(db, again.Token)
+		if u2.ID != u.ID {
+			t.Errorf("Expected the second sign in to be the same user but got %s and %s", u.ID, u2.ID)
+		}
+	})
+
+	t.Run("existing accounts are linked by verified email", func(t *testing.T) {
+		if w := do(http.MethodPost, "/signup", `{"email": "linked@example.com", "password": "secret123"}`, ""); w.Code != http.StatusCreated {
+			t.Fatalf("Expected the password user to be created but got %d", w.Code)
+		}
+		var id string
+		db.Get(&id, `SELECT id FROM public.user WHERE email = 'linked@example.com'`)
+
+		m.Subject, m.Email, m.EmailVerified = "unverified", "linked@example.com", false
+		if _, w := login(); w.Code != http.StatusConflict {
+			t.Errorf("Expected an unverified email not to be linked but got %d %s", w.Code, w.Body.String())
+		}
+
+		m.Subject, m.EmailVerified = "linked", true
+		tokens, w := login()
+		_, u := o.Verify(db, tokens.Token)
+		if w.Code != http.StatusOK || u.ID != id {
+			t.Fatalf("Expected to be signed in as the existing user but got %d %s", w.Code, w.Body.String())
+		}
+		// The password was set by someone who never proved the email was theirs
+		if w := do(http.MethodPost, "/login", `{"email": "linked@example.com", "password": "secret123"}`, ""); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected the unverified password to stop working but got %d", w.Code)
+		}
+	})
+
+	t.Run("state", func(t *testing.T) {
+		m.Subject, m.Email, m.EmailVerified = "new-user", "new@example.com", true
+		callback, state := signIn()
+		if w := do(http.MethodGet, callback, "", "other-browser"); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected a callback without the state cookie to fail but got %d", w.Code)
+		}
+		if w := do(http.MethodGet, callback, "", state); w.Code != http.StatusOK {
+			t.Fatalf("Expected the callback to work but got %d %s", w.Code, w.Body.String())
+		}
+		if w := do(http.MethodGet, callback, "", state); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected the state to be single use but got %d", w.Code)
+		}
+		if w := do(http.MethodGet, "/login?provider=unknown", "", ""); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected an unknown provider to fail but got %d", w.Code)
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/policy"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/server"
+	"github.com/madswillem/recipeApp/internal/user"
+)
+
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		role    string
+		rel     policy.Relation
+		res     policy.Resource
+		act     policy.Action
+		allowed bool
+	}{
+		{user.RoleUser, policy.None, policy.Recipe, policy.Create, true},
+		{"", policy.None, policy.Recipe, policy.Create, false},
+		{user.RoleUser, policy.None, policy.Recipe, policy.Update, false},
+		{user.RoleUser, policy.Editor, policy.Recipe, policy.Update, true},
+		{user.RoleUser, policy.Editor, policy.Recipe, policy.Delete, false},
+		{user.RoleUser, policy.Editor, policy.Recipe, policy.ManageEditors, false},
+		{user.RoleUser, policy.Coauthor, policy.Recipe, policy.ManageEditors, true},
+		{user.RoleUser, policy.Owner, policy.Recipe, policy.Delete, true},
+		{user.RoleModerator, policy.None, policy.Recipe, policy.Delete, true},
+		{user.RoleModerator, policy.None, policy.Recipe, policy.ManageEditors, false},
+		{user.RoleUser, policy.None, policy.Ingredient, policy.Create, false},
+		{user.RoleModerator, policy.None, policy.Ingredient, policy.Update, true},
+		{user.RoleModerator, policy.None, policy.Ingredient, policy.Delete, false},
+		{user.RoleAdmin, policy.None, policy.Ingredient, policy.Merge, true},
+		{user.RoleModerator, policy.None, policy.Diet, policy.Update, false},
+		{user.RoleModerator, policy.None, policy.Technique, policy.Create, true},
+		{user.RoleAdmin, policy.None, policy.User, policy.UpdateRole, true},
+		{"superuser", policy.None, policy.Diet, policy.Create, false},
+	}
+	for _, tt := range tests {
+		allowed, err := policy.Default.Allowed(tt.role, tt.rel, tt.res, tt.act)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != tt.allowed {
+			t.Errorf("Expected %q with relation %d to %s a %s to be %t", tt.role, tt.rel, tt.act, tt.res, tt.allowed)
+		}
+	}
+
+	if _, err := policy.Default.Allowed(user.RoleAdmin, policy.None, policy.Allergen, policy.Delete); err == nil {
+		t.Error("Expected an action without a rule to fail")
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	container, ctxp := InitTestContainer(t)
+	dbURL, err := container.ConnectionString(*ctxp, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := database.ConnectToDB(&sqlx.Conn{}, dbURL)
+	s := server.Server{NewDB: db}
+	s.RecipeRepo = recipe.NewRecipeRepo(db)
+	s.IngredientRepo = recipe.NewIngredientDBRepo(db)
+
+	const editorID = "5b3f8d2e-0c41-4a7e-9d6b-1e2f3a4b5c6d"
+	const moderatorID = "7c4e9f3a-1d52-4b8f-8e7c-2f3a4b5c6d7e"
+	const carbonara = "aa85daf1-dbc5-462d-a6fe-3fbb358b08dd"
+	db.MustExec(`INSERT INTO "user" (id, email, role) VALUES ($1, 'editor@example.com', 'user'), ($2, 'mod@example.com', 'moderator')`, editorID, moderatorID)
+
+	gin.SetMode(gin.TestMode)
+	do := func(userID string, method string, path string, body string) *httptest.ResponseRecorder {
+		r := gin.New()
+		setUser := func(c *gin.Context) {
+			if userID != "" {
+				c.Set("user", user.UserModel{ID: userID})
+			}
+		}
+		r.PATCH("/update/:id", setUser, s.Authorize(policy.Recipe, policy.Update), s.UpdateRecipe)
+		r.DELETE("/delete/:id", setUser, s.Authorize(policy.Recipe, policy.Delete), s.DeleteRecipe)
+		r.POST("/recipes/:id/editors", setUser, s.Authorize(policy.Recipe, policy.ManageEditors), s.AddEditor)
+		r.DELETE("/recipes/:id/editors/:user", setUser, s.Authorize(policy.Recipe, policy.ManageEditors), s.RemoveEditor)
+		r.POST("/ingredients", setUser, s.Authorize(policy.Ingredient, policy.Create), s.AddIngredient)
+		r.PUT("/users/:id/role", setUser, s.Authorize(policy.User, policy.UpdateRole), s.SetRole)
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("not logged in", func(t *testing.T) {
+		if w := do("", http.MethodPatch, "/update/"+carbonara, `{"name":"x"}`); w.Code != http.StatusUnauthorized {
+			t.Errorf("Expected %d but got %d", http.StatusUnauthorized, w.Code)
+		}
+	})
+
+	t.Run("missing recipes are a 404 for everybody", func(t *testing.T) {
+		for _, path := range []string{"/delete/3f0c4a1e-2b3c-4d5e-8f90-a1b2c3d4e5f6", "/delete/not-an-id"} {
+			if w := do(editorID, http.MethodDelete, path, ""); w.Code != http.StatusNotFound {
+				t.Errorf("Expected %d for %s but got %d", http.StatusNotFound, path, w.Code)
+			}
+		}
+	})
+
+	t.Run("editors", func(t *testing.T) {
+		if w := do(editorID, http.MethodPatch, "/update/"+carbonara, `{"name":"x"}`); w.Code != http.StatusForbidden {
+			t.Fatalf("Expected others to be forbidden but got %d", w.Code)
+		}
+		if w := do(editorID, http.MethodPost, "/recipes/"+carbonara+"/editors", `{"user_id":"`+editorID+`"}`); w.Code != http.StatusForbidden {
+			t.Fatalf("Expected others not to make themselves editors but got %d", w.Code)
+		}
+		if w := do(testUserID, http.MethodPost, "/recipes/"+carbonara+"/editors", `{"email":"editor@example.com"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected editors to be given by id, not email, but got %d", w.Code)
+		}
+		w := do(testUserID, http.MethodPost, "/recipes/"+carbonara+"/editors", `{"user_id":"`+editorID+`"}`)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"role":"editor"`) {
+			t.Fatalf("Expected the author to add an editor but got %d %s", w.Code, w.Body.String())
+		}
+
+		if w := do(editorID, http.MethodPatch, "/update/"+carbonara, `{"name":"Edited Carbonara"}`); w.Code != http.StatusOK {
+			t.Errorf("Expected the editor to change the recipe but got %d %s", w.Code, w.Body.String())
+		}
+		if w := do(editorID, http.MethodDelete, "/delete/"+carbonara, ""); w.Code != http.StatusForbidden {
+			t.Errorf("Expected the editor not to delete the recipe but got %d", w.Code)
+		}
+		if w := do(editorID, http.MethodDelete, "/recipes/"+carbonara+"/editors/"+editorID, ""); w.Code != http.StatusForbidden {
+			t.Errorf("Expected editors not to manage editors but got %d", w.Code)
+		}
+		if w := do(testUserID, http.MethodDelete, "/recipes/"+carbonara+"/editors/"+editorID, ""); w.Code != http.StatusNoContent {
+			t.Errorf("Expected the author to remove the editor but got %d", w.Code)
+		}
+		if w := do(editorID, http.MethodPatch, "/update/"+carbonara, `{"name":"x"}`); w.Code != http.StatusForbidden {
+			t.Errorf("Expected a removed editor to be forbidden but got %d", w.Code)
+		}
+	})
+
+	t.Run("roles", func(t *testing.T) {
+		if w := do(testUserID, http.MethodPost, "/ingredients", `{"name":"Saffron"}`); w.Code != http.StatusForbidden {
+			t.Errorf("Expected users not to create ingredients but got %d", w.Code)
+		}
+		if w := do(moderatorID, http.MethodPost, "/ingredients", `{"name":"Saffron"}`); w.Code != http.StatusCreated {
+			t.Errorf("Expected moderators to create ingredients but got %d %s", w.Code, w.Body.String())
+		}
+		if w := do(moderatorID, http.MethodPut, "/users/"+editorID+"/role", `{"role":"moderator"}`); w.Code != http.StatusForbidden {
+			t.Errorf("Expected moderators not to change roles but got %d", w.Code)
+		}
+		if w := do(testAdminID, http.MethodPut, "/users/"+testAdminID+"/role", `{"role":"user"}`); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected admins not to change their own role but got %d", w.Code)
+		}
+		if w := do(testAdminID, http.MethodPut, "/users/"+editorID+"/role", `{"role":"moderator"}`); w.Code != http.StatusOK {
+			t.Fatalf("Expected admins to change roles but got %d %s", w.Code, w.Body.String())
+		}
+		if w := do(editorID, http.MethodDelete, "/delete/"+carbonara, ""); w.Code != http.StatusOK {
+			t.Errorf("Expected the new moderator to delete the recipe but got %d %s", w.Code, w.Body.String())
+		}
+	})
+}
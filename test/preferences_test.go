@@ -0,0 +1,181 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/server"
+	"github.com/madswillem/recipeApp/internal/user"
+)
+
+func TestParseInterval(t *testing.T) {
+	cases := map[string]time.Duration{
+		"00:30:00":       30 * time.Minute,
+		"01:05:03":       time.Hour + 5*time.Minute + 3*time.Second,
+		"1 day 02:00:00": 26 * time.Hour,
+		"2 days":         48 * time.Hour,
+		"00:00:10.5":     10 * time.Second,
+	}
+	for s, expected := range cases {
+		d, ok := recipe.ParseInterval(s)
+		if !ok || d != expected {
+			t.Errorf("%s: expected %s but got %s", s, expected, d)
+		}
+	}
+	for _, s := range []string{"", "30 minutes", "1:30"} {
+		if _, ok := recipe.ParseInterval(s); ok {
+			t.Errorf("Expected %q to be invalid", s)
+		}
+	}
+}
+
+func TestUserSettings(t *testing.T) {
+	maxPrep := "00:30:00"
+	settings := user.UserSettings{
+		Allergies:           []string{"peanuts"},
+		Diets:               []recipe.DietSchema{{ID: vegetarian}},
+		DislikedIngredients: []user.DislikedIngredient{{ID: "olives", Name: "Olives"}},
+		MaxPrepTime:         &maxPrep,
+	}
+
+	t.Run("defaults of the filter", func(t *testing.T) {
+		f := recipe.Filter{}
+		settings.ApplyTo(&f)
+		if f.Diets == nil || (*f.Diets)[0] != vegetarian || *f.PrepTime != maxPrep {
+			t.Errorf("Expected the diets and max prep time as defaults but got %+v", f)
+		}
+		if strings.Join(f.ExcludeIngredients, ",") != "olives" || strings.Join(f.ExcludeAllergens, ",") != "peanuts" {
+			t.Errorf("Expected the olives and peanuts to be excluded but got %+v", f)
+		}
+
+		diets := []string{}
+		prep := "01:00:00"
+		f = recipe.Filter{Diets: &diets, PrepTime: &prep}
+		settings.ApplyTo(&f)
+		if len(*f.Diets) != 0 || *f.PrepTime != prep {
+			t.Errorf("Expected the filter to win over the preferences but got %+v", f)
+		}
+
+		f = recipe.Filter{IgnorePreferences: true}
+		settings.ApplyTo(&f)
+		if f.Diets != nil || f.PrepTime != nil || len(f.ExcludeIngredients) != 0 || len(f.ExcludeAllergens) != 1 {
+			t.Errorf("Expected only the allergies to be applied but got %+v", f)
+		}
+	})
+
+	t.Run("recommendable recipes", func(t *testing.T) {
+		salad := recipe.RecipeSchema{
+			PrepTime:    "00:15:00",
+			Diet:        []recipe.DietSchema{{ID: vegetarian, Inferred: true}},
+			Ingredients: []recipe.IngredientsSchema{{IngredientID: "tomato"}},
+		}
+		if !settings.Allows(&salad) {
+			t.Error("Expected the salad to be allowed")
+		}
+		slow := salad
+		slow.PrepTime = "01:00:00"
+		withOlives := salad
+		withOlives.Ingredients = []recipe.IngredientsSchema{{IngredientID: "tomato"}, {IngredientID: "olives"}}
+		conflicting := salad
+		conflicting.Diet = []recipe.DietSchema{{ID: vegetarian, Conflicts: []string{"Pancetta"}}}
+		withPeanuts := salad
+		withPeanuts.Allergens = []recipe.Allergen{{ID: "peanuts"}}
+		for name, r := range map[string]recipe.RecipeSchema{"slow": slow, "olives": withOlives, "conflicting": conflicting, "peanuts": withPeanuts} {
+			if settings.Allows(&r) {
+				t.Errorf("Expected the %s recipe not to be allowed", name)
+			}
+		}
+		if !settings.WithoutPreferences().Allows(&slow) || settings.WithoutPreferences().Allows(&withPeanuts) {
+			t.Error("Expected only the allergies to count without preferences")
+		}
+	})
+}
+
+func TestPreferences(t *testing.T) {
+	container, ctxp := InitTestContainer(t)
+	dbURL, err := container.ConnectionString(*ctxp, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := database.ConnectToDB(&sqlx.Conn{}, dbURL)
+	s := server.Server{NewDB: db, RecipeRepo: recipe.NewRecipeRepo(db)}
+	const pancetta = "5e8cd4c6-51aa-42aa-ac24-ac3997c73341"
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	setUser := func(c *gin.Context) { c.Set("user", user.UserModel{ID: testUserID}) }
+	r.POST("/filter", setUser, s.Filter)
+	r.GET("/user/preferences", setUser, s.GetPreferences)
+	r.PATCH("/user/preferences", setUser, s.UpdatePreferences)
+	r.POST("/user/preferences/disliked_ingredients/:id", setUser, s.AddDislikedIngredient)
+	r.DELETE("/user/preferences/diets/:id", setUser, s.RemovePreferredDiet)
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+	total := func(body string) int {
+		w := do(http.MethodPost, "/filter", body)
+		var page recipe.RecipePage
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil || w.Code != http.StatusOK {
+			t.Fatalf("Expected a page but got %d %s", w.Code, w.Body.String())
+		}
+		return page.Total
+	}
+
+	// The seeded user is vegetarian and both carbonaras contain pancetta
+	w := do(http.MethodGet, "/user/preferences", "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"name":"Vegetarien"`) {
+		t.Fatalf("Expected the vegetarian diet but got %d %s", w.Code, w.Body.String())
+	}
+	if n := total(`{}`); n != 0 {
+		t.Errorf("Expected no vegetarian recipes but got %d", n)
+	}
+	if n := total(`{"ignore_preferences": true}`); n != 2 {
+		t.Errorf("Expected the preferences to be ignored but got %d recipes", n)
+	}
+
+	w = do(http.MethodDelete, "/user/preferences/diets/"+vegetarian, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"diets":[]`) {
+		t.Errorf("Expected the diet to be removed but got %d %s", w.Code, w.Body.String())
+	}
+	if n := total(`{}`); n != 2 {
+		t.Errorf("Expected both recipes without preferences but got %d", n)
+	}
+
+	w = do(http.MethodPost, "/user/preferences/disliked_ingredients/"+pancetta, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"name":"Pancetta"`) {
+		t.Errorf("Expected pancetta to be disliked but got %d %s", w.Code, w.Body.String())
+	}
+	if n := total(`{}`); n != 0 {
+		t.Errorf("Expected the pancetta recipes to be left out but got %d", n)
+	}
+
+	w = do(http.MethodPatch, "/user/preferences", `{"disliked_ingredients": [], "max_prep_time": "00:30:00"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"max_prep_time":"00:30:00"`) {
+		t.Errorf("Expected the max prep time to be set but got %d %s", w.Code, w.Body.String())
+	}
+	// Both carbonaras take an hour
+	if n := total(`{}`); n != 0 {
+		t.Errorf("Expected no recipe within 30 minutes but got %d", n)
+	}
+	if n := total(`{"prep_time": "02:00:00"}`); n != 2 {
+		t.Errorf("Expected the filter to override the max prep time but got %d", n)
+	}
+
+	for _, body := range []string{`{"diets": ["not-a-diet"]}`, `{"max_prep_time": "soon"}`} {
+		if w := do(http.MethodPatch, "/user/preferences", body); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected %d but got %d", body, http.StatusBadRequest, w.Code)
+		}
+	}
+}
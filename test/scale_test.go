@@ -0,0 +1,157 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/server"
+	"github.com/madswillem/recipeApp/internal/units"
+)
+
+func scaleTestRecipe() recipe.RecipeSchema {
+	return recipe.RecipeSchema{
+		ID:    "carbonara",
+		Name:  "Carbonara",
+		Yield: 4,
+		Ingredients: []recipe.IngredientsSchema{
+			{Name: "Spaghetti", Amount: 400, Unit: "g"},
+			{Name: "Egg", Amount: 4, Unit: "large"},
+			{Name: "Salt", Amount: 1, Unit: "tsp"},
+			{Name: "Garlic", Amount: 2, Unit: "cloves"},
+			{Name: "Water", Amount: 250, Unit: "ml"},
+		},
+		NutritionalValue: recipe.NutritionalValue{Kcal: 500, Protein: 20, Nutriscore: "C"},
+	}
+}
+
+func TestScaleRecipe(t *testing.T) {
+	type amount struct {
+		amount float64
+		unit   string
+	}
+	cases := []struct {
+		servings float64
+		expected []amount
+	}{
+		{2, []amount{{200, "g"}, {2, "large"}, {0.5, "tsp"}, {1, "cloves"}, {125, "ml"}}},
+		{10, []amount{{1, "kg"}, {10, "large"}, {2.5, "tsp"}, {5, "cloves"}, {625, "ml"}}},
+		{12, []amount{{1.2, "kg"}, {12, "large"}, {1, "tbsp"}, {6, "cloves"}, {750, "ml"}}},
+		{3, []amount{{300, "g"}, {3, "large"}, {0.75, "tsp"}, {1.5, "cloves"}, {188, "ml"}}},
+	}
+
+	r := scaleTestRecipe()
+	for _, c := range cases {
+		scaled, err := r.Scale(c.servings)
+		if err != nil {
+			t.Fatal(err.Errors[0])
+		}
+		for i, e := range c.expected {
+			got := scaled.Ingredients[i]
+			if got.Amount != e.amount || got.Unit != e.unit {
+				t.Errorf("%v servings: expected %v %s of %s but got %v %s", c.servings, e.amount, e.unit, got.Name, got.Amount, got.Unit)
+			}
+		}
+		if scaled.NutritionTotal.Kcal != 500*c.servings || scaled.NutritionTotal.Nutriscore != "C" {
+			t.Errorf("%v servings: unexpected nutrition total %+v", c.servings, scaled.NutritionTotal)
+		}
+	}
+	if r.Ingredients[0].Amount != 400 {
+		t.Error("Expected scaling not to change the original recipe")
+	}
+
+	for _, servings := range []float64{0, -1, recipe.MaxServings + 1} {
+		if _, err := r.Scale(servings); err == nil || err.Code != http.StatusBadRequest {
+			t.Errorf("Expected %v servings to be rejected", servings)
+		}
+	}
+
+	t.Run("range", func(t *testing.T) {
+		r := scaleTestRecipe()
+		max := 3.0
+		r.Ingredients = []recipe.IngredientsSchema{{Name: "Garlic", Amount: 2, AmountMax: &max, Unit: "cloves"}, {Name: "Stock", Amount: 500, AmountMax: &[]float64{750}[0], Unit: "ml"}}
+		scaled, _ := r.Scale(8)
+		if scaled.Ingredients[0].Amount != 4 || *scaled.Ingredients[0].AmountMax != 6 {
+			t.Errorf("Expected 4-6 cloves but got %s", scaled.Ingredients[0].Quantity())
+		}
+		// The upper end follows the lower one into liters
+		if scaled.Ingredients[1].Unit != "l" || scaled.Ingredients[1].Quantity() != "1-1.5" {
+			t.Errorf("Expected 1-1.5 l stock but got %s %s", scaled.Ingredients[1].Quantity(), scaled.Ingredients[1].Unit)
+		}
+	})
+
+	t.Run("mass yield", func(t *testing.T) {
+		r := scaleTestRecipe()
+		r.Yield, r.YieldUnit = 500, "g"
+		scaled, _ := r.Scale(2)
+		if scaled.Yield != 200 || scaled.Ingredients[0].Amount != 160 {
+			t.Errorf("Expected 200 g with 160 g spaghetti but got %d g with %v", scaled.Yield, scaled.Ingredients[0].Amount)
+		}
+	})
+}
+
+func TestSimplifyUnits(t *testing.T) {
+	cases := []struct {
+		amount       float64
+		unit         string
+		expected     float64
+		expectedUnit string
+	}{
+		{1000, "g", 1, "kg"},
+		{0.5, "kg", 0.5, "kg"},
+		{0.75, "cup", 0.75, "cup"},
+		{0.5, "l", 0.5, "l"},
+		{1500, "ml", 1.5, "l"},
+		{3, "tsp", 1, "tbsp"},
+		{48, "tsp", 1, "cup"},
+		{4, "tbsp", 4, "tbsp"},
+		{2, "pinch", 2, "pinch"},
+		{5, "dl", 5, "dl"},
+		{3, "cloves", 3, "cloves"},
+	}
+	for _, c := range cases {
+		amount, unit := units.Simplify(c.amount, c.unit)
+		if amount != c.expected || unit != c.expectedUnit {
+			t.Errorf("Expected %v %s to be %v %s but got %v %s", c.amount, c.unit, c.expected, c.expectedUnit, amount, unit)
+		}
+	}
+}
+
+func TestServer_GetScaled(t *testing.T) {
+	s := server.Server{RecipeRepo: &fakeRecipeRepo{recipes: []recipe.RecipeSchema{scaleTestRecipe()}}}
+
+	request := func(id string, servings string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		gin.SetMode(gin.TestMode)
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, "/recipes/"+id+"/scaled?servings="+servings, nil)
+		c.Params = gin.Params{gin.Param{Key: "id", Value: id}}
+		s.GetScaled(c)
+		return w
+	}
+
+	w := request("carbonara", "2")
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected %d but got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var scaled recipe.ScaledRecipe
+	json.Unmarshal(w.Body.Bytes(), &scaled)
+	if scaled.Servings != 2 || scaled.Ingredients[0].Amount != 200 || scaled.NutritionTotal.Kcal != 1000 {
+		t.Errorf("Unexpected scaled recipe %+v", scaled)
+	}
+
+	cases := map[string][2]string{
+		"missing servings": {"carbonara", ""},
+		"not a number":     {"carbonara", "two"},
+		"unknown recipe":   {"lasagna", "2"},
+	}
+	expected := map[string]int{"missing servings": http.StatusBadRequest, "not a number": http.StatusBadRequest, "unknown recipe": http.StatusNotFound}
+	for name, c := range cases {
+		if w := request(c[0], c[1]); w.Code != expected[name] {
+			t.Errorf("%s: expected %d but got %d", name, expected[name], w.Code)
+		}
+	}
+}
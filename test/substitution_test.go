@@ -0,0 +1,175 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/madswillem/recipeApp/internal/database"
+	"github.com/madswillem/recipeApp/internal/error_handler"
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/server"
+	"github.com/madswillem/recipeApp/internal/user"
+)
+
+func TestNoSubstituteError(t *testing.T) {
+	subErr := &recipe.NoSubstituteError{Ingredients: []string{"Egg", "Parmesan cheese"}}
+	if subErr.Error() != "no substitute for Egg, Parmesan cheese" {
+		t.Errorf("Unexpected message %s", subErr.Error())
+	}
+
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	error_handler.HandleError(c, http.StatusUnprocessableEntity, "Can't save the variant", []error{subErr})
+	if !strings.Contains(w.Body.String(), `"unresolved":["Egg","Parmesan cheese"]`) {
+		t.Errorf("Expected the unresolved ingredients as details but got %s", w.Body.String())
+	}
+}
+
+func TestSubstitutions(t *testing.T) {
+	container, ctxp := InitTestContainer(t)
+	dbURL, err := container.ConnectionString(*ctxp, "sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := database.ConnectToDB(&sqlx.Conn{}, dbURL)
+	repo := recipe.NewRecipeRepo(db)
+	s := server.Server{NewDB: db, RecipeRepo: repo, IngredientRepo: recipe.NewIngredientDBRepo(db)}
+	const carbonara = "aa85daf1-dbc5-462d-a6fe-3fbb358b08dd"
+	const pancetta = "5e8cd4c6-51aa-42aa-ac24-ac3997c73341"
+	const egg = "ea3f9073-6a75-4625-80d1-19dc42aca7ef"
+
+	mushrooms := recipe.IngredientDB{Name: "Mushrooms", StandardUnit: "g", Category: "Vegetables and Vegetable Products"}
+	salmon := recipe.IngredientDB{Name: "Smoked salmon", StandardUnit: "g", Category: "Finfish and Shellfish Products"}
+	for _, ing := range []*recipe.IngredientDB{&mushrooms, &salmon} {
+		if apiErr := s.IngredientRepo.Create(ing); apiErr != nil {
+			t.Fatal(apiErr.Message)
+		}
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	setUser := func(c *gin.Context) { c.Set("user", user.UserModel{ID: testUserID}) }
+	r.GET("/ingredients/:id/substitutes", s.GetSubstitutes)
+	r.POST("/ingredients/:id/substitutes", s.AddSubstitute)
+	r.GET("/recipes/:id/substitutions", s.GetSubstitutions)
+	r.POST("/recipes/:id/variants", setUser, s.CreateVariant)
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, body := range []string{
+		`{"substitute_id": "` + salmon.ID + `", "ratio": 0.5}`,
+		`{"substitute_id": "` + mushrooms.ID + `", "ratio": 1.5, "note": "Fry them until brown"}`,
+	} {
+		if w := do(http.MethodPost, "/ingredients/"+pancetta+"/substitutes", body); w.Code != http.StatusOK {
+			t.Fatalf("Expected the substitute to be added but got %d %s", w.Code, w.Body.String())
+		}
+	}
+	w := do(http.MethodGet, "/ingredients/"+pancetta+"/substitutes", "")
+	var subs []recipe.Substitute
+	json.NewDecoder(w.Body).Decode(&subs)
+	if len(subs) != 2 || subs[0].Name != "Mushrooms" || len(subs[0].Diets) != 1 || len(subs[1].Diets) != 0 {
+		t.Errorf("Expected the mushrooms to be vegetarian and the salmon not but got %+v", subs)
+	}
+	if w := do(http.MethodPost, "/ingredients/"+pancetta+"/substitutes", `{"substitute_id": "`+pancetta+`"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("Expected an ingredient substituting itself to be rejected but got %d", w.Code)
+	}
+
+	t.Run("diet", func(t *testing.T) {
+		w := do(http.MethodGet, "/recipes/"+carbonara+"/substitutions?diet="+vegetarian, "")
+		var plan recipe.SubstitutionPlan
+		json.NewDecoder(w.Body).Decode(&plan)
+		if w.Code != http.StatusOK || len(plan.Substitutions) != 1 || len(plan.Unresolved) != 0 {
+			t.Fatalf("Expected the pancetta to be substituted but got %d %s", w.Code, w.Body.String())
+		}
+		sub := plan.Substitutions[0]
+		if sub.Reason != recipe.ReasonDiet || sub.Substitute.Name != "Mushrooms" || len(sub.Alternatives) != 1 {
+			t.Errorf("Expected only the mushrooms to follow the diet but got %+v", sub)
+		}
+		for _, ing := range plan.Ingredients {
+			if ing.IngredientID == pancetta {
+				t.Error("Expected the pancetta to be replaced")
+			}
+			if ing.IngredientID == mushrooms.ID && ing.Amount != 225 {
+				t.Errorf("Expected 150 g pancetta to become 225 g mushrooms but got %v", ing.Amount)
+			}
+		}
+	})
+
+	t.Run("excluded ingredients", func(t *testing.T) {
+		w := do(http.MethodGet, "/recipes/"+carbonara+"/substitutions?exclude="+pancetta+","+egg, "")
+		var plan recipe.SubstitutionPlan
+		json.NewDecoder(w.Body).Decode(&plan)
+		if len(plan.Substitutions) != 2 || strings.Join(plan.Unresolved, ",") != "Egg" {
+			t.Fatalf("Expected the egg to have no substitute but got %s", w.Body.String())
+		}
+		if plan.Substitutions[0].Substitute.Name != "Mushrooms" || len(plan.Substitutions[0].Alternatives) != 2 {
+			t.Errorf("Expected the salmon as an alternative without a diet but got %+v", plan.Substitutions[0])
+		}
+
+		w = do(http.MethodPost, "/recipes/"+carbonara+"/variants", `{"exclude": ["`+pancetta+`", "`+egg+`"]}`)
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `"unresolved":["Egg"]`) {
+			t.Errorf("Expected the variant without the egg to be rejected but got %d %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("save a variant", func(t *testing.T) {
+		body := `{"name": "Vegetarian carbonara", "diet": "` + vegetarian + `", "choose": {"` + pancetta + `": "` + salmon.ID + `"}}`
+		if w := do(http.MethodPost, "/recipes/"+carbonara+"/variants", body); w.Code != http.StatusBadRequest {
+			t.Errorf("Expected the salmon to be rejected for the diet but got %d", w.Code)
+		}
+
+		// The steps about the pancetta and the eggs reference their recipe ingredients
+		db.MustExec(`UPDATE step SET ingredient_id = '2de9c1c6-cc35-4038-8fbc-17029984f1d8' WHERE id = '13b29b7b-8ce8-44ba-90ae-c243c98da031'`)
+		db.MustExec(`UPDATE step SET ingredient_id = 'c2f50f80-71dd-4374-a856-bf417a26a5eb' WHERE id = 'e8148c4f-6203-49e9-b50a-aa3a8545e808'`)
+
+		body = `{"name": "Vegetarian carbonara", "diet": "` + vegetarian + `"}`
+		w := do(http.MethodPost, "/recipes/"+carbonara+"/variants", body)
+		if w.Code != http.StatusCreated {
+			t.Fatalf("Expected %d but got %d %s", http.StatusCreated, w.Code, w.Body.String())
+		}
+		var variant recipe.RecipeSchema
+		json.NewDecoder(w.Body).Decode(&variant)
+		if variant.VariantOf == nil || *variant.VariantOf != carbonara || variant.Author != testUserID {
+			t.Errorf("Expected a variant of the carbonara by the user but got %+v", variant)
+		}
+		if len(variant.Diet) != 1 || variant.Diet[0].ID != vegetarian || variant.Diet[0].Inferred || len(variant.Diet[0].Conflicts) != 0 {
+			t.Errorf("Expected the variant to be tagged vegetarian but got %+v", variant.Diet)
+		}
+		if len(variant.Ingredients) != 7 || len(variant.Steps) == 0 {
+			t.Errorf("Expected the ingredients and steps to be copied but got %+v", variant)
+		}
+
+		ingredients := map[string]string{}
+		for _, ing := range variant.Ingredients {
+			ingredients[ing.ID] = ing.IngredientID
+		}
+		referenced := map[string]string{}
+		for _, step := range variant.Steps {
+			if step.IngredientID == nil {
+				continue
+			}
+			if _, ok := ingredients[*step.IngredientID]; !ok {
+				t.Errorf("Expected step %q to reference an ingredient of the variant but got %s", step.Step, *step.IngredientID)
+			}
+			referenced[ingredients[*step.IngredientID]] = step.Step
+		}
+		if len(referenced) != 2 || !strings.Contains(referenced[mushrooms.ID], "pancetta") || !strings.Contains(referenced[egg], "eggs") {
+			t.Errorf("Expected the steps to reference the mushrooms and the eggs of the variant but got %+v", referenced)
+		}
+	})
+
+	if w := do(http.MethodGet, "/recipes/"+carbonara+"/substitutions?diet=bbadd945-5557-459f-951e-000000000000", ""); w.Code != http.StatusNotFound {
+		t.Errorf("Expected an unknown diet to be %d but got %d", http.StatusNotFound, w.Code)
+	}
+}
@@ -0,0 +1,90 @@
+-- Test data, inserted after all migrations ran
+
+INSERT INTO public."user" (id, created_at, cookie, ip, groups) VALUES
+    ('f85a98f8-2572-420a-9ae5-2c997ad96b6d', '2024-07-21 22:21:31.536743', '6uZEqNNvlGeQOCO9fIvY', '127.0.0.1', NULL);
+
+INSERT INTO public."user" (id, created_at, cookie, ip, role) VALUES
+    ('0b7c1e52-3f4d-4b8e-9a61-2d5f8c9e7a10', '2024-07-21 22:25:02.114261', 'Qm3vX8pLr2TnY6cW1zKd', '127.0.0.1', 'admin');
+
+INSERT INTO public.diet (id, created_at, name, description) VALUES
+    ('bbadd945-5557-459f-951e-9ad3ad277059', '2024-08-26 20:38:25.856765', 'Vegetarien', 'A diet woithout fish and meat');
+
+INSERT INTO public.ingredient (id, created_at, name, standard_unit, ndb_number, category, fdic_id) VALUES
+    ('84eb6da1-25b9-40ec-97a1-c0db1844ca54', '2024-06-15 23:34:15.856578', 'tomato', 'piece', '100261', 'Vegetables and Vegetable Products', '1999634'),
+    ('8d7de19b-30f3-4cfd-ae93-c33a8f19a18d', '2024-06-15 23:36:56.172512', 'salt', 'g', '2047', 'Spices and Herbs', '746775'),
+    ('69332cc2-7b6f-42aa-be4d-c2ac2f2954c0', '2024-07-01 15:30:32.231656', 'Spaghetti', 'g', '0', 'Pasta by Shape & Type', '2099117'),
+    ('5e8cd4c6-51aa-42aa-ac24-ac3997c73341', '2024-07-02 14:11:08.757873', 'Pancetta', 'g', '0', 'Pepperoni, Salami & Cold Cuts', '2098421'),
+    ('ea3f9073-6a75-4625-80d1-19dc42aca7ef', '2024-07-02 14:14:10.412584', 'Egg', 'piece', '1123', 'Dairy and Egg Products', '748967'),
+    ('db630404-6115-4ca1-91cd-f9ed8981676f', '2024-07-02 14:16:37.660621', 'Parmesan cheese', 'g', '1032', 'Dairy and Egg Products', '325036'),
+    ('567e990a-20cf-4f85-974f-38189c0bb64b', '2024-07-02 14:18:15.185307', 'Garlic', 'g', '11215', 'Vegetables and Vegetable Products', '1104647'),
+    ('c1ac47d6-2126-48a4-ad73-75da637dee65', '2024-07-02 14:23:16.532751', 'Black pepper', 'g', '0', 'Spices and Herbes', '2157235');
+
+INSERT INTO public.recipes (id, created_at, author, name, cuisine, yield, yield_unit, prep_time, cooking_time, version, selects, views) VALUES
+    ('aa85daf1-dbc5-462d-a6fe-3fbb358b08dd', '2024-07-24 15:49:43.879625', 'f85a98f8-2572-420a-9ae5-2c997ad96b6d', 'Classic Spaghetti Carbonara', 'italian', '500', '', '01:00:00', '01:00:00', '9', '9', '0'),
+    ('c4ef5707-1577-4f8c-99ef-0f492e82b895', '2024-09-01 20:32:48.395312', 'f85a98f8-2572-420a-9ae5-2c997ad96b6d', 'Classic Spaghetti Carbonara 2', 'italian', '500', '', '01:00:00', '01:00:00', '0', '0', '0');
+
+INSERT INTO public.nutritional_value (id, created_at, ingredient_id, recipe_id, kcal, kj, fat, saturated_fat, carbohydrate, sugar, protein, salt, nutriscore) VALUES
+    ('002e607b-8b82-4b29-87a9-bfe50ee30433', '2024-06-16 11:37:49.95385', '84eb6da1-25b9-40ec-97a1-c0db1844ca54', NULL, '22', '92', '0.42', NULL, '3.84', NULL, '0.7', NULL, NULL);
+
+INSERT INTO public.rating (id, created_at, ingredient_id, recipe_id, overall, mon, tue, wed, thu, fri, sat, sun, win, spr, sum, aut, thirtydegree, twentiedegree, tendegree, zerodegree, subzerodegree) VALUES
+    ('489a1860-c881-4057-8607-b6826abfb2cd', '2024-07-01 15:30:32.231656', '69332cc2-7b6f-42aa-be4d-c2ac2f2954c0', NULL, '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000'),
+    ('97f5f257-d319-4fc7-b939-063858e1ae04', '2024-07-02 14:11:08.757873', '5e8cd4c6-51aa-42aa-ac24-ac3997c73341', NULL, '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000'),
+    ('e0ba6c46-36ae-450f-ac62-5a133d51df1f', '2024-07-02 14:14:10.412584', 'ea3f9073-6a75-4625-80d1-19dc42aca7ef', NULL, '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000'),
+    ('58081d5d-73ec-4078-b8c4-58db512c8a2f', '2024-07-02 14:16:37.660621', 'db630404-6115-4ca1-91cd-f9ed8981676f', NULL, '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000'),
+    ('bf64206b-53dc-4b9a-a5e5-2f14fafdd4e4', '2024-07-02 14:18:15.185307', '567e990a-20cf-4f85-974f-38189c0bb64b', NULL, '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000'),
+    ('8e98f6f5-aace-44a7-a09f-e3a71ca12329', '2024-07-02 14:23:16.532751', 'c1ac47d6-2126-48a4-ad73-75da637dee65', NULL, '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000'),
+    ('b0fe7647-5756-4c6c-b576-afc740b8f025', '2024-07-24 15:49:43.879625', NULL, 'aa85daf1-dbc5-462d-a6fe-3fbb358b08dd', '1238.675', '1000', '1000', '1000', '1000', '1000', '1331', '1771.6', '1000', '1000', '2358.1', '1000', '1000', '1000', '2358.1', '1000', '1000'),
+    ('eb3ce3eb-e90a-4bc9-a3d7-744e05b7e201', '2024-09-01 20:32:48.395312', NULL, 'c4ef5707-1577-4f8c-99ef-0f492e82b895', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000', '1000');
+
+INSERT INTO public.recipe_ingredient (id, created_at, recipe_id, ingredient_id, amount, unit) VALUES
+    ('185ae84d-4fe5-4328-ba1d-7af4434cb521', '2024-07-24 15:49:43.879625', 'aa85daf1-dbc5-462d-a6fe-3fbb358b08dd', '69332cc2-7b6f-42aa-be4d-c2ac2f2954c0', '400', 'g'),
+    ('2de9c1c6-cc35-4038-8fbc-17029984f1d8', '2024-07-24 15:49:43.879625', 'aa85daf1-dbc5-462d-a6fe-3fbb358b08dd', '5e8cd4c6-51aa-42aa-ac24-ac3997c73341', '150', 'g'),
+    ('c2f50f80-71dd-4374-a856-bf417a26a5eb', '2024-07-24 15:49:43.879625', 'aa85daf1-dbc5-462d-a6fe-3fbb358b08dd', 'ea3f9073-6a75-4625-80d1-19dc42aca7ef', '4', 'large'),
+    ('ed5fbfb6-2d2d-4467-82cf-9e7a97924724', '2024-07-24 15:49:43.879625', 'aa85daf1-dbc5-462d-a6fe-3fbb358b08dd', 'db630404-6115-4ca1-91cd-f9ed8981676f', '100', 'g'),
+    ('a4dd3925-e377-4380-8f0c-797d266b40e4', '2024-07-24 15:49:43.879625', 'aa85daf1-dbc5-462d-a6fe-3fbb358b08dd', '567e990a-20cf-4f85-974f-38189c0bb64b', '2', 'cloves'),
+    ('69842c21-5832-4c64-9d27-2ffb8abd4617', '2024-07-24 15:49:43.879625', 'aa85daf1-dbc5-462d-a6fe-3fbb358b08dd', '8d7de19b-30f3-4cfd-ae93-c33a8f19a18d', '1', 'tsp'),
+    ('07c807a0-15c2-4db5-8ca6-836499825c46', '2024-07-24 15:49:43.879625', 'aa85daf1-dbc5-462d-a6fe-3fbb358b08dd', 'c1ac47d6-2126-48a4-ad73-75da637dee65', '1', 'tsp'),
+    ('3d88c865-916c-406c-bb28-4252484a8744', '2024-09-01 20:32:48.395312', 'c4ef5707-1577-4f8c-99ef-0f492e82b895', '69332cc2-7b6f-42aa-be4d-c2ac2f2954c0', '400', 'g'),
+    ('652abb1a-9014-47de-b5c9-c8c43721b686', '2024-09-01 20:32:48.395312', 'c4ef5707-1577-4f8c-99ef-0f492e82b895', '5e8cd4c6-51aa-42aa-ac24-ac3997c73341', '150', 'g'),
+    ('6c16fc93-514d-4ffb-98f7-194c1d36257b', '2024-09-01 20:32:48.395312', 'c4ef5707-1577-4f8c-99ef-0f492e82b895', 'ea3f9073-6a75-4625-80d1-19dc42aca7ef', '4', 'large'),
+    ('d973f0cd-f55f-401d-ba65-7f9bafe5240e', '2024-09-01 20:32:48.395312', 'c4ef5707-1577-4f8c-99ef-0f492e82b895', 'db630404-6115-4ca1-91cd-f9ed8981676f', '100', 'g'),
+    ('9cb0edb9-c4dc-4499-8b4e-da5c7daad99d', '2024-09-01 20:32:48.395312', 'c4ef5707-1577-4f8c-99ef-0f492e82b895', '567e990a-20cf-4f85-974f-38189c0bb64b', '2', 'cloves'),
+    ('2125e6f4-04f0-4a2b-a54c-7dbde1b6641e', '2024-09-01 20:32:48.395312', 'c4ef5707-1577-4f8c-99ef-0f492e82b895', '8d7de19b-30f3-4cfd-ae93-c33a8f19a18d', '1', 'tsp'),
+    ('0daaf384-c8bd-419f-b31d-2d3442295a2a', '2024-09-01 20:32:48.395312', 'c4ef5707-1577-4f8c-99ef-0f492e82b895', 'c1ac47d6-2126-48a4-ad73-75da637dee65', '1', 'tsp');
+
+INSERT INTO public.step (id, created_at, step, recipe_id, technique_id, ingredient_id) VALUES
+    ('705897bb-6ec9-4d5f-adfc-0a7b4fa471dc', '2024-07-24 15:49:43.879625', 'Cook the spaghetti according to package directions until al dente. Reserve 1 cup of pasta water, then drain the pasta.', 'aa85daf1-dbc5-462d-a6fe-3fbb358b08dd', NULL, NULL),
+    ('13b29b7b-8ce8-44ba-90ae-c243c98da031', '2024-07-24 15:49:43.879625', 'While the pasta cooks, heat a large skillet over medium heat and add the pancetta. Cook until crispy, then remove from heat and set aside.', 'aa85daf1-dbc5-462d-a6fe-3fbb358b08dd', NULL, NULL),
+    ('e8148c4f-6203-49e9-b50a-aa3a8545e808', '2024-07-24 15:49:43.879625', 'In a bowl, whisk together the eggs and grated Parmesan cheese until well combined.', 'aa85daf1-dbc5-462d-a6fe-3fbb358b08dd', NULL, NULL),
+    ('926126e2-463b-436c-b574-5fbb646f82c8', '2024-07-24 15:49:43.879625', 'Return the skillet with pancetta to low heat. Add the minced garlic and cook until fragrant, about 1 minute.', 'aa85daf1-dbc5-462d-a6fe-3fbb358b08dd', NULL, NULL),
+    ('f508edbc-4c63-4f7e-949c-2f89422d7ad9', '2024-07-24 15:49:43.879625', 'Add the cooked pasta to the skillet and toss to combine with the pancetta and garlic.', 'aa85daf1-dbc5-462d-a6fe-3fbb358b08dd', NULL, NULL),
+    ('5e800272-2815-4220-ae2b-dc08c4ffc80b', '2024-07-24 15:49:43.879625', 'Remove the skillet from heat and quickly pour in the egg and cheese mixture, tossing rapidly to create a creamy sauce. If the sauce is too thick, add a little reserved pasta water until desired consistency is reached.', 'aa85daf1-dbc5-462d-a6fe-3fbb358b08dd', NULL, NULL),
+    ('539c001b-aa6d-4e20-9721-9a34eef5cccc', '2024-07-24 15:49:43.879625', 'Season with salt and freshly ground black pepper to taste. Serve immediately with extra Parmesan cheese on top, if desired.', 'aa85daf1-dbc5-462d-a6fe-3fbb358b08dd', NULL, NULL),
+    ('6617a65c-bc28-4066-bc94-669ba5af86d7', '2024-09-01 20:32:48.395312', 'Cook the spaghetti according to package directions until al dente. Reserve 1 cup of pasta water, then drain the pasta.', 'c4ef5707-1577-4f8c-99ef-0f492e82b895', NULL, NULL),
+    ('02e95737-52bb-4a52-b234-e1bda0d214ab', '2024-09-01 20:32:48.395312', 'While the pasta cooks, heat a large skillet over medium heat and add the pancetta. Cook until crispy, then remove from heat and set aside.', 'c4ef5707-1577-4f8c-99ef-0f492e82b895', NULL, NULL),
+    ('0b271eb6-498d-4d4f-a33a-a9dcbf7605cc', '2024-09-01 20:32:48.395312', 'In a bowl, whisk together the eggs and grated Parmesan cheese until well combined.', 'c4ef5707-1577-4f8c-99ef-0f492e82b895', NULL, NULL),
+    ('c9910490-db84-44a6-bc04-39e602968c7c', '2024-09-01 20:32:48.395312', 'Return the skillet with pancetta to low heat. Add the minced garlic and cook until fragrant, about 1 minute.', 'c4ef5707-1577-4f8c-99ef-0f492e82b895', NULL, NULL),
+    ('567a04dd-92b7-4e00-a75a-2271199d9f47', '2024-09-01 20:32:48.395312', 'Add the cooked pasta to the skillet and toss to combine with the pancetta and garlic.', 'c4ef5707-1577-4f8c-99ef-0f492e82b895', NULL, NULL),
+    ('463158ac-cd78-4294-ab01-f86c41fac8e5', '2024-09-01 20:32:48.395312', 'Remove the skillet from heat and quickly pour in the egg and cheese mixture, tossing rapidly to create a creamy sauce. If the sauce is too thick, add a little reserved pasta water until desired consistency is reached.', 'c4ef5707-1577-4f8c-99ef-0f492e82b895', NULL, NULL),
+    ('ba1b9c28-5f7c-496a-84c0-ba0de4ad482d', '2024-09-01 20:32:48.395312', 'Season with salt and freshly ground black pepper to taste. Serve immediately with extra Parmesan cheese on top, if desired.', 'c4ef5707-1577-4f8c-99ef-0f492e82b895', NULL, NULL);
+
+INSERT INTO public.diet_excluded_category (diet_id, name) VALUES
+    ('bbadd945-5557-459f-951e-9ad3ad277059', 'Pepperoni, Salami & Cold Cuts'),
+    ('bbadd945-5557-459f-951e-9ad3ad277059', 'Beef Products'),
+    ('bbadd945-5557-459f-951e-9ad3ad277059', 'Pork Products'),
+    ('bbadd945-5557-459f-951e-9ad3ad277059', 'Poultry Products'),
+    ('bbadd945-5557-459f-951e-9ad3ad277059', 'Finfish and Shellfish Products');
+
+INSERT INTO public.rel_diet_recipe (id, recipe_id, diet_id) VALUES
+    ('a8e9c8b8-c857-49f3-b700-1b87592ae51f', 'c4ef5707-1577-4f8c-99ef-0f492e82b895', 'bbadd945-5557-459f-951e-9ad3ad277059');
+
+INSERT INTO public.rel_user_diet (id, user_id, diet_id) VALUES
+    ('cbf679ff-539f-4078-ac6f-af7c9beac8e5', 'f85a98f8-2572-420a-9ae5-2c997ad96b6d', 'bbadd945-5557-459f-951e-9ad3ad277059');
+
+INSERT INTO public.ingredient_alias (ingredient_id, alias) VALUES
+    ('db630404-6115-4ca1-91cd-f9ed8981676f', 'parmesan'),
+    ('db630404-6115-4ca1-91cd-f9ed8981676f', 'parmigiano reggiano');
+
+INSERT INTO public.ingredient_allergen (ingredient_id, allergen_id) VALUES
+    ('ea3f9073-6a75-4625-80d1-19dc42aca7ef', 'eggs'),
+    ('db630404-6115-4ca1-91cd-f9ed8981676f', 'milk');
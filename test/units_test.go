@@ -0,0 +1,107 @@
+package test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/units"
+)
+
+func TestLookupUnit(t *testing.T) {
+	cases := map[string]string{
+		"g":           "g",
+		"Grams":       "g",
+		"tbsp.":       "tbsp",
+		"Teaspoons":   "tsp",
+		"cloves":      "clove",
+		"fluid ounce": "fl oz",
+		" L ":         "l",
+	}
+	for name, expected := range cases {
+		u, ok := units.Lookup(name)
+		if !ok || u.Name != expected {
+			t.Errorf("Expected %q to be %s but got %+v", name, expected, u)
+		}
+	}
+	if _, ok := units.Lookup("pice"); ok {
+		t.Error("Expected pice to be unknown")
+	}
+}
+
+func TestConvertUnits(t *testing.T) {
+	cases := []struct {
+		amount   float64
+		from, to string
+		expected float64
+	}{
+		{1.5, "kg", "g", 1500},
+		{0.25, "l", "ml", 250},
+		{3, "tsp", "tbsp", 1},
+		{1, "lb", "oz", 16},
+		{2, "cloves", "clove", 2},
+	}
+	for _, c := range cases {
+		got, err := units.Convert(c.amount, c.from, c.to)
+		if err != nil || math.Abs(got-c.expected) > 1e-6 {
+			t.Errorf("Expected %v %s to be %v %s but got %v (%v)", c.amount, c.from, c.expected, c.to, got, err)
+		}
+	}
+
+	for _, c := range [][2]string{{"g", "ml"}, {"piece", "clove"}, {"g", "handful"}} {
+		if _, err := units.Convert(1, c[0], c[1]); err == nil {
+			t.Errorf("Expected converting %s to %s to fail", c[0], c[1])
+		}
+	}
+}
+
+func TestUnitsToSystem(t *testing.T) {
+	cases := []struct {
+		amount       float64
+		unit         string
+		system       units.System
+		expected     float64
+		expectedUnit string
+	}{
+		{2, "lb", units.Metric, 907.18474, "g"},
+		{3, "lb", units.Metric, 1.36077711, "kg"},
+		{500, "g", units.Imperial, 1.10231131, "lb"},
+		{10, "g", units.Imperial, 0.35273962, "oz"},
+		{480, "ml", units.Imperial, 2, "cup"},
+		{1, "cup", units.Metric, 240, "ml"},
+		{4, "large", units.Metric, 4, "large"},
+	}
+	for _, c := range cases {
+		amount, unit, err := units.ToSystem(c.amount, c.unit, c.system)
+		if err != nil || unit != c.expectedUnit || math.Abs(amount-c.expected) > 1e-6 {
+			t.Errorf("Expected %v %s to be %v %s but got %v %s (%v)", c.amount, c.unit, c.expected, c.expectedUnit, amount, unit, err)
+		}
+	}
+}
+
+func TestIngredientUnits(t *testing.T) {
+	valid := recipe.IngredientsSchema{Name: "Salt", Amount: 0.5, Unit: "tsp"}
+	if err := valid.CheckForRequiredFields(); err != nil {
+		t.Errorf("Expected half a teaspoon to be valid but got %v", err)
+	}
+	invalid := recipe.IngredientsSchema{Name: "Tomato", Amount: 1, Unit: "pice"}
+	if err := invalid.CheckForRequiredFields(); err == nil {
+		t.Error("Expected an unknown unit to be rejected")
+	}
+
+	density := 0.92
+	oil := recipe.IngredientDB{Name: "olive oil", StandardUnit: "ml", Density: &density}
+	if g, ok := oil.Grams(2, "tbsp"); !ok || math.Abs(g-27.6) > 1e-9 {
+		t.Errorf("Expected 2 tbsp of oil to weigh 27.6 g but got %v", g)
+	}
+	egg := recipe.IngredientDB{Name: "egg", StandardUnit: "piece", PieceWeights: map[string]float64{"piece": 50, "large": 60}}
+	if g, ok := egg.Grams(2, "large"); !ok || g != 120 {
+		t.Errorf("Expected 2 large eggs to weigh 120 g but got %v", g)
+	}
+	if g, ok := egg.Grams(1, "medium"); !ok || g != 50 {
+		t.Errorf("Expected a medium egg to fall back to the piece weight but got %v", g)
+	}
+	if _, ok := (&recipe.IngredientDB{}).Grams(1, "clove"); ok {
+		t.Error("Expected the weight of a clove without piece weights to be unknown")
+	}
+}
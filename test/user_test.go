@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/madswillem/recipeApp/internal/recipe"
+	"github.com/madswillem/recipeApp/internal/tools"
 	"github.com/madswillem/recipeApp/internal/user"
 )
 
@@ -567,7 +568,9 @@ func TestMerge(t *testing.T) {
 			CookingTime: time.Minute * 92,
 		}
 
-		rp.Merge(&rp2)
+		if err := rp.Merge(&rp2); err != nil {
+			t.Fatal(err)
+		}
 
 		if !reflect.DeepEqual(rp.RecipeIDs, expected.RecipeIDs) {
 			t.Errorf("Expected %+v but got %+v", expected.RecipeIDs, rp.RecipeIDs)
@@ -604,3 +607,217 @@ func TestMerge(t *testing.T) {
 		}
 	})
 }
+
+func TestGetRecomendation(t *testing.T) {
+	carbonara := recipe.RecipeSchema{
+		ID:          "carbonara",
+		Name:        "Spaghetti Carbonara",
+		Cuisine:     "italian",
+		PrepTime:    "00:10:00",
+		CookingTime: "00:20:00",
+		Ingredients: []recipe.IngredientsSchema{{Name: "Spaghetti"}, {Name: "Pancetta"}, {Name: "Egg"}, {Name: "Parmesan cheese"}},
+		Steps:       []recipe.StepsStruct{{Step: "Cook the spaghetti"}, {Step: "Fry the pancetta"}, {Step: "Mix the eggs and cheese"}},
+	}
+	amatriciana := recipe.RecipeSchema{
+		ID:          "amatriciana",
+		Cuisine:     "italian",
+		Ingredients: []recipe.IngredientsSchema{{Name: "Spaghetti"}, {Name: "Pancetta"}, {Name: "Tomato"}, {Name: "Parmesan cheese"}},
+		Steps:       []recipe.StepsStruct{{Step: "Cook the spaghetti"}, {Step: "Fry the pancetta"}, {Step: "Add the tomatoes"}},
+	}
+	curry := recipe.RecipeSchema{
+		ID:          "curry",
+		Cuisine:     "indian",
+		Ingredients: []recipe.IngredientsSchema{{Name: "Rice"}, {Name: "Chickpeas"}, {Name: "Curry paste"}},
+		Steps:       []recipe.StepsStruct{{Step: "Simmer the chickpeas in curry paste"}, {Step: "Serve with rice"}},
+	}
+	for _, r := range []*recipe.RecipeSchema{&carbonara, &amatriciana, &curry} {
+		r.Rating.DefaultRatingStruct(&r.ID, nil)
+	}
+	repo := &fakeRecipeRepo{recipes: []recipe.RecipeSchema{curry, amatriciana, carbonara}}
+	data := tools.CurrentData{Day: "Mon", Season: "Win", Temp: "zerodegree"}
+
+	t.Run("ranks similar recipes first and skips selected ones", func(t *testing.T) {
+		u := user.UserModel{}
+		g := user.RecipeGroupSchema{}
+		g.Create(&carbonara)
+		u.RecipeGroups = append(u.RecipeGroups, g)
+
+		err, recs := u.GetRecomendation(repo, user.DefaultGroupingConfig(), data, 1, 10)
+		if err != nil {
+			t.Fatal(err.Message)
+		}
+		if recs.Total != 2 || len(recs.Recipes) != 2 {
+			t.Fatalf("Expected 2 recommendations but got %d (total %d)", len(recs.Recipes), recs.Total)
+		}
+		if recs.Recipes[0].Recipe.ID != "amatriciana" {
+			t.Errorf("Expected amatriciana first but got %s", recs.Recipes[0].Recipe.ID)
+		}
+		if recs.Recipes[0].Score <= recs.Recipes[1].Score {
+			t.Errorf("Expected descending scores but got %f and %f", recs.Recipes[0].Score, recs.Recipes[1].Score)
+		}
+
+		e := recs.Recipes[0].Explanation
+		if e.Group == nil {
+			t.Fatal("Expected the matching group to be explained")
+		}
+		if d := cmp.Diff([]user.GroupRecipe{{ID: "carbonara", Name: "Spaghetti Carbonara"}}, e.Group.Recipes); d != "" {
+			t.Error(d)
+		}
+		if e.Group.Similarity.Cuisine != 1 {
+			t.Errorf("Expected a cuisine similarity of 1 but got %f", e.Group.Similarity.Cuisine)
+		}
+		if e.Group.Similarity.Total != recs.Recipes[0].GroupScore {
+			t.Errorf("Expected the explained similarity %f to be the group score %f", e.Group.Similarity.Total, recs.Recipes[0].GroupScore)
+		}
+		if len(e.Context.Buckets) != 3 || e.Context.Strongest != "" {
+			t.Errorf("Expected 3 default buckets without a strongest one but got %+v", e.Context)
+		}
+	})
+	t.Run("falls back to the contextual rating without groups", func(t *testing.T) {
+		r := repo.recipes[0]
+		r.Rating.Mon = 3000
+		cold := &fakeRecipeRepo{recipes: []recipe.RecipeSchema{carbonara, r}}
+		u := user.UserModel{}
+
+		err, recs := u.GetRecomendation(cold, user.DefaultGroupingConfig(), data, 1, 10)
+		if err != nil {
+			t.Fatal(err.Message)
+		}
+		if recs.Recipes[0].Recipe.ID != r.ID {
+			t.Errorf("Expected %s first but got %s", r.ID, recs.Recipes[0].Recipe.ID)
+		}
+		if recs.Recipes[0].Explanation.Group != nil || recs.Recipes[0].Explanation.Context.Strongest != "mon" {
+			t.Errorf("Expected only the monday bucket to be explained but got %+v", recs.Recipes[0].Explanation)
+		}
+		if recs.Recipes[1].RatingScore != 0.5 {
+			t.Errorf("Expected a rating score of 0.5 for the default rating but got %f", recs.Recipes[1].RatingScore)
+		}
+	})
+	t.Run("paginates", func(t *testing.T) {
+		u := user.UserModel{}
+		err, recs := u.GetRecomendation(repo, user.DefaultGroupingConfig(), data, 2, 2)
+		if err != nil {
+			t.Fatal(err.Message)
+		}
+		if recs.Total != 3 || len(recs.Recipes) != 1 {
+			t.Errorf("Expected 1 of 3 recommendations on page 2 but got %d of %d", len(recs.Recipes), recs.Total)
+		}
+	})
+	t.Run("skips recipes the user is allergic to", func(t *testing.T) {
+		withEggs := carbonara
+		withEggs.Allergens = []recipe.Allergen{{ID: "eggs", Name: "Eggs"}, {ID: "milk", Name: "Milk"}}
+		allergic := &fakeRecipeRepo{recipes: []recipe.RecipeSchema{curry, withEggs}}
+		u := user.UserModel{Settings: user.UserSettings{Allergies: []string{"eggs", "peanuts"}}}
+
+		err, recs := u.GetRecomendation(allergic, user.DefaultGroupingConfig(), data, 1, 10)
+		if err != nil {
+			t.Fatal(err.Message)
+		}
+		if recs.Total != 1 || recs.Recipes[0].Recipe.ID != "curry" {
+			t.Errorf("Expected only the curry but got %+v", recs.Recipes)
+		}
+	})
+}
+
+func TestGroupingConfig(t *testing.T) {
+	italian := func(id string, ings ...string) *recipe.RecipeSchema {
+		r := &recipe.RecipeSchema{ID: id, Cuisine: "italian", Steps: []recipe.StepsStruct{{Step: "Cook the pasta"}}}
+		for _, i := range ings {
+			r.Ingredients = append(r.Ingredients, recipe.IngredientsSchema{Name: i})
+		}
+		return r
+	}
+	carbonara := italian("carbonara", "Spaghetti", "Pancetta", "Egg")
+	carbonara2 := italian("carbonara2", "Spaghetti", "Pancetta", "Egg")
+	curry := &recipe.RecipeSchema{
+		ID:          "curry",
+		Cuisine:     "indian",
+		Ingredients: []recipe.IngredientsSchema{{Name: "Rice"}, {Name: "Chickpeas"}},
+		Steps:       []recipe.StepsStruct{{Step: "Simmer the chickpeas"}},
+	}
+
+	t.Run("starts a new group below the add threshold", func(t *testing.T) {
+		cfg := user.DefaultGroupingConfig()
+		groups := cfg.Assign(nil, carbonara)
+		groups = cfg.Assign(groups, curry)
+		groups = cfg.Assign(groups, carbonara2)
+
+		if len(groups) != 2 {
+			t.Fatalf("Expected 2 groups but got %d", len(groups))
+		}
+		if d := cmp.Diff([]string{"carbonara", "carbonara2"}, groups[0].RecipeIDs); d != "" {
+			t.Error(d)
+		}
+	})
+	t.Run("joins the most similar group once max groups is reached", func(t *testing.T) {
+		cfg := user.DefaultGroupingConfig()
+		cfg.MaxGroups = 1
+		groups := cfg.Assign(nil, carbonara)
+		groups = cfg.Assign(groups, curry)
+
+		if len(groups) != 1 || len(groups[0].RecipeIDs) != 2 {
+			t.Errorf("Expected 1 group with 2 recipes but got %+v", groups)
+		}
+	})
+	t.Run("merges groups above the merge threshold", func(t *testing.T) {
+		cfg := user.DefaultGroupingConfig()
+		groups := cfg.Assign(nil, carbonara)
+		groups = cfg.Assign(groups, curry)
+
+		cfg.AddThreshold = 0
+		cfg.MergeThreshold = 0
+		groups = cfg.Assign(groups, carbonara2)
+		if len(groups) != 1 || len(groups[0].RecipeIDs) != 3 {
+			t.Errorf("Expected 1 group with 3 recipes but got %+v", groups)
+		}
+	})
+	t.Run("weights change the similarity", func(t *testing.T) {
+		g := user.RecipeGroupSchema{}
+		g.Create(carbonara)
+		sim := g.CompareDetailed(curry, user.SimilarityWeights{Techniques: 1})
+		if sim.Total != sim.Techniques {
+			t.Errorf("Expected only the technique similarity %f to count but got %f", sim.Techniques, sim.Total)
+		}
+	})
+	t.Run("rejects invalid configs", func(t *testing.T) {
+		cfg := user.DefaultGroupingConfig()
+		cfg.Weights.Cuisine = -1
+		if cfg.Validate() == nil {
+			t.Error("Expected negative weights to be rejected")
+		}
+		cfg = user.DefaultGroupingConfig()
+		cfg.AddThreshold = 1.5
+		if cfg.Validate() == nil {
+			t.Error("Expected a threshold above 1 to be rejected")
+		}
+	})
+	t.Run("evaluates the select history", func(t *testing.T) {
+		history := map[string][]*recipe.RecipeSchema{
+			"user1": {carbonara, curry, carbonara2},
+			"user2": {curry},
+		}
+		report := user.EvaluateGrouping(user.DefaultGroupingConfig(), history, func(r *recipe.RecipeSchema) string {
+			return r.Cuisine
+		})
+
+		expected := []user.UserGroupingReport{
+			{UserID: "user1", Selects: 3, Groups: 2, Purity: 1},
+			{UserID: "user2", Selects: 1, Groups: 1, Purity: 1},
+		}
+		if d := cmp.Diff(expected, report.Users); d != "" {
+			t.Error(d)
+		}
+		if report.Groups != 3 || report.AvgGroups != 1.5 || report.Purity != 1 {
+			t.Errorf("Expected 3 groups, 1.5 per user and a purity of 1 but got %+v", report)
+		}
+
+		cfg := user.DefaultGroupingConfig()
+		cfg.MaxGroups = 1
+		report = user.EvaluateGrouping(cfg, history, func(r *recipe.RecipeSchema) string {
+			return r.Cuisine
+		})
+		if report.Users[0].Purity != 2.0/3.0 {
+			t.Errorf("Expected a purity of 2/3 for a single group but got %f", report.Users[0].Purity)
+		}
+	})
+}